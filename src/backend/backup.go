@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"scriptorium/internal/backend/backup"
+	"scriptorium/internal/backend/config"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// runBackup writes a backup of the database and storage, as `scriptorium backup [-o file]`.
+// Like fsck it needs the server stopped, GET /v1/admin/backup takes one while it's running.
+func runBackup(args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write the archive to, scriptorium-<timestamp>.tar.zst by default, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: failed to load configuration: %v\n", err)
+		return 1
+	}
+	if _, err := os.Stat(cfg.Database.Path); err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		return 1
+	}
+
+	d, err := openDAO(cfg.Database, time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v (is the server still running?)\n", err)
+		return 1
+	}
+	defer d.Disconnect()
+	snapshotter, ok := d.(dao.Snapshotter)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "backup: the %s database backend can't be snapshotted\n", cfg.Database.Backend)
+		return 1
+	}
+
+	name := *output
+	if name == "" {
+		name = fmt.Sprintf("scriptorium-%s.tar.zst", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	var w io.Writer = os.Stdout
+	if name != "-" {
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	// interrupted, the archive is left without its manifest and removed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	manifest, err := backup.Write(ctx, w, snapshotter, fao.NewLocalFao(cfg.Storage.Path), dao.SchemaVersion())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		if name != "-" {
+			os.Remove(name)
+		}
+		return 1
+	}
+	if name != "-" {
+		fmt.Printf("backed up the database (%d bytes) and %d files to %s\n", manifest.Database.Size, len(manifest.Files), name)
+	}
+	return 0
+}
+
+// runRestore replaces the database and storage with a backup, as `scriptorium restore
+// [-force] archive`. The archive is checked against its manifest before anything is moved.
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := flags.Bool("force", false, "restore over an existing library, which is kept renamed with a .pre-restore-<timestamp> suffix")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: scriptorium restore [-force] archive")
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	// a running server holds Bolt's lock, the database can't be swapped out from under it
+	if _, err := os.Stat(cfg.Database.Path); err == nil && cfg.Database.Backend == "bolt" {
+		db, err := bolt.Open(cfg.Database.Path, os.FileMode(cfg.Database.Mode), &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "restore: %v (is the server still running?)\n", err)
+			return 1
+		}
+		db.Close()
+	}
+
+	archive, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 1
+	}
+	defer archive.Close()
+
+	manifest, err := backup.Restore(archive, backup.RestoreOptions{
+		DatabasePath:  cfg.Database.Path,
+		DatabaseMode:  os.FileMode(cfg.Database.Mode),
+		StoragePath:   cfg.Storage.Path,
+		SchemaVersion: dao.SchemaVersion(),
+		Force:         *force,
+	})
+	if errors.Is(err, backup.ErrTargetNotEmpty) {
+		fmt.Fprintf(os.Stderr, "restore: %v, use -force to replace it\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 1
+	}
+	fmt.Printf("restored the database and %d files from a backup taken %s\n", len(manifest.Files), manifest.CreatedAt)
+	if manifest.SchemaVersion < dao.SchemaVersion() {
+		fmt.Printf("the backup is at schema version %d, it's migrated on the next start\n", manifest.SchemaVersion)
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"scriptorium/internal/backend/config"
+	"scriptorium/internal/backend/fao"
+	"scriptorium/internal/backend/service"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// exit codes of the fsck subcommand
+const (
+	fsckClean    = 0
+	fsckProblems = 1
+	fsckError    = 2
+)
+
+// runFsck checks the database against storage, as `scriptorium fsck [-fix classes] [-json]`.
+// Bolt only allows one process at a time, so it's run while the server is stopped, or
+// through /v1/admin/fsck while it's running.
+func runFsck(args []string) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	fixFlag := flags.String("fix", "", "comma separated classes of problem to fix: "+strings.Join(service.FsckClasses, ", ")+" or all")
+	jsonFlag := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return fsckError
+	}
+
+	fix, err := service.ParseFsckClasses(strings.Split(*fixFlag, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return fsckError
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: failed to load configuration: %v\n", err)
+		return fsckError
+	}
+
+	d, err := openDAO(cfg.Database, time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v (is the server still running?)\n", err)
+		return fsckError
+	}
+	defer d.Disconnect()
+
+	serv, err := service.DaoService{}.New(d)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return fsckError
+	}
+	daos := serv.(service.DaoService)
+
+	// interrupting stops the check, and any repairs, where they are
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := daos.Fsck(ctx, fao.NewLocalFao(cfg.Storage.Path), fix, "fsck")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return fsckError
+	}
+
+	if *jsonFlag {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		if err := out.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+			return fsckError
+		}
+	} else {
+		printFsckReport(report)
+	}
+
+	if report.Unfixed() > 0 {
+		return fsckProblems
+	}
+	return fsckClean
+}
+
+func printFsckReport(report service.FsckReport) {
+	fmt.Printf("checked %d record(s) and %d stored file(s)\n", report.Records, report.Files)
+	if len(report.Problems) == 0 {
+		fmt.Println("no problems found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLASS\tUUID\tPATH\tDETAIL\tSTATUS")
+	for _, problem := range report.Problems {
+		status := ""
+		switch {
+		case problem.Fixed:
+			status = "fixed"
+		case problem.FixError != "":
+			status = "fix failed: " + problem.FixError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", problem.Class, problem.Uuid, problem.Path, problem.Detail, status)
+	}
+	w.Flush()
+
+	var counts []string
+	for _, class := range service.FsckClasses {
+		if n := report.Counts[class]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, class))
+		}
+	}
+	fmt.Printf("%d problem(s): %s, %d fixed\n", len(report.Problems), strings.Join(counts, ", "), report.Fixed)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"scriptorium/internal/backend/config"
+	"scriptorium/internal/backend/fao"
+	"scriptorium/internal/backend/service"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// exit codes of the import subcommand
+const (
+	importClean    = 0
+	importFailures = 1
+	importError    = 2
+)
+
+// runImport stores the files of a directory or zip archive, or creates the documents of a
+// BibTeX or RIS bibliography, as
+// `scriptorium import [-type DocType] [-user name] [-json] <dir|zip|bib|ris>`. Files and
+// references already in the library are skipped, so an interrupted import is resumed by
+// running it again. Like fsck it needs the server stopped, or it can be run through
+// /v1/admin/import.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	typeFlag := flags.String("type", "Notes", "DocType of files whose metadata doesn't give one, and of entries of no other type")
+	userFlag := flags.String("user", "import", "who the documents' first revisions are recorded as made by")
+	jsonFlag := flags.Bool("json", false, "print the report as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: scriptorium import [-type DocType] [-user name] [-json] <dir|zip|bib|ris>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return importError
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return importError
+	}
+	source := flags.Arg(0)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: failed to load configuration: %v\n", err)
+		return importError
+	}
+	docFactory, err := newDocumentFactory(cfg.Types.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: error loading document types: %v\n", err)
+		return importError
+	}
+
+	d, err := openDAO(cfg.Database, time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v (is the server still running?)\n", err)
+		return importError
+	}
+	defer d.Disconnect()
+
+	serv, err := service.DaoService{}.New(d)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return importError
+	}
+	daos := serv.(service.DaoService)
+
+	if err := os.MkdirAll(cfg.Storage.Path, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "import: failed to create storage directory: %v\n", err)
+		return importError
+	}
+	f := fao.NewLocalFao(cfg.Storage.Path)
+
+	// an import interrupted mid-file leaves its write journaled, finish that first
+	if _, err := daos.ReconcileFiles(context.Background(), f); err != nil {
+		fmt.Fprintf(os.Stderr, "import: error reconciling storage with the database: %v\n", err)
+		return importError
+	}
+
+	importer := &service.Importer{
+		DaoService:      &daos,
+		Files:           f,
+		DocumentFactory: docFactory,
+		DocType:         *typeFlag,
+		User:            *userFlag,
+		Progress: func(done, total int, result service.ImportResult) {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s %s\n", done, total, result.Status, result.Path)
+		},
+	}
+
+	// interrupting stops after the file in hand, what was imported stays
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := importer.Import(ctx, source)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "import: interrupted after %d of %d file(s), run it again to resume\n", len(report.Results), report.Files)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return importError
+	}
+
+	if *jsonFlag {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		if err := out.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			return importError
+		}
+	} else {
+		printImportReport(report)
+	}
+
+	switch {
+	case err != nil:
+		return importError
+	case report.Failed > 0:
+		return importFailures
+	}
+	return importClean
+}
+
+func printImportReport(report service.ImportReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	problems := 0
+	for _, result := range report.Results {
+		if (result.Status == service.ImportImported || result.Status == service.ImportMatched) && len(result.Unmapped) == 0 {
+			continue
+		}
+		if problems == 0 {
+			fmt.Fprintln(w, "STATUS\tPATH\tDETAIL")
+		}
+		problems++
+		detail := result.Message
+		if result.DuplicateOf != "" {
+			detail = "same as " + result.DuplicateOf
+		}
+		if len(result.Unmapped) > 0 {
+			detail = strings.TrimPrefix(detail+"; unmapped "+strings.Join(result.Unmapped, ", "), "; ")
+		}
+		for _, field := range result.Details {
+			detail += fmt.Sprintf("; %s %s", field.Field, field.Message)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Status, result.Path, detail)
+	}
+	w.Flush()
+
+	fmt.Printf("%s: %d file(s), %d imported, %d matched, %d duplicate(s), %d skipped, %d failed\n",
+		report.Source, report.Files, report.Imported, report.Matched, report.Duplicates, report.Skipped, report.Failed)
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"scriptorium/internal/backend/fao"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+//---------------------------------------------------
+//----------------------BACKUP-----------------------
+//---------------------------------------------------
+
+// a backup is a zstd compressed tar of the database snapshot, every stored file and a
+// manifest with their sizes and sha256 sums. the manifest is the last entry, written once
+// everything it describes is in the archive, so a backup cut short is never restorable.
+
+// FormatVersion is the archive layout written by Write, Restore refuses any other.
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	databaseName = "database.db"
+	storageDir   = "storage"
+)
+
+// Entry is a file in the archive, Path being relative to storage for stored files.
+type Entry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Manifest describes the contents of a backup.
+type Manifest struct {
+	Format    int    `json:"format"`
+	CreatedAt string `json:"created_at"`
+	// SchemaVersion is the database's schema version, a build older than it can't restore it
+	SchemaVersion int     `json:"schema_version"`
+	Database      Entry   `json:"database"`
+	Files         []Entry `json:"files"`
+}
+
+// Snapshotter is the part of a DAO a backup needs.
+type Snapshotter interface {
+	Snapshot(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// Write streams a backup of db and every file in storage to w. The database is a snapshot
+// of a single read transaction, files stored after it are included and files deleted while
+// the backup runs are left out. It stops when ctx is done, leaving the archive without a manifest.
+func Write(ctx context.Context, w io.Writer, db Snapshotter, files fao.FAO, schemaVersion int) (Manifest, error) {
+	manifest := Manifest{
+		Format:        FormatVersion,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		SchemaVersion: schemaVersion,
+		Files:         []Entry{},
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return manifest, err
+	}
+	tw := tar.NewWriter(zw)
+
+	// tar needs the size up front, so the snapshot is spooled to disk first
+	snapshot, err := os.CreateTemp("", "scriptorium-snapshot-*.db")
+	if err != nil {
+		return manifest, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(snapshot.Name())
+	defer snapshot.Close()
+	if _, err := db.Snapshot(ctx, snapshot); err != nil {
+		return manifest, err
+	}
+	if _, err := snapshot.Seek(0, io.SeekStart); err != nil {
+		return manifest, err
+	}
+	stat, err := snapshot.Stat()
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Database, err = writeEntry(tw, databaseName, stat.Size(), snapshot)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to archive database: %w", err)
+	}
+
+	stored, err := files.ListFiles(ctx, "")
+	if err != nil {
+		return manifest, fmt.Errorf("failed to list storage: %w", err)
+	}
+	for _, info := range stored {
+		file, err := files.GetFile(ctx, info.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("failed to read %s: %w", info.Path, err)
+		}
+		entry, err := writeEntry(tw, path.Join(storageDir, info.Path), info.Size, file)
+		file.Close()
+		if err != nil {
+			return manifest, fmt.Errorf("failed to archive %s: %w", info.Path, err)
+		}
+		entry.Path = info.Path
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return manifest, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, zw.Close()
+}
+
+// writeEntry adds a file of the given size to the archive, hashing it on the way. A file
+// that changes size while it's read fails the backup, tar can't take back a header.
+func writeEntry(tw *tar.Writer, name string, size int64, r io.Reader) (Entry, error) {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: size, ModTime: time.Now()}); err != nil {
+		return Entry{}, err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(tw, io.TeeReader(io.LimitReader(r, size), hash))
+	if err != nil {
+		return Entry{}, err
+	}
+	if n != size {
+		return Entry{}, fmt.Errorf("file changed while archived, expected %d bytes and read %d", size, n)
+	}
+	return Entry{Path: name, Size: size, Sha256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+)
+
+// newLibrary creates a database with one document and a storage directory holding its file
+func newLibrary(t *testing.T) (*dao.BoltDao, string, string) {
+	dir := t.TempDir()
+	db, err := dao.NewBoltDao(dao.BoltOptions{Path: filepath.Join(dir, "library.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("error connecting: %s", err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+
+	id := uuid.NewString()
+	doc := &dao.Notes{}
+	doc.SetMetaData(dao.MetaData{Title: "Backed up", DocType: "Notes", Uuid: id, Path: id + ".txt"})
+	if err := db.Create(context.Background(), doc); err != nil {
+		t.Fatalf("error creating document: %s", err)
+	}
+	storage := filepath.Join(dir, "storage")
+	os.MkdirAll(storage, 0755)
+	if err := os.WriteFile(filepath.Join(storage, id+".txt"), []byte("contents"), 0644); err != nil {
+		t.Fatalf("error storing file: %s", err)
+	}
+	return db, storage, id
+}
+
+func restoreOptions(dir string) RestoreOptions {
+	return RestoreOptions{
+		DatabasePath:  filepath.Join(dir, "restored.db"),
+		DatabaseMode:  0600,
+		StoragePath:   filepath.Join(dir, "storage"),
+		SchemaVersion: dao.SchemaVersion(),
+	}
+}
+
+func TestWhenBackupRestoredExpectSameLibrary(t *testing.T) {
+	db, storage, id := newLibrary(t)
+	var archive bytes.Buffer
+	manifest, err := Write(context.Background(), &archive, db, fao.NewLocalFao(storage), dao.SchemaVersion())
+	if err != nil {
+		t.Fatalf("error writing backup: %s", err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Path != id+".txt" {
+		t.Fatalf("wanted the stored file in the manifest; have %+v", manifest.Files)
+	}
+
+	opts := restoreOptions(t.TempDir())
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), opts); err != nil {
+		t.Fatalf("error restoring: %s", err)
+	}
+	data, err := os.ReadFile(filepath.Join(opts.StoragePath, id+".txt"))
+	if err != nil || string(data) != "contents" {
+		t.Errorf("wanted the file restored; have %q, %v", data, err)
+	}
+
+	restored, err := dao.NewBoltDao(dao.BoltOptions{Path: opts.DatabasePath, Mode: 0600})
+	if err != nil {
+		t.Fatalf("error connecting to restored database: %s", err)
+	}
+	defer restored.Disconnect()
+	all, err := restored.GetAll(context.Background())
+	if err != nil || len(all) != 1 || all[0].Uuid != id {
+		t.Errorf("wanted the document restored; have %+v, %v", all, err)
+	}
+}
+
+func TestWhenArchiveTamperedExpectRefused(t *testing.T) {
+	db, storage, id := newLibrary(t)
+	var archive bytes.Buffer
+	if _, err := Write(context.Background(), &archive, db, fao.NewLocalFao(storage), dao.SchemaVersion()); err != nil {
+		t.Fatalf("error writing backup: %s", err)
+	}
+
+	// rewritten with one file's contents changed, and then without the manifest
+	tampered := rewrite(t, archive.Bytes(), func(name string, data []byte) []byte {
+		if name == "storage/"+id+".txt" {
+			return []byte("CONTENTS")
+		}
+		return data
+	})
+	truncated := rewrite(t, archive.Bytes(), func(name string, data []byte) []byte {
+		if name == manifestName {
+			return nil
+		}
+		return data
+	})
+
+	for name, data := range map[string][]byte{"tampered": tampered, "truncated": truncated, "garbage": []byte("not a backup")} {
+		opts := restoreOptions(t.TempDir())
+		_, err := Restore(bytes.NewReader(data), opts)
+		if !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("%s: wanted ErrInvalidArchive; have %v", name, err)
+		}
+		if _, err := os.Stat(opts.DatabasePath); err == nil {
+			t.Errorf("%s: wanted nothing restored", name)
+		}
+	}
+}
+
+func TestWhenLibraryExistsExpectRestoreNeedsForce(t *testing.T) {
+	db, storage, _ := newLibrary(t)
+	var archive bytes.Buffer
+	if _, err := Write(context.Background(), &archive, db, fao.NewLocalFao(storage), dao.SchemaVersion()); err != nil {
+		t.Fatalf("error writing backup: %s", err)
+	}
+
+	dir := t.TempDir()
+	opts := restoreOptions(dir)
+	os.WriteFile(opts.DatabasePath, []byte("existing"), 0600)
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), opts); !errors.Is(err, ErrTargetNotEmpty) {
+		t.Fatalf("wanted ErrTargetNotEmpty; have %v", err)
+	}
+
+	opts.Force = true
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), opts); err != nil {
+		t.Fatalf("error restoring with force: %s", err)
+	}
+	kept, _ := filepath.Glob(opts.DatabasePath + ".pre-restore-*")
+	if len(kept) != 1 {
+		t.Fatalf("wanted the existing database kept; have %v", kept)
+	}
+	if data, _ := os.ReadFile(kept[0]); string(data) != "existing" {
+		t.Errorf("wanted the existing database unchanged; have %q", data)
+	}
+}
+
+// rewrite copies an archive through edit, dropping entries it returns nil for
+func rewrite(t *testing.T, archive []byte, edit func(name string, data []byte) []byte) []byte {
+	zr, err := zstd.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("error reading archive: %s", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	var out bytes.Buffer
+	zw, _ := zstd.NewWriter(&out)
+	tw := tar.NewWriter(zw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading archive: %s", err)
+		}
+		data, _ := io.ReadAll(tr)
+		data = edit(header.Name, data)
+		if data == nil {
+			continue
+		}
+		if !strings.HasPrefix(header.Name, "storage/") && header.Name != manifestName && header.Name != databaseName {
+			t.Fatalf("unexpected entry %s", header.Name)
+		}
+		header.Size = int64(len(data))
+		tw.WriteHeader(header)
+		tw.Write(data)
+	}
+	tw.Close()
+	zw.Close()
+	return out.Bytes()
+}
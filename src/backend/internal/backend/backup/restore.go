@@ -0,0 +1,226 @@
+package backup
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+//---------------------------------------------------
+//----------------------RESTORE----------------------
+//---------------------------------------------------
+
+// ErrTargetNotEmpty is returned when restoring over an existing library without Force.
+var ErrTargetNotEmpty = errors.New("a library already exists at the restore target")
+
+// ErrInvalidArchive is returned (wrapped) when an archive doesn't match its manifest, or isn't
+// a backup at all.
+var ErrInvalidArchive = errors.New("invalid backup archive")
+
+// RestoreOptions says where a backup is restored to.
+type RestoreOptions struct {
+	DatabasePath string
+	DatabaseMode fs.FileMode
+	StoragePath  string
+	// SchemaVersion is the newest schema this build reads, newer backups are refused
+	SchemaVersion int
+	// Force restores over an existing database and storage, which are kept alongside,
+	// renamed with a .pre-restore-<timestamp> suffix
+	Force bool
+}
+
+// Restore unpacks a backup into a staging area next to the targets and checks every entry
+// against the manifest. Only once the whole archive has been verified is the database and
+// storage moved into place, nothing is touched when it's refused.
+func Restore(r io.Reader, opts RestoreOptions) (Manifest, error) {
+	var manifest Manifest
+	if !opts.Force {
+		if exists, err := libraryExists(opts); err != nil || exists {
+			if err == nil {
+				err = ErrTargetNotEmpty
+			}
+			return manifest, err
+		}
+	}
+
+	for _, dir := range []string{filepath.Dir(opts.DatabasePath), filepath.Dir(opts.StoragePath)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return manifest, err
+		}
+	}
+	// staged next to each target, so they can be renamed into place
+	stagedDB, err := os.CreateTemp(filepath.Dir(opts.DatabasePath), ".restore-*.db")
+	if err != nil {
+		return manifest, err
+	}
+	stagedDB.Close()
+	defer os.Remove(stagedDB.Name())
+	stagedStorage, err := os.MkdirTemp(filepath.Dir(opts.StoragePath), ".restore-storage-*")
+	if err != nil {
+		return manifest, err
+	}
+	defer os.RemoveAll(stagedStorage)
+
+	manifest, err = extract(r, stagedDB.Name(), stagedStorage, opts)
+	if err != nil {
+		return manifest, err
+	}
+
+	suffix := ".pre-restore-" + time.Now().UTC().Format("20060102T150405Z")
+	for _, target := range []string{opts.DatabasePath, opts.StoragePath} {
+		if err := os.Rename(target, target+suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return manifest, fmt.Errorf("failed to move %s aside: %w", target, err)
+		}
+	}
+	if err := os.Chmod(stagedDB.Name(), opts.DatabaseMode); err != nil {
+		return manifest, err
+	}
+	if err := os.Rename(stagedDB.Name(), opts.DatabasePath); err != nil {
+		return manifest, fmt.Errorf("failed to move the database into place: %w", err)
+	}
+	if err := os.Rename(stagedStorage, opts.StoragePath); err != nil {
+		return manifest, fmt.Errorf("failed to move storage into place: %w", err)
+	}
+	return manifest, nil
+}
+
+// extract unpacks the archive into the staging paths and verifies it against its manifest
+func extract(r io.Reader, dbPath, storagePath string, opts RestoreOptions) (Manifest, error) {
+	var manifest Manifest
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return manifest, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	var database *Entry
+	found := map[string]Entry{}
+	var haveManifest bool
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if haveManifest {
+			return manifest, fmt.Errorf("%w: entries after the manifest", ErrInvalidArchive)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return manifest, fmt.Errorf("%w: %s is not a regular file", ErrInvalidArchive, header.Name)
+		}
+
+		name := header.Name
+		switch {
+		case name == manifestName:
+			if err := json.NewDecoder(io.LimitReader(tr, 64<<20)).Decode(&manifest); err != nil {
+				return manifest, fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidArchive, err)
+			}
+			haveManifest = true
+		case name == databaseName:
+			entry, err := extractFile(tr, name, dbPath)
+			if err != nil {
+				return manifest, err
+			}
+			database = &entry
+		case strings.HasPrefix(name, storageDir+"/"):
+			rel := strings.TrimPrefix(name, storageDir+"/")
+			// nothing may land outside storage
+			if rel == "" || path.IsAbs(rel) || path.Clean(rel) != rel || strings.HasPrefix(rel, "../") || rel == ".." {
+				return manifest, fmt.Errorf("%w: unsafe path %s", ErrInvalidArchive, name)
+			}
+			if _, dup := found[rel]; dup {
+				return manifest, fmt.Errorf("%w: %s is in the archive twice", ErrInvalidArchive, name)
+			}
+			target := filepath.Join(storagePath, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return manifest, err
+			}
+			entry, err := extractFile(tr, rel, target)
+			if err != nil {
+				return manifest, err
+			}
+			found[rel] = entry
+		default:
+			return manifest, fmt.Errorf("%w: unexpected entry %s", ErrInvalidArchive, name)
+		}
+	}
+
+	if !haveManifest {
+		return manifest, fmt.Errorf("%w: no manifest, the backup may be incomplete", ErrInvalidArchive)
+	}
+	if manifest.Format != FormatVersion {
+		return manifest, fmt.Errorf("%w: format %d, expected %d", ErrInvalidArchive, manifest.Format, FormatVersion)
+	}
+	if manifest.SchemaVersion > opts.SchemaVersion {
+		return manifest, fmt.Errorf("%w: schema version %d is newer than this build's %d", ErrInvalidArchive, manifest.SchemaVersion, opts.SchemaVersion)
+	}
+	if database == nil {
+		return manifest, fmt.Errorf("%w: no database", ErrInvalidArchive)
+	}
+	if err := matches(manifest.Database, *database); err != nil {
+		return manifest, err
+	}
+	if len(manifest.Files) != len(found) {
+		return manifest, fmt.Errorf("%w: manifest lists %d files, archive holds %d", ErrInvalidArchive, len(manifest.Files), len(found))
+	}
+	for _, want := range manifest.Files {
+		have, ok := found[want.Path]
+		if !ok {
+			return manifest, fmt.Errorf("%w: %s is missing", ErrInvalidArchive, want.Path)
+		}
+		if err := matches(want, have); err != nil {
+			return manifest, err
+		}
+	}
+	return manifest, nil
+}
+
+func extractFile(r io.Reader, name, target string) (Entry, error) {
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hash), r)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidArchive, name, err)
+	}
+	return Entry{Path: name, Size: n, Sha256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func matches(want, have Entry) error {
+	if want.Size != have.Size || want.Sha256 != have.Sha256 {
+		return fmt.Errorf("%w: %s doesn't match the manifest checksum", ErrInvalidArchive, have.Path)
+	}
+	return nil
+}
+
+// libraryExists reports whether restoring would replace a database or stored files
+func libraryExists(opts RestoreOptions) (bool, error) {
+	if _, err := os.Stat(opts.DatabasePath); err == nil {
+		return true, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	entries, err := os.ReadDir(opts.StoragePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return len(entries) > 0, err
+}
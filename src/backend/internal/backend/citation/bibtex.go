@@ -0,0 +1,650 @@
+package citation
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//---------------------------------------------------
+//----------------------BIBTEX-----------------------
+//---------------------------------------------------
+
+// BibTeXTypes maps the kinds of Item to BibTeX entry types
+var BibTeXTypes = map[string]string{
+	KindBook:      "book",
+	KindArticle:   "article",
+	KindReport:    "techreport",
+	KindManual:    "manual",
+	KindReference: "book",
+	KindMisc:      "misc",
+}
+
+var bibtexMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "&", `\&`, "%", `\%`, "$", `\$`,
+	"#", `\#`, "_", `\_`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+)
+
+// WriteBibTeX writes an entry per item. An entry's key is the first author's family name,
+// the year and the first word of the title longer than three letters, "turing1950computing",
+// with a letter added to the second and later entries with the same key.
+func WriteBibTeX(w io.Writer, items []Item) error {
+	seen := map[string]int{}
+	for i, item := range items {
+		key := bibtexKey(item)
+		if n := seen[key]; n > 0 {
+			seen[key]++
+			if n < 26 {
+				key += string(rune('a' + n))
+			} else {
+				key += strconv.Itoa(n + 1)
+			}
+		} else {
+			seen[key] = 1
+		}
+
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, bibtexEntry(item, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bibtexKey(item Item) string {
+	family := "anon"
+	if len(item.Authors) > 0 {
+		family = item.Authors[0].Family
+	}
+	// skipping short words, such as "The" and "On"
+	word := ""
+	for _, w := range strings.Fields(item.Title) {
+		w = keyPart(w)
+		if word == "" || len(w) > 3 {
+			word = w
+		}
+		if len(w) > 3 {
+			break
+		}
+	}
+	year := ""
+	if item.Date.Year > 0 {
+		year = strconv.Itoa(item.Date.Year)
+	}
+	key := keyPart(family) + year + word
+	if key == "" {
+		return "item"
+	}
+	return key
+}
+
+// keyPart lowercases s and drops everything but ASCII letters and digits, which any
+// BibTeX implementation takes in a key
+func keyPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func bibtexEntry(item Item, key string) string {
+	var fields [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{name, "{" + bibtexEscaper.Replace(value) + "}"})
+		}
+	}
+
+	authors := make([]string, 0, len(item.Authors))
+	for _, author := range item.Authors {
+		authors = append(authors, author.Inverted())
+	}
+	add("author", strings.Join(authors, " and "))
+	add("title", item.Title)
+	add("journal", item.Container)
+	if item.Kind == KindReport {
+		add("institution", item.publisher())
+		add("number", item.Number)
+	} else {
+		add("publisher", item.Publisher)
+	}
+	if item.Date.Year > 0 {
+		add("year", strconv.Itoa(item.Date.Year))
+	}
+	if item.Date.Month >= 1 && item.Date.Month <= 12 {
+		// month macros aren't braced
+		fields = append(fields, [2]string{"month", bibtexMonths[item.Date.Month-1]})
+	}
+	add("series", item.Series)
+	add("volume", item.Volume)
+	if item.Kind != KindReport {
+		add("number", item.Issue)
+	}
+	add("pages", strings.ReplaceAll(normalizePages(item.Pages), "-", "--"))
+	if item.PageCount > 0 {
+		add("pagetotal", strconv.Itoa(item.PageCount))
+	}
+	add("edition", item.Edition)
+	add("version", item.Version)
+	add("isbn", item.ISBN)
+	if item.DOI != "" {
+		// the doi field is read verbatim, escaping would break it
+		fields = append(fields, [2]string{"doi", "{" + item.DOI + "}"})
+	}
+	add("language", item.Language)
+	add("abstract", item.Abstract)
+	add("keywords", strings.Join(item.Keywords, ", "))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s{%s", BibTeXTypes[item.Kind], key)
+	for _, field := range fields {
+		fmt.Fprintf(&b, ",\n  %s = %s", field[0], field[1])
+	}
+	b.WriteString("\n}\n")
+	return b.String()
+}
+
+//-----READING-----
+
+// bibtexKinds maps BibTeX and biblatex entry types to the kind of Item they're read as. A
+// paper in proceedings is read as an article in them, a chapter as the book it's in. Any
+// other type is read as a generic work.
+var bibtexKinds = map[string]string{
+	"article":       KindArticle,
+	"inproceedings": KindArticle,
+	"conference":    KindArticle,
+	"book":          KindBook,
+	"mvbook":        KindBook,
+	"inbook":        KindBook,
+	"booklet":       KindBook,
+	"incollection":  KindBook,
+	"techreport":    KindReport,
+	"report":        KindReport,
+	"manual":        KindManual,
+}
+
+// fields that are read as written, as biblatex does, LaTeX and all
+var bibtexVerbatim = map[string]bool{"doi": true, "url": true, "eprint": true, "file": true}
+
+// ParseBibTeX reads the entries of a BibTeX or biblatex file. @string macros are expanded,
+// @comment and @preamble are skipped, as is any text between entries. An entry that can't
+// be read is returned with Err set, reading carries on at the next "@".
+func ParseBibTeX(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &bibtexParser{src: strings.TrimPrefix(string(data), "\uFEFF"), line: 1, macros: map[string]string{}}
+	var entries []Entry
+	for {
+		at := strings.IndexByte(p.src[p.pos:], '@')
+		if at < 0 {
+			return entries, nil
+		}
+		p.advance(at + 1)
+		entry, ok := p.entry()
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+}
+
+// bibtexParser reads src from pos, counting lines as it goes
+type bibtexParser struct {
+	src    string
+	pos    int
+	line   int
+	macros map[string]string
+}
+
+func (p *bibtexParser) advance(n int) {
+	p.line += strings.Count(p.src[p.pos:p.pos+n], "\n")
+	p.pos += n
+}
+
+func (p *bibtexParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *bibtexParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.advance(1)
+	}
+}
+
+// ident reads a type, field or macro name
+func (p *bibtexParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if unicode.IsSpace(rune(c)) || strings.IndexByte(`{}(),="#%'@`, c) >= 0 {
+			break
+		}
+		p.advance(1)
+	}
+	return p.src[start:p.pos]
+}
+
+// entry reads what follows an "@", ok being false for the commands that aren't entries
+func (p *bibtexParser) entry() (entry Entry, ok bool) {
+	entry.Line = p.line
+	start := p.pos
+	entry.Type = strings.ToLower(p.ident())
+	p.skipSpace()
+	open := p.peek()
+	if open != '{' && open != '(' {
+		if entry.Type == "" || entry.Type == "comment" {
+			// a stray "@", or a one line @comment
+			return entry, false
+		}
+		entry.Err = fmt.Errorf("line %d: @%s isn't followed by { or (", entry.Line, entry.Type)
+		return entry, true
+	}
+	closing := byte('}')
+	if open == '(' {
+		closing = ')'
+	}
+
+	switch entry.Type {
+	case "comment", "preamble":
+		p.skipGroup(open, closing)
+		return entry, false
+	case "string":
+		p.advance(1)
+		fields, err := p.fields(closing)
+		if err != nil {
+			// a broken macro breaks the entries that use it, they're reported then
+			p.pos, p.line = start, entry.Line
+			return entry, false
+		}
+		for _, field := range fields {
+			p.macros[field[0]] = field[1]
+		}
+		return entry, false
+	}
+
+	p.advance(1)
+	p.skipSpace()
+	keyStart := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ',' && p.src[p.pos] != closing {
+		p.advance(1)
+	}
+	entry.Key = strings.TrimSpace(p.src[keyStart:p.pos])
+	if p.peek() == ',' {
+		p.advance(1)
+	}
+	fields, err := p.fields(closing)
+	if err != nil {
+		entry.Err = fmt.Errorf("line %d: %s: %w", entry.Line, entry.Key, err)
+		// an unclosed brace runs to the end of the file, the next entry may well be fine
+		p.pos, p.line = start, entry.Line
+		return entry, true
+	}
+	entry.Item, entry.Unmapped = bibtexItem(entry.Type, fields)
+	entry.finish()
+	return entry, true
+}
+
+// skipGroup skips a group opened at pos, up to the delimiter that closes it. It's false
+// when the group isn't closed.
+func (p *bibtexParser) skipGroup(open, closing byte) bool {
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.advance(1)
+		switch c {
+		case open:
+			depth++
+		case closing:
+			if depth--; depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fields reads "name = value" pairs separated by commas, up to closing. Names are
+// lowercased, values are as written, braces and all, with macros expanded.
+func (p *bibtexParser) fields(closing byte) ([][2]string, error) {
+	var fields [][2]string
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case closing:
+			p.advance(1)
+			return fields, nil
+		case ',':
+			p.advance(1)
+			continue
+		case 0:
+			return nil, fmt.Errorf("the entry isn't closed")
+		}
+
+		name := strings.ToLower(p.ident())
+		p.skipSpace()
+		if name == "" || p.peek() != '=' {
+			return nil, fmt.Errorf("expected a field on line %d", p.line)
+		}
+		p.advance(1)
+		value, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		fields = append(fields, [2]string{name, value})
+	}
+}
+
+// value reads a field's value: braced, quoted, a number or a macro, or several of them
+// joined with "#"
+func (p *bibtexParser) value() (string, error) {
+	var b strings.Builder
+	for {
+		p.skipSpace()
+		switch c := p.peek(); {
+		case c == '{':
+			start := p.pos + 1
+			if !p.skipGroup('{', '}') {
+				return "", fmt.Errorf("unbalanced braces")
+			}
+			b.WriteString(p.src[start : p.pos-1])
+		case c == '"':
+			p.advance(1)
+			start, depth := p.pos, 0
+			for p.pos < len(p.src) && (p.src[p.pos] != '"' || depth > 0) {
+				switch p.src[p.pos] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+				p.advance(1)
+			}
+			if p.pos >= len(p.src) {
+				return "", fmt.Errorf("unterminated quote")
+			}
+			b.WriteString(p.src[start:p.pos])
+			p.advance(1)
+		default:
+			name := p.ident()
+			if name == "" {
+				return "", fmt.Errorf("expected a value on line %d", p.line)
+			}
+			if expanded, ok := p.macros[strings.ToLower(name)]; ok {
+				name = expanded
+			}
+			b.WriteString(name)
+		}
+
+		p.skipSpace()
+		if p.peek() != '#' {
+			return b.String(), nil
+		}
+		p.advance(1)
+	}
+}
+
+// bibtexItem maps an entry's fields to an Item, returning those it has no place for
+func bibtexItem(entryType string, fields [][2]string) (Item, map[string]string) {
+	entry := Entry{Item: Item{Kind: KindMisc}}
+	if kind, ok := bibtexKinds[entryType]; ok {
+		entry.Item.Kind = kind
+	}
+	item := &entry.Item
+	for _, field := range fields {
+		name, raw := field[0], field[1]
+		value := detex(raw)
+		if bibtexVerbatim[name] {
+			value = strings.Join(strings.Fields(raw), " ")
+		}
+		switch name {
+		case "author":
+			item.Authors = bibtexNames(raw)
+		case "title":
+			item.Title = value
+		case "journal", "journaltitle":
+			item.Container = value
+		case "booktitle":
+			if item.Container == "" {
+				item.Container = value
+			}
+		case "year":
+			year, _ := strconv.Atoi(strings.TrimSpace(value))
+			item.Date.Year = year
+		case "month":
+			item.Date.Month = parseMonth(value)
+		case "date":
+			// biblatex, a range is cited by when it starts
+			start, _, _ := strings.Cut(value, "/")
+			item.Date = parseDate(start)
+		case "series":
+			item.Series = value
+		case "volume":
+			item.Volume = value
+		case "number", "issue":
+			if item.Kind == KindReport && name == "number" {
+				item.Number = value
+			} else {
+				item.Issue = value
+			}
+		case "pages":
+			item.Pages = value
+		case "pagetotal":
+			item.PageCount, _ = strconv.Atoi(strings.TrimSpace(value))
+		case "publisher":
+			item.Publisher = value
+		case "institution", "school":
+			item.Institution = value
+		case "organization":
+			if item.Publisher == "" {
+				item.Publisher = value
+			}
+		case "edition":
+			item.Edition = value
+		case "version":
+			item.Version = value
+		case "isbn":
+			item.ISBN = value
+		case "doi":
+			item.DOI = value
+		case "language", "langid":
+			item.Language = value
+		case "abstract":
+			item.Abstract = value
+		case "keywords":
+			item.Keywords = splitKeywords(value)
+		default:
+			entry.unmapped(name, value)
+		}
+	}
+	return entry.Item, entry.Unmapped
+}
+
+// bibtexNames splits an author list on "and", outside braces. A name in braces is an
+// organisation's, "{World Health Organization}", and is kept whole.
+func bibtexNames(raw string) []Name {
+	var names []Name
+	for _, part := range splitTopLevel(raw, " and ") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "" || part == "others":
+			continue
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") && len(splitTopLevel(part, ",")) == 1:
+			names = append(names, Name{Family: detex(part)})
+			continue
+		}
+		// "von Last, Jr, First" keeps the suffix with the family name
+		if pieces := splitTopLevel(part, ","); len(pieces) == 3 {
+			names = append(names, Name{Family: detex(pieces[0] + " " + pieces[1]), Given: detex(pieces[2])})
+			continue
+		}
+		if name := ParseName(detex(part)); name.Family != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// splitTopLevel splits s on sep, case insensitively, where it's not inside braces
+func splitTopLevel(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	lower := strings.ToLower(s)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '{':
+			depth++
+		case s[i] == '}':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// latexSymbols are the control words that stand for a character
+var latexSymbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "aa": "å", "AA": "Å",
+	"l": "ł", "L": "Ł", "i": "ı", "j": "ȷ", "textbackslash": `\`, "textasciitilde": "~",
+	"textasciicircum": "^", "textendash": "–", "textemdash": "—", "ldots": "…", "dots": "…",
+	"textquoteleft": "‘", "textquoteright": "’", "textquotedblleft": "“", "textquotedblright": "”",
+	"S": "§", "P": "¶", "copyright": "©", "pounds": "£", "euro": "€",
+}
+
+// latexAccents are the accent commands with the letters they combine with, each letter
+// followed by its accented form. A letter not listed keeps no accent.
+var latexAccents = map[string]string{
+	"'": "aá eé ií oó uú yý cć nń sś zź lĺ rŕ AÁ EÉ IÍ OÓ UÚ YÝ CĆ NŃ SŚ ZŹ LĹ RŔ",
+	"`": "aà eè iì oò uù AÀ EÈ IÌ OÒ UÙ",
+	"^": "aâ eê iî oô uû cĉ gĝ hĥ jĵ sŝ wŵ yŷ AÂ EÊ IÎ OÔ UÛ",
+	`"`: "aä eë iï oö uü yÿ AÄ EË IÏ OÖ UÜ YŸ",
+	"~": "aã nñ oõ AÃ NÑ OÕ",
+	"=": "aā eē iī oō uū AĀ EĒ IĪ OŌ UŪ",
+	".": "zż eė gġ ZŻ EĖ Iİ",
+	"c": "cç sş tţ CÇ SŞ TŢ",
+	"v": "cč sš zž rř eě nň dď tť CČ SŠ ZŽ RŘ EĚ NŇ DĎ TŤ",
+	"u": "aă gğ AĂ GĞ",
+	"H": "oő uű OŐ UŰ",
+	"k": "aą eę AĄ EĘ",
+	"r": "aå uů AÅ UŮ",
+}
+
+// detex turns a field's LaTeX into plain text: special characters are unescaped, accents
+// applied, braces dropped, formatting commands leave their text, "--" and "---" become
+// dashes and whitespace is collapsed.
+func detex(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '{' || c == '}':
+			i++
+		case c == '~':
+			b.WriteByte(' ')
+			i++
+		case strings.HasPrefix(s[i:], "---"):
+			b.WriteString("—")
+			i += 3
+		case strings.HasPrefix(s[i:], "--"):
+			b.WriteString("–")
+			i += 2
+		case strings.HasPrefix(s[i:], "``"):
+			b.WriteString("“")
+			i += 2
+		case strings.HasPrefix(s[i:], "''"):
+			b.WriteString("”")
+			i += 2
+		case c == '\\' && i+1 < len(s):
+			i = detexCommand(&b, s, i+1)
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// detexCommand writes what the command after a backslash at s[i-1] stands for, returning
+// where the text after it starts
+func detexCommand(b *strings.Builder, s string, i int) int {
+	c := s[i]
+	if strings.IndexByte(`&%$#_{} `, c) >= 0 {
+		b.WriteByte(c)
+		return i + 1
+	}
+	if c == '\\' || c == ',' {
+		// a line break or a thin space
+		b.WriteByte(' ')
+		return i + 1
+	}
+
+	start := i
+	if isLetter(c) {
+		for i < len(s) && isLetter(s[i]) {
+			i++
+		}
+	} else {
+		i++
+	}
+	name := s[start:i]
+	if _, ok := latexAccents[name]; ok {
+		// the accented letter follows, braced or not: \"o, \"{o}, \c{c}, \'{\i}
+		j := i
+		for j < len(s) && (s[j] == ' ' || s[j] == '{') {
+			j++
+		}
+		if j < len(s) && s[j] == '\\' && j+1 < len(s) && (s[j+1] == 'i' || s[j+1] == 'j') {
+			j++
+		}
+		if j < len(s) {
+			letter, size := utf8.DecodeRuneInString(s[j:])
+			b.WriteString(accent(name, letter))
+			j += size
+			if j < len(s) && s[j] == '}' {
+				j++
+			}
+			return j
+		}
+		return i
+	}
+	if symbol, ok := latexSymbols[name]; ok {
+		b.WriteString(symbol)
+	}
+	// a control word swallows the space after it, any other command is dropped for its text
+	if isLetter(c) && i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// accent is letter with the accent of command, or just letter when they don't combine
+func accent(command string, letter rune) string {
+	for _, pair := range strings.Fields(latexAccents[command]) {
+		if runes := []rune(pair); runes[0] == letter {
+			return string(runes[1])
+		}
+	}
+	return string(letter)
+}
@@ -0,0 +1,340 @@
+package citation
+
+import (
+	"fmt"
+	"scriptorium/internal/backend/dao"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//---------------------------------------------------
+//---------------------CITATION----------------------
+//---------------------------------------------------
+
+// a citation is built from a document's record, its MetaData and the fields of its type,
+// as an Item. the DocTypes with a bibliographic counterpart are cited as that kind of work,
+// anything else as a generic one. items are written as BibTeX, RIS or CSL-JSON, or
+// formatted as a reference in one of Styles. BibTeX and RIS files are read back as an
+// Entry each, which holds the Item.
+
+// the kinds of work an Item can be
+const (
+	KindBook      = "book"
+	KindArticle   = "article"
+	KindReport    = "report"
+	KindManual    = "manual"
+	KindReference = "reference"
+	KindMisc      = "misc"
+)
+
+var docTypeKinds = map[string]string{
+	"Book":      KindBook,
+	"Article":   KindArticle,
+	"Report":    KindReport,
+	"Manual":    KindManual,
+	"Reference": KindReference,
+}
+
+// DocType is the DocType an Item of kind is stored as, empty for a generic work.
+func DocType(kind string) string {
+	for docType, k := range docTypeKinds {
+		if k == kind {
+			return docType
+		}
+	}
+	return ""
+}
+
+// Name is a person's name, or an organisation's, which is all Family.
+type Name struct {
+	Family string
+	Given  string
+}
+
+// ParseName splits "Turing, Alan" or "Alan Turing" into family and given names. A single
+// word is taken as the family name.
+func ParseName(name string) Name {
+	name = strings.Join(strings.Fields(name), " ")
+	if family, given, ok := strings.Cut(name, ","); ok {
+		return Name{Family: strings.TrimSpace(family), Given: strings.TrimSpace(given)}
+	}
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return Name{Family: name[i+1:], Given: name[:i]}
+	}
+	return Name{Family: name}
+}
+
+// String is the name in reading order, "Alan Turing"
+func (n Name) String() string {
+	return strings.TrimSpace(n.Given + " " + n.Family)
+}
+
+// Inverted is the name family first, "Turing, Alan"
+func (n Name) Inverted() string {
+	if n.Given == "" {
+		return n.Family
+	}
+	return n.Family + ", " + n.Given
+}
+
+// Initials abbreviates the given names, "Alan Mathison" to "A. M." and "Jean-Paul" to "J.-P."
+func (n Name) Initials() string {
+	var initials []string
+	for _, given := range strings.Fields(n.Given) {
+		var parts []string
+		for _, part := range strings.Split(given, "-") {
+			if r := []rune(strings.TrimRight(part, ".")); len(r) > 0 {
+				parts = append(parts, string(unicode.ToUpper(r[0]))+".")
+			}
+		}
+		if len(parts) > 0 {
+			initials = append(initials, strings.Join(parts, "-"))
+		}
+	}
+	return strings.Join(initials, " ")
+}
+
+// Date is a publication date, zero in the parts that aren't known.
+type Date struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// parseDate reads the ISO 8601 dates PublishDate holds: 2009, 2009-07 or 2009-07-31
+func parseDate(value string) Date {
+	var date Date
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	for i, dest := range []*int{&date.Year, &date.Month, &date.Day} {
+		if i >= len(parts) {
+			break
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			break
+		}
+		*dest = n
+	}
+	return date
+}
+
+// String is the date in the ISO 8601 form PublishDate holds, empty when the year isn't known
+func (d Date) String() string {
+	switch {
+	case d.Year == 0:
+		return ""
+	case d.Month == 0:
+		return fmt.Sprintf("%04d", d.Year)
+	case d.Day == 0:
+		return fmt.Sprintf("%04d-%02d", d.Year, d.Month)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+var monthNames = []string{"january", "february", "march", "april", "may", "june", "july",
+	"august", "september", "october", "november", "december"}
+
+// parseMonth reads a month as a number, "10", or a name, "October", "Oct." or "oct". It's 0
+// when the month isn't one of those.
+func parseMonth(value string) int {
+	value = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(value), "."))
+	if n, err := strconv.Atoi(value); err == nil && n >= 1 && n <= 12 {
+		return n
+	}
+	if len(value) < 3 {
+		return 0
+	}
+	for i, name := range monthNames {
+		if strings.HasPrefix(name, value) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Item is what's cited of a document.
+type Item struct {
+	Uuid    string
+	Kind    string
+	Title   string
+	Authors []Name
+	Date    Date
+	// Container is the journal an article is in
+	Container   string
+	Series      string
+	Volume      string
+	Issue       string
+	Pages       string
+	PageCount   int
+	Publisher   string
+	Institution string
+	// Number is a report's number
+	Number   string
+	Edition  string
+	Version  string
+	ISBN     string
+	DOI      string
+	Language string
+	Abstract string
+	Keywords []string
+}
+
+// NewItem builds the Item of a document's record.
+func NewItem(record dao.Record) Item {
+	fields := record.Fields()
+	text := func(name string) string {
+		value, _ := fields[name].(string)
+		return strings.TrimSpace(value)
+	}
+
+	item := Item{
+		Uuid:        record.Uuid,
+		Kind:        KindMisc,
+		Title:       strings.TrimSpace(record.Title),
+		Date:        parseDate(record.PublishDate),
+		Container:   text("Journal"),
+		Series:      text("Series"),
+		Volume:      text("Volume"),
+		Issue:       text("Issue"),
+		Pages:       text("Pages"),
+		PageCount:   record.PageCount,
+		Publisher:   strings.TrimSpace(record.Publisher),
+		Institution: text("Institution"),
+		Number:      text("ReportNumber"),
+		Edition:     strings.TrimSpace(record.Edition),
+		Version:     text("Version"),
+		ISBN:        strings.TrimSpace(record.ISBN),
+		DOI:         strings.TrimSpace(record.DOI),
+		Language:    strings.TrimSpace(record.Language),
+		Abstract:    strings.TrimSpace(record.Description),
+		Keywords:    record.Tags,
+	}
+	if kind, ok := docTypeKinds[record.DocType]; ok {
+		item.Kind = kind
+	}
+
+	meta := record.MetaData
+	meta.SyncAuthors()
+	for _, author := range meta.Authors {
+		if name := ParseName(author); name.Family != "" {
+			item.Authors = append(item.Authors, name)
+		}
+	}
+	return item
+}
+
+// publisher is who published the item, the institution for a report
+func (item Item) publisher() string {
+	if item.Kind == KindReport && item.Institution != "" {
+		return item.Institution
+	}
+	return item.Publisher
+}
+
+// startPage and endPage split Pages, "112-130" or "112–130", into its first and last page
+func (item Item) startPage() string {
+	start, _, _ := strings.Cut(normalizePages(item.Pages), "-")
+	return start
+}
+
+func (item Item) endPage() string {
+	_, end, _ := strings.Cut(normalizePages(item.Pages), "-")
+	return end
+}
+
+func normalizePages(pages string) string {
+	pages = strings.NewReplacer("–", "-", "—", "-", " ", "").Replace(pages)
+	for strings.Contains(pages, "--") {
+		pages = strings.ReplaceAll(pages, "--", "-")
+	}
+	return pages
+}
+
+// doiURL is the DOI as a link, unless it's one already
+func (item Item) doiURL() string {
+	if item.DOI == "" || strings.HasPrefix(item.DOI, "http") {
+		return item.DOI
+	}
+	return "https://doi.org/" + item.DOI
+}
+
+// ordinalEdition turns an edition number into "2nd", leaving anything else as it is
+func ordinalEdition(edition string) string {
+	n, err := strconv.Atoi(edition)
+	if err != nil {
+		return edition
+	}
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+//---------------------------------------------------
+//----------------------ENTRIES----------------------
+//---------------------------------------------------
+
+// Entry is an entry read from a BibTeX or RIS file, as an Item.
+type Entry struct {
+	// Line is the line of the file the entry starts on
+	Line int
+	// Key is a BibTeX entry's citation key, or a RIS record's ID
+	Key string
+	// Type is the entry type as written, "article" or "JOUR"
+	Type string
+	Item Item
+	// Unmapped holds the fields that have no place in an Item, by their name in the file: a
+	// BibTeX field lowercased, or a RIS tag. A RIS tag's repeats are joined with "; ".
+	Unmapped map[string]string
+	// Err is set when the entry couldn't be read, the rest of the file is read regardless
+	Err error
+}
+
+func (e *Entry) unmapped(name, value string) {
+	if value == "" {
+		return
+	}
+	if e.Unmapped == nil {
+		e.Unmapped = map[string]string{}
+	}
+	if prev, ok := e.Unmapped[name]; ok {
+		value = prev + "; " + value
+	}
+	e.Unmapped[name] = value
+}
+
+// finish tidies the Item once every field is read: pages are written with a hyphen, a DOI
+// link is cut down to the DOI, and a report without an institution takes its publisher.
+func (e *Entry) finish() {
+	e.Item.Pages = normalizePages(e.Item.Pages)
+	doi := strings.TrimSpace(e.Item.DOI)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		if len(doi) > len(prefix) && strings.EqualFold(doi[:len(prefix)], prefix) {
+			doi = doi[len(prefix):]
+			break
+		}
+	}
+	e.Item.DOI = doi
+	if e.Item.Kind == KindReport && e.Item.Institution == "" {
+		e.Item.Institution = e.Item.Publisher
+	}
+}
+
+// splitKeywords splits a keyword list on commas or semicolons
+func splitKeywords(value string) []string {
+	var keywords []string
+	for _, keyword := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
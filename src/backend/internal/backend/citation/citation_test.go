@@ -0,0 +1,296 @@
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"scriptorium/internal/backend/dao"
+)
+
+func turing() Item {
+	article := &dao.Article{Journal: "Mind", Volume: "59", Issue: "236", Pages: "433-460"}
+	article.SetMetaData(dao.MetaData{
+		Uuid:        "0b0e6c1e-5f0a-4f4e-9a43-2f3d0b8c9d11",
+		DocType:     "Article",
+		Title:       "Computing Machinery and Intelligence",
+		Authors:     []string{"Turing, Alan Mathison"},
+		PublishDate: "1950-10",
+		DOI:         "10.1093/mind/LIX.236.433",
+		Tags:        []string{"ai", "philosophy"},
+	})
+	record, _ := dao.NewRecord(article)
+	return NewItem(record)
+}
+
+func knuth() Item {
+	book := &dao.Book{Volume: "1"}
+	book.SetMetaData(dao.MetaData{
+		Uuid:        "6f1c2a4e-8d0b-4c36-b1c5-0f1a2b3c4d5e",
+		DocType:     "Book",
+		Title:       "The Art of Computer Programming",
+		Author:      "Donald E. Knuth",
+		PublishDate: "1997",
+		Publisher:   "Addison-Wesley",
+		Edition:     "3",
+		ISBN:        "0201896834",
+	})
+	record, _ := dao.NewRecord(book)
+	return NewItem(record)
+}
+
+func TestWhenRecordCitedExpectTypeAndFieldsMapped(t *testing.T) {
+	item := turing()
+	if item.Kind != KindArticle || item.Container != "Mind" || item.Date != (Date{Year: 1950, Month: 10}) {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if len(item.Authors) != 1 || item.Authors[0] != (Name{Family: "Turing", Given: "Alan Mathison"}) {
+		t.Errorf("wanted the inverted name split; have %+v", item.Authors)
+	}
+
+	notes := &dao.Notes{Content: "x"}
+	notes.SetMetaData(dao.MetaData{DocType: "Notes", Title: "Lecture 1"})
+	record, _ := dao.NewRecord(notes)
+	if item := NewItem(record); item.Kind != KindMisc || BibTeXTypes[item.Kind] != "misc" || RISTypes[item.Kind] != "GEN" {
+		t.Errorf("wanted notes cited as a generic work; have %+v", item)
+	}
+}
+
+func TestWhenBibTeXWrittenExpectEntries(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteBibTeX(&out, []Item{turing(), knuth(), knuth()}); err != nil {
+		t.Fatalf("error writing BibTeX: %s", err)
+	}
+	bib := out.String()
+	for _, want := range []string{
+		"@article{turing1950computing,",
+		"  author = {Turing, Alan Mathison},",
+		"  month = oct,",
+		"  pages = {433--460},",
+		"  doi = {10.1093/mind/LIX.236.433},",
+		"@book{knuth1997computer,",
+		"@book{knuth1997computerb,",
+		"  edition = {3},",
+	} {
+		if !strings.Contains(bib, want) {
+			t.Errorf("wanted %q in\n%s", want, bib)
+		}
+	}
+
+	item := knuth()
+	item.Title = "Profit & Loss_50%"
+	out.Reset()
+	WriteBibTeX(&out, []Item{item})
+	if !strings.Contains(out.String(), `title = {Profit \& Loss\_50\%}`) {
+		t.Errorf("wanted special characters escaped; have\n%s", out.String())
+	}
+}
+
+func TestWhenRISWrittenExpectTaggedRecord(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteRIS(&out, []Item{turing()}); err != nil {
+		t.Fatalf("error writing RIS: %s", err)
+	}
+	want := strings.Join([]string{
+		"TY  - JOUR",
+		"ID  - 0b0e6c1e-5f0a-4f4e-9a43-2f3d0b8c9d11",
+		"AU  - Turing, Alan Mathison",
+		"TI  - Computing Machinery and Intelligence",
+		"T2  - Mind",
+		"PY  - 1950",
+		"DA  - 1950/10//",
+		"VL  - 59",
+		"IS  - 236",
+		"SP  - 433",
+		"EP  - 460",
+		"DO  - 10.1093/mind/LIX.236.433",
+		"KW  - ai",
+		"KW  - philosophy",
+		"ER  - ",
+	}, "\r\n") + "\r\n"
+	if out.String() != want {
+		t.Errorf("wanted\n%s\nhave\n%s", want, out.String())
+	}
+}
+
+func TestWhenCSLJSONWrittenExpectItems(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteCSLJSON(&out, []Item{turing(), knuth()}); err != nil {
+		t.Fatalf("error writing CSL-JSON: %s", err)
+	}
+	var items []CSLItem
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil {
+		t.Fatalf("error reading CSL-JSON back: %s", err)
+	}
+	if len(items) != 2 || items[0].Type != "article-journal" || items[0].ContainerTitle != "Mind" || items[0].Page != "433-460" {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if issued := items[0].Issued.DateParts; len(issued) != 1 || len(issued[0]) != 2 || issued[0][1] != 10 {
+		t.Errorf("wanted year and month issued; have %v", issued)
+	}
+	if items[1].Type != "book" || items[1].Author[0] != (CSLName{Family: "Knuth", Given: "Donald E."}) {
+		t.Errorf("unexpected book %+v", items[1])
+	}
+}
+
+func TestWhenFormattedExpectStyle(t *testing.T) {
+	tests := []struct {
+		item  Item
+		style string
+		text  string
+	}{
+		{turing(), StyleAPA, "Turing, A. M. (1950). Computing Machinery and Intelligence. Mind, 59(236), 433–460. https://doi.org/10.1093/mind/LIX.236.433"},
+		{turing(), StyleChicago, "Turing, Alan Mathison. “Computing Machinery and Intelligence.” Mind 59, no. 236 (1950): 433–460. https://doi.org/10.1093/mind/LIX.236.433."},
+		{knuth(), StyleAPA, "Knuth, D. E. (1997). The Art of Computer Programming (3rd ed., Vol. 1). Addison-Wesley."},
+		{knuth(), StyleChicago, "Knuth, Donald E. The Art of Computer Programming. 3rd ed. Vol. 1. Addison-Wesley, 1997."},
+		{Item{Kind: KindMisc, Title: "Untitled notes?"}, StyleAPA, "Untitled notes? (n.d.)."},
+	}
+	for _, tt := range tests {
+		ref, err := Format(tt.item, tt.style)
+		if err != nil {
+			t.Fatalf("error formatting: %s", err)
+		}
+		if ref.Text != tt.text {
+			t.Errorf("%s: wanted\n%s\nhave\n%s", tt.style, tt.text, ref.Text)
+		}
+	}
+
+	ref, _ := Format(knuth(), StyleAPA)
+	if !strings.Contains(ref.HTML, "<i>The Art of Computer Programming</i>") {
+		t.Errorf("wanted the title in italics; have %s", ref.HTML)
+	}
+	if _, err := Format(knuth(), "mla"); err == nil {
+		t.Error("wanted an unknown style refused")
+	}
+}
+
+func TestWhenManyAuthorsExpectListShortened(t *testing.T) {
+	var names []Name
+	for _, family := range strings.Fields("A B C D E F G H I J K L M N O P Q R S T U") {
+		names = append(names, Name{Family: family, Given: "X"})
+	}
+	if got := apaAuthors(names); !strings.HasSuffix(got, "S, X., . . . U, X.") {
+		t.Errorf("wanted 19 authors, an ellipsis and the last; have %s", got)
+	}
+	if got := chicagoAuthors(names); got != "A, X, X B, X C, X D, X E, X F, X G, et al." {
+		t.Errorf("wanted seven authors and et al.; have %s", got)
+	}
+	if got := apaAuthors(names[:2]); got != "A, X., & B, X." {
+		t.Errorf("have %s", got)
+	}
+}
+
+func TestWhenWrittenEntriesReadBackExpectSameItems(t *testing.T) {
+	items := []Item{turing(), knuth()}
+	for _, format := range []struct {
+		name  string
+		write func(io.Writer, []Item) error
+		parse func(io.Reader) ([]Entry, error)
+	}{
+		{"bibtex", WriteBibTeX, ParseBibTeX},
+		{"ris", WriteRIS, ParseRIS},
+	} {
+		var out bytes.Buffer
+		format.write(&out, items)
+		entries, err := format.parse(&out)
+		if err != nil || len(entries) != len(items) {
+			t.Fatalf("%s: wanted %d entries; have %d, %v", format.name, len(items), len(entries), err)
+		}
+		for i, entry := range entries {
+			want := items[i]
+			want.Uuid = ""
+			if entry.Err != nil || len(entry.Unmapped) > 0 || !reflect.DeepEqual(entry.Item, want) {
+				t.Errorf("%s: wanted\n%+v\nhave\n%+v (%v, unmapped %v)", format.name, want, entry.Item, entry.Err, entry.Unmapped)
+			}
+		}
+	}
+}
+
+func TestWhenBibTeXReadExpectLaTeXMacrosAndErrorsHandled(t *testing.T) {
+	bib := `Exported by a reference manager, text out here is a comment.
+@string{acm = "Communications of the {ACM}"}
+@comment{ @article{ignored, title = {Nope}} }
+@Article{dijkstra68,
+  Author   = {Dijkstra, Edsger W. and {IEEE Computer Society} and others},
+  Title    = "{Go To} Statement Considered Harmful",
+  Journal  = acm,
+  Year     = 1968, month = mar,
+  Pages    = {147--148},
+  doi      = {https://doi.org/10.1145/362929.362947},
+  url      = {https://example.org/~dijkstra},
+  note     = {Letter to the editor}
+}
+@book{broken, title = {Unclosed
+@techreport(erdos, author = {Erd{\H o}s, Paul and G\"{o}del, Kurt and Paul Erd\H{o}s},
+  title = {Caf\'e \& Na\"ive Proofs\,---\,Vol~1}, institution = {MIT}, number = 42, date = {1999-07-31/2000})
+`
+	entries, err := ParseBibTeX(strings.NewReader(bib))
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("wanted 3 entries; have %d, %v", len(entries), err)
+	}
+
+	dijkstra := entries[0]
+	want := Item{
+		Kind:      KindArticle,
+		Title:     "Go To Statement Considered Harmful",
+		Authors:   []Name{{Family: "Dijkstra", Given: "Edsger W."}, {Family: "IEEE Computer Society"}},
+		Date:      Date{Year: 1968, Month: 3},
+		Container: "Communications of the ACM",
+		Pages:     "147-148",
+		DOI:       "10.1145/362929.362947",
+	}
+	if dijkstra.Key != "dijkstra68" || dijkstra.Line != 4 || dijkstra.Err != nil || !reflect.DeepEqual(dijkstra.Item, want) {
+		t.Errorf("wanted\n%+v\nhave\n%+v (%s line %d, %v)", want, dijkstra.Item, dijkstra.Key, dijkstra.Line, dijkstra.Err)
+	}
+	if len(dijkstra.Unmapped) != 2 || dijkstra.Unmapped["url"] != "https://example.org/~dijkstra" || dijkstra.Unmapped["note"] != "Letter to the editor" {
+		t.Errorf("wanted url and note unmapped; have %v", dijkstra.Unmapped)
+	}
+
+	if entries[1].Err == nil || entries[1].Line != 14 {
+		t.Errorf("wanted the unclosed entry reported on its line; have %+v", entries[1])
+	}
+
+	erdos := entries[2].Item
+	if erdos.Kind != KindReport || erdos.Number != "42" || erdos.Institution != "MIT" || erdos.Date != (Date{Year: 1999, Month: 7, Day: 31}) {
+		t.Errorf("unexpected report %+v", erdos)
+	}
+	if erdos.Title != "Café & Naïve Proofs — Vol 1" {
+		t.Errorf("wanted LaTeX turned to text; have %q", erdos.Title)
+	}
+	wantNames := []Name{{Family: "Erdős", Given: "Paul"}, {Family: "Gödel", Given: "Kurt"}, {Family: "Erdős", Given: "Paul"}}
+	if !reflect.DeepEqual(erdos.Authors, wantNames) {
+		t.Errorf("wanted %v; have %v", wantNames, erdos.Authors)
+	}
+}
+
+func TestWhenRISReadExpectTagsMapped(t *testing.T) {
+	ris := "UR  - https://stray.example.org\r\n" +
+		"TY  - RPRT\r\nAU  - Shannon, Claude E.\r\nTI  - A Mathematical Theory\r\n of Communication\r\n" +
+		"PY  - 1948///\r\nPB  - Bell Labs\r\nM1  - TR-5\r\nSP  - 379–423\r\nN1  - reprinted\r\nN1  - twice\r\nER  - \r\n" +
+		"\r\nTY  - JOUR\r\nJA  - Commun. ACM\r\nJF  - Communications of the ACM\r\nTI  - No end\r\n"
+	entries, err := ParseRIS(strings.NewReader(ris))
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("wanted 3 entries; have %d, %v", len(entries), err)
+	}
+	if entries[0].Err == nil || entries[0].Line != 1 {
+		t.Errorf("wanted the stray tag reported; have %+v", entries[0])
+	}
+
+	shannon := entries[1]
+	if shannon.Line != 2 || shannon.Type != "RPRT" || shannon.Item.Title != "A Mathematical Theory of Communication" {
+		t.Errorf("unexpected entry %+v", shannon)
+	}
+	if shannon.Item.Institution != "Bell Labs" || shannon.Item.Number != "TR-5" || shannon.Item.Pages != "379-423" || shannon.Item.Date.Year != 1948 {
+		t.Errorf("unexpected report %+v", shannon.Item)
+	}
+	if shannon.Unmapped["N1"] != "reprinted; twice" {
+		t.Errorf("wanted repeated notes joined; have %v", shannon.Unmapped)
+	}
+
+	if unended := entries[2]; unended.Item.Container != "Communications of the ACM" || unended.Item.Kind != KindArticle {
+		t.Errorf("wanted the record without ER kept, with the full journal name; have %+v", unended.Item)
+	}
+}
@@ -0,0 +1,108 @@
+package citation
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//---------------------------------------------------
+//---------------------CSL-JSON----------------------
+//---------------------------------------------------
+
+// CSLTypes maps the kinds of Item to CSL item types
+var CSLTypes = map[string]string{
+	KindBook:      "book",
+	KindArticle:   "article-journal",
+	KindReport:    "report",
+	KindManual:    "book",
+	KindReference: "book",
+	KindMisc:      "document",
+}
+
+// CSLItem is an item of CSL-JSON, the input citeproc processors format references from.
+type CSLItem struct {
+	ID              string    `json:"id"`
+	Type            string    `json:"type"`
+	Title           string    `json:"title,omitempty"`
+	Author          []CSLName `json:"author,omitempty"`
+	Issued          *CSLDate  `json:"issued,omitempty"`
+	ContainerTitle  string    `json:"container-title,omitempty"`
+	CollectionTitle string    `json:"collection-title,omitempty"`
+	Volume          string    `json:"volume,omitempty"`
+	Issue           string    `json:"issue,omitempty"`
+	Page            string    `json:"page,omitempty"`
+	NumberOfPages   string    `json:"number-of-pages,omitempty"`
+	Publisher       string    `json:"publisher,omitempty"`
+	Number          string    `json:"number,omitempty"`
+	Edition         string    `json:"edition,omitempty"`
+	Version         string    `json:"version,omitempty"`
+	ISBN            string    `json:"ISBN,omitempty"`
+	DOI             string    `json:"DOI,omitempty"`
+	Language        string    `json:"language,omitempty"`
+	Abstract        string    `json:"abstract,omitempty"`
+	// Keyword is comma separated
+	Keyword string `json:"keyword,omitempty"`
+}
+
+type CSLName struct {
+	Family string `json:"family,omitempty"`
+	Given  string `json:"given,omitempty"`
+}
+
+// CSLDate holds a single date as [[year, month, day]], leaving out the parts not known
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// NewCSLItem is the CSL-JSON of an item, its id the document's UUID.
+func NewCSLItem(item Item) CSLItem {
+	csl := CSLItem{
+		ID:              item.Uuid,
+		Type:            CSLTypes[item.Kind],
+		Title:           item.Title,
+		ContainerTitle:  item.Container,
+		CollectionTitle: item.Series,
+		Volume:          item.Volume,
+		Issue:           item.Issue,
+		Page:            normalizePages(item.Pages),
+		Publisher:       item.publisher(),
+		Number:          item.Number,
+		Edition:         item.Edition,
+		Version:         item.Version,
+		ISBN:            item.ISBN,
+		DOI:             item.DOI,
+		Language:        item.Language,
+		Abstract:        item.Abstract,
+		Keyword:         strings.Join(item.Keywords, ", "),
+	}
+	for _, author := range item.Authors {
+		csl.Author = append(csl.Author, CSLName{Family: author.Family, Given: author.Given})
+	}
+	if item.PageCount > 0 {
+		csl.NumberOfPages = strconv.Itoa(item.PageCount)
+	}
+	if item.Date.Year > 0 {
+		parts := []int{item.Date.Year}
+		if item.Date.Month > 0 {
+			parts = append(parts, item.Date.Month)
+			if item.Date.Day > 0 {
+				parts = append(parts, item.Date.Day)
+			}
+		}
+		csl.Issued = &CSLDate{DateParts: [][]int{parts}}
+	}
+	return csl
+}
+
+// WriteCSLJSON writes the items as a CSL-JSON array.
+func WriteCSLJSON(w io.Writer, items []Item) error {
+	csl := make([]CSLItem, 0, len(items))
+	for _, item := range items {
+		csl = append(csl, NewCSLItem(item))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(csl)
+}
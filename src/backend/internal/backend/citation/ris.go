@@ -0,0 +1,273 @@
+package citation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//---------------------------------------------------
+//------------------------RIS------------------------
+//---------------------------------------------------
+
+// RISTypes maps the kinds of Item to RIS reference types. RIS has no type for a manual,
+// it's cited as a generic work.
+var RISTypes = map[string]string{
+	KindBook:      "BOOK",
+	KindArticle:   "JOUR",
+	KindReport:    "RPRT",
+	KindManual:    "GEN",
+	KindReference: "BOOK",
+	KindMisc:      "GEN",
+}
+
+// WriteRIS writes a record per item, tagged lines ending in CRLF as the format asks. The
+// document's UUID is kept in the ID tag.
+func WriteRIS(w io.Writer, items []Item) error {
+	for _, item := range items {
+		if _, err := io.WriteString(w, risRecord(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func risRecord(item Item) string {
+	var b strings.Builder
+	add := func(tag, value string) {
+		if value = strings.Join(strings.Fields(value), " "); value != "" {
+			fmt.Fprintf(&b, "%s  - %s\r\n", tag, value)
+		}
+	}
+
+	add("TY", RISTypes[item.Kind])
+	add("ID", item.Uuid)
+	for _, author := range item.Authors {
+		add("AU", author.Inverted())
+	}
+	add("TI", item.Title)
+	add("T2", item.Container)
+	add("T3", item.Series)
+	if item.Date.Year > 0 {
+		add("PY", strconv.Itoa(item.Date.Year))
+		add("DA", risDate(item.Date))
+	}
+	add("VL", item.Volume)
+	add("IS", item.Issue)
+	add("SP", item.startPage())
+	add("EP", item.endPage())
+	add("PB", item.publisher())
+	add("M1", item.Number)
+	add("ET", item.Edition)
+	add("SN", item.ISBN)
+	add("DO", item.DOI)
+	add("LA", item.Language)
+	add("AB", item.Abstract)
+	for _, keyword := range item.Keywords {
+		add("KW", keyword)
+	}
+	b.WriteString("ER  - \r\n")
+	return b.String()
+}
+
+// risDate is "2009/07/31/", with the parts that aren't known left empty: "2009/07//"
+func risDate(date Date) string {
+	part := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%02d", n)
+	}
+	return fmt.Sprintf("%04d/%s/%s/", date.Year, part(date.Month), part(date.Day))
+}
+
+//-----READING-----
+
+// risKinds maps RIS reference types to the kind of Item they're read as. Any other type is
+// read as a generic work.
+var risKinds = map[string]string{
+	"JOUR":   KindArticle,
+	"JFULL":  KindArticle,
+	"EJOUR":  KindArticle,
+	"MGZN":   KindArticle,
+	"NEWS":   KindArticle,
+	"CPAPER": KindArticle,
+	"CONF":   KindArticle,
+	"BOOK":   KindBook,
+	"EBOOK":  KindBook,
+	"EDBOOK": KindBook,
+	"CHAP":   KindBook,
+	"ECHAP":  KindBook,
+	"ENCYC":  KindBook,
+	"DICT":   KindBook,
+	"RPRT":   KindReport,
+}
+
+var risLine = regexp.MustCompile(`^([A-Z][A-Z0-9])  ?-(?: (.*))?$`)
+
+// ParseRIS reads the records of a RIS file. A line that isn't tagged continues the value
+// on the line before it, as some exporters wrap long abstracts. A record missing its ER
+// at the end of the file is kept.
+func ParseRIS(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var record *risRecordReader
+	// tags outside a record are reported once, with the line they start on
+	stray := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		match := risLine.FindStringSubmatch(line)
+		switch {
+		case match == nil && record != nil && record.last >= 0 && strings.TrimSpace(line) != "":
+			record.fields[record.last][1] += " " + strings.TrimSpace(line)
+		case match == nil:
+			continue
+		case match[1] == "TY":
+			if record != nil {
+				entries = append(entries, record.entry())
+			}
+			record = &risRecordReader{line: n, kind: strings.TrimSpace(match[2]), last: -1}
+			stray = false
+		case record == nil:
+			if !stray {
+				entries = append(entries, Entry{Line: n, Err: fmt.Errorf("line %d: %s outside a record, records start with TY", n, match[1])})
+				stray = true
+			}
+		case match[1] == "ER":
+			entries = append(entries, record.entry())
+			record = nil
+		default:
+			record.fields = append(record.fields, [2]string{match[1], strings.TrimSpace(match[2])})
+			record.last = len(record.fields) - 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if record != nil {
+		entries = append(entries, record.entry())
+	}
+	return entries, nil
+}
+
+// risRecordReader collects a record's tagged fields up to its ER
+type risRecordReader struct {
+	line   int
+	kind   string
+	fields [][2]string
+	// last is the field a line without a tag continues
+	last int
+}
+
+func (r *risRecordReader) entry() Entry {
+	entry := Entry{Line: r.line, Type: r.kind, Item: Item{Kind: KindMisc}}
+	if kind, ok := risKinds[strings.ToUpper(r.kind)]; ok {
+		entry.Item.Kind = kind
+	}
+	item := &entry.Item
+	// full journal names win over abbreviations, whatever order they come in
+	var abbreviation, startPage, endPage string
+	for _, field := range r.fields {
+		tag, value := field[0], field[1]
+		if value == "" {
+			continue
+		}
+		switch tag {
+		case "ID":
+			entry.Key = value
+		case "AU", "A1":
+			if name := ParseName(value); name.Family != "" {
+				item.Authors = append(item.Authors, name)
+			}
+		case "TI", "T1":
+			item.Title = value
+		case "BT":
+			if item.Kind == KindBook && item.Title == "" {
+				item.Title = value
+			} else if item.Container == "" {
+				item.Container = value
+			}
+		case "T2", "JF", "JO":
+			if item.Container == "" {
+				item.Container = value
+			}
+		case "JA", "J2":
+			abbreviation = value
+		case "T3":
+			item.Series = value
+		case "PY", "Y1", "DA":
+			if date := risParseDate(value); date.Year > 0 && (item.Date.Year == 0 || date.Month > 0) {
+				item.Date = date
+			}
+		case "VL":
+			item.Volume = value
+		case "IS":
+			item.Issue = value
+		case "SP":
+			startPage = value
+		case "EP":
+			endPage = value
+		case "PB":
+			item.Publisher = value
+		case "M1":
+			if item.Kind == KindReport {
+				item.Number = value
+			} else {
+				entry.unmapped(tag, value)
+			}
+		case "ET":
+			item.Edition = value
+		case "SN":
+			// an article's serial number is the journal's ISSN
+			if item.Kind == KindArticle {
+				entry.unmapped(tag, value)
+			} else {
+				item.ISBN = value
+			}
+		case "DO":
+			item.DOI = value
+		case "LA":
+			item.Language = value
+		case "AB", "N2":
+			if item.Abstract == "" {
+				item.Abstract = value
+			}
+		case "KW":
+			item.Keywords = append(item.Keywords, splitKeywords(value)...)
+		default:
+			entry.unmapped(tag, value)
+		}
+	}
+	if item.Container == "" {
+		item.Container = abbreviation
+	}
+	item.Pages = startPage
+	if endPage != "" && !strings.ContainsAny(startPage, "-–") {
+		item.Pages += "-" + endPage
+	}
+	entry.finish()
+	return entry
+}
+
+// risParseDate reads "1950", "1950/10/01/" or "1950/10//", and the ISO 8601 some exporters
+// write instead
+func risParseDate(value string) Date {
+	if strings.Contains(value, "-") && !strings.Contains(value, "/") {
+		return parseDate(value)
+	}
+	var date Date
+	parts := strings.Split(value, "/")
+	for i, dest := range []*int{&date.Year, &date.Month, &date.Day} {
+		if i < len(parts) {
+			*dest, _ = strconv.Atoi(strings.TrimSpace(parts[i]))
+		}
+	}
+	return date
+}
@@ -0,0 +1,267 @@
+package citation
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+//---------------------------------------------------
+//----------------------STYLES-----------------------
+//---------------------------------------------------
+
+// the styles an Item can be formatted in: APA 7th edition, and the bibliography entries
+// of the Chicago Manual of Style, 17th edition
+const (
+	StyleAPA     = "apa"
+	StyleChicago = "chicago"
+)
+
+var Styles = []string{StyleAPA, StyleChicago}
+
+// ErrUnknownStyle is returned (wrapped) by Format for a style not in Styles.
+var ErrUnknownStyle = errors.New("unknown citation style")
+
+// Reference is a formatted reference, as plain text and as HTML with the titles the style
+// sets in italics in <i>.
+type Reference struct {
+	Text string
+	HTML string
+}
+
+// Format formats item as a reference in style.
+func Format(item Item, style string) (Reference, error) {
+	var r reference
+	switch style {
+	case StyleAPA:
+		r.apa(item)
+	case StyleChicago:
+		r.chicago(item)
+	default:
+		return Reference{}, fmt.Errorf("%w: %s", ErrUnknownStyle, style)
+	}
+	return Reference{Text: r.text.String(), HTML: r.html.String()}, nil
+}
+
+// reference builds the text and HTML of a reference together
+type reference struct {
+	text strings.Builder
+	html strings.Builder
+}
+
+func (r *reference) add(s string) {
+	r.text.WriteString(s)
+	r.html.WriteString(html.EscapeString(s))
+}
+
+func (r *reference) italic(s string) {
+	r.text.WriteString(s)
+	r.html.WriteString("<i>" + html.EscapeString(s) + "</i>")
+}
+
+// period ends a sentence with a full stop, unless s already ends with punctuation that does
+func period(s string) string {
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
+
+// pageRange sets a range of pages with an en dash
+func pageRange(pages string) string {
+	return strings.ReplaceAll(normalizePages(pages), "-", "–")
+}
+
+func (item Item) titleOrUntitled() string {
+	if item.Title == "" {
+		return "Untitled"
+	}
+	return item.Title
+}
+
+func (item Item) year() string {
+	if item.Date.Year == 0 {
+		return "n.d."
+	}
+	return strconv.Itoa(item.Date.Year)
+}
+
+//-----APA-----
+
+// apa formats, keeping titles as they are stored, for an article:
+//
+//	Turing, A. M. (1950). Computing Machinery and Intelligence. Mind, 59(236), 433–460. https://doi.org/...
+//
+// and for the other kinds, with the edition, volume, report number or version in brackets:
+//
+//	Knuth, D. E. (1997). The Art of Computer Programming (3rd ed., Vol. 1). Addison-Wesley.
+func (r *reference) apa(item Item) {
+	date := "(" + item.year() + ")"
+	if len(item.Authors) > 0 {
+		r.add(period(apaAuthors(item.Authors)) + " " + date + ". ")
+		r.apaTitle(item)
+	} else {
+		// without an author the title takes its place
+		r.apaTitle(item)
+		r.add(" " + date + ".")
+	}
+
+	if item.Kind == KindArticle {
+		if item.Container != "" {
+			r.add(" ")
+			r.italic(item.Container)
+			if item.Volume != "" {
+				r.add(", ")
+				r.italic(item.Volume)
+			}
+			if item.Issue != "" {
+				r.add("(" + item.Issue + ")")
+			}
+			if item.Pages != "" {
+				r.add(", " + pageRange(item.Pages))
+			}
+			r.add(".")
+		}
+	} else if publisher := item.publisher(); publisher != "" {
+		r.add(" " + period(publisher))
+	}
+	if doi := item.doiURL(); doi != "" {
+		r.add(" " + doi)
+	}
+}
+
+func (r *reference) apaTitle(item Item) {
+	title := item.titleOrUntitled()
+	if item.Kind == KindArticle {
+		r.add(period(title))
+		return
+	}
+
+	var notes []string
+	if item.Edition != "" {
+		notes = append(notes, ordinalEdition(item.Edition)+" ed.")
+	}
+	if item.Volume != "" {
+		notes = append(notes, "Vol. "+item.Volume)
+	}
+	if item.Kind == KindReport && item.Number != "" {
+		notes = append(notes, "Report No. "+item.Number)
+	}
+	if item.Version != "" {
+		notes = append(notes, "Version "+item.Version)
+	}
+	r.italic(title)
+	if len(notes) > 0 {
+		r.add(" (" + strings.Join(notes, ", ") + ").")
+	} else if period(title) != title {
+		r.add(".")
+	}
+}
+
+// apaAuthors lists up to 20 authors as "Family, G. G.", the last after an ampersand. Past
+// 20, the first 19 are followed by an ellipsis and the last.
+func apaAuthors(names []Name) string {
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		if initials := name.Initials(); initials != "" {
+			list = append(list, name.Family+", "+initials)
+		} else {
+			list = append(list, name.Family)
+		}
+	}
+	last := list[len(list)-1]
+	switch {
+	case len(list) == 1:
+		return last
+	case len(list) > 20:
+		return strings.Join(list[:19], ", ") + ", . . . " + last
+	}
+	return strings.Join(list[:len(list)-1], ", ") + ", & " + last
+}
+
+//-----CHICAGO-----
+
+// chicago formats a bibliography entry, for an article:
+//
+//	Turing, Alan. “Computing Machinery and Intelligence.” Mind 59, no. 236 (1950): 433–460.
+//
+// and for the other kinds:
+//
+//	Knuth, Donald E. The Art of Computer Programming. 3rd ed. Vol. 1. Addison-Wesley, 1997.
+func (r *reference) chicago(item Item) {
+	if len(item.Authors) > 0 {
+		r.add(period(chicagoAuthors(item.Authors)) + " ")
+	}
+
+	title := item.titleOrUntitled()
+	if item.Kind == KindArticle {
+		r.add("“" + period(title) + "”")
+		if item.Container != "" {
+			r.add(" ")
+			r.italic(item.Container)
+		}
+		if item.Volume != "" {
+			r.add(" " + item.Volume)
+		}
+		if item.Issue != "" {
+			if item.Volume != "" {
+				r.add(",")
+			}
+			r.add(" no. " + item.Issue)
+		}
+		r.add(" (" + item.year() + ")")
+		if item.Pages != "" {
+			r.add(": " + pageRange(item.Pages))
+		}
+		r.add(".")
+	} else {
+		r.italic(title)
+		if period(title) != title {
+			r.add(".")
+		}
+		if item.Edition != "" {
+			r.add(" " + ordinalEdition(item.Edition) + " ed.")
+		}
+		if item.Volume != "" {
+			r.add(" Vol. " + item.Volume + ".")
+		}
+		if item.Kind == KindReport && item.Number != "" {
+			r.add(" Report " + item.Number + ".")
+		}
+		if item.Version != "" {
+			r.add(" Version " + item.Version + ".")
+		}
+		if publisher := item.publisher(); publisher != "" {
+			r.add(" " + publisher + ",")
+		}
+		r.add(" " + item.year() + ".")
+	}
+
+	if doi := item.doiURL(); doi != "" {
+		r.add(" " + doi + ".")
+	}
+}
+
+// chicagoAuthors inverts the first author's name only, "Turing, Alan, and Alonzo Church".
+// Past ten authors, the first seven are followed by et al.
+func chicagoAuthors(names []Name) string {
+	list := make([]string, 0, len(names))
+	for i, name := range names {
+		if i == 0 {
+			list = append(list, name.Inverted())
+		} else {
+			list = append(list, name.String())
+		}
+	}
+	switch {
+	case len(list) == 1:
+		return list[0]
+	case len(list) == 2:
+		return list[0] + " and " + list[1]
+	case len(list) > 10:
+		return strings.Join(list[:7], ", ") + ", et al."
+	}
+	return strings.Join(list[:len(list)-1], ", ") + ", and " + list[len(list)-1]
+}
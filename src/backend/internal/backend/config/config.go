@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config represents the application configuration
@@ -11,23 +13,65 @@ type Config struct {
 	Database DatabaseConfig
 	Storage  StorageConfig
 	Server   ServerConfig
+	Types    TypesConfig
+	History  HistoryConfig
+	Trash    TrashConfig
+	Convert  ConvertConfig
 }
 
 // DatabaseConfig represents database configuration
 type DatabaseConfig struct {
-	Path string
-	Mode int
+	// Backend is the name the DAO implementation is registered under, "bolt" or "sqlite" built in
+	Backend string
+	Path    string
+	Mode    int
+	// BackupDir is where the database is copied before a schema migration, next to it when empty
+	BackupDir string
+	// Timeout is the deadline on each database operation a request makes, 0 for none
+	Timeout time.Duration
+	// Options is the backend's own settings, a JSON object it decodes itself
+	Options json.RawMessage
 }
 
 // StorageConfig represents storage configuration
 type StorageConfig struct {
 	Path string
+	// Timeout is the deadline on each call to the file service, a whole transfer included, 0 for none
+	Timeout time.Duration
+}
+
+// ConvertConfig represents file conversion configuration
+type ConvertConfig struct {
+	// Timeout is how long pandoc gets for a conversion before it's killed, 0 for no limit
+	Timeout time.Duration
+}
+
+// TypesConfig represents document type configuration
+type TypesConfig struct {
+	// Path is the JSON file schema-defined document types are loaded from and saved to
+	Path string
+}
+
+// HistoryConfig represents document history configuration
+type HistoryConfig struct {
+	// KeepFileVersions is how many file versions of each document stay in storage, 0 keeps all of them
+	KeepFileVersions int
+}
+
+// TrashConfig represents deleted document configuration
+type TrashConfig struct {
+	// RetentionDays is how long deleted documents stay in the trash before they're purged, 0 keeps them
+	RetentionDays int
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
 	RestPort int
 	GrpcPort int
+	// LegacyAPI additionally serves the REST API at its unversioned paths, in the pre-/v1 response shapes
+	LegacyAPI bool
+	// AdminToken is the bearer token /admin requires, the routes aren't served without one
+	AdminToken string
 }
 
 // LoadConfig loads configuration from environment variables
@@ -35,6 +79,7 @@ func LoadConfig() (*Config, error) {
 	config := &Config{}
 
 	// Database configuration
+	config.Database.Backend = getEnv("DB_BACKEND", "bolt")
 	config.Database.Path = getEnv("DB_PATH", "./scriptorium.db")
 
 	dbModeStr := getEnv("DB_MODE", "0600")
@@ -43,9 +88,22 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid DB_MODE: %s", dbModeStr)
 	}
 	config.Database.Mode = int(dbMode)
+	config.Database.BackupDir = getEnv("DB_BACKUP_DIR", "")
+	if config.Database.Timeout, err = getDuration("DB_TIMEOUT", "10s"); err != nil {
+		return nil, err
+	}
+	if options := getEnv("DB_OPTIONS", ""); options != "" {
+		if !json.Valid([]byte(options)) {
+			return nil, fmt.Errorf("invalid DB_OPTIONS: %s", options)
+		}
+		config.Database.Options = json.RawMessage(options)
+	}
 
 	// Storage configuration
 	config.Storage.Path = getEnv("STORAGE_PATH", "./storage")
+	if config.Storage.Timeout, err = getDuration("STORAGE_TIMEOUT", "10m"); err != nil {
+		return nil, err
+	}
 
 	// Server configuration
 	restPortStr := getEnv("REST_PORT", "8080")
@@ -62,9 +120,52 @@ func LoadConfig() (*Config, error) {
 	}
 	config.Server.GrpcPort = grpcPort
 
+	legacyAPIStr := getEnv("LEGACY_API", "false")
+	legacyAPI, err := strconv.ParseBool(legacyAPIStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LEGACY_API: %s", legacyAPIStr)
+	}
+	config.Server.LegacyAPI = legacyAPI
+	config.Server.AdminToken = getEnv("ADMIN_TOKEN", "")
+
+	// Document type configuration
+	config.Types.Path = getEnv("TYPES_PATH", "./document_types.json")
+
+	// History configuration
+	keepFilesStr := getEnv("HISTORY_KEEP_FILES", "5")
+	keepFiles, err := strconv.Atoi(keepFilesStr)
+	if err != nil || keepFiles < 0 {
+		return nil, fmt.Errorf("invalid HISTORY_KEEP_FILES: %s", keepFilesStr)
+	}
+	config.History.KeepFileVersions = keepFiles
+
+	// Trash configuration
+	retentionStr := getEnv("TRASH_RETENTION_DAYS", "30")
+	retention, err := strconv.Atoi(retentionStr)
+	if err != nil || retention < 0 {
+		return nil, fmt.Errorf("invalid TRASH_RETENTION_DAYS: %s", retentionStr)
+	}
+	config.Trash.RetentionDays = retention
+
+	// Conversion configuration
+	if config.Convert.Timeout, err = getDuration("CONVERT_TIMEOUT", "2m"); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// getDuration parses an environment variable as a time.Duration, like "30s" or "2m", with a
+// default value. 0 turns a timeout off.
+func getDuration(key, defaultValue string) (time.Duration, error) {
+	value := getEnv(key, defaultValue)
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return d, nil
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
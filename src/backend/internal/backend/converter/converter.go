@@ -2,6 +2,7 @@ package converter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"scriptorium/internal/backend/dao"
 	"scriptorium/internal/backend/fao"
@@ -16,17 +18,21 @@ import (
 	"github.com/google/uuid"
 )
 
+// Converter runs files through an external converter. the process is killed once ctx is done.
 type Converter interface {
-	ConvertFile(inputPath, outputPath, fromFormat, toFormat string) error
-	ConvertDocumentByUUID(documentUUID string, fromFormat, toFormat string) (string, error)
-	ConvertFileByPath(filePath, fromFormat, toFormat string) (string, error)
-	GetAvailableFormats() (map[string][]string, error)
+	ConvertFile(ctx context.Context, inputPath, outputPath, fromFormat, toFormat string) error
+	ConvertDocumentByUUID(ctx context.Context, documentUUID string, fromFormat, toFormat string) (string, error)
+	ConvertFileByPath(ctx context.Context, filePath, fromFormat, toFormat string) (string, error)
+	GetAvailableFormats(ctx context.Context) (map[string][]string, error)
 }
 
 type PandocConverter struct {
 	pandocPath string
 	dao        dao.DAO
 	fao        fao.FAO
+	// Timeout is the deadline on each conversion, fetching the file and storing the result
+	// included. 0 leaves it to the caller's context.
+	Timeout time.Duration
 }
 
 func NewPandocConverter(pandocPath string) *PandocConverter {
@@ -42,23 +48,41 @@ func NewPandocConverterWithInterfaces(pandocPath string, dao dao.DAO, fao fao.FA
 	}
 }
 
-// run a file through pandoc, returning whatever error the command results in.
-func (pc *PandocConverter) runPandoc(inputPath, outputPath, fromFormat, toFormat string) error {
+// withTimeout bounds ctx by the converter's Timeout, when one is set.
+func (pc *PandocConverter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if pc.Timeout > 0 {
+		return context.WithTimeout(ctx, pc.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// run a file through pandoc, returning whatever error the command results in. pandoc is
+// killed if ctx is done first.
+func (pc *PandocConverter) runPandoc(ctx context.Context, inputPath, outputPath, fromFormat, toFormat string) error {
 	if pc.pandocPath == "" {
 		pc.pandocPath = "pandoc" // Default to system pandoc
 	}
 
-	cmd := exec.Command(pc.pandocPath,
+	cmd := exec.CommandContext(ctx, pc.pandocPath,
 		inputPath,
 		"-f", fromFormat,
 		"-t", toFormat,
 		"-o", outputPath)
 
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		// the kill's "signal: killed" says less than why it was killed
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
 }
 
-func (pc *PandocConverter) ConvertFile(inputPath, outputPath, fromFormat, toFormat string) error {
-	err := pc.runPandoc(inputPath, outputPath, fromFormat, toFormat)
+func (pc *PandocConverter) ConvertFile(ctx context.Context, inputPath, outputPath, fromFormat, toFormat string) error {
+	ctx, cancel := pc.withTimeout(ctx)
+	defer cancel()
+	err := pc.runPandoc(ctx, inputPath, outputPath, fromFormat, toFormat)
 	if err != nil {
 		return err
 	}
@@ -67,10 +91,12 @@ func (pc *PandocConverter) ConvertFile(inputPath, outputPath, fromFormat, toForm
 }
 
 // ConvertDocumentByUUID converts a document by its UUID using DAO and FAO
-func (pc *PandocConverter) ConvertDocumentByUUID(documentUUID string, fromFormat, toFormat string) (string, error) {
+func (pc *PandocConverter) ConvertDocumentByUUID(ctx context.Context, documentUUID string, fromFormat, toFormat string) (string, error) {
 	if pc.dao == nil || pc.fao == nil {
 		return "", fmt.Errorf("DAO and FAO interfaces are required for document conversion")
 	}
+	ctx, cancel := pc.withTimeout(ctx)
+	defer cancel()
 
 	// Parse UUID
 	uuid, err := uuid.Parse(documentUUID)
@@ -79,7 +105,7 @@ func (pc *PandocConverter) ConvertDocumentByUUID(documentUUID string, fromFormat
 	}
 
 	// Get document metadata from DAO
-	rawData, err := pc.dao.ReadRaw(uuid)
+	rawData, err := pc.dao.ReadRaw(ctx, uuid)
 	if err != nil {
 		return "", fmt.Errorf("failed to read document: %w", err)
 	}
@@ -91,7 +117,7 @@ func (pc *PandocConverter) ConvertDocumentByUUID(documentUUID string, fromFormat
 	}
 
 	// Get the file from FAO
-	file, err := pc.fao.GetFile(metadata.Path)
+	file, err := pc.fao.GetFile(ctx, metadata.Path)
 	if err != nil {
 		return "", fmt.Errorf("failed to get file from storage: %w", err)
 	}
@@ -121,7 +147,7 @@ func (pc *PandocConverter) ConvertDocumentByUUID(documentUUID string, fromFormat
 	defer tempOutput.Close()
 
 	// Convert using pandoc
-	if err := pc.runPandoc(tempInput.Name(), tempOutput.Name(), fromFormat, toFormat); err != nil {
+	if err := pc.runPandoc(ctx, tempInput.Name(), tempOutput.Name(), fromFormat, toFormat); err != nil {
 		return "", fmt.Errorf("conversion failed: %w", err)
 	}
 
@@ -133,7 +159,7 @@ func (pc *PandocConverter) ConvertDocumentByUUID(documentUUID string, fromFormat
 
 	// Save converted file to FAO in same location
 	reader := bytes.NewReader(convertedContent)
-	if err := pc.fao.SaveFile(outputPath, reader); err != nil {
+	if err := pc.fao.SaveFile(ctx, outputPath, reader); err != nil {
 		return "", fmt.Errorf("failed to save converted file: %w", err)
 	}
 
@@ -141,13 +167,15 @@ func (pc *PandocConverter) ConvertDocumentByUUID(documentUUID string, fromFormat
 }
 
 // ConvertFileByPath converts a file directly from storage using FAO
-func (pc *PandocConverter) ConvertFileByPath(filePath, fromFormat, toFormat string) (string, error) {
+func (pc *PandocConverter) ConvertFileByPath(ctx context.Context, filePath, fromFormat, toFormat string) (string, error) {
 	if pc.fao == nil {
 		return "", fmt.Errorf("FAO interface is required for file conversion")
 	}
+	ctx, cancel := pc.withTimeout(ctx)
+	defer cancel()
 
 	// Get the file from FAO
-	file, err := pc.fao.GetFile(filePath)
+	file, err := pc.fao.GetFile(ctx, filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to get file from storage: %w", err)
 	}
@@ -177,7 +205,7 @@ func (pc *PandocConverter) ConvertFileByPath(filePath, fromFormat, toFormat stri
 	defer tempOutput.Close()
 
 	// Convert using pandoc
-	if err := pc.runPandoc(tempInput.Name(), tempOutput.Name(), fromFormat, toFormat); err != nil {
+	if err := pc.runPandoc(ctx, tempInput.Name(), tempOutput.Name(), fromFormat, toFormat); err != nil {
 		return "", fmt.Errorf("conversion failed: %w", err)
 	}
 
@@ -189,7 +217,7 @@ func (pc *PandocConverter) ConvertFileByPath(filePath, fromFormat, toFormat stri
 
 	// Save converted file to FAO in same location
 	reader := bytes.NewReader(convertedContent)
-	if err := pc.fao.SaveFile(outputPath, reader); err != nil {
+	if err := pc.fao.SaveFile(ctx, outputPath, reader); err != nil {
 		return "", fmt.Errorf("failed to save converted file: %w", err)
 	}
 
@@ -197,14 +225,17 @@ func (pc *PandocConverter) ConvertFileByPath(filePath, fromFormat, toFormat stri
 }
 
 // GetAvailableFormats returns supported input and output formats from pandoc
-func (pc *PandocConverter) GetAvailableFormats() (map[string][]string, error) {
+func (pc *PandocConverter) GetAvailableFormats(ctx context.Context) (map[string][]string, error) {
+	ctx, cancel := pc.withTimeout(ctx)
+	defer cancel()
+
 	// Run pandoc --list-input-formats and --list-output-formats
-	inputFormats, err := pc.getPandocFormats("--list-input-formats")
+	inputFormats, err := pc.getPandocFormats(ctx, "--list-input-formats")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get input formats: %w", err)
 	}
 
-	outputFormats, err := pc.getPandocFormats("--list-output-formats")
+	outputFormats, err := pc.getPandocFormats(ctx, "--list-output-formats")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get output formats: %w", err)
 	}
@@ -216,12 +247,12 @@ func (pc *PandocConverter) GetAvailableFormats() (map[string][]string, error) {
 }
 
 // getPandocFormats gets the list of supported formats from pandoc
-func (pc *PandocConverter) getPandocFormats(formatFlag string) ([]string, error) {
+func (pc *PandocConverter) getPandocFormats(ctx context.Context, formatFlag string) ([]string, error) {
 	if pc.pandocPath == "" {
 		pc.pandocPath = "pandoc"
 	}
 
-	cmd := exec.Command(pc.pandocPath, formatFlag)
+	cmd := exec.CommandContext(ctx, pc.pandocPath, formatFlag)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pandoc formats: %w", err)
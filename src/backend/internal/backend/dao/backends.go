@@ -0,0 +1,148 @@
+package dao
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+//---------------------------------------------------
+//--------------------BACKENDS-----------------------
+//---------------------------------------------------
+
+// each backend has its own typed options and constructor, NewBoltDao and NewSQLiteDao. Open
+// picks one by name, for callers that go by configuration; a package adding a backend
+// registers it from an init function, the way database/sql drivers do.
+
+// ErrUnknownBackend is returned (wrapped) by Open for a name nothing is registered under.
+var ErrUnknownBackend = errors.New("unknown database backend")
+
+// Config is the configuration every backend is opened from. a backend's OpenFunc maps the
+// common fields onto its own options, and decodes whatever only it has from Options.
+type Config struct {
+	Path string
+	Mode fs.FileMode
+	// Timeout is how long to wait on a lock held by another process, 0 leaving it to the backend
+	Timeout time.Duration
+	// BackupDir is where the database is copied before migrating, next to it when empty
+	BackupDir string
+	// Options is the backend's own section of the configuration, a JSON object it decodes
+	// with DecodeOptions. empty leaves them all at their defaults.
+	Options json.RawMessage
+}
+
+// DecodeOptions decodes Options into v, failing on fields v doesn't have so a misspelt
+// option isn't silently ignored. it leaves v as it is when there are no options.
+func (cfg Config) DecodeOptions(v any) error {
+	if len(bytes.TrimSpace(cfg.Options)) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(cfg.Options))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid database options: %w", err)
+	}
+	return nil
+}
+
+// boltConfigOptions are the options the bolt backend reads from Config.Options.
+type boltConfigOptions struct {
+	// NoGrowSync skips truncating the file when it grows, see bolt.Options
+	NoGrowSync bool `json:"no_grow_sync"`
+	// InitialMmapSize is how much of the file is mapped up front, in bytes
+	InitialMmapSize int `json:"initial_mmap_size"`
+}
+
+// sqliteConfigOptions are the options the sqlite backend reads from Config.Options.
+type sqliteConfigOptions struct {
+	// Pragmas are set on every connection, e.g. {"journal_mode": "wal"}
+	Pragmas map[string]string `json:"pragmas"`
+}
+
+// OpenFunc opens a backend's DAO from Config.
+type OpenFunc func(Config) (DAO, error)
+
+// the built in backends have every capability
+var (
+	_ DAO             = (*BoltDao)(nil)
+	_ HistoryStore    = (*BoltDao)(nil)
+	_ TrashStore      = (*BoltDao)(nil)
+	_ FileJournal     = (*BoltDao)(nil)
+	_ QuarantineStore = (*BoltDao)(nil)
+	_ Snapshotter     = (*BoltDao)(nil)
+	_ DAO             = (*SQLiteDao)(nil)
+	_ HistoryStore    = (*SQLiteDao)(nil)
+	_ TrashStore      = (*SQLiteDao)(nil)
+	_ FileJournal     = (*SQLiteDao)(nil)
+	_ QuarantineStore = (*SQLiteDao)(nil)
+	_ Snapshotter     = (*SQLiteDao)(nil)
+)
+
+var backends = struct {
+	sync.RWMutex
+	open map[string]OpenFunc
+}{open: make(map[string]OpenFunc)}
+
+func init() {
+	RegisterBackend("bolt", func(cfg Config) (DAO, error) {
+		var options boltConfigOptions
+		if err := cfg.DecodeOptions(&options); err != nil {
+			return nil, err
+		}
+		opts := BoltOptions{Path: cfg.Path, Mode: cfg.Mode, BackupDir: cfg.BackupDir}
+		if cfg.Timeout > 0 || options != (boltConfigOptions{}) {
+			opts.Opts = &bolt.Options{
+				Timeout:         cfg.Timeout,
+				NoGrowSync:      options.NoGrowSync,
+				InitialMmapSize: options.InitialMmapSize,
+			}
+		}
+		return NewBoltDao(opts)
+	})
+	RegisterBackend("sqlite", func(cfg Config) (DAO, error) {
+		var options sqliteConfigOptions
+		if err := cfg.DecodeOptions(&options); err != nil {
+			return nil, err
+		}
+		return NewSQLiteDao(SQLiteOptions{Path: cfg.Path, Mode: cfg.Mode, BusyTimeout: cfg.Timeout, Pragmas: options.Pragmas})
+	})
+}
+
+// RegisterBackend makes a backend available to Open under name. it panics if the name is
+// taken or open is nil, as both are programming errors.
+func RegisterBackend(name string, open OpenFunc) {
+	backends.Lock()
+	defer backends.Unlock()
+	if open == nil {
+		panic("dao: RegisterBackend open is nil")
+	}
+	if _, taken := backends.open[name]; taken {
+		panic("dao: RegisterBackend called twice for backend " + name)
+	}
+	backends.open[name] = open
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backends.RLock()
+	defer backends.RUnlock()
+	return sortedKeys(backends.open)
+}
+
+// Open opens the DAO of the backend registered under name.
+func Open(name string, cfg Config) (DAO, error) {
+	backends.RLock()
+	open, found := backends.open[name]
+	backends.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("%w %q, have %s", ErrUnknownBackend, name, strings.Join(Backends(), ", "))
+	}
+	return open(cfg)
+}
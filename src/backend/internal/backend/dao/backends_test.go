@@ -0,0 +1,51 @@
+package dao
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWhenBackendOpenedByNameExpectItsDAO(t *testing.T) {
+	db, err := Open("sqlite", Config{Path: filepath.Join(t.TempDir(), "test.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("error opening: %s", err)
+	}
+	defer db.Disconnect()
+	if _, ok := db.(*SQLiteDao); !ok {
+		t.Errorf("wanted a *SQLiteDao; have %T", db)
+	}
+
+	if _, err := Open("postgres", Config{}); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("wanted ErrUnknownBackend; have %v", err)
+	}
+}
+
+func TestWhenBackendRegisteredTwiceExpectPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("wanted a panic registering bolt again")
+		}
+	}()
+	RegisterBackend("bolt", func(Config) (DAO, error) { return nil, nil })
+}
+
+func TestWhenBackendOptionsGivenExpectThemApplied(t *testing.T) {
+	options := json.RawMessage(`{"pragmas": {"journal_mode": "wal"}}`)
+	db, err := Open("sqlite", Config{Path: filepath.Join(t.TempDir(), "test.db"), Mode: 0600, Options: options})
+	if err != nil {
+		t.Fatalf("error opening: %s", err)
+	}
+	defer db.Disconnect()
+	var mode string
+	if err := db.(*SQLiteDao).db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("wanted journal_mode wal; have %q, %v", mode, err)
+	}
+
+	// a misspelt option fails rather than being ignored
+	options = json.RawMessage(`{"no_grow_snyc": true}`)
+	if _, err := Open("bolt", Config{Path: filepath.Join(t.TempDir(), "test.db"), Mode: 0600, Options: options}); err == nil {
+		t.Error("wanted an error for an unknown bolt option")
+	}
+}
@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//-----------------------BULK------------------------
+//---------------------------------------------------
+
+// a bulk update applies one edit to many records in a single transaction. a document the
+// edit can't be applied to is reported in its result and skipped, the others are written
+// together, each with its own revision.
+
+// BulkEdit changes a copy of a stored record in place. An error leaves the document as it
+// was and is reported in its BulkResult.
+type BulkEdit func(record *Record) error
+
+// BulkResult is what a bulk update did, or would do in a dry run, to one document.
+type BulkResult struct {
+	Uuid string
+	// Record is the record as written, or as stored when nothing changed or Err is set
+	Record  Record
+	Changes []FieldChange
+	// Err is ErrDocumentNotFound, ErrDocumentTrashed or the edit's own error
+	Err error
+}
+
+// Updated is true when the edit changed the document
+func (r BulkResult) Updated() bool {
+	return r.Err == nil && len(r.Changes) > 0
+}
+
+// BulkUpdate applies edit to each of ids in one transaction. Documents the edit leaves
+// unchanged aren't written. With dryRun nothing is written, the results show what would be.
+func (b *BoltDao) BulkUpdate(ctx context.Context, ids []uuid.UUID, edit BulkEdit, change Change, dryRun bool) ([]BulkResult, error) {
+	run := b.update
+	if dryRun {
+		run = b.view
+	}
+
+	var results []BulkResult
+	err := run(ctx, func(tx *bolt.Tx) error {
+		results = make([]BulkResult, 0, len(ids))
+		documents := tx.Bucket([]byte("documents"))
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			result := BulkResult{Uuid: id.String()}
+			prev, err := storedRecord(documents, id.String())
+			switch {
+			case inTrash(tx, id.String()):
+				result.Err = ErrDocumentTrashed
+			case err != nil:
+				result.Err = err
+			case prev == nil:
+				result.Err = ErrDocumentNotFound
+			default:
+				result.Record, result.Changes, result.Err = bulkEdit(*prev, edit)
+			}
+
+			if result.Updated() {
+				if dryRun {
+					result.Record = stampRecord(result.Record, prev, Timestamp())
+				} else if result.Record, err = commitRecord(tx, result.Record, prev, change, 0); err != nil {
+					return err
+				}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error in bulk update: %w", err)
+	}
+	return results, nil
+}
+
+// bulkEdit runs edit on a deep copy of prev, so a failed or partial edit can't touch the
+// stored record, and lists the fields it changed. prev is returned when edit fails.
+func bulkEdit(prev Record, edit BulkEdit) (Record, []FieldChange, error) {
+	data, err := json.Marshal(prev)
+	if err != nil {
+		return prev, nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return prev, nil, err
+	}
+
+	if err := edit(&record); err != nil {
+		return prev, nil, err
+	}
+	// the edit may only change what a client could, the rest is put back
+	record.Uuid, record.DocType = prev.Uuid, prev.DocType
+	record.Path, record.FileType, record.Size, record.Hash = prev.Path, prev.FileType, prev.Size, prev.Hash
+	record.CreatedAt, record.LastUpdated = prev.CreatedAt, prev.LastUpdated
+
+	changes := diffRecords(&prev, record)
+	if len(changes) == 0 {
+		return prev, nil, nil
+	}
+	return record, changes, nil
+}
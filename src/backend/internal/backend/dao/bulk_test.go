@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// createBulkNotes stores a note per title, returning their UUIDs in order
+func createBulkNotes(t *testing.T, db fullDAO, titles ...string) []uuid.UUID {
+	t.Helper()
+	ids := make([]uuid.UUID, 0, len(titles))
+	for _, title := range titles {
+		id := uuid.New()
+		doc := &Notes{Content: title}
+		doc.SetMetaData(MetaData{Title: title, DocType: "Notes", Uuid: id.String(), Tags: []string{"inbox"}})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func setDewey(code string) BulkEdit {
+	return func(record *Record) error {
+		record.DeweyDecimal = code
+		return nil
+	}
+}
+
+func TestWhenBulkUpdateExpectEveryDocumentWrittenWithRevision(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		ids := createBulkNotes(t, db, "Calculus", "Topology")
+
+		results, err := db.BulkUpdate(context.Background(), ids, setDewey("510"), Change{User: "ada", Action: ActionUpdate}, false)
+		if err != nil {
+			t.Fatalf("error in bulk update: %s", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("wanted a result per document; have %+v", results)
+		}
+		for i, result := range results {
+			if !result.Updated() || result.Uuid != ids[i].String() || result.Record.DeweyDecimal != "510" {
+				t.Errorf("unexpected result %d: %+v", i, result)
+			}
+			if len(result.Changes) != 1 || result.Changes[0].Field != "DeweyDecimal" {
+				t.Errorf("wanted only DeweyDecimal changed; have %+v", result.Changes)
+			}
+
+			history, _ := db.History(context.Background(), ids[i])
+			if len(history) != 2 || history[1].User != "ada" || history[1].Record.DeweyDecimal != "510" {
+				t.Errorf("wanted a second revision by ada; have %+v", history)
+			}
+		}
+	})
+}
+
+func TestWhenBulkUpdateDryRunExpectNothingWritten(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		ids := createBulkNotes(t, db, "Calculus")
+
+		results, err := db.BulkUpdate(context.Background(), ids, setDewey("510"), Change{Action: ActionUpdate}, true)
+		if err != nil {
+			t.Fatalf("error in bulk update: %s", err)
+		}
+		if len(results) != 1 || !results[0].Updated() || results[0].Record.DeweyDecimal != "510" {
+			t.Fatalf("wanted the previewed change; have %+v", results)
+		}
+
+		var doc Document = &Notes{}
+		doc, _ = db.Read(context.Background(), &doc, ids[0])
+		if doc.GetMetaData().DeweyDecimal != "" {
+			t.Errorf("wanted the dry run to leave the document alone; have %+v", doc.GetMetaData())
+		}
+		if history, _ := db.History(context.Background(), ids[0]); len(history) != 1 {
+			t.Errorf("wanted no revision from a dry run; have %d", len(history))
+		}
+	})
+}
+
+func TestWhenBulkUpdateFailsForSomeExpectOthersWritten(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		ids := createBulkNotes(t, db, "Calculus", "Binned", "Refused", "Same")
+		if _, err := db.Trash(context.Background(), ids[1], "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+		missing := uuid.New()
+		refused := errors.New("refused")
+
+		edit := func(record *Record) error {
+			switch record.Title {
+			case "Refused":
+				return refused
+			case "Same":
+				return nil
+			}
+			record.Tags = slices.DeleteFunc(record.Tags, func(tag string) bool { return tag == "inbox" })
+			// fields owned by the server are put back
+			record.Path = "elsewhere.txt"
+			return nil
+		}
+		results, err := db.BulkUpdate(context.Background(), append(ids, missing), edit, Change{Action: ActionUpdate}, false)
+		if err != nil {
+			t.Fatalf("error in bulk update: %s", err)
+		}
+		if len(results) != 5 {
+			t.Fatalf("wanted a result per UUID; have %+v", results)
+		}
+
+		if !results[0].Updated() || len(results[0].Record.Tags) != 0 || results[0].Record.Path != "" {
+			t.Errorf("wanted the tag removed and nothing else; have %+v", results[0])
+		}
+		if !errors.Is(results[1].Err, ErrDocumentTrashed) {
+			t.Errorf("wanted ErrDocumentTrashed; have %v", results[1].Err)
+		}
+		if !errors.Is(results[2].Err, refused) || results[2].Record.Title != "Refused" {
+			t.Errorf("wanted the edit's error and the stored record; have %+v", results[2])
+		}
+		if results[3].Updated() || results[3].Err != nil {
+			t.Errorf("wanted an unchanged document left alone; have %+v", results[3])
+		}
+		if !errors.Is(results[4].Err, ErrDocumentNotFound) {
+			t.Errorf("wanted ErrDocumentNotFound; have %v", results[4].Err)
+		}
+
+		if history, _ := db.History(context.Background(), ids[3]); len(history) != 1 {
+			t.Errorf("wanted no revision for an unchanged document; have %d", len(history))
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// the tests run through forEachBackend make up the DAO conformance suite, every
+// registered backend with all the capabilities has to pass them
+
+// fullDAO is a backend with every optional capability
+type fullDAO interface {
+	DAO
+	HistoryStore
+	TrashStore
+	FileJournal
+	QuarantineStore
+	Snapshotter
+}
+
+// forEachBackend runs test as a subtest against a fresh database of every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, db fullDAO)) {
+	for _, name := range Backends() {
+		t.Run(name, func(t *testing.T) {
+			db, err := Open(name, Config{Path: filepath.Join(t.TempDir(), "test.db"), Mode: 0600})
+			if err != nil {
+				t.Fatalf("error initialising DB: %s", err)
+			}
+			defer db.Disconnect()
+			full, ok := db.(fullDAO)
+			if !ok {
+				t.Skipf("%s backend lacks a capability the suite needs", name)
+			}
+			test(t, full)
+		})
+	}
+}
+
+// putCorruptRecord stores a record under key in the documents or the trash that can't be
+// decoded. For Bolt it's truncated JSON in the documents, and valid JSON that isn't a record
+// in the trash.
+func putCorruptRecord(t *testing.T, db DAO, set RecordSet, key string) {
+	var err error
+	switch db := db.(type) {
+	case *BoltDao:
+		value := `{"Title": "Trunc`
+		if set == RecordsTrashed {
+			value = `{"Title": 42}`
+		}
+		err = db.db.Update(func(tx *bolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(set))
+			if err != nil {
+				return err
+			}
+			return bucket.Put([]byte(key), []byte(value))
+		})
+	case *SQLiteDao:
+		var deletedAt any
+		if set == RecordsTrashed {
+			deletedAt = Timestamp()
+		}
+		_, err = db.db.Exec(`INSERT INTO documents (uuid, title, tags, deleted_at) VALUES (?, 'Trunc', '["trunc', ?)`, key, deletedAt)
+	default:
+		t.Fatalf("no way to corrupt a %T record", db)
+	}
+	if err != nil {
+		t.Fatalf("error writing corrupt record: %s", err)
+	}
+}
@@ -0,0 +1,301 @@
+package dao
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+//---------------------------------------------------
+//--------------------VALIDATION---------------------
+//---------------------------------------------------
+
+// Validator is implemented by documents that check their own fields. BoltDao doesn't call
+// it, callers validate before Create/Update so they can report the field errors.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError is a single invalid field, Field is the JSON name as sent by clients.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError collects every invalid field of a document.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		problems = append(problems, field.Field+": "+field.Message)
+	}
+	return "invalid document: " + strings.Join(problems, ", ")
+}
+
+// add records a problem, to be returned by err once validation is done
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns nil when nothing was added, so Validate can end in `return problems.err()`
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidateDocument runs the document's own validation, if it has any.
+func ValidateDocument(doc Document) error {
+	if v, ok := doc.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+var (
+	doiPattern   = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+	pagesPattern = regexp.MustCompile(`^\d+(\s*[-–]\s*\d+)?$`)
+)
+
+// validISBN checks the length and check digit of an ISBN-10 or ISBN-13, ignoring hyphens and spaces
+func validISBN(isbn string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(isbn)
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, r := range digits {
+			var d int
+			switch {
+			case r >= '0' && r <= '9':
+				d = int(r - '0')
+			case (r == 'X' || r == 'x') && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += d * (10 - i)
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, r := range digits {
+			if r < '0' || r > '9' {
+				return false
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += int(r-'0') * weight
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+//---------------------------------------------------
+//--------------------DOCUMENT-TYPES-----------------
+//---------------------------------------------------
+
+// baseDocument carries the MetaData shared by every concrete type. The type specific
+// fields of the embedding struct are what gets persisted as the record's payload.
+type baseDocument struct {
+	Metadata MetaData `json:"-"`
+}
+
+func (b baseDocument) GetTitle() string {
+	return b.Metadata.Title
+}
+
+func (b *baseDocument) SetTitle(title string) error {
+	b.Metadata.Title = title
+	return nil
+}
+
+func (b baseDocument) GetMetaData() MetaData {
+	return b.Metadata
+}
+
+func (b *baseDocument) SetMetaData(meta MetaData) error {
+	b.Metadata = meta
+	return nil
+}
+
+func (b *baseDocument) GetID() string {
+	return b.Metadata.Uuid
+}
+
+var bookFormats = []string{"hardcover", "paperback", "ebook", "audiobook"}
+
+// Book uses the ISBN, Publisher and Edition from MetaData, adding series information.
+type Book struct {
+	baseDocument
+	Series string `json:",omitempty"`
+	Volume string `json:",omitempty"`
+	// Format is one of hardcover, paperback, ebook or audiobook
+	Format string `json:",omitempty"`
+}
+
+func (b *Book) Validate() error {
+	var problems ValidationError
+	if b.Metadata.ISBN != "" && !validISBN(b.Metadata.ISBN) {
+		problems.add("ISBN", "not a valid ISBN-10 or ISBN-13")
+	}
+	if b.Format != "" && !slices.Contains(bookFormats, b.Format) {
+		problems.add("Format", "must be one of %s", strings.Join(bookFormats, ", "))
+	}
+	if b.Volume != "" && b.Series == "" {
+		problems.add("Series", "required when Volume is set")
+	}
+	return problems.err()
+}
+
+func (b *Book) Schema() TypeSchema {
+	return TypeSchema{
+		Description: "Books, the ISBN, publisher and edition are part of the metadata",
+		Type:        "object",
+		Properties: map[string]PropertySchema{
+			"Series": {Type: "string"},
+			"Volume": {Type: "string", Description: "volume within the series"},
+			"Format": {Type: "string", Enum: stringEnum(bookFormats)},
+		},
+		RequiredMetaData: []string{"Author"},
+	}
+}
+
+// Article is a journal or magazine article, the DOI is kept in MetaData.
+type Article struct {
+	baseDocument
+	Journal string
+	Volume  string `json:",omitempty"`
+	Issue   string `json:",omitempty"`
+	// Pages is a single page or a range, e.g. "112-130"
+	Pages string `json:",omitempty"`
+}
+
+func (a *Article) Validate() error {
+	var problems ValidationError
+	if strings.TrimSpace(a.Journal) == "" {
+		problems.add("Journal", "required")
+	}
+	if a.Metadata.DOI != "" && !doiPattern.MatchString(a.Metadata.DOI) {
+		problems.add("DOI", "must look like 10.1234/suffix")
+	}
+	if a.Pages != "" && !pagesPattern.MatchString(a.Pages) {
+		problems.add("Pages", "must be a page number or range, e.g. 112-130")
+	}
+	if a.Issue != "" && a.Volume == "" {
+		problems.add("Volume", "required when Issue is set")
+	}
+	return problems.err()
+}
+
+func (a *Article) Schema() TypeSchema {
+	return TypeSchema{
+		Description: "Journal and magazine articles, the DOI is part of the metadata",
+		Type:        "object",
+		Properties: map[string]PropertySchema{
+			"Journal": {Type: "string"},
+			"Volume":  {Type: "string"},
+			"Issue":   {Type: "string"},
+			"Pages":   {Type: "string", Description: "a page or range, e.g. 112-130", Pattern: pagesPattern.String()},
+		},
+		Required:         []string{"Journal"},
+		RequiredMetaData: []string{"Author"},
+	}
+}
+
+// Report is a technical or institutional report.
+type Report struct {
+	baseDocument
+	Institution  string
+	ReportNumber string `json:",omitempty"`
+}
+
+func (r *Report) Validate() error {
+	var problems ValidationError
+	if strings.TrimSpace(r.Institution) == "" {
+		problems.add("Institution", "required")
+	}
+	return problems.err()
+}
+
+func (r *Report) Schema() TypeSchema {
+	return TypeSchema{
+		Type: "object",
+		Properties: map[string]PropertySchema{
+			"Institution":  {Type: "string"},
+			"ReportNumber": {Type: "string", Title: "Report Number"},
+		},
+		Required: []string{"Institution"},
+	}
+}
+
+// Manual documents a product, optionally for a specific version of it.
+type Manual struct {
+	baseDocument
+	Product string
+	Version string `json:",omitempty"`
+}
+
+func (m *Manual) Validate() error {
+	var problems ValidationError
+	if strings.TrimSpace(m.Product) == "" {
+		problems.add("Product", "required")
+	}
+	return problems.err()
+}
+
+func (m *Manual) Schema() TypeSchema {
+	return TypeSchema{
+		Type: "object",
+		Properties: map[string]PropertySchema{
+			"Product": {Type: "string"},
+			"Version": {Type: "string"},
+		},
+		Required: []string{"Product"},
+	}
+}
+
+var referenceKinds = []string{"dictionary", "encyclopedia", "atlas", "handbook", "thesaurus", "almanac", "other"}
+
+// Reference covers dictionaries, encyclopedias and similar works consulted rather than read.
+type Reference struct {
+	baseDocument
+	// Kind is one of dictionary, encyclopedia, atlas, handbook, thesaurus, almanac or other
+	Kind   string
+	Volume string `json:",omitempty"`
+}
+
+func (r *Reference) Validate() error {
+	var problems ValidationError
+	if !slices.Contains(referenceKinds, r.Kind) {
+		problems.add("Kind", "must be one of %s", strings.Join(referenceKinds, ", "))
+	}
+	return problems.err()
+}
+
+func (r *Reference) Schema() TypeSchema {
+	return TypeSchema{
+		Type: "object",
+		Properties: map[string]PropertySchema{
+			"Kind":   {Type: "string", Enum: stringEnum(referenceKinds)},
+			"Volume": {Type: "string"},
+		},
+		Required: []string{"Kind"},
+	}
+}
+
+func stringEnum(values []string) []any {
+	enum := make([]any, 0, len(values))
+	for _, v := range values {
+		enum = append(enum, v)
+	}
+	return enum
+}
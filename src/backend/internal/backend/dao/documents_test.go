@@ -0,0 +1,81 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWhenValidISBNExpectAccepted(t *testing.T) {
+	for _, isbn := range []string{"0-13-110362-8", "978-0262033848", "080442957X"} {
+		if !validISBN(isbn) {
+			t.Errorf("wanted %s to be valid", isbn)
+		}
+	}
+	for _, isbn := range []string{"0-13-110362-7", "978-0262033849", "12345", "ABCDEFGHIJ"} {
+		if validISBN(isbn) {
+			t.Errorf("wanted %s to be invalid", isbn)
+		}
+	}
+}
+
+func TestWhenInvalidDocumentExpectFieldErrors(t *testing.T) {
+	article := &Article{Pages: "twelve", Issue: "3"}
+	article.Metadata.DOI = "doi:nope"
+
+	err := ValidateDocument(article)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("wanted a ValidationError; have %v", err)
+	}
+
+	want := []string{"Journal", "DOI", "Pages", "Volume"}
+	if len(invalid.Fields) != len(want) {
+		t.Fatalf("wanted %d field errors; have %+v", len(want), invalid.Fields)
+	}
+	for i, field := range want {
+		if invalid.Fields[i].Field != field {
+			t.Errorf("wanted field %d to be %s; have %s", i, field, invalid.Fields[i].Field)
+		}
+	}
+
+	if err := ValidateDocument(&Notes{}); err != nil {
+		t.Errorf("wanted Notes to have no validation; have %v", err)
+	}
+}
+
+func TestWhenReadTypedDocumentExpectPayload(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		article := &Article{Journal: "Nature", Volume: "521", Issue: "7553", Pages: "436-444"}
+		article.SetMetaData(MetaData{Title: "Deep learning", DocType: "Article", DOI: "10.1038/nature14539", Uuid: uuid.New().String()})
+		if err := ValidateDocument(article); err != nil {
+			t.Fatalf("wanted a valid article; have %v", err)
+		}
+		if err := db.Create(context.Background(), article); err != nil {
+			t.Fatalf("error inserting document: %s", err)
+		}
+
+		id, _ := uuid.Parse(article.GetID())
+		var doc Document = &Article{}
+		got, err := db.Read(context.Background(), &doc, id)
+		if err != nil {
+			t.Fatalf("error reading document: %s", err)
+		}
+
+		read, ok := got.(*Article)
+		if !ok {
+			t.Fatalf("wanted *Article; have %T", got)
+		}
+		if read.Journal != "Nature" || read.Pages != "436-444" || read.GetMetaData().DOI != "10.1038/nature14539" {
+			t.Errorf("payload not restored: %+v", read)
+		}
+
+		// the payload is stored beside the metadata, scans still see the plain fields
+		metas, err := db.SearchByKeyValue(context.Background(), "DOI", "10.1038/nature14539")
+		if err != nil || len(metas) != 1 {
+			t.Errorf("wanted the article from a metadata search; have %d results, err %v", len(metas), err)
+		}
+	})
+}
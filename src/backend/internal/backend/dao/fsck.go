@@ -0,0 +1,112 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//-----------------------FSCK------------------------
+//---------------------------------------------------
+
+// RawRecord is a record as found by ScanRecords. Err is set when the stored value couldn't
+// be decoded, Raw then holds it as stored.
+type RawRecord struct {
+	Set    RecordSet
+	Key    string
+	Record Record
+	Raw    []byte
+	Err    error
+}
+
+// ScanRecords returns every current and trashed record. Unlike the search scans a value
+// that can't be decoded doesn't fail the scan, it's returned with Err set.
+func (b *BoltDao) ScanRecords(ctx context.Context) ([]RawRecord, error) {
+	var records []RawRecord
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		for _, set := range []RecordSet{RecordsCurrent, RecordsTrashed} {
+			bucket := tx.Bucket([]byte(set))
+			if bucket == nil {
+				continue
+			}
+			err := bucket.ForEach(func(k, v []byte) error {
+				raw := RawRecord{Set: set, Key: string(k)}
+				var err error
+				if set == RecordsTrashed {
+					var trashed TrashedRecord
+					err = json.Unmarshal(v, &trashed)
+					raw.Record = trashed.Record
+				} else {
+					err = json.Unmarshal(v, &raw.Record)
+				}
+				if err != nil {
+					raw.Err = err
+					raw.Raw = slices.Clone(v)
+				}
+				records = append(records, raw)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning records: %w", err)
+	}
+	return records, nil
+}
+
+// RepairRecord overwrites a current or trashed record with a fixed up copy, recorded as a
+// "repair" revision by user.
+func (b *BoltDao) RepairRecord(ctx context.Context, set RecordSet, record Record, user string) error {
+	id, err := uuid.Parse(record.Uuid)
+	if err != nil {
+		return fmt.Errorf("could not repair document: %w", err)
+	}
+
+	err = b.update(ctx, func(tx *bolt.Tx) error {
+		if set == RecordsCurrent {
+			prev, err := storedRecord(tx.Bucket([]byte(RecordsCurrent)), id.String())
+			if err != nil {
+				return err
+			}
+			if prev == nil {
+				return ErrDocumentNotFound
+			}
+			_, err = commitRecord(tx, record, prev, Change{User: user, Action: ActionRepair}, 0)
+			return err
+		}
+
+		// a trashed record keeps its deletion details, only the record itself changes
+		trash := tx.Bucket([]byte(RecordsTrashed))
+		trashed, err := trashedRecord(trash, id.String())
+		if err != nil {
+			return err
+		}
+		if trashed == nil {
+			return ErrDocumentNotFound
+		}
+		prev := trashed.Record
+		trashed.Record = record
+		data, err := json.Marshal(trashed)
+		if err != nil {
+			return err
+		}
+		if err := trash.Put([]byte(id.String()), data); err != nil {
+			return err
+		}
+		rev := Revision{Timestamp: Timestamp(), User: user, Action: ActionRepair, Changes: diffRecords(&prev, record), Record: record}
+		return appendRevision(tx, id, rev)
+	})
+	if err != nil {
+		return fmt.Errorf("could not repair document: %w", err)
+	}
+	return nil
+}
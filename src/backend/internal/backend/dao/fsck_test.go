@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWhenRecordCorruptExpectScannedAndQuarantined(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Fine", DocType: "Notes", Uuid: uuid.NewString()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		bad := uuid.NewString()
+		putCorruptRecord(t, db, RecordsCurrent, bad)
+
+		records, err := db.ScanRecords(context.Background())
+		if err != nil {
+			t.Fatalf("error scanning records: %s", err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("wanted both records scanned; have %d", len(records))
+		}
+		for _, raw := range records {
+			if (raw.Key == bad) != (raw.Err != nil) {
+				t.Errorf("wanted only %s flagged; have %+v", bad, raw)
+			}
+		}
+
+		if err := db.Quarantine(context.Background(), RecordsCurrent, bad, "truncated"); err != nil {
+			t.Fatalf("error quarantining record: %s", err)
+		}
+		if records, _ := db.ScanRecords(context.Background()); len(records) != 1 {
+			t.Errorf("wanted the corrupt record out of the documents; have %+v", records)
+		}
+		quarantined, _ := db.Quarantined(context.Background())
+		if len(quarantined) != 1 || quarantined[0].Set != RecordsCurrent || quarantined[0].Key != bad || len(quarantined[0].Raw) == 0 {
+			t.Errorf("wanted the raw value kept in quarantine; have %+v", quarantined)
+		}
+	})
+}
+
+func TestWhenRecordRepairedExpectRevision(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		current, trashed := uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{current, trashed} {
+			doc := &Notes{}
+			doc.SetMetaData(MetaData{Title: "Scan", DocType: "Notes", Uuid: id.String(), Path: "gone.pdf", Size: 10})
+			if err := db.Create(context.Background(), doc); err != nil {
+				t.Fatalf("error creating document: %s", err)
+			}
+		}
+		if _, err := db.Trash(context.Background(), trashed, "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+
+		records, _ := db.ScanRecords(context.Background())
+		for _, raw := range records {
+			record := raw.Record
+			record.Path, record.Size = "", 0
+			if err := db.RepairRecord(context.Background(), raw.Set, record, "fsck"); err != nil {
+				t.Fatalf("error repairing %s record: %s", raw.Set, err)
+			}
+		}
+
+		if raw, _ := db.ReadRaw(context.Background(), current); raw == nil {
+			t.Fatal("wanted the current record still readable")
+		}
+		stillTrashed, err := db.ReadTrashed(context.Background(), trashed)
+		if err != nil || stillTrashed.Path != "" || stillTrashed.DeletedBy != "ada" {
+			t.Errorf("wanted the trashed record repaired in the trash; have %+v, %v", stillTrashed, err)
+		}
+		for _, id := range []uuid.UUID{current, trashed} {
+			revisions, _ := db.History(context.Background(), id)
+			last := revisions[len(revisions)-1]
+			if last.Action != ActionRepair || last.User != "fsck" || last.Record.Path != "" {
+				t.Errorf("wanted a repair revision for %s; have %+v", id, last)
+			}
+		}
+	})
+}
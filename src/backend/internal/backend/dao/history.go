@@ -0,0 +1,492 @@
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//---------------------HISTORY-----------------------
+//---------------------------------------------------
+
+// every write appends a revision to the document's log in the "revisions" bucket, in the
+// same transaction as the record itself. revisions are never rewritten, other than to mark
+// a file version as pruned.
+
+var (
+	// ErrRevisionNotFound is returned (wrapped) when a document has no revision with the given number.
+	ErrRevisionNotFound = errors.New("revision not found")
+	// ErrFilePruned is returned (wrapped) when restoring a revision whose file has been removed by retention.
+	ErrFilePruned = errors.New("file version has been pruned")
+)
+
+// the Action of a revision
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionFile    = "file"
+	ActionRestore = "restore"
+	ActionRepair  = "repair"
+)
+
+// Change describes a write: who made it and what kind of write it was.
+type Change struct {
+	User   string
+	Action string
+}
+
+// FieldChange is one field that differs between a revision and the one before it. Field is
+// the MetaData name, or the type specific field's name, as sent by clients.
+type FieldChange struct {
+	Field string
+	Old   any `json:",omitempty"`
+	New   any `json:",omitempty"`
+}
+
+// FileVersion is a stored file a revision pointed the document at.
+type FileVersion struct {
+	Path     string
+	FileType string
+	Size     int64
+	Hash     string `json:",omitempty"`
+	// Pruned is set once retention has removed the file from storage
+	Pruned bool `json:",omitempty"`
+}
+
+// Revision is one entry of a document's history, with the full record as it was written so
+// it can be restored.
+type Revision struct {
+	Number    int
+	Timestamp string
+	User      string
+	Action    string
+	// RestoredFrom is the revision a restore went back to
+	RestoredFrom int           `json:",omitempty"`
+	Changes      []FieldChange `json:",omitempty"`
+	// File is set when the revision stored a new file, or restored an old one
+	File   *FileVersion `json:",omitempty"`
+	Record Record
+}
+
+// Save writes the document and appends a revision for the change. CreatedAt is kept from
+// the stored record, or stamped when there isn't one, and LastUpdated is always stamped.
+// The stamped MetaData is set back on doc.
+func (b *BoltDao) Save(ctx context.Context, doc Document, change Change) error {
+	record, err := NewRecord(doc)
+	if err != nil {
+		return fmt.Errorf("could not save document: %v", err)
+	}
+
+	err = b.update(ctx, func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("documents"))
+		if err != nil {
+			return fmt.Errorf("could not create documents bucket: %v", err)
+		}
+		if inTrash(tx, record.Uuid) {
+			return ErrDocumentTrashed
+		}
+		prev, err := storedRecord(bucket, record.Uuid)
+		if err != nil {
+			return err
+		}
+		record, err = commitRecord(tx, record, prev, change, 0)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not save document: %w", err)
+	}
+	return doc.SetMetaData(record.MetaData)
+}
+
+// ReplaceFile points an existing document at a newly stored file. The old file is left in
+// storage, it stays reachable through the history until it's pruned.
+func (b *BoltDao) ReplaceFile(ctx context.Context, id uuid.UUID, file FileVersion, user string) (Record, error) {
+	var record Record
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		prev, err := storedRecord(tx.Bucket([]byte("documents")), id.String())
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return ErrDocumentNotFound
+		}
+
+		record = *prev
+		record.Path = file.Path
+		record.FileType = file.FileType
+		record.Size = file.Size
+		record.Hash = file.Hash
+		record, err = commitRecord(tx, record, prev, Change{User: user, Action: ActionFile}, 0)
+		return err
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("could not replace file: %w", err)
+	}
+	return record, nil
+}
+
+// Restore makes a past revision's record current again, as a new revision. Restoring a
+// revision whose file has since been pruned fails with ErrFilePruned.
+func (b *BoltDao) Restore(ctx context.Context, id uuid.UUID, number int, user string) (Record, error) {
+	var record Record
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		if inTrash(tx, id.String()) {
+			return ErrDocumentTrashed
+		}
+		revisions := revisionBucket(tx, id, false)
+		if revisions == nil {
+			return ErrRevisionNotFound
+		}
+		target, err := getRevision(revisions, number)
+		if err != nil {
+			return err
+		}
+		if target.Record.Path != "" && filePruned(revisions, target.Record.Path) {
+			return ErrFilePruned
+		}
+
+		bucket, err := tx.CreateBucketIfNotExists([]byte("documents"))
+		if err != nil {
+			return fmt.Errorf("could not create documents bucket: %v", err)
+		}
+		prev, err := storedRecord(bucket, id.String())
+		if err != nil {
+			return err
+		}
+		record, err = commitRecord(tx, target.Record, prev, Change{User: user, Action: ActionRestore}, number)
+		return err
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("could not restore revision %d: %w", number, err)
+	}
+	return record, nil
+}
+
+// History returns every revision of a document, oldest first.
+func (b *BoltDao) History(ctx context.Context, id uuid.UUID) ([]Revision, error) {
+	var history []Revision
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		revisions := revisionBucket(tx, id, false)
+		if revisions == nil {
+			return nil
+		}
+		return revisions.ForEach(func(_, v []byte) error {
+			var rev Revision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return fmt.Errorf("error unmarshaling revision: %v", err)
+			}
+			history = append(history, rev)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return history, nil
+}
+
+// PruneFiles marks all but the keep most recent file versions of a document as pruned and
+// returns the paths no other document references, journaled for the caller to remove from
+// storage. The current file always counts as one of the kept versions. keep <= 0 keeps
+// everything.
+func (b *BoltDao) PruneFiles(ctx context.Context, id uuid.UUID, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	var pruned []string
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		revisions := revisionBucket(tx, id, false)
+		if revisions == nil {
+			return nil
+		}
+		current, err := storedRecord(tx.Bucket([]byte("documents")), id.String())
+		if err != nil {
+			return err
+		}
+
+		kept := map[string]bool{}
+		if current != nil && current.Path != "" {
+			kept[current.Path] = true
+		}
+
+		// newest first, so the versions past keep are the oldest ones
+		updates := map[string][]byte{}
+		c := revisions.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var rev Revision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return fmt.Errorf("error unmarshaling revision: %v", err)
+			}
+			if rev.File == nil || rev.File.Pruned {
+				continue
+			}
+			path := rev.File.Path
+			if kept[path] || len(kept) < keep {
+				kept[path] = true
+				continue
+			}
+
+			rev.File.Pruned = true
+			data, err := json.Marshal(rev)
+			if err != nil {
+				return err
+			}
+			updates[string(k)] = data
+			if !slices.Contains(pruned, path) {
+				pruned = append(pruned, path)
+			}
+		}
+
+		// written after the scan, bolt doesn't allow modifying a bucket mid-iteration
+		for k, v := range updates {
+			if err := revisions.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		if pruned, err = unreferencedFiles(tx, pruned); err != nil {
+			return err
+		}
+		return journalDeletes(tx, id.String(), pruned)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pruning file versions: %w", err)
+	}
+	return pruned, nil
+}
+
+// commitRecord stamps and stores record, then appends its revision. prev is the record
+// being replaced, nil for a new document.
+func commitRecord(tx *bolt.Tx, record Record, prev *Record, change Change, restoredFrom int) (Record, error) {
+	now := Timestamp()
+	record = stampRecord(record, prev, now)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, err
+	}
+	if err := tx.Bucket([]byte("documents")).Put([]byte(record.Uuid), data); err != nil {
+		return Record{}, err
+	}
+
+	rev := newRevision(record, prev, change, restoredFrom, now)
+	return record, appendRevision(tx, uuid.MustParse(record.Uuid), rev)
+}
+
+// stampRecord keeps CreatedAt from prev, or stamps it for a new document, and stamps LastUpdated
+func stampRecord(record Record, prev *Record, now string) Record {
+	if prev != nil {
+		record.CreatedAt = prev.CreatedAt
+	} else {
+		record.CreatedAt = now
+	}
+	record.LastUpdated = now
+	return record
+}
+
+// newRevision is the revision for writing record over prev, numbered once it's appended
+func newRevision(record Record, prev *Record, change Change, restoredFrom int, now string) Revision {
+	rev := Revision{
+		Timestamp:    now,
+		User:         change.User,
+		Action:       change.Action,
+		RestoredFrom: restoredFrom,
+		Changes:      diffRecords(prev, record),
+		Record:       record,
+	}
+	if record.Path != "" && (prev == nil || prev.Path != record.Path) {
+		rev.File = &FileVersion{Path: record.Path, FileType: record.FileType, Size: record.Size, Hash: record.Hash}
+	}
+	return rev
+}
+
+func appendRevision(tx *bolt.Tx, id uuid.UUID, rev Revision) error {
+	revisions := revisionBucket(tx, id, true)
+	if revisions == nil {
+		return fmt.Errorf("could not create revisions bucket for %s", id)
+	}
+
+	seq, err := revisions.NextSequence()
+	if err != nil {
+		return err
+	}
+	rev.Number = int(seq)
+
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	return revisions.Put(revisionKey(rev.Number), data)
+}
+
+// revisionBucket returns the document's bucket nested under "revisions", nil when it
+// doesn't exist and create isn't set (or the transaction is read only).
+func revisionBucket(tx *bolt.Tx, id uuid.UUID, create bool) *bolt.Bucket {
+	if !create {
+		root := tx.Bucket([]byte("revisions"))
+		if root == nil {
+			return nil
+		}
+		return root.Bucket([]byte(id.String()))
+	}
+
+	root, err := tx.CreateBucketIfNotExists([]byte("revisions"))
+	if err != nil {
+		return nil
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte(id.String()))
+	if err != nil {
+		return nil
+	}
+	return bucket
+}
+
+// deleteHistory removes a document's revisions, along with the record itself
+func deleteHistory(tx *bolt.Tx, id uuid.UUID) error {
+	root := tx.Bucket([]byte("revisions"))
+	if root == nil || root.Bucket([]byte(id.String())) == nil {
+		return nil
+	}
+	return root.DeleteBucket([]byte(id.String()))
+}
+
+// revision keys are big endian so the bucket iterates in revision order
+func revisionKey(number int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(number))
+	return key
+}
+
+func getRevision(revisions *bolt.Bucket, number int) (Revision, error) {
+	var rev Revision
+	data := revisions.Get(revisionKey(number))
+	if data == nil {
+		return rev, ErrRevisionNotFound
+	}
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return rev, fmt.Errorf("error unmarshaling revision: %v", err)
+	}
+	return rev, nil
+}
+
+func filePruned(revisions *bolt.Bucket, path string) bool {
+	pruned := false
+	revisions.ForEach(func(_, v []byte) error {
+		var rev Revision
+		if json.Unmarshal(v, &rev) == nil && rev.File != nil && rev.File.Path == path && rev.File.Pruned {
+			pruned = true
+		}
+		return nil
+	})
+	return pruned
+}
+
+func storedRecord(bucket *bolt.Bucket, id string) (*Record, error) {
+	if bucket == nil {
+		return nil, nil
+	}
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("error reading stored document: %v", err)
+	}
+	return &record, nil
+}
+
+// the timestamps change on every write, listing them would only be noise
+var unversionedFields = []string{"CreatedAt", "LastUpdated"}
+
+// diffRecords lists the metadata and type specific fields that differ between two records,
+// sorted by name. prev is nil for a new document, every set field is then a change.
+func diffRecords(prev *Record, next Record) []FieldChange {
+	before := map[string]any{}
+	if prev != nil {
+		before = flattenRecord(*prev)
+	}
+	after := flattenRecord(next)
+
+	names := map[string]any{}
+	for name := range before {
+		names[name] = nil
+	}
+	for name := range after {
+		names[name] = nil
+	}
+
+	var changes []FieldChange
+	for _, name := range sortedKeys(names) {
+		if slices.Contains(unversionedFields, name) {
+			continue
+		}
+		old, updated := before[name], after[name]
+		if reflect.DeepEqual(old, updated) || (isZeroJSON(old) && isZeroJSON(updated)) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Old: old, New: updated})
+	}
+	return changes
+}
+
+// Fields is the record as clients send it: the MetaData and the type specific fields in
+// one map, as decoded JSON.
+func (r Record) Fields() map[string]any {
+	return flattenRecord(r)
+}
+
+// Fingerprint identifies the record's current state, it changes with every write. Fields
+// left at their zero value are skipped, so it's the same whichever backend the record was
+// read back from.
+func (r Record) Fingerprint() string {
+	fields := flattenRecord(r)
+	for name, value := range fields {
+		if isZeroJSON(value) {
+			delete(fields, name)
+		}
+	}
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// flattenRecord puts the MetaData and payload fields in one map, as decoded JSON so
+// values compare the same whichever side they came from
+func flattenRecord(record Record) map[string]any {
+	fields := map[string]any{}
+	if len(record.Payload) > 0 {
+		json.Unmarshal(record.Payload, &fields)
+	}
+	data, _ := json.Marshal(record.MetaData)
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+func isZeroJSON(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWhenSaveExpectRevisionsWithChanges(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{Content: "first draft"}
+		doc.SetMetaData(MetaData{Title: "Draft", DocType: "Notes", Uuid: id.String()})
+		if err := db.Save(context.Background(), doc, Change{User: "ada", Action: ActionCreate}); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+
+		meta := doc.GetMetaData()
+		meta.Title = "Final"
+		doc.SetMetaData(meta)
+		doc.Content = "second draft"
+		if err := db.Save(context.Background(), doc, Change{User: "grace", Action: ActionUpdate}); err != nil {
+			t.Fatalf("error updating document: %s", err)
+		}
+
+		history, err := db.History(context.Background(), id)
+		if err != nil {
+			t.Fatalf("error reading history: %s", err)
+		}
+		if len(history) != 2 {
+			t.Fatalf("wanted 2 revisions; have %d", len(history))
+		}
+		if history[0].Number != 1 || history[0].User != "ada" || history[0].Action != ActionCreate {
+			t.Errorf("unexpected first revision: %+v", history[0])
+		}
+
+		want := []FieldChange{
+			{Field: "Content", Old: "first draft", New: "second draft"},
+			{Field: "Title", Old: "Draft", New: "Final"},
+		}
+		if history[1].User != "grace" || !reflect.DeepEqual(history[1].Changes, want) {
+			t.Errorf("wanted changes %+v by grace; have %+v by %s", want, history[1].Changes, history[1].User)
+		}
+	})
+}
+
+func TestWhenRestoreRevisionExpectOldStateAndFile(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{Content: "v1"}
+		doc.SetMetaData(MetaData{Title: "Scan", DocType: "Notes", Uuid: id.String(), Path: "v1.pdf", FileType: ".pdf", Size: 10})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		if _, err := db.ReplaceFile(context.Background(), id, FileVersion{Path: "v2.pdf", FileType: ".pdf", Size: 20}, "ada"); err != nil {
+			t.Fatalf("error replacing file: %s", err)
+		}
+
+		record, err := db.Restore(context.Background(), id, 1, "grace")
+		if err != nil {
+			t.Fatalf("error restoring revision: %s", err)
+		}
+		if record.Path != "v1.pdf" || record.Size != 10 {
+			t.Errorf("wanted the first file back; have %s (%d bytes)", record.Path, record.Size)
+		}
+
+		history, _ := db.History(context.Background(), id)
+		last := history[len(history)-1]
+		if len(history) != 3 || last.Action != ActionRestore || last.RestoredFrom != 1 || last.File == nil {
+			t.Errorf("wanted a restore revision pointing at the old file; have %+v", last)
+		}
+
+		if _, err := db.Restore(context.Background(), id, 9, "grace"); !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("wanted ErrRevisionNotFound; have %v", err)
+		}
+	})
+}
+
+func TestWhenPruneFilesExpectOldestVersionsPruned(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Scan", DocType: "Notes", Uuid: id.String(), Path: "v1.pdf"})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		for _, path := range []string{"v2.pdf", "v3.pdf"} {
+			if _, err := db.ReplaceFile(context.Background(), id, FileVersion{Path: path}, "ada"); err != nil {
+				t.Fatalf("error replacing file: %s", err)
+			}
+		}
+
+		pruned, err := db.PruneFiles(context.Background(), id, 2)
+		if err != nil {
+			t.Fatalf("error pruning: %s", err)
+		}
+		if !reflect.DeepEqual(pruned, []string{"v1.pdf"}) {
+			t.Fatalf("wanted v1.pdf pruned; have %v", pruned)
+		}
+
+		// pruning again finds nothing new, and the pruned revision can't be restored
+		if pruned, _ := db.PruneFiles(context.Background(), id, 2); len(pruned) != 0 {
+			t.Errorf("wanted nothing left to prune; have %v", pruned)
+		}
+		if _, err := db.Restore(context.Background(), id, 1, "ada"); !errors.Is(err, ErrFilePruned) {
+			t.Errorf("wanted ErrFilePruned; have %v", err)
+		}
+	})
+}
+
+func TestWhenDeleteExpectHistoryRemoved(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Gone", DocType: "Notes", Uuid: id.String()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		if err := db.Delete(context.Background(), id); err != nil {
+			t.Fatalf("error deleting document: %s", err)
+		}
+
+		if history, _ := db.History(context.Background(), id); len(history) != 0 {
+			t.Errorf("wanted no history after delete; have %d revisions", len(history))
+		}
+	})
+}
+
+func TestWhenRecordReadBackExpectSameFingerprint(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		ids := createBulkNotes(t, db, "Calculus")
+		raw, err := db.ReadRaw(context.Background(), ids[0])
+		if err != nil {
+			t.Fatalf("error reading document: %s", err)
+		}
+		var before Record
+		json.Unmarshal(raw, &before)
+
+		results, err := db.BulkUpdate(context.Background(), ids, setDewey("510"), Change{Action: ActionUpdate}, false)
+		if err != nil {
+			t.Fatalf("error in bulk update: %s", err)
+		}
+		written := results[0].Record
+		if written.Fingerprint() == before.Fingerprint() {
+			t.Errorf("wanted the fingerprint to change with the write")
+		}
+
+		raw, _ = db.ReadRaw(context.Background(), ids[0])
+		var after Record
+		json.Unmarshal(raw, &after)
+		if after.Fingerprint() != written.Fingerprint() {
+			t.Errorf("wanted the written record's fingerprint on reading it back; have %s, wrote %s", after.Fingerprint(), written.Fingerprint())
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/boltdb/bolt"
+)
+
+//---------------------------------------------------
+//---------------------JOURNAL-----------------------
+//---------------------------------------------------
+
+// the "journal" bucket is an outbox of storage operations that go with a database write.
+// a file that's about to be stored is journaled before it's written, a file that has to go
+// is journaled in the same transaction that stops referencing it. entries are cleared once
+// storage has caught up, whatever is left after a crash is reconciled at start up.
+
+// the Action of a FileOp
+const (
+	FileOpStore  = "store"
+	FileOpDelete = "delete"
+)
+
+// FileOp is a pending storage operation on a file, keyed by its path.
+type FileOp struct {
+	Path   string
+	Action string
+	// Uuid is the document the file belongs to, when known
+	Uuid    string `json:",omitempty"`
+	Created string
+}
+
+// JournalFileOp records a storage operation before it's carried out, replacing any
+// pending operation on the same path.
+func (b *BoltDao) JournalFileOp(ctx context.Context, op FileOp) error {
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		return putFileOp(tx, op)
+	})
+	if err != nil {
+		return fmt.Errorf("could not journal file operation: %w", err)
+	}
+	return nil
+}
+
+// CompleteFileOp clears the pending operation on a path, once storage reflects it.
+func (b *BoltDao) CompleteFileOp(ctx context.Context, path string) error {
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		journal := tx.Bucket([]byte("journal"))
+		if journal == nil {
+			return nil
+		}
+		return journal.Delete([]byte(path))
+	})
+	if err != nil {
+		return fmt.Errorf("could not clear file operation: %w", err)
+	}
+	return nil
+}
+
+// PendingFileOps returns every storage operation that hasn't been completed, by path.
+func (b *BoltDao) PendingFileOps(ctx context.Context) ([]FileOp, error) {
+	var ops []FileOp
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		journal := tx.Bucket([]byte("journal"))
+		if journal == nil {
+			return nil
+		}
+		return journal.ForEach(func(_, v []byte) error {
+			var op FileOp
+			if err := json.Unmarshal(v, &op); err != nil {
+				return fmt.Errorf("error unmarshaling file operation: %v", err)
+			}
+			ops = append(ops, op)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	return ops, nil
+}
+
+// ReferencedFiles returns every path a current or trashed record, or an unpruned file
+// version in a history, points at. Records that can't be decoded are skipped, they're
+// for fsck to report.
+func (b *BoltDao) ReferencedFiles(ctx context.Context) (map[string]bool, error) {
+	var paths map[string]bool
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		var err error
+		paths, err = referencedFiles(tx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing referenced files: %w", err)
+	}
+	return paths, nil
+}
+
+func referencedFiles(tx *bolt.Tx) (map[string]bool, error) {
+	paths := map[string]bool{}
+	for _, name := range []string{"documents", "trash"} {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			continue
+		}
+		err := bucket.ForEach(func(_, v []byte) error {
+			var meta MetaData
+			if err := json.Unmarshal(v, &meta); err != nil {
+				return nil
+			}
+			if meta.Path != "" {
+				paths[meta.Path] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	root := tx.Bucket([]byte("revisions"))
+	if root == nil {
+		return paths, nil
+	}
+	err := root.ForEach(func(k, _ []byte) error {
+		revisions := root.Bucket(k)
+		if revisions == nil {
+			return nil
+		}
+		return revisions.ForEach(func(_, v []byte) error {
+			var rev Revision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return fmt.Errorf("error unmarshaling revision: %v", err)
+			}
+			if rev.File != nil && !rev.File.Pruned {
+				paths[rev.File.Path] = true
+			}
+			return nil
+		})
+	})
+	return paths, err
+}
+
+// unreferencedFiles drops the paths another document still points at. It's called once the
+// document's own references are gone, a file shared with another record is left in storage.
+func unreferencedFiles(tx *bolt.Tx, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return paths, nil
+	}
+	referenced, err := referencedFiles(tx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(paths, func(path string) bool { return referenced[path] }), nil
+}
+
+// journalDeletes queues the files for deletion as part of the transaction that drops them
+func journalDeletes(tx *bolt.Tx, id string, paths []string) error {
+	now := Timestamp()
+	for _, path := range paths {
+		if err := putFileOp(tx, FileOp{Path: path, Action: FileOpDelete, Uuid: id, Created: now}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putFileOp(tx *bolt.Tx, op FileOp) error {
+	journal, err := tx.CreateBucketIfNotExists([]byte("journal"))
+	if err != nil {
+		return fmt.Errorf("could not create journal bucket: %v", err)
+	}
+	if op.Created == "" {
+		op.Created = Timestamp()
+	}
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	return journal.Put([]byte(op.Path), data)
+}
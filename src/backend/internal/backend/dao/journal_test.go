@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWhenPruneAndPurgeExpectDeletesJournaled(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Scan", DocType: "Notes", Uuid: id.String(), Path: "v1.pdf"})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		if _, err := db.ReplaceFile(context.Background(), id, FileVersion{Path: "v2.pdf"}, "ada"); err != nil {
+			t.Fatalf("error replacing file: %s", err)
+		}
+
+		if _, err := db.PruneFiles(context.Background(), id, 1); err != nil {
+			t.Fatalf("error pruning: %s", err)
+		}
+		ops, _ := db.PendingFileOps(context.Background())
+		if len(ops) != 1 || ops[0].Path != "v1.pdf" || ops[0].Action != FileOpDelete || ops[0].Uuid != id.String() {
+			t.Fatalf("wanted the pruned file journaled for deletion; have %+v", ops)
+		}
+		if err := db.CompleteFileOp(context.Background(), "v1.pdf"); err != nil {
+			t.Fatalf("error completing file operation: %s", err)
+		}
+
+		if _, err := db.Trash(context.Background(), id, "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+		paths, err := db.Purge(context.Background(), id)
+		if err != nil {
+			t.Fatalf("error purging document: %s", err)
+		}
+		if !reflect.DeepEqual(paths, []string{"v2.pdf"}) {
+			t.Errorf("wanted only the unpruned file purged; have %v", paths)
+		}
+		if ops, _ := db.PendingFileOps(context.Background()); len(ops) != 1 || ops[0].Path != "v2.pdf" {
+			t.Errorf("wanted the purged file journaled for deletion; have %+v", ops)
+		}
+	})
+}
+
+func TestWhenReferencedFilesExpectCurrentTrashedAndUnpruned(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		for _, path := range []string{"kept.pdf", "binned.pdf"} {
+			doc := &Notes{}
+			doc.SetMetaData(MetaData{Title: path, DocType: "Notes", Uuid: uuid.NewString(), Path: path})
+			if err := db.Create(context.Background(), doc); err != nil {
+				t.Fatalf("error creating document: %s", err)
+			}
+			if path == "binned.pdf" {
+				if _, err := db.Trash(context.Background(), uuid.MustParse(doc.GetID()), "ada"); err != nil {
+					t.Fatalf("error trashing document: %s", err)
+				}
+			}
+		}
+
+		id := uuid.New()
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Scan", DocType: "Notes", Uuid: id.String(), Path: "v1.pdf"})
+		db.Create(context.Background(), doc)
+		db.ReplaceFile(context.Background(), id, FileVersion{Path: "v2.pdf"}, "ada")
+		db.ReplaceFile(context.Background(), id, FileVersion{Path: "v3.pdf"}, "ada")
+		db.PruneFiles(context.Background(), id, 2)
+
+		referenced, err := db.ReferencedFiles(context.Background())
+		if err != nil {
+			t.Fatalf("error listing referenced files: %s", err)
+		}
+		var paths []string
+		for path := range referenced {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		want := []string{"binned.pdf", "kept.pdf", "v2.pdf", "v3.pdf"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("wanted %v referenced; have %v", want, paths)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+//---------------------------------------------------
+//--------------------MIGRATIONS---------------------
+//---------------------------------------------------
+
+// the "meta" bucket holds the schema version, the number of migrations the database has
+// been through. a database without one predates versioning and is at version 0. every
+// change to how records are stored is a new migration appended to the list, never an edit
+// to one that has shipped.
+
+// Migration upgrades the database by one schema version. Up runs inside a single Bolt
+// transaction together with the version bump, so a failed migration leaves nothing behind.
+type Migration struct {
+	Name string
+	Up   func(tx *bolt.Tx) error
+}
+
+// migrations in the order they're applied, migration i takes the database to version i+1
+var migrations = []Migration{
+	{Name: "split Author into Authors", Up: migrateAuthors},
+}
+
+// SchemaVersion is the version this build reads and writes.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// MigrationReport is what NewBoltDao did to bring the database up to SchemaVersion.
+type MigrationReport struct {
+	From int
+	To   int
+	// Applied names the migrations run, or that would run on a dry run
+	Applied []string
+	// Backup is the copy of the database taken before migrating, empty when nothing needed one
+	Backup string
+	DryRun bool
+}
+
+// MigrationReport returns what NewBoltDao did to the schema.
+func (b *BoltDao) MigrationReport() MigrationReport {
+	return b.migration
+}
+
+// migrate applies the pending migrations, each in its own transaction. A database with
+// records in it is copied to backupDir first. On a dry run they all run in one transaction
+// that's rolled back, so the database is left as it was.
+func migrate(db *bolt.DB, backupDir string, dryRun bool) (MigrationReport, error) {
+	var report MigrationReport
+	var populated bool
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		report.From, err = schemaVersion(tx)
+		populated = tx.Bucket([]byte(RecordsCurrent)) != nil || tx.Bucket([]byte(RecordsTrashed)) != nil
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+	report.To, report.DryRun = report.From, dryRun
+
+	if report.From > SchemaVersion() {
+		return report, fmt.Errorf("database schema version %d is newer than this build's %d", report.From, SchemaVersion())
+	}
+	pending := migrations[report.From:]
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	if dryRun {
+		tx, err := db.Begin(true)
+		if err != nil {
+			return report, err
+		}
+		defer tx.Rollback()
+		for i, migration := range pending {
+			if err := migration.Up(tx); err != nil {
+				return report, fmt.Errorf("migration %d (%s) failed: %w", report.From+i+1, migration.Name, err)
+			}
+			report.Applied = append(report.Applied, migration.Name)
+			report.To++
+		}
+		return report, nil
+	}
+
+	if populated {
+		if report.Backup, err = backupDatabase(db, backupDir, report.From); err != nil {
+			return report, fmt.Errorf("failed to back up the database before migrating: %w", err)
+		}
+	}
+	for i, migration := range pending {
+		version := report.From + i + 1
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, version)
+		})
+		if err != nil {
+			return report, fmt.Errorf("migration %d (%s) failed: %w", version, migration.Name, err)
+		}
+		report.Applied = append(report.Applied, migration.Name)
+		report.To = version
+	}
+	return report, nil
+}
+
+// backupDatabase writes a consistent copy of the database, named after the version it's at
+func backupDatabase(db *bolt.DB, dir string, version int) (string, error) {
+	if dir == "" {
+		dir = filepath.Dir(db.Path())
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s.v%d-%s.bak", filepath.Base(db.Path()), version, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0600)
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func schemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket([]byte("meta"))
+	if meta == nil {
+		return 0, nil
+	}
+	value := meta.Get([]byte("schema_version"))
+	if value == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %v", value, err)
+	}
+	return version, nil
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte("meta"))
+	if err != nil {
+		return fmt.Errorf("could not create meta bucket: %v", err)
+	}
+	return meta.Put([]byte("schema_version"), []byte(strconv.Itoa(version)))
+}
+
+// upgrades records written before Authors existed, in place. Safe to run repeatedly.
+func migrateAuthors(tx *bolt.Tx) error {
+	bucket := tx.Bucket([]byte("documents"))
+	if bucket == nil {
+		return nil
+	}
+
+	upgraded := map[string][]byte{}
+	err := bucket.ForEach(func(k, v []byte) error {
+		var record Record
+		if err := json.Unmarshal(v, &record); err != nil {
+			return nil // left for the scans to quarantine
+		}
+		if !record.SyncAuthors() {
+			return nil
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		upgraded[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// written after the scan, bolt doesn't allow modifying a bucket mid-ForEach
+	for k, v := range upgraded {
+		if err := bucket.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package dao
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+)
+
+// writes a record as stored before schema versions, Author without Authors
+func writeUnversionedDB(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+	defer raw.Close()
+	id := uuid.NewString()
+	err = raw.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(RecordsCurrent))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), []byte(`{"Title":"old","Author":"Pike & Kernighan","DocType":"Notes","Uuid":"`+id+`"}`))
+	})
+	if err != nil {
+		t.Fatalf("error writing record: %s", err)
+	}
+	return id
+}
+
+func readVersion(t *testing.T, db *BoltDao) int {
+	t.Helper()
+	var version int
+	db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = schemaVersion(tx)
+		return err
+	})
+	return version
+}
+
+func TestWhenConnectNewDBExpectCurrentVersionWithoutBackup(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewBoltDao(BoltOptions{Path: filepath.Join(dir, "new.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("error connecting: %s", err)
+	}
+	defer db.Disconnect()
+
+	if version := readVersion(t, db); version != SchemaVersion() {
+		t.Errorf("wanted version %d; have %d", SchemaVersion(), version)
+	}
+	if report := db.MigrationReport(); report.Backup != "" || report.To != SchemaVersion() {
+		t.Errorf("wanted no backup of an empty database; have %+v", report)
+	}
+}
+
+func TestWhenConnectUnversionedDBExpectBackupAndMigration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.db")
+	id := writeUnversionedDB(t, path)
+
+	db, err := NewBoltDao(BoltOptions{Path: path, Mode: 0600, BackupDir: filepath.Join(dir, "backups")})
+	if err != nil {
+		t.Fatalf("error connecting: %s", err)
+	}
+	defer db.Disconnect()
+
+	report := db.MigrationReport()
+	if report.From != 0 || report.To != SchemaVersion() || len(report.Applied) != SchemaVersion() {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if filepath.Dir(report.Backup) != filepath.Join(dir, "backups") {
+		t.Fatalf("wanted the backup in the backup directory; have %q", report.Backup)
+	}
+
+	// the backup is the database as it was before migrating
+	backup, err := bolt.Open(report.Backup, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("error opening backup: %s", err)
+	}
+	defer backup.Close()
+	backup.View(func(tx *bolt.Tx) error {
+		if version, _ := schemaVersion(tx); version != 0 || tx.Bucket([]byte(RecordsCurrent)).Get([]byte(id)) == nil {
+			t.Error("wanted the unmigrated record in the backup")
+		}
+		return nil
+	})
+
+	metas, _ := db.GetAll(context.Background())
+	if len(metas) != 1 || !reflect.DeepEqual(metas[0].Authors, []string{"Pike", "Kernighan"}) {
+		t.Errorf("wanted the record migrated; have %+v", metas)
+	}
+}
+
+func TestWhenDryRunExpectDatabaseUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.db")
+	writeUnversionedDB(t, path)
+
+	db, err := NewBoltDao(BoltOptions{Path: path, Mode: 0600, DryRun: true})
+	if err != nil {
+		t.Fatalf("error connecting: %s", err)
+	}
+	report := db.MigrationReport()
+	if !report.DryRun || report.To != SchemaVersion() || report.Backup != "" {
+		t.Errorf("wanted every migration reported without a backup; have %+v", report)
+	}
+	if version := readVersion(t, db); version != 0 {
+		t.Errorf("wanted the version untouched; have %d", version)
+	}
+	metas, _ := db.GetAll(context.Background())
+	if len(metas) != 1 || len(metas[0].Authors) != 0 {
+		t.Errorf("wanted the record untouched; have %+v", metas)
+	}
+	db.Disconnect()
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("wanted no backup written; have %d files", len(entries))
+	}
+}
+
+func TestWhenDBNewerThanBuildExpectRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.db")
+	raw, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+	raw.Update(func(tx *bolt.Tx) error {
+		return setSchemaVersion(tx, SchemaVersion()+1)
+	})
+	raw.Close()
+
+	db, err := NewBoltDao(BoltOptions{Path: path, Mode: 0600})
+	if err == nil {
+		db.Disconnect()
+		t.Fatal("wanted a database from a newer build refused")
+	}
+}
@@ -1,12 +1,20 @@
 package dao
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"reflect"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/google/uuid"
@@ -16,62 +24,183 @@ import (
 //----------------------DAO--------------------------
 //---------------------------------------------------
 
-type ConnectParams interface {
-	getParams() any
-}
+// ErrDocumentNotFound is returned (wrapped) when no record exists for a UUID.
+var ErrDocumentNotFound = errors.New("document not found")
 
-// DAO interface, contains basic CRUD functions
+// DAO is the core every backend implements: storing, reading and searching documents.
+// implementations are opened through their own constructor, or by backend name with Open,
+// see backends.go. anything more a backend keeps is a capability of its own below, which
+// callers check for with a type assertion, e.g. db.(dao.TrashStore), and do without or
+// report as ErrUnsupported when it's missing.
 type DAO interface {
-	Create(Document) error
-	Read(*Document, uuid.UUID) (Document, error)
-	ReadRaw(uuid.UUID) ([]byte, error)
-	SearchByKeyValue(key, value string) ([]MetaData, error)
-	FuzzySearch(query string) ([]MetaData, error)
-	GetAll() ([]MetaData, error)
-	Update(Document) error
-	Delete(uuid.UUID) error
-	Connect(ConnectParams) error
+	Create(ctx context.Context, doc Document) error
+	Read(ctx context.Context, doc *Document, id uuid.UUID) (Document, error)
+	ReadRaw(ctx context.Context, id uuid.UUID) ([]byte, error)
+	// ReadRecords calls fn with the record of each of ids in turn, see eachRecordBatch
+	ReadRecords(ctx context.Context, ids []uuid.UUID, fn func(Record) error) error
+	SearchByKeyValue(ctx context.Context, key, value string) ([]MetaData, error)
+	FuzzySearch(ctx context.Context, query string) ([]MetaData, error)
+	GetAll(ctx context.Context) ([]MetaData, error)
+	Update(ctx context.Context, doc Document) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Save is Create/Update with the change recorded, in the document's history if it's kept
+	Save(ctx context.Context, doc Document, change Change) error
+	ReplaceFile(ctx context.Context, id uuid.UUID, file FileVersion, user string) (Record, error)
+	// BulkUpdate applies one edit to many documents in a single transaction, see bulk.go
+	BulkUpdate(ctx context.Context, ids []uuid.UUID, edit BulkEdit, change Change, dryRun bool) ([]BulkResult, error)
+	Stats(ctx context.Context) (Stats, error)
 	Disconnect() error
 }
 
+// the number of records ReadRecords reads in each transaction
+const recordBatch = 100
+
+// eachRecordBatch is ReadRecords over a backend's read of a batch of ids: it calls fn with
+// each record in the order of ids, skipping those with no current document. A batch is read
+// in a transaction of its own and fn is only called once it's over, so a slow consumer, like
+// an export streaming to a client, holds no transaction open. An error from fn stops it.
+func eachRecordBatch(ctx context.Context, ids []uuid.UUID, fn func(Record) error, read func([]uuid.UUID) ([]Record, error)) error {
+	for batch := range slices.Chunk(ids, recordBatch) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		records, err := read(batch)
+		if err != nil {
+			return fmt.Errorf("error reading documents: %w", err)
+		}
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ErrUnsupported is returned (wrapped) for an operation that needs a capability the backend
+// doesn't have.
+var ErrUnsupported = errors.New("not supported by the database backend")
+
+// HistoryStore keeps every revision of a document and its earlier file versions, see history.go.
+type HistoryStore interface {
+	History(ctx context.Context, id uuid.UUID) ([]Revision, error)
+	Restore(ctx context.Context, id uuid.UUID, revision int, user string) (Record, error)
+	PruneFiles(ctx context.Context, id uuid.UUID, keep int) ([]string, error)
+}
+
+// TrashStore keeps deleted documents. Trash moves a document out of the scans, Untrash
+// brings it back and Purge removes it for good, see trash.go.
+type TrashStore interface {
+	Trash(ctx context.Context, id uuid.UUID, user string) (TrashedRecord, error)
+	Untrash(ctx context.Context, id uuid.UUID, user string) (Record, error)
+	ReadTrashed(ctx context.Context, id uuid.UUID) (TrashedRecord, error)
+	Trashed(ctx context.Context) ([]TrashedRecord, error)
+	Purge(ctx context.Context, id uuid.UUID) ([]string, error)
+}
+
+// FileJournal is the journal of storage operations that go with database writes, see journal.go.
+type FileJournal interface {
+	JournalFileOp(ctx context.Context, op FileOp) error
+	CompleteFileOp(ctx context.Context, path string) error
+	PendingFileOps(ctx context.Context) ([]FileOp, error)
+	ReferencedFiles(ctx context.Context) (map[string]bool, error)
+}
+
+// QuarantineStore is the integrity check's view of the records, see fsck.go. corrupt records
+// are moved to quarantine by the scans, or by fsck.
+type QuarantineStore interface {
+	ScanRecords(ctx context.Context) ([]RawRecord, error)
+	RepairRecord(ctx context.Context, set RecordSet, record Record, user string) error
+	Quarantine(ctx context.Context, set RecordSet, key, reason string) error
+	Quarantined(ctx context.Context) ([]QuarantinedRecord, error)
+	QuarantineCount(ctx context.Context, set RecordSet) (int, error)
+}
+
+// Snapshotter writes a consistent copy of the whole database, while it stays in use.
+type Snapshotter interface {
+	Snapshot(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// RecordSet is which records, current or trashed, a record is among. each backend keeps them
+// its own way: Bolt in a bucket named after the set, SQLite as rows without and with deleted_at.
+type RecordSet string
+
+const (
+	RecordsCurrent RecordSet = "documents"
+	RecordsTrashed RecordSet = "trash"
+)
+
 //---------------------------------------------------
 //-------------------BOLT-DAO------------------------
 //---------------------------------------------------
 
-type BoltConnectionParams struct {
+// BoltOptions are what NewBoltDao opens a database with
+type BoltOptions struct {
 	Path string
 	Mode fs.FileMode
 	Opts *bolt.Options
-}
-
-// TODO: fix this dumpster fire of a design decision
-// this is nightmare fuel, and needs a more elegant solution -
-// perhaps interface/concrete isn't necessarily the right tool for the job?
-func (bcp *BoltConnectionParams) getParams() any {
-	return *bcp
+	// BackupDir is where the database is copied before migrating, next to it when empty
+	BackupDir string
+	// DryRun runs pending migrations in a transaction that's rolled back, leaving the database
+	// at its old version. Such a connection is for inspecting the report, not for serving.
+	DryRun bool
 }
 
 // BoltDAO struct, with realised methods from the DAO interface
 type BoltDao struct {
 	db *bolt.DB
+	// skipped counts the corrupt records scans have passed over, see quarantine.go
+	skipped atomic.Int64
+	// migration is what NewBoltDao did to the schema, see migrate.go
+	migration MigrationReport
 }
 
-func (b *BoltDao) Connect(cp ConnectParams) error {
-	// Open the my.db data file in your current directory.
-	// It will be created if it doesn't exist.
-	params, ok := cp.getParams().(BoltConnectionParams)
-	if !ok {
-		return fmt.Errorf("connection parameters do not conform to BoltConnectionParams type")
+// NewBoltDao opens the database at opts.Path, creating it if it doesn't exist, and brings
+// it up to the current schema.
+func NewBoltDao(opts BoltOptions) (*BoltDao, error) {
+	db, err := bolt.Open(opts.Path, opts.Mode, opts.Opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %v", err)
 	}
-	// connect to given db
-	db, err := bolt.Open(params.Path, params.Mode, params.Opts)
+
+	// bring databases written by older versions up to the current schema
+	report, err := migrate(db, opts.BackupDir, opts.DryRun)
 	if err != nil {
-		return fmt.Errorf("failed to open DB: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate DB: %w", err)
 	}
+	return &BoltDao{db: db, migration: report}, nil
+}
 
-	// assign DAO db to established connection
-	b.db = db
-	return nil
+// Snapshot writes the database file as of a single read transaction, so writers carry on
+// while it's copied.
+func (b *BoltDao) Snapshot(ctx context.Context, w io.Writer) (int64, error) {
+	var n int64
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return n, fmt.Errorf("error writing database snapshot: %w", err)
+	}
+	return n, nil
+}
+
+// view and update run fn in a read or write transaction, unless ctx is already done. Bolt
+// can't interrupt a transaction once it has started, the scans check ctx as they go.
+func (b *BoltDao) view(ctx context.Context, fn func(*bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.View(fn)
+}
+
+func (b *BoltDao) update(ctx context.Context, fn func(*bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(fn)
 }
 
 func (b *BoltDao) Disconnect() error {
@@ -85,79 +214,93 @@ func (b *BoltDao) Disconnect() error {
 	return nil
 }
 
-func (b *BoltDao) Create(doc Document) error {
-	metaData := doc.GetMetaData()
-
-	err := b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("documents"))
-		if bucket == nil {
-			var err error
-			bucket, err = tx.CreateBucket([]byte("documents"))
-			if err != nil {
-				return fmt.Errorf("could not create documents bucket: %v", err)
-			}
-		}
-
-		docID := []byte(doc.GetID())
-		docData, err := json.Marshal(metaData)
-		if err != nil {
-			return fmt.Errorf("could not insert document: %v", err)
-		}
-
-		return bucket.Put(docID, docData)
-	})
-	return err
+// Create stores a new document, see Save.
+func (b *BoltDao) Create(ctx context.Context, doc Document) error {
+	return b.Save(ctx, doc, Change{Action: ActionCreate})
 }
 
-func (b *BoltDao) ReadRaw(id uuid.UUID) ([]byte, error) {
+func (b *BoltDao) ReadRaw(ctx context.Context, id uuid.UUID) ([]byte, error) {
 	var rawData []byte
 
-	err := b.db.View(func(tx *bolt.Tx) error {
+	err := b.view(ctx, func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("documents"))
 		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
+			return ErrDocumentNotFound
 		}
 
 		data := bucket.Get([]byte(id.String()))
 		if data == nil {
-			return fmt.Errorf("document not found")
+			return ErrDocumentNotFound
 		}
 
 		rawData = slices.Clone(data) // Copy data
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving document: %v", err)
+		return nil, fmt.Errorf("error retrieving document: %w", err)
 	}
 	return rawData, nil
 }
 
-// Read method for BoltDao, expects a Document(empty ideally, for example, a 'Note') and a UUID
-func (b *BoltDao) Read(doc *Document, id uuid.UUID) (Document, error) {
-	var metaData MetaData
+// ReadRecords calls fn with the record of each of ids, a batch of them read per transaction.
+func (b *BoltDao) ReadRecords(ctx context.Context, ids []uuid.UUID, fn func(Record) error) error {
+	return eachRecordBatch(ctx, ids, fn, func(batch []uuid.UUID) ([]Record, error) {
+		var records []Record
+		err := b.view(ctx, func(tx *bolt.Tx) error {
+			bucket := tx.Bucket([]byte(RecordsCurrent))
+			if bucket == nil {
+				return nil
+			}
+			for _, id := range batch {
+				data := bucket.Get([]byte(id.String()))
+				if data == nil {
+					continue
+				}
+				var record Record
+				if err := json.Unmarshal(data, &record); err != nil {
+					return fmt.Errorf("error reading %s: %w", id, err)
+				}
+				records = append(records, record)
+			}
+			return nil
+		})
+		return records, err
+	})
+}
+
+// Read method for BoltDao, expects a Document(empty ideally, for example, a 'Note') and a UUID.
+// the stored payload is decoded onto the Document, so it should be the concrete type for DocType.
+func (b *BoltDao) Read(ctx context.Context, doc *Document, id uuid.UUID) (Document, error) {
+	var record Record
 
 	// use View to retrieve from documents bucket, erroring if it doesn't exist
-	err := b.db.View(func(tx *bolt.Tx) error {
+	err := b.view(ctx, func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("documents"))
 		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
+			return ErrDocumentNotFound
 		}
 
 		// get by UUID
 		data := bucket.Get([]byte(id.String()))
 		if data == nil {
-			return fmt.Errorf("document not found")
+			return ErrDocumentNotFound
 		}
 
 		// unmarshal the metadata from the JSON response
-		return json.Unmarshal(data, &metaData)
+		return json.Unmarshal(data, &record)
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving document: %v", err)
+		return nil, fmt.Errorf("error retrieving document: %w", err)
+	}
+
+	if len(record.Payload) > 0 {
+		if err := json.Unmarshal(record.Payload, *doc); err != nil {
+			return nil, fmt.Errorf("error reading %s payload: %v", record.DocType, err)
+		}
 	}
 
 	// call the Documents setMetaData method, by dereferencing the Document
-	err = (*doc).SetMetaData(metaData)
+	err = (*doc).SetMetaData(record.MetaData)
 	if err != nil {
 		return nil, fmt.Errorf("error reading metaData: %v", err)
 	}
@@ -165,126 +308,88 @@ func (b *BoltDao) Read(doc *Document, id uuid.UUID) (Document, error) {
 	return *doc, nil
 }
 
-func (b *BoltDao) Update(doc Document) error {
-	metaData := doc.GetMetaData()
+// Update replaces the stored record, keeping its CreatedAt and stamping LastUpdated.
+func (b *BoltDao) Update(ctx context.Context, doc Document) error {
+	return b.Save(ctx, doc, Change{Action: ActionUpdate})
+}
 
-	err := b.db.Update(func(tx *bolt.Tx) error {
+// Delete permanently removes a document, whether it's current or in the trash, and its history.
+func (b *BoltDao) Delete(ctx context.Context, id uuid.UUID) error {
+	err := b.update(ctx, func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("documents"))
 		if bucket == nil {
 			return fmt.Errorf("documents bucket does not exist")
 		}
 
-		docID := []byte(doc.GetID())
-		docData, err := json.Marshal(metaData)
-		if err != nil {
-			return fmt.Errorf("could not update document: %v", err)
+		if err := bucket.Delete([]byte(id.String())); err != nil {
+			return err
 		}
-
-		return bucket.Put(docID, docData)
-	})
-	return err
-}
-
-func (b *BoltDao) Delete(id uuid.UUID) error {
-	err := b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("documents"))
-		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
+		if trash := tx.Bucket([]byte("trash")); trash != nil {
+			if err := trash.Delete([]byte(id.String())); err != nil {
+				return err
+			}
 		}
-
-		return bucket.Delete([]byte(id.String()))
+		return deleteHistory(tx, id)
 	})
 	return err
 }
 
-func (b *BoltDao) SearchByKeyValue(key, value string) ([]MetaData, error) {
+// SearchByKeyValue returns the documents whose key matches value. Like the other scans it
+// skips records that can't be decoded, quarantining them.
+func (b *BoltDao) SearchByKeyValue(ctx context.Context, key, value string) ([]MetaData, error) {
 	var results []MetaData
-
-	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("documents"))
-		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
-		}
-
-		c := bucket.Cursor()
-		for _, v := c.First(); v != nil; _, v = c.Next() {
-			var metaData MetaData
-			if err := json.Unmarshal(v, &metaData); err != nil {
-				return fmt.Errorf("error unmarshaling document: %v", err)
-			}
-
-			// Check if metadata contains the key-value pair (case-insensitive match for strings)
-			if metaDataMatches(metaData, key, value) {
-				results = append(results, metaData)
-			}
+	err := scanBucket(ctx, b, RecordsCurrent, true, func(metaData MetaData) {
+		// Check if metadata contains the key-value pair (case-insensitive match for strings)
+		if metaDataMatches(metaData, key, value) {
+			results = append(results, metaData)
 		}
-		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error searching documents: %v", err)
+		return nil, fmt.Errorf("error searching documents: %w", err)
 	}
 
 	return results, nil
 }
 
-func (b *BoltDao) GetAll() ([]MetaData, error) {
+func (b *BoltDao) GetAll(ctx context.Context) ([]MetaData, error) {
 	var results []MetaData
-
-	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("documents"))
-		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
-		}
-
-		c := bucket.Cursor()
-		for _, v := c.First(); v != nil; _, v = c.Next() {
-			var metaData MetaData
-			if err := json.Unmarshal(v, &metaData); err != nil {
-				return fmt.Errorf("error unmarshaling document: %v", err)
-			}
-
-			results = append(results, metaData)
-		}
-		return nil
+	err := scanBucket(ctx, b, RecordsCurrent, true, func(metaData MetaData) {
+		results = append(results, metaData)
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving all documents: %v", err)
+		return nil, fmt.Errorf("error retrieving all documents: %w", err)
 	}
 
 	return results, nil
 }
 
-func (b *BoltDao) FuzzySearch(query string) ([]MetaData, error) {
+func (b *BoltDao) FuzzySearch(ctx context.Context, query string) ([]MetaData, error) {
 	var results []MetaData
 	query = strings.ToLower(query)
 
-	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("documents"))
-		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
-		}
-
-		c := bucket.Cursor()
-		for _, v := c.First(); v != nil; _, v = c.Next() {
-			var metaData MetaData
-			if err := json.Unmarshal(v, &metaData); err != nil {
-				return fmt.Errorf("error unmarshaling document: %v", err)
-			}
-
-			if fuzzyMatchMetaData(metaData, query) {
-				results = append(results, metaData)
-			}
+	err := scanBucket(ctx, b, RecordsCurrent, true, func(metaData MetaData) {
+		if fuzzyMatchMetaData(metaData, query) {
+			results = append(results, metaData)
 		}
-		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error searching documents: %v", err)
+		return nil, fmt.Errorf("error searching documents: %w", err)
 	}
 
 	return results, nil
 }
 
 func fuzzyMatchMetaData(meta MetaData, query string) bool {
+	for _, field := range fuzzyFields(meta) {
+		if field != "" && strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyFields are the values FuzzySearch looks through
+func fuzzyFields(meta MetaData) []string {
 	fields := []string{
 		meta.Title,
 		meta.Author,
@@ -292,48 +397,71 @@ func fuzzyMatchMetaData(meta MetaData, query string) bool {
 		meta.FileType,
 		meta.DeweyDecimal,
 		meta.PublishDate,
+		meta.ISBN,
+		meta.DOI,
+		meta.Publisher,
+		meta.Language,
+		meta.Description,
 	}
-	for _, field := range fields {
-		if field != "" && strings.Contains(strings.ToLower(field), query) {
-			return true
-		}
+	fields = append(fields, meta.Authors...)
+	fields = append(fields, meta.Tags...)
+	for _, v := range meta.Custom {
+		fields = append(fields, v)
 	}
-	return false
+	return fields
 }
 
-// Helper function to check if metadata struct contains the key-value pair
+// Helper function to check if metadata struct contains the key-value pair.
+// key is a field name, or a dotted path into a map field (e.g. "Custom.series").
+// list fields match when any element equals value.
 func metaDataMatches(metaData MetaData, key, value string) bool {
-	metaValue, err := getStructFieldValue(metaData, key)
-	if err != nil {
-		return false // Field not found or invalid
-	}
-	return metaValue == value
+	return fieldMatches(reflect.ValueOf(metaData), strings.Split(key, "."), value)
 }
 
-// Reflection-based function to retrieve a field value by name
-func getStructFieldValue(metaData MetaData, fieldName string) (string, error) {
-	val := reflect.ValueOf(metaData)
-
-	// Ensure we're dealing with a struct
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem() // Dereference pointer if needed
-	}
-
-	if val.Kind() != reflect.Struct {
-		return "", fmt.Errorf("expected struct, got %s", val.Kind())
+// Reflection-based walk of a key path, comparing whatever it lands on against value
+func fieldMatches(val reflect.Value, path []string, value string) bool {
+	// Dereference pointers and interfaces if needed
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return false
+		}
+		val = val.Elem()
 	}
 
-	field := val.FieldByName(fieldName)
-	if !field.IsValid() {
-		return "", fmt.Errorf("field %s not found in metadata", fieldName)
+	if len(path) == 0 {
+		switch val.Kind() {
+		case reflect.String:
+			return val.String() == value
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(val.Int(), 10) == value
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < val.Len(); i++ {
+				if fieldMatches(val.Index(i), nil, value) {
+					return true
+				}
+			}
+		}
+		return false
 	}
 
-	// Ensure the field is a string before returning it
-	if field.Kind() == reflect.String {
-		return field.String(), nil
+	switch val.Kind() {
+	case reflect.Struct:
+		field := val.FieldByName(path[0])
+		if !field.IsValid() {
+			return false // Field not found
+		}
+		return fieldMatches(field, path[1:], value)
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		entry := val.MapIndex(reflect.ValueOf(path[0]).Convert(val.Type().Key()))
+		if !entry.IsValid() {
+			return false
+		}
+		return fieldMatches(entry, path[1:], value)
 	}
-
-	return "", fmt.Errorf("field %s is not a string", fieldName)
+	return false
 }
 
 //---------------------------------------------------
@@ -343,15 +471,91 @@ func getStructFieldValue(metaData MetaData, fieldName string) (string, error) {
 // basic struct, contains generic information
 // regarding the document it references.
 type MetaData struct {
-	Title        string
-	Author       string
-	PublishDate  string
+	Title string
+	// Author is the display form ("Cormen et al."), Authors holds every individual author
+	Author      string
+	Authors     []string
+	PublishDate string
+	// CreatedAt and LastUpdated are RFC 3339 timestamps, stamped by the DAO on Create and Update
+	CreatedAt    string
 	LastUpdated  string
 	FileType     string
 	DocType      string
 	DeweyDecimal string
 	Path         string
 	Uuid         string
+	// Size of the stored file in bytes, 0 for records without one
+	Size int64
+	// Hash is the hex encoded sha256 of the stored file, recorded when it's stored
+	Hash      string
+	ISBN      string
+	DOI       string
+	Publisher string
+	Edition   string
+	Language  string
+	PageCount int
+	Tags      []string
+	// Description is a free text abstract or summary
+	Description string
+	// Custom holds any key/value pairs that have no dedicated field, searchable as "Custom.<key>"
+	Custom map[string]string
+}
+
+// Record is the stored form of a document: its MetaData, with the fields specific to the
+// concrete type alongside under Payload. Scans only need the MetaData and ignore the payload.
+type Record struct {
+	MetaData
+	Payload json.RawMessage `json:",omitempty"`
+}
+
+// NewRecord builds the stored form of a document. the payload is the document's own JSON,
+// concrete types keep MetaData out of it with a `json:"-"` tag.
+func NewRecord(doc Document) (Record, error) {
+	record := Record{MetaData: doc.GetMetaData()}
+	record.SyncAuthors()
+
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return Record{}, err
+	}
+	if string(payload) != "{}" {
+		record.Payload = payload
+	}
+	return record, nil
+}
+
+// Timestamp is the current time in the form CreatedAt and LastUpdated are stored in.
+func Timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func encodeRecord(doc Document) ([]byte, error) {
+	record, err := NewRecord(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(record)
+}
+
+var authorSeparators = regexp.MustCompile(`\s*(?:;|\s+and\s+|\s*&\s*)\s*`)
+
+// SyncAuthors keeps Author and Authors consistent: an empty Authors is split out of Author
+// on ";", "and" and "&" (commas are left alone, "Doe, Jane" is one person), and an empty
+// Author is joined from Authors. Returns true when anything changed.
+func (m *MetaData) SyncAuthors() bool {
+	switch {
+	case len(m.Authors) == 0 && strings.TrimSpace(m.Author) != "":
+		for _, author := range authorSeparators.Split(strings.TrimSpace(m.Author), -1) {
+			if author != "" {
+				m.Authors = append(m.Authors, author)
+			}
+		}
+		return true
+	case m.Author == "" && len(m.Authors) > 0:
+		m.Author = strings.Join(m.Authors, "; ")
+		return true
+	}
+	return false
 }
 
 //---------------------------------------------------
@@ -361,26 +565,133 @@ type MetaData struct {
 // DocumentFactoryFunc defines a function signature for document creation.
 type DocumentFactoryFunc func() Document
 
-// DocumentFactory encapsulates the registry for document types.
+var (
+	// ErrBuiltInType is returned when defining or removing a type that's compiled in.
+	ErrBuiltInType = errors.New("built-in document type")
+	// ErrUnknownType is returned when removing a type that isn't registered.
+	ErrUnknownType = errors.New("unknown document type")
+)
+
+// DocumentFactory encapsulates the registry for document types. types can be defined from
+// schemas while the server runs, so access is guarded.
 type DocumentFactory struct {
+	mu       sync.RWMutex
 	registry map[string]DocumentFactoryFunc
+	schemas  map[string]TypeSchema
+	// schemaPath is where schema-defined types are persisted, empty keeps them in memory
+	schemaPath string
 }
 
 // NewDocumentFactory creates an instance of the factory.
 func NewDocumentFactory() *DocumentFactory {
 	return &DocumentFactory{
 		registry: make(map[string]DocumentFactoryFunc),
+		schemas:  make(map[string]TypeSchema),
 	}
 }
 
 // RegisterDocumentType registers a document type in the factory.
 func (f *DocumentFactory) RegisterDocumentType(docType string, factory DocumentFactoryFunc) {
+	schema := TypeSchema{Type: "object", Properties: map[string]PropertySchema{}}
+	if provider, ok := factory().(SchemaProvider); ok {
+		schema = provider.Schema()
+	}
+	schema.Title = docType
+	schema.BuiltIn = true
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.registry[docType] = factory
+	f.schemas[docType] = schema
+}
+
+// LoadSchemaFile registers every type defined in the file, and persists types defined
+// later on back to it.
+func (f *DocumentFactory) LoadSchemaFile(path string) error {
+	schemas, err := LoadTypeSchemas(path)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		if err := f.defineType(schema); err != nil {
+			return fmt.Errorf("document type %q in %s: %w", schema.Title, path, err)
+		}
+	}
+
+	f.mu.Lock()
+	f.schemaPath = path
+	f.mu.Unlock()
+	return nil
+}
+
+// DefineType registers (or replaces) a schema-defined type and persists it.
+func (f *DocumentFactory) DefineType(schema TypeSchema) error {
+	if err := f.defineType(schema); err != nil {
+		return err
+	}
+	return f.saveSchemas()
+}
+
+// RemoveType unregisters a schema-defined type. documents of that type stay readable
+// through the metadata endpoints, new ones can't be created.
+func (f *DocumentFactory) RemoveType(docType string) error {
+	f.mu.Lock()
+	schema, found := f.schemas[docType]
+	switch {
+	case !found:
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownType, docType)
+	case schema.BuiltIn:
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrBuiltInType, docType)
+	}
+	delete(f.registry, docType)
+	delete(f.schemas, docType)
+	f.mu.Unlock()
+
+	return f.saveSchemas()
+}
+
+func (f *DocumentFactory) defineType(schema TypeSchema) error {
+	schema.BuiltIn = false
+	if err := schema.Check(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if existing, found := f.schemas[schema.Title]; found && existing.BuiltIn {
+		return fmt.Errorf("%w: %s", ErrBuiltInType, schema.Title)
+	}
+	f.schemas[schema.Title] = schema
+	f.registry[schema.Title] = func() Document { return NewSchemaDocument(schema) }
+	return nil
+}
+
+func (f *DocumentFactory) saveSchemas() error {
+	f.mu.RLock()
+	path := f.schemaPath
+	var defined []TypeSchema
+	for _, name := range sortedKeys(f.schemas) {
+		if !f.schemas[name].BuiltIn {
+			defined = append(defined, f.schemas[name])
+		}
+	}
+	f.mu.RUnlock()
+
+	if path == "" {
+		return nil
+	}
+	return SaveTypeSchemas(path, defined)
 }
 
 // NewDocument dynamically creates a document instance.
 func (f *DocumentFactory) NewDocument(docType string) (Document, error) {
-	if factory, found := f.registry[docType]; found {
+	f.mu.RLock()
+	factory, found := f.registry[docType]
+	f.mu.RUnlock()
+
+	if found {
 		return factory(), nil
 	}
 	return nil, fmt.Errorf("unknown document type: %s", docType)
@@ -388,11 +699,29 @@ func (f *DocumentFactory) NewDocument(docType string) (Document, error) {
 
 // GetRegisteredTypes returns a list of all registered document type names.
 func (f *DocumentFactory) GetRegisteredTypes() []string {
-	types := make([]string, 0, len(f.registry))
-	for docType := range f.registry {
-		types = append(types, docType)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return sortedKeys(f.registry)
+}
+
+// GetSchema returns the schema of a registered type.
+func (f *DocumentFactory) GetSchema(docType string) (TypeSchema, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	schema, found := f.schemas[docType]
+	return schema, found
+}
+
+// GetSchemas returns the schema of every registered type, sorted by name.
+func (f *DocumentFactory) GetSchemas() []TypeSchema {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	schemas := make([]TypeSchema, 0, len(f.schemas))
+	for _, name := range sortedKeys(f.schemas) {
+		schemas = append(schemas, f.schemas[name])
 	}
-	return types
+	return schemas
 }
 
 //---------------------------------------------------
@@ -412,7 +741,7 @@ type Document interface {
 // basic type, for functionality testing. content is just a simple string.
 type Notes struct {
 	Title    string
-	Metadata MetaData
+	Metadata MetaData `json:"-"`
 	Content  string
 }
 
@@ -450,3 +779,10 @@ func (n *Notes) SetContent(content any) error {
 func (n *Notes) GetID() string {
 	return n.Metadata.Uuid
 }
+
+func (n *Notes) Schema() TypeSchema {
+	return TypeSchema{
+		Type:       "object",
+		Properties: map[string]PropertySchema{"Content": {Type: "string"}},
+	}
+}
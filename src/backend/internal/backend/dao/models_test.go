@@ -1,27 +1,26 @@
 package dao
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/google/uuid"
 )
 
-var conparams *BoltConnectionParams = &BoltConnectionParams{Path: "./test.db", Mode: 0600, Opts: nil}
+var conparams = BoltOptions{Path: "./test.db", Mode: 0600, Opts: nil}
 
 const tempDbPath = "./test.db"
 
 func initDB() (*BoltDao, error) {
-	db := BoltDao{}
-	err := db.Connect(conparams)
-	if err != nil {
-		return nil, err
-	}
-
-	return &db, nil
+	return NewBoltDao(conparams)
 }
 
 func TestWhenCreateBoltDBExpectDbFile(t *testing.T) {
@@ -76,7 +75,7 @@ func TestWhenCreateTableExpectTable(t *testing.T) {
 		Content:  "THIS IS A TEST DOCUMENT",
 	}
 
-	err = db.Create(doc)
+	err = db.Create(context.Background(), doc)
 	if err != nil {
 		t.Errorf("error inserting document: %s", err)
 	}
@@ -95,100 +94,79 @@ func TestWhenCreateTableExpectTable(t *testing.T) {
 }
 
 func TestWhenCreateRecordExpectRecord(t *testing.T) {
-	defer os.Remove(tempDbPath)
-
-	db, err := initDB()
-	if err != nil {
-		t.Fatalf("error initialising DB: %s", err)
-	}
-	defer db.Disconnect()
-
-	meta := MetaData{
-		Title:       "test",
-		Author:      "me",
-		PublishDate: "right now",
-		LastUpdated: "right now",
-		FileType:    "md",
-		Uuid:        uuid.New().String(),
-	}
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		meta := MetaData{
+			Title:       "test",
+			Author:      "me",
+			PublishDate: "right now",
+			LastUpdated: "right now",
+			FileType:    "md",
+			Uuid:        uuid.New().String(),
+		}
 
-	doc := &Notes{
-		Title:    "test",
-		Metadata: meta,
-		Content:  "THIS IS A TEST DOCUMENT",
-	}
+		doc := &Notes{
+			Title:    "test",
+			Metadata: meta,
+			Content:  "THIS IS A TEST DOCUMENT",
+		}
 
-	err = db.Create(doc)
-	if err != nil {
-		t.Errorf("error inserting document: %s", err)
-	}
+		err := db.Create(context.Background(), doc)
+		if err != nil {
+			t.Errorf("error inserting document: %s", err)
+		}
 
-	resMeta := MetaData{}
-	err = db.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("documents"))
-		if bucket == nil {
-			return fmt.Errorf("documents bucket does not exist")
+		resMeta := MetaData{}
+		data, err := db.ReadRaw(context.Background(), uuid.MustParse(doc.GetID()))
+		if err == nil {
+			err = json.Unmarshal(data, &resMeta)
 		}
 
-		data := bucket.Get([]byte(doc.GetID()))
-		if data == nil {
-			return fmt.Errorf("document not found")
+		if err != nil {
+			t.Errorf("error retrieving document: %s", err)
 		}
 
-		return json.Unmarshal(data, &resMeta)
+		if resMeta.Uuid != meta.Uuid {
+			t.Error("UUIDs do not match")
+		}
 	})
-
-	if err != nil {
-		t.Errorf("error retrieving document: %s", err)
-	}
-
-	if resMeta.Uuid != meta.Uuid {
-		t.Error("UUIDs do not match")
-	}
 }
 
 func TestWhenSearchByKeyValueExpectRecords(t *testing.T) {
-	defer os.Remove(tempDbPath)
-
-	db, err := initDB()
-	if err != nil {
-		t.Fatalf("error initialising DB: %s", err)
-	}
-	defer db.Disconnect()
-
-	meta := MetaData{
-		Title:       "test",
-		Author:      "me",
-		PublishDate: "right now",
-		LastUpdated: "right now",
-		FileType:    "md",
-		Uuid:        uuid.New().String(),
-	}
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		meta := MetaData{
+			Title:       "test",
+			Author:      "me",
+			PublishDate: "right now",
+			LastUpdated: "right now",
+			FileType:    "md",
+			Uuid:        uuid.New().String(),
+		}
 
-	doc := &Notes{
-		Title:    "test",
-		Metadata: meta,
-		Content:  "THIS IS A TEST DOCUMENT",
-	}
+		doc := &Notes{
+			Title:    "test",
+			Metadata: meta,
+			Content:  "THIS IS A TEST DOCUMENT",
+		}
 
-	err = db.Create(doc)
-	if err != nil {
-		t.Errorf("error inserting document: %s", err)
-	}
+		err := db.Create(context.Background(), doc)
+		if err != nil {
+			t.Errorf("error inserting document: %s", err)
+		}
 
-	metas, err := db.SearchByKeyValue("Title", "test")
-	if err != nil {
-		t.Errorf("error searching by Key-Value pair: %s", err)
-	}
+		metas, err := db.SearchByKeyValue(context.Background(), "Title", "test")
+		if err != nil {
+			t.Errorf("error searching by Key-Value pair: %s", err)
+		}
 
-	if len(metas) == 0 {
-		t.Errorf("no records retrieved")
-	}
+		if len(metas) == 0 {
+			t.Errorf("no records retrieved")
+		}
 
-	empty := metas[len(metas)-1] == MetaData{}
-	if empty {
-		t.Errorf("metadata empty")
-	}
+		empty := reflect.DeepEqual(metas[len(metas)-1], MetaData{})
+		if empty {
+			t.Errorf("metadata empty")
+		}
+	})
 }
 
 func TestWhenDisconnectDBisNil(t *testing.T) {
@@ -210,47 +188,274 @@ func TestWhenDisconnectDBisNil(t *testing.T) {
 }
 
 func TestWhenDeleteRecordExpectDeletedRecord(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		meta := MetaData{
+			Title:       "test",
+			Author:      "me",
+			PublishDate: "right now",
+			LastUpdated: "right now",
+			FileType:    "md",
+			Uuid:        uuid.New().String(),
+		}
+
+		doc := &Notes{
+			Title:    "test",
+			Metadata: meta,
+			Content:  "THIS IS A TEST DOCUMENT",
+		}
+
+		err := db.Create(context.Background(), doc)
+		if err != nil {
+			t.Errorf("error inserting document: %s", err)
+		}
+
+		docUUID, err := uuid.Parse(doc.GetID())
+		if err != nil {
+			t.Errorf("error parsing UUID: %s", err)
+		}
+
+		err = db.Delete(context.Background(), docUUID)
+		if err != nil {
+			t.Errorf("error deleting document: %s", err)
+		}
+
+		var d Document = &Notes{}
+		_, err = db.Read(context.Background(), &d, docUUID)
+		if err == nil {
+			t.Errorf("document found, deletion failed")
+		}
+	})
+}
+
+func TestWhenSearchByListAndNestedKeyExpectRecords(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		doc := &Notes{Metadata: MetaData{
+			Title:     "Introduction to Algorithms",
+			Author:    "Cormen; Leiserson & Rivest and Stein",
+			Tags:      []string{"algorithms", "textbook"},
+			PageCount: 1312,
+			Custom:    map[string]string{"series": "MIT Press"},
+			Uuid:      uuid.New().String(),
+		}}
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error inserting document: %s", err)
+		}
+
+		cases := map[string]string{
+			"Authors":       "Rivest",
+			"Tags":          "textbook",
+			"PageCount":     "1312",
+			"Custom.series": "MIT Press",
+		}
+		for key, value := range cases {
+			metas, err := db.SearchByKeyValue(context.Background(), key, value)
+			if err != nil {
+				t.Fatalf("error searching %s=%s: %s", key, value, err)
+			}
+			if len(metas) != 1 {
+				t.Errorf("wanted 1 record for %s=%s; have %d", key, value, len(metas))
+			}
+		}
+
+		metas, err := db.SearchByKeyValue(context.Background(), "Custom.missing", "MIT Press")
+		if err != nil {
+			t.Fatalf("error searching: %s", err)
+		}
+		if len(metas) != 0 {
+			t.Errorf("wanted no records for a missing custom key; have %d", len(metas))
+		}
+
+		metas, err = db.FuzzySearch(context.Background(), "textbook")
+		if err != nil {
+			t.Fatalf("error fuzzy searching: %s", err)
+		}
+		if len(metas) != 1 {
+			t.Errorf("wanted fuzzy search to match tags; have %d records", len(metas))
+		}
+	})
+}
+
+func TestWhenConnectExpectLegacyAuthorsMigrated(t *testing.T) {
 	defer os.Remove(tempDbPath)
 
+	// a record as written before Authors existed
+	id := uuid.New().String()
+	legacy := fmt.Sprintf(`{"Title":"old","Author":"Kernighan and Ritchie","DocType":"Notes","Uuid":%q}`, id)
+
+	raw, err := bolt.Open(tempDbPath, 0600, nil)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+	err = raw.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("documents"))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), []byte(legacy))
+	})
+	raw.Close()
+	if err != nil {
+		t.Fatalf("error writing legacy record: %s", err)
+	}
+
 	db, err := initDB()
 	if err != nil {
 		t.Fatalf("error initialising DB: %s", err)
 	}
 	defer db.Disconnect()
 
-	meta := MetaData{
-		Title:       "test",
-		Author:      "me",
-		PublishDate: "right now",
-		LastUpdated: "right now",
-		FileType:    "md",
-		Uuid:        uuid.New().String(),
+	docID, _ := uuid.Parse(id)
+	data, err := db.ReadRaw(context.Background(), docID)
+	if err != nil {
+		t.Fatalf("error reading migrated record: %s", err)
 	}
 
-	doc := &Notes{
-		Title:    "test",
-		Metadata: meta,
-		Content:  "THIS IS A TEST DOCUMENT",
+	var meta MetaData
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("error decoding migrated record: %s", err)
 	}
-
-	err = db.Create(doc)
-	if err != nil {
-		t.Errorf("error inserting document: %s", err)
+	if !reflect.DeepEqual(meta.Authors, []string{"Kernighan", "Ritchie"}) {
+		t.Errorf("wanted Authors [Kernighan Ritchie]; have %q", meta.Authors)
 	}
-
-	docUUID, err := uuid.Parse(doc.GetID())
-	if err != nil {
-		t.Errorf("error parsing UUID: %s", err)
+	if meta.Author != "Kernighan and Ritchie" {
+		t.Errorf("wanted Author untouched; have %q", meta.Author)
 	}
-
-	err = db.Delete(docUUID)
-	if err != nil {
-		t.Errorf("error deleting document: %s", err)
+	if backup := db.MigrationReport().Backup; backup != "" {
+		os.Remove(backup)
 	}
+}
 
-	var d Document = &Notes{}
-	_, err = db.Read(&d, docUUID)
-	if err == nil {
-		t.Errorf("document found, deletion failed")
-	}
+func TestWhenCreateAndUpdateExpectTimestamps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		doc := &Notes{Content: "stamped"}
+		doc.SetMetaData(MetaData{Title: "stamped", DocType: "Notes", CreatedAt: "1970-01-01T00:00:00Z", Uuid: uuid.New().String()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error inserting document: %s", err)
+		}
+
+		created := doc.GetMetaData()
+		createdAt, err := time.Parse(time.RFC3339Nano, created.CreatedAt)
+		if err != nil || time.Since(createdAt) > time.Minute {
+			t.Fatalf("wanted CreatedAt to be now; have %q", created.CreatedAt)
+		}
+		if created.LastUpdated != created.CreatedAt {
+			t.Errorf("wanted LastUpdated to start at CreatedAt; have %q", created.LastUpdated)
+		}
+
+		// a client can't move CreatedAt by sending it back
+		updated := created
+		updated.Title = "restamped"
+		updated.CreatedAt = ""
+		doc.SetMetaData(updated)
+		if err := db.Update(context.Background(), doc); err != nil {
+			t.Fatalf("error updating document: %s", err)
+		}
+
+		var d Document = &Notes{}
+		read, err := db.Read(context.Background(), &d, uuid.MustParse(doc.GetID()))
+		if err != nil {
+			t.Fatalf("error reading document: %s", err)
+		}
+		meta := read.GetMetaData()
+		if meta.CreatedAt != created.CreatedAt {
+			t.Errorf("wanted CreatedAt %q to be kept; have %q", created.CreatedAt, meta.CreatedAt)
+		}
+		lastUpdated, err := time.Parse(time.RFC3339Nano, meta.LastUpdated)
+		if err != nil || lastUpdated.Before(createdAt) {
+			t.Errorf("wanted LastUpdated after CreatedAt; have %q", meta.LastUpdated)
+		}
+	})
+}
+
+func TestWhenFuzzySearchExpectCaseInsensitiveSubstrings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Gödel, Escher, Bach", DocType: "Notes", Tags: []string{"recursion"}, Uuid: uuid.New().String()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error inserting document: %s", err)
+		}
+
+		cases := map[string]int{
+			"escher":  1,
+			"GÖDEL":   1,
+			"cursio":  1,
+			"er, b":   1,
+			"ch":      1,
+			"100%":    0,
+			"fractal": 0,
+		}
+		for query, want := range cases {
+			metas, err := db.FuzzySearch(context.Background(), query)
+			if err != nil {
+				t.Fatalf("error fuzzy searching %q: %s", query, err)
+			}
+			if len(metas) != want {
+				t.Errorf("wanted %d records for %q; have %d", want, query, len(metas))
+			}
+		}
+	})
+}
+
+func TestWhenContextCancelledExpectOperationsStopped(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Kept", DocType: "Notes", Uuid: uuid.NewString()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := db.GetAll(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("wanted context.Canceled from GetAll; have %v", err)
+		}
+		other := &Notes{}
+		other.SetMetaData(MetaData{Title: "Dropped", DocType: "Notes", Uuid: uuid.NewString()})
+		if err := db.Create(ctx, other); !errors.Is(err, context.Canceled) {
+			t.Errorf("wanted context.Canceled from Create; have %v", err)
+		}
+
+		all, err := db.GetAll(context.Background())
+		if err != nil || len(all) != 1 {
+			t.Errorf("wanted only the first document stored; have %+v, %v", all, err)
+		}
+	})
+}
+
+func TestWhenReadRecordsExpectThemInOrderAcrossBatches(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		titles := make([]string, recordBatch+5)
+		for i := range titles {
+			titles[i] = fmt.Sprintf("Note %d", i)
+		}
+		ids := createBulkNotes(t, db, titles...)
+		// reversed, with a document that doesn't exist and one in the trash among them
+		slices.Reverse(ids)
+		if _, err := db.Trash(context.Background(), ids[1], "ada"); err != nil {
+			t.Fatalf("error trashing: %s", err)
+		}
+		ids = slices.Insert(ids, 3, uuid.New())
+
+		var read []string
+		err := db.ReadRecords(context.Background(), ids, func(record Record) error {
+			read = append(read, record.Title)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("error reading records: %s", err)
+		}
+		if len(read) != len(titles)-1 || read[0] != titles[len(titles)-1] || read[1] != titles[len(titles)-3] || read[len(read)-1] != "Note 0" {
+			t.Errorf("wanted every current record in the order asked for; have %d starting %v", len(read), read[:2])
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = db.ReadRecords(context.Background(), ids, func(Record) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("wanted fn's error to stop it; have %v after %d calls", err, calls)
+		}
+	})
 }
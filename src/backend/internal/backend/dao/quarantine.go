@@ -0,0 +1,214 @@
+package dao
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/boltdb/bolt"
+)
+
+//---------------------------------------------------
+//--------------------QUARANTINE---------------------
+//---------------------------------------------------
+
+// a record that can't be decoded would otherwise fail every scan of its bucket. scans skip
+// it instead and move it, as stored, to the "quarantine" bucket, where it waits to be
+// inspected or fixed by hand.
+
+// QuarantinedRecord is a value moved out of its bucket because it couldn't be decoded.
+type QuarantinedRecord struct {
+	Set           RecordSet `json:"Bucket"`
+	Key           string
+	Raw           []byte
+	Reason        string
+	QuarantinedAt string
+}
+
+// Stats counts what's in the database, for the metrics endpoint.
+type Stats struct {
+	Documents      int
+	Trashed        int
+	Quarantined    int
+	PendingFileOps int
+	// SkippedRecords is how many corrupt records scans have skipped since the database was opened
+	SkippedRecords int64
+}
+
+// Quarantine moves a stored value out of its bucket into "quarantine", so scans no longer
+// trip over it.
+func (b *BoltDao) Quarantine(ctx context.Context, set RecordSet, key, reason string) error {
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		return quarantineValue(tx, set, key, reason)
+	})
+	if err != nil {
+		return fmt.Errorf("could not quarantine %s/%s: %w", set, key, err)
+	}
+	return nil
+}
+
+// Quarantined returns every quarantined record.
+func (b *BoltDao) Quarantined(ctx context.Context) ([]QuarantinedRecord, error) {
+	var records []QuarantinedRecord
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		quarantine := tx.Bucket([]byte("quarantine"))
+		if quarantine == nil {
+			return nil
+		}
+		return quarantine.ForEach(func(_, v []byte) error {
+			var record QuarantinedRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("error unmarshaling quarantined record: %v", err)
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing quarantine: %w", err)
+	}
+	return records, nil
+}
+
+// QuarantineCount is how many records taken out of set are quarantined, counted by key
+// without decoding them.
+func (b *BoltDao) QuarantineCount(ctx context.Context, set RecordSet) (int, error) {
+	count := 0
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		quarantine := tx.Bucket([]byte("quarantine"))
+		if quarantine == nil {
+			return nil
+		}
+		prefix := []byte(string(set) + "/")
+		c := quarantine.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error counting quarantine: %w", err)
+	}
+	return count, nil
+}
+
+// Stats counts the records in each bucket.
+func (b *BoltDao) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{SkippedRecords: b.skipped.Load()}
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		for name, count := range map[string]*int{
+			string(RecordsCurrent): &stats.Documents,
+			string(RecordsTrashed): &stats.Trashed,
+			"quarantine":           &stats.Quarantined,
+			"journal":              &stats.PendingFileOps,
+		} {
+			if bucket := tx.Bucket([]byte(name)); bucket != nil {
+				*count = bucket.Stats().KeyN
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("error reading database stats: %w", err)
+	}
+	return stats, nil
+}
+
+// scanBucket decodes every value in a bucket, calling fn with each. A value that can't be
+// decoded is skipped, and quarantined once the scan's transaction is over. A missing bucket
+// is an error when required, otherwise it's scanned as empty.
+func scanBucket[T any](ctx context.Context, b *BoltDao, set RecordSet, required bool, fn func(T)) error {
+	corrupt := map[string]corruptValue{}
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(set))
+		if bucket == nil && required {
+			return fmt.Errorf("%s bucket does not exist", set)
+		}
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var value T
+			if err := json.Unmarshal(v, &value); err != nil {
+				corrupt[string(k)] = corruptValue{raw: slices.Clone(v), reason: err.Error()}
+				continue
+			}
+			fn(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(corrupt) > 0 {
+		b.skipped.Add(int64(len(corrupt)))
+		b.quarantineCorrupt(set, corrupt)
+	}
+	return nil
+}
+
+// quarantineCorrupt moves the values a scan couldn't decode to quarantine. A failure only
+// means the next scan skips them again, so it's logged rather than failing the scan.
+func (b *BoltDao) quarantineCorrupt(set RecordSet, corrupt map[string]corruptValue) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(set))
+		for key, value := range corrupt {
+			// it may have been fixed or removed since the scan
+			if !bytes.Equal(bucket.Get([]byte(key)), value.raw) {
+				continue
+			}
+			if err := quarantineValue(tx, set, key, value.reason); err != nil {
+				return err
+			}
+			log.Printf("quarantined corrupt record %s/%s: %s", set, key, value.reason)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to quarantine corrupt records in %s: %v", set, err)
+	}
+}
+
+type corruptValue struct {
+	raw    []byte
+	reason string
+}
+
+func quarantineValue(tx *bolt.Tx, set RecordSet, key, reason string) error {
+	bucket := tx.Bucket([]byte(set))
+	if bucket == nil {
+		return ErrDocumentNotFound
+	}
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return ErrDocumentNotFound
+	}
+
+	quarantine, err := tx.CreateBucketIfNotExists([]byte("quarantine"))
+	if err != nil {
+		return fmt.Errorf("could not create quarantine bucket: %v", err)
+	}
+	entry, err := json.Marshal(QuarantinedRecord{
+		Set:           set,
+		Key:           key,
+		Raw:           slices.Clone(data),
+		Reason:        reason,
+		QuarantinedAt: Timestamp(),
+	})
+	if err != nil {
+		return err
+	}
+	// keyed by bucket as well, the same key could turn up in both
+	if err := quarantine.Put([]byte(string(set)+"/"+key), entry); err != nil {
+		return err
+	}
+	return bucket.Delete([]byte(key))
+}
@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWhenScanHitsCorruptRecordExpectSkippedAndQuarantined(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		for _, title := range []string{"First", "Second"} {
+			doc := &Notes{}
+			doc.SetMetaData(MetaData{Title: title, DocType: "Notes", Uuid: uuid.NewString()})
+			if err := db.Create(context.Background(), doc); err != nil {
+				t.Fatalf("error creating document: %s", err)
+			}
+		}
+		bad, trashedBad := uuid.NewString(), uuid.NewString()
+		putCorruptRecord(t, db, RecordsCurrent, bad)
+		putCorruptRecord(t, db, RecordsTrashed, trashedBad)
+
+		found, err := db.FuzzySearch(context.Background(), "first")
+		if err != nil || len(found) != 1 {
+			t.Fatalf("wanted the search to skip the corrupt record; have %v, %v", found, err)
+		}
+		if trashed, err := db.Trashed(context.Background()); err != nil || len(trashed) != 0 {
+			t.Fatalf("wanted the trash listing to skip the corrupt record; have %v, %v", trashed, err)
+		}
+
+		if all, err := db.GetAll(context.Background()); err != nil || len(all) != 2 {
+			t.Errorf("wanted the remaining records; have %v, %v", all, err)
+		}
+
+		// a search may not have come across it, the full listing has
+		quarantined, err := db.Quarantined(context.Background())
+		if err != nil || len(quarantined) != 2 {
+			t.Fatalf("wanted both corrupt records quarantined; have %+v, %v", quarantined, err)
+		}
+		for _, record := range quarantined {
+			if record.Reason == "" || len(record.Raw) == 0 {
+				t.Errorf("wanted the reason and raw value kept; have %+v", record)
+			}
+		}
+		if count, err := db.QuarantineCount(context.Background(), RecordsCurrent); err != nil || count != 1 {
+			t.Errorf("wanted one quarantined document; have %d, %v", count, err)
+		}
+		stats, err := db.Stats(context.Background())
+		if err != nil {
+			t.Fatalf("error reading stats: %s", err)
+		}
+		if stats.Documents != 2 || stats.Trashed != 0 || stats.Quarantined != 2 || stats.SkippedRecords != 2 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,305 @@
+package dao
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+//---------------------------------------------------
+//--------------------TYPE-SCHEMA--------------------
+//---------------------------------------------------
+
+// TypeSchema describes the fields a document type carries beyond MetaData. It's a subset of
+// JSON Schema: an object whose properties are strings, integers, numbers, booleans or
+// arrays of those, with required fields, enums and string patterns.
+type TypeSchema struct {
+	// Title is the DocType name
+	Title       string                    `json:"title"`
+	Description string                    `json:"description,omitempty"`
+	Type        string                    `json:"type"`
+	Properties  map[string]PropertySchema `json:"properties"`
+	Required    []string                  `json:"required,omitempty"`
+	// RequiredMetaData lists metadata fields (e.g. Author) this type can't be stored without
+	RequiredMetaData []string `json:"x-required-metadata,omitempty"`
+	// BuiltIn is set on types compiled into the binary, which can't be redefined or removed
+	BuiltIn bool `json:"x-builtin,omitempty"`
+}
+
+type PropertySchema struct {
+	Type        string          `json:"type"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Enum        []any           `json:"enum,omitempty"`
+	Pattern     string          `json:"pattern,omitempty"`
+	Items       *PropertySchema `json:"items,omitempty"`
+}
+
+// SchemaProvider is implemented by built-in document types, so /data/types can describe them
+// the same way as schema-defined ones.
+type SchemaProvider interface {
+	Schema() TypeSchema
+}
+
+var (
+	propertyName   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	propertyTypes  = []string{"string", "integer", "number", "boolean", "array"}
+	reservedFields = metaDataFieldNames()
+)
+
+// property names may not shadow MetaData, the handlers decode both from the same object
+func metaDataFieldNames() []string {
+	t := reflect.TypeOf(MetaData{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, t.Field(i).Name)
+	}
+	return names
+}
+
+// Check reports everything wrong with the schema itself, before it's registered.
+func (s TypeSchema) Check() error {
+	var problems ValidationError
+	if !propertyName.MatchString(s.Title) {
+		problems.add("title", "must start with a letter and contain only letters, digits and underscores")
+	}
+	if s.Type != "object" {
+		problems.add("type", `must be "object"`)
+	}
+	for name, prop := range s.Properties {
+		field := "properties." + name
+		switch {
+		case !propertyName.MatchString(name):
+			problems.add(field, "must start with a letter and contain only letters, digits and underscores")
+		case slices.Contains(reservedFields, name):
+			problems.add(field, "clashes with a metadata field")
+		}
+		checkProperty(&problems, field, prop, true)
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			problems.add("required", "%s is not a property", name)
+		}
+	}
+	for _, name := range s.RequiredMetaData {
+		if !slices.Contains(requirableMetaData, name) {
+			problems.add("x-required-metadata", "%s is not one of %s", name, strings.Join(requirableMetaData, ", "))
+		}
+	}
+	return problems.err()
+}
+
+func checkProperty(problems *ValidationError, field string, prop PropertySchema, allowArray bool) {
+	if !slices.Contains(propertyTypes, prop.Type) || (prop.Type == "array" && !allowArray) {
+		problems.add(field+".type", "unsupported type %q", prop.Type)
+		return
+	}
+	if prop.Type == "array" {
+		if prop.Items == nil {
+			problems.add(field+".items", "required for arrays")
+			return
+		}
+		checkProperty(problems, field+".items", *prop.Items, false)
+	}
+	if prop.Pattern != "" {
+		if _, err := regexp.Compile(prop.Pattern); err != nil {
+			problems.add(field+".pattern", "invalid pattern: %v", err)
+		}
+	}
+	for _, value := range prop.Enum {
+		if msg := checkValue(prop, value); msg != "" {
+			problems.add(field+".enum", "%v %s", value, msg)
+		}
+	}
+}
+
+// Validate checks decoded JSON field values against the schema.
+func (s TypeSchema) Validate(fields map[string]any) error {
+	var problems ValidationError
+	for _, name := range s.Required {
+		if isEmpty(fields[name]) {
+			problems.add(name, "required")
+		}
+	}
+	for _, name := range sortedKeys(s.Properties) {
+		value, ok := fields[name]
+		if !ok || value == nil {
+			continue
+		}
+		if msg := checkValue(s.Properties[name], value); msg != "" {
+			problems.add(name, "%s", msg)
+		}
+	}
+	return problems.err()
+}
+
+// checkValue returns what's wrong with a single value, or "" when it's valid
+func checkValue(prop PropertySchema, value any) string {
+	switch prop.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if prop.Pattern != "" {
+			if re, err := regexp.Compile(prop.Pattern); err == nil && !re.MatchString(str) {
+				return "must match " + prop.Pattern
+			}
+		}
+	case "integer":
+		num, ok := value.(float64)
+		if !ok || num != float64(int64(num)) {
+			return "must be an integer"
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return "must be a list"
+		}
+		for _, item := range items {
+			if msg := checkValue(*prop.Items, item); msg != "" {
+				return "items " + msg
+			}
+		}
+		return ""
+	}
+
+	if len(prop.Enum) > 0 && !slices.ContainsFunc(prop.Enum, func(e any) bool { return e == value }) {
+		options := make([]string, 0, len(prop.Enum))
+		for _, e := range prop.Enum {
+			options = append(options, fmt.Sprint(e))
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	}
+	return ""
+}
+
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+//---------------------------------------------------
+//------------------SCHEMA-DOCUMENT------------------
+//---------------------------------------------------
+
+// SchemaDocument is the Document behind every schema-defined type. Its fields are kept as
+// decoded JSON and only the properties named by the schema are accepted.
+type SchemaDocument struct {
+	baseDocument
+	Fields map[string]any
+	schema TypeSchema
+}
+
+func NewSchemaDocument(schema TypeSchema) *SchemaDocument {
+	return &SchemaDocument{Fields: map[string]any{}, schema: schema}
+}
+
+// UnmarshalJSON picks the schema's properties out of an object, anything else (e.g. the
+// metadata sent alongside) is ignored.
+func (d *SchemaDocument) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if d.Fields == nil {
+		d.Fields = map[string]any{}
+	}
+	for name := range d.schema.Properties {
+		if value, ok := raw[name]; ok {
+			d.Fields[name] = value
+		}
+	}
+	return nil
+}
+
+func (d *SchemaDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Fields)
+}
+
+func (d *SchemaDocument) Validate() error {
+	return d.schema.Validate(d.Fields)
+}
+
+func (d *SchemaDocument) Schema() TypeSchema {
+	return d.schema
+}
+
+//---------------------------------------------------
+//-------------------SCHEMA-FILE---------------------
+//---------------------------------------------------
+
+// LoadTypeSchemas reads a JSON array of schemas. a missing file is not an error, it just
+// means no types have been defined yet.
+func LoadTypeSchemas(path string) ([]TypeSchema, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read type schemas: %w", err)
+	}
+
+	var schemas []TypeSchema
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, fmt.Errorf("failed to parse type schemas in %s: %w", path, err)
+	}
+	return schemas, nil
+}
+
+// SaveTypeSchemas replaces the schema file, via a temporary file so a crash can't truncate it.
+func SaveTypeSchemas(path string, schemas []TypeSchema) error {
+	data, err := json.MarshalIndent(schemas, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode type schemas: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".types-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save type schemas: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save type schemas: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save type schemas: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save type schemas: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func thesisSchema() TypeSchema {
+	return TypeSchema{
+		Title: "Thesis",
+		Type:  "object",
+		Properties: map[string]PropertySchema{
+			"University": {Type: "string"},
+			"Degree":     {Type: "string", Enum: []any{"MSc", "PhD"}},
+			"Year":       {Type: "integer"},
+			"Advisors":   {Type: "array", Items: &PropertySchema{Type: "string"}},
+		},
+		Required: []string{"University", "Degree"},
+	}
+}
+
+func TestWhenInvalidSchemaExpectCheckErrors(t *testing.T) {
+	schema := TypeSchema{
+		Title: "Bad Name",
+		Type:  "object",
+		Properties: map[string]PropertySchema{
+			"Title":  {Type: "string"},
+			"Scores": {Type: "array"},
+			"When":   {Type: "date"},
+		},
+		Required: []string{"Missing"},
+	}
+
+	var invalid *ValidationError
+	if err := schema.Check(); !errors.As(err, &invalid) {
+		t.Fatalf("wanted a ValidationError; have %v", err)
+	}
+	// title, the clash with MetaData.Title, missing items, unknown type and the unknown required field
+	if len(invalid.Fields) != 5 {
+		t.Errorf("wanted 5 problems; have %+v", invalid.Fields)
+	}
+
+	if err := thesisSchema().Check(); err != nil {
+		t.Errorf("wanted a valid schema; have %v", err)
+	}
+}
+
+func TestWhenSchemaDocumentDecodedExpectOnlyProperties(t *testing.T) {
+	doc := NewSchemaDocument(thesisSchema())
+	body := `{"Title": "On Things", "University": "Leiden", "Degree": "BA", "Year": 2020.5, "Advisors": ["A", 3]}`
+	if err := json.Unmarshal([]byte(body), doc); err != nil {
+		t.Fatalf("error decoding: %s", err)
+	}
+
+	if _, ok := doc.Fields["Title"]; ok {
+		t.Error("wanted metadata fields to be left out of the payload")
+	}
+
+	var invalid *ValidationError
+	if err := doc.Validate(); !errors.As(err, &invalid) {
+		t.Fatalf("wanted a ValidationError; have %v", err)
+	}
+	got := map[string]bool{}
+	for _, field := range invalid.Fields {
+		got[field.Field] = true
+	}
+	for _, field := range []string{"Degree", "Year", "Advisors"} {
+		if !got[field] {
+			t.Errorf("wanted an error for %s; have %+v", field, invalid.Fields)
+		}
+	}
+}
+
+func TestWhenTypeDefinedExpectPersistedAndReloaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "types.json")
+
+	factory := NewDocumentFactory()
+	factory.RegisterDocumentType("Book", func() Document { return &Book{} })
+	if err := factory.LoadSchemaFile(path); err != nil {
+		t.Fatalf("error loading missing schema file: %s", err)
+	}
+	if err := factory.DefineType(thesisSchema()); err != nil {
+		t.Fatalf("error defining type: %s", err)
+	}
+
+	if err := factory.DefineType(TypeSchema{Title: "Book", Type: "object"}); !errors.Is(err, ErrBuiltInType) {
+		t.Errorf("wanted ErrBuiltInType redefining Book; have %v", err)
+	}
+
+	reloaded := NewDocumentFactory()
+	if err := reloaded.LoadSchemaFile(path); err != nil {
+		t.Fatalf("error reloading schema file: %s", err)
+	}
+	doc, err := reloaded.NewDocument("Thesis")
+	if err != nil {
+		t.Fatalf("wanted Thesis after reload: %s", err)
+	}
+	if _, ok := doc.(*SchemaDocument); !ok {
+		t.Errorf("wanted a *SchemaDocument; have %T", doc)
+	}
+
+	if err := reloaded.RemoveType("Thesis"); err != nil {
+		t.Fatalf("error removing type: %s", err)
+	}
+	if err := reloaded.RemoveType("Thesis"); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("wanted ErrUnknownType removing twice; have %v", err)
+	}
+}
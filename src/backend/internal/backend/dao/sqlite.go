@@ -0,0 +1,690 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+//---------------------------------------------------
+//-------------------SQLITE-DAO----------------------
+//---------------------------------------------------
+
+// the documents table holds MetaData in typed columns, with the list and map fields and the
+// type specific payload as JSON. a trashed document keeps its row with deleted_at set, in
+// place of Bolt's "trash" bucket. documents_fts indexes the fields FuzzySearch looks
+// through, with the trigram tokenizer so a query matches anywhere in a word.
+
+// SQLiteOptions are what NewSQLiteDao opens a database with
+type SQLiteOptions struct {
+	Path string
+	Mode fs.FileMode
+	// BusyTimeout is how long a statement waits on another process's lock, 5s when zero
+	BusyTimeout time.Duration
+	// Pragmas are set on every connection, by name, e.g. "journal_mode": "wal"
+	Pragmas map[string]string
+}
+
+// SQLiteDao is the DAO over a SQLite database, through a pure Go driver so it builds
+// without cgo.
+type SQLiteDao struct {
+	db *sql.DB
+	// skipped counts the corrupt records scans have passed over, as for BoltDao
+	skipped atomic.Int64
+}
+
+// sqliteMigrations are applied in order on opening, the database's user_version counting
+// those it has been through. like Bolt's migrations, a shipped entry is never edited.
+var sqliteMigrations = []string{
+	`CREATE TABLE documents (
+		id            INTEGER PRIMARY KEY,
+		uuid          TEXT NOT NULL UNIQUE,
+		title         TEXT NOT NULL DEFAULT '',
+		author        TEXT NOT NULL DEFAULT '',
+		authors       TEXT NOT NULL DEFAULT 'null',
+		publish_date  TEXT NOT NULL DEFAULT '',
+		created_at    TEXT NOT NULL DEFAULT '',
+		last_updated  TEXT NOT NULL DEFAULT '',
+		file_type     TEXT NOT NULL DEFAULT '',
+		doc_type      TEXT NOT NULL DEFAULT '',
+		dewey_decimal TEXT NOT NULL DEFAULT '',
+		path          TEXT NOT NULL DEFAULT '',
+		size          INTEGER NOT NULL DEFAULT 0,
+		hash          TEXT NOT NULL DEFAULT '',
+		isbn          TEXT NOT NULL DEFAULT '',
+		doi           TEXT NOT NULL DEFAULT '',
+		publisher     TEXT NOT NULL DEFAULT '',
+		edition       TEXT NOT NULL DEFAULT '',
+		language      TEXT NOT NULL DEFAULT '',
+		page_count    INTEGER NOT NULL DEFAULT 0,
+		tags          TEXT NOT NULL DEFAULT 'null',
+		description   TEXT NOT NULL DEFAULT '',
+		custom        TEXT NOT NULL DEFAULT 'null',
+		payload       TEXT NOT NULL DEFAULT '',
+		deleted_at    TEXT,
+		deleted_by    TEXT
+	);
+	CREATE INDEX documents_deleted_at ON documents (deleted_at);
+	CREATE VIRTUAL TABLE documents_fts USING fts5 (text, tokenize = 'trigram');
+	CREATE TABLE revisions (
+		uuid     TEXT NOT NULL,
+		number   INTEGER NOT NULL,
+		revision TEXT NOT NULL,
+		PRIMARY KEY (uuid, number)
+	);
+	CREATE TABLE journal (
+		path    TEXT PRIMARY KEY,
+		action  TEXT NOT NULL,
+		uuid    TEXT NOT NULL DEFAULT '',
+		created TEXT NOT NULL
+	);
+	CREATE TABLE quarantine (
+		bucket         TEXT NOT NULL,
+		key            TEXT NOT NULL,
+		raw            BLOB NOT NULL,
+		reason         TEXT NOT NULL,
+		quarantined_at TEXT NOT NULL,
+		PRIMARY KEY (bucket, key)
+	);`,
+}
+
+// NewSQLiteDao opens the database at opts.Path, creating it if it doesn't exist, and
+// applies any of sqliteMigrations it hasn't been through.
+func NewSQLiteDao(opts SQLiteOptions) (*SQLiteDao, error) {
+	// created up front, so it gets the configured mode rather than sqlite's default
+	file, err := os.OpenFile(opts.Path, os.O_RDWR|os.O_CREATE, opts.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %v", err)
+	}
+	file.Close()
+
+	timeout := opts.BusyTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)", opts.Path, timeout.Milliseconds())
+	for _, name := range sortedKeys(opts.Pragmas) {
+		dsn += "&_pragma=" + url.QueryEscape(name+"("+opts.Pragmas[name]+")")
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %v", err)
+	}
+	// one connection, so writes are serialised the way Bolt's are
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open DB: %v", err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate DB: %w", err)
+	}
+	return &SQLiteDao{db: db}, nil
+}
+
+func (s *SQLiteDao) Disconnect() error {
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			return fmt.Errorf("failed to close DB: %v", err)
+		}
+		s.db = nil
+	}
+	return nil
+}
+
+// Snapshot writes a copy of the database made with VACUUM INTO, which reads it in a single
+// transaction.
+func (s *SQLiteDao) Snapshot(ctx context.Context, w io.Writer) (int64, error) {
+	dir, err := os.MkdirTemp("", "scriptorium-snapshot-")
+	if err != nil {
+		return 0, fmt.Errorf("error writing database snapshot: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	if _, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return 0, fmt.Errorf("error writing database snapshot: %w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error writing database snapshot: %w", err)
+	}
+	defer file.Close()
+	n, err := io.Copy(w, file)
+	if err != nil {
+		return n, fmt.Errorf("error writing database snapshot: %w", err)
+	}
+	return n, nil
+}
+
+// Create stores a new document, see Save.
+func (s *SQLiteDao) Create(ctx context.Context, doc Document) error {
+	return s.Save(ctx, doc, Change{Action: ActionCreate})
+}
+
+// Update replaces the stored record, keeping its CreatedAt and stamping LastUpdated.
+func (s *SQLiteDao) Update(ctx context.Context, doc Document) error {
+	return s.Save(ctx, doc, Change{Action: ActionUpdate})
+}
+
+// ReadRaw returns the current record as Bolt stores it, JSON encoded.
+func (s *SQLiteDao) ReadRaw(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	record, err := s.readRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving document: %w", err)
+	}
+	return data, nil
+}
+
+// ReadRecords calls fn with the record of each of ids, a batch of them read per query.
+func (s *SQLiteDao) ReadRecords(ctx context.Context, ids []uuid.UUID, fn func(Record) error) error {
+	return eachRecordBatch(ctx, ids, fn, func(batch []uuid.UUID) ([]Record, error) {
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id.String()
+		}
+		rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteRecordColumns+` FROM documents WHERE deleted_at IS NULL AND uuid IN (?`+strings.Repeat(", ?", len(batch)-1)+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		found := make(map[string]Record, len(batch))
+		for rows.Next() {
+			row, err := scanSQLiteRecord(rows)
+			if err != nil {
+				return nil, err
+			}
+			record, err := row.record()
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", row.Uuid, err)
+			}
+			found[row.Uuid] = record
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		records := make([]Record, 0, len(found))
+		for _, id := range batch {
+			if record, ok := found[id.String()]; ok {
+				records = append(records, record)
+			}
+		}
+		return records, nil
+	})
+}
+
+// Read decodes the stored record onto doc, which should be the concrete type for its DocType.
+func (s *SQLiteDao) Read(ctx context.Context, doc *Document, id uuid.UUID) (Document, error) {
+	record, err := s.readRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(record.Payload) > 0 {
+		if err := json.Unmarshal(record.Payload, *doc); err != nil {
+			return nil, fmt.Errorf("error reading %s payload: %v", record.DocType, err)
+		}
+	}
+	if err := (*doc).SetMetaData(record.MetaData); err != nil {
+		return nil, fmt.Errorf("error reading metaData: %v", err)
+	}
+	return *doc, nil
+}
+
+func (s *SQLiteDao) readRecord(ctx context.Context, id uuid.UUID) (Record, error) {
+	row, err := sqliteRow(ctx, s.db, id.String(), false)
+	if err == nil && row == nil {
+		err = ErrDocumentNotFound
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("error retrieving document: %w", err)
+	}
+	record, err := row.record()
+	if err != nil {
+		return Record{}, fmt.Errorf("error retrieving document: %w", err)
+	}
+	return record, nil
+}
+
+// Delete permanently removes a document, whether it's current or in the trash, and its history.
+func (s *SQLiteDao) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.update(ctx, func(tx *sql.Tx) error {
+		if err := deleteSQLiteDocument(tx, id.String()); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM revisions WHERE uuid = ?`, id.String())
+		return err
+	})
+}
+
+// SearchByKeyValue returns the documents whose key matches value, compared in SQL against
+// the field's column. Keys and matching are those of BoltDao: list fields match on any
+// element and "Custom.<key>" looks up a custom field.
+func (s *SQLiteDao) SearchByKeyValue(ctx context.Context, key, value string) ([]MetaData, error) {
+	condition, args, ok := sqliteKeyCondition(key, value)
+	if !ok {
+		return nil, nil
+	}
+	results, err := s.scanMetaData(ctx, condition, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error searching documents: %w", err)
+	}
+	return results, nil
+}
+
+func (s *SQLiteDao) GetAll(ctx context.Context) ([]MetaData, error) {
+	results, err := s.scanMetaData(ctx, "TRUE")
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all documents: %w", err)
+	}
+	return results, nil
+}
+
+// FuzzySearch finds the documents with query anywhere in one of their fields, ignoring case,
+// through the FTS index. Trigrams need three characters, shorter queries fall back to LIKE
+// over the same index.
+func (s *SQLiteDao) FuzzySearch(ctx context.Context, query string) ([]MetaData, error) {
+	var results []MetaData
+	var err error
+	if len([]rune(query)) >= 3 {
+		results, err = s.scanMetaData(ctx, `id IN (SELECT rowid FROM documents_fts WHERE documents_fts MATCH ?)`,
+			`"`+strings.ReplaceAll(query, `"`, `""`)+`"`)
+	} else {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+		results, err = s.scanMetaData(ctx, `id IN (SELECT rowid FROM documents_fts WHERE text LIKE ? ESCAPE '\')`,
+			"%"+escaped+"%")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error searching documents: %w", err)
+	}
+	return results, nil
+}
+
+func (s *SQLiteDao) scanMetaData(ctx context.Context, condition string, args ...any) ([]MetaData, error) {
+	var results []MetaData
+	err := s.scan(ctx, RecordsCurrent, condition, args, func(record TrashedRecord) {
+		results = append(results, record.MetaData)
+	})
+	return results, err
+}
+
+// the columns of the MetaData fields, for SearchByKeyValue
+var sqliteColumns = map[string]string{
+	"Title":        "title",
+	"Author":       "author",
+	"PublishDate":  "publish_date",
+	"CreatedAt":    "created_at",
+	"LastUpdated":  "last_updated",
+	"FileType":     "file_type",
+	"DocType":      "doc_type",
+	"DeweyDecimal": "dewey_decimal",
+	"Path":         "path",
+	"Uuid":         "uuid",
+	"Hash":         "hash",
+	"ISBN":         "isbn",
+	"DOI":          "doi",
+	"Publisher":    "publisher",
+	"Edition":      "edition",
+	"Language":     "language",
+	"Description":  "description",
+}
+
+// sqliteKeyCondition translates a SearchByKeyValue key into a WHERE condition, ok is false
+// for a key no record can match
+func sqliteKeyCondition(key, value string) (string, []any, bool) {
+	path := strings.Split(key, ".")
+	if len(path) == 2 && path[0] == "Custom" {
+		return `EXISTS (SELECT 1 FROM json_each(custom) WHERE json_each.key = ? AND json_each.value = ?)`, []any{path[1], value}, true
+	}
+	if len(path) != 1 {
+		return "", nil, false
+	}
+	switch key {
+	case "Authors", "Tags":
+		return `EXISTS (SELECT 1 FROM json_each(` + strings.ToLower(key) + `) WHERE json_each.value = ?)`, []any{value}, true
+	case "Size":
+		return `CAST(size AS TEXT) = ?`, []any{value}, true
+	case "PageCount":
+		return `CAST(page_count AS TEXT) = ?`, []any{value}, true
+	}
+	column, ok := sqliteColumns[key]
+	if !ok {
+		return "", nil, false
+	}
+	return column + ` = ?`, []any{value}, true
+}
+
+//---------------------------------------------------
+//-------------------SQLITE-ROWS---------------------
+//---------------------------------------------------
+
+const sqliteRecordColumns = `uuid, title, author, authors, publish_date, created_at, last_updated, file_type, doc_type,
+	dewey_decimal, path, size, hash, isbn, doi, publisher, edition, language, page_count, tags, description,
+	custom, payload, deleted_at, deleted_by`
+
+// sqliteRecord is a documents row as stored, the list and map fields still JSON encoded.
+// Its JSON form is what's quarantined when the row can't be decoded.
+type sqliteRecord struct {
+	Uuid         string         `json:"uuid"`
+	Title        string         `json:"title"`
+	Author       string         `json:"author"`
+	Authors      string         `json:"authors"`
+	PublishDate  string         `json:"publish_date"`
+	CreatedAt    string         `json:"created_at"`
+	LastUpdated  string         `json:"last_updated"`
+	FileType     string         `json:"file_type"`
+	DocType      string         `json:"doc_type"`
+	DeweyDecimal string         `json:"dewey_decimal"`
+	Path         string         `json:"path"`
+	Size         int64          `json:"size"`
+	Hash         string         `json:"hash"`
+	ISBN         string         `json:"isbn"`
+	DOI          string         `json:"doi"`
+	Publisher    string         `json:"publisher"`
+	Edition      string         `json:"edition"`
+	Language     string         `json:"language"`
+	PageCount    int            `json:"page_count"`
+	Tags         string         `json:"tags"`
+	Description  string         `json:"description"`
+	Custom       string         `json:"custom"`
+	Payload      string         `json:"payload"`
+	DeletedAt    sql.NullString `json:"deleted_at"`
+	DeletedBy    sql.NullString `json:"deleted_by"`
+}
+
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLiteRecord(row sqliteScanner) (sqliteRecord, error) {
+	var r sqliteRecord
+	err := row.Scan(&r.Uuid, &r.Title, &r.Author, &r.Authors, &r.PublishDate, &r.CreatedAt, &r.LastUpdated,
+		&r.FileType, &r.DocType, &r.DeweyDecimal, &r.Path, &r.Size, &r.Hash, &r.ISBN, &r.DOI, &r.Publisher,
+		&r.Edition, &r.Language, &r.PageCount, &r.Tags, &r.Description, &r.Custom, &r.Payload,
+		&r.DeletedAt, &r.DeletedBy)
+	return r, err
+}
+
+func newSQLiteRecord(record Record) (sqliteRecord, error) {
+	r := sqliteRecord{
+		Uuid:         record.Uuid,
+		Title:        record.Title,
+		Author:       record.Author,
+		PublishDate:  record.PublishDate,
+		CreatedAt:    record.CreatedAt,
+		LastUpdated:  record.LastUpdated,
+		FileType:     record.FileType,
+		DocType:      record.DocType,
+		DeweyDecimal: record.DeweyDecimal,
+		Path:         record.Path,
+		Size:         record.Size,
+		Hash:         record.Hash,
+		ISBN:         record.ISBN,
+		DOI:          record.DOI,
+		Publisher:    record.Publisher,
+		Edition:      record.Edition,
+		Language:     record.Language,
+		PageCount:    record.PageCount,
+		Description:  record.Description,
+		Payload:      string(record.Payload),
+	}
+	for _, column := range []struct {
+		value any
+		data  *string
+	}{{record.Authors, &r.Authors}, {record.Tags, &r.Tags}, {record.Custom, &r.Custom}} {
+		data, err := json.Marshal(column.value)
+		if err != nil {
+			return r, err
+		}
+		*column.data = string(data)
+	}
+	return r, nil
+}
+
+// record decodes the row, failing on JSON columns that don't hold what they should
+func (r sqliteRecord) record() (Record, error) {
+	record := Record{MetaData: MetaData{
+		Title:        r.Title,
+		Author:       r.Author,
+		PublishDate:  r.PublishDate,
+		CreatedAt:    r.CreatedAt,
+		LastUpdated:  r.LastUpdated,
+		FileType:     r.FileType,
+		DocType:      r.DocType,
+		DeweyDecimal: r.DeweyDecimal,
+		Path:         r.Path,
+		Uuid:         r.Uuid,
+		Size:         r.Size,
+		Hash:         r.Hash,
+		ISBN:         r.ISBN,
+		DOI:          r.DOI,
+		Publisher:    r.Publisher,
+		Edition:      r.Edition,
+		Language:     r.Language,
+		PageCount:    r.PageCount,
+		Description:  r.Description,
+	}}
+	for _, column := range []struct {
+		name, data string
+		value      any
+	}{{"authors", r.Authors, &record.Authors}, {"tags", r.Tags, &record.Tags}, {"custom", r.Custom, &record.Custom}} {
+		if err := json.Unmarshal([]byte(column.data), column.value); err != nil {
+			return Record{}, fmt.Errorf("invalid %s column: %v", column.name, err)
+		}
+	}
+	if r.Payload != "" {
+		if !json.Valid([]byte(r.Payload)) {
+			return Record{}, fmt.Errorf("invalid payload column")
+		}
+		record.Payload = json.RawMessage(r.Payload)
+	}
+	return record, nil
+}
+
+func (r sqliteRecord) trashed() (TrashedRecord, error) {
+	record, err := r.record()
+	return TrashedRecord{Record: record, DeletedAt: r.DeletedAt.String, DeletedBy: r.DeletedBy.String}, err
+}
+
+// raw is the row in the form it's quarantined in
+func (r sqliteRecord) raw() []byte {
+	data, _ := json.Marshal(r)
+	return data
+}
+
+func (r sqliteRecord) set() RecordSet {
+	if r.DeletedAt.Valid {
+		return RecordsTrashed
+	}
+	return RecordsCurrent
+}
+
+// sqliteQuerier is a *sql.DB or *sql.Tx
+type sqliteQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// setCondition selects the rows of a record set
+func setCondition(set RecordSet) string {
+	if set == RecordsTrashed {
+		return `deleted_at IS NOT NULL`
+	}
+	return `deleted_at IS NULL`
+}
+
+// sqliteRow reads the row for id from the documents or the trash, nil when there's none
+func sqliteRow(ctx context.Context, q sqliteQuerier, id string, trashed bool) (*sqliteRecord, error) {
+	set := RecordsCurrent
+	if trashed {
+		set = RecordsTrashed
+	}
+	row, err := scanSQLiteRecord(q.QueryRowContext(ctx, `SELECT `+sqliteRecordColumns+` FROM documents WHERE uuid = ? AND `+setCondition(set), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// storedSQLiteRecord decodes the row for id, like storedRecord does a Bolt value
+func storedSQLiteRecord(ctx context.Context, q sqliteQuerier, id string, trashed bool) (*TrashedRecord, error) {
+	row, err := sqliteRow(ctx, q, id, trashed)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	record, err := row.trashed()
+	if err != nil {
+		return nil, fmt.Errorf("error reading stored document: %v", err)
+	}
+	return &record, nil
+}
+
+// putSQLiteRecord writes record as a current document and indexes it for FuzzySearch
+func putSQLiteRecord(tx *sql.Tx, record Record) error {
+	r, err := newSQLiteRecord(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO documents (`+sqliteRecordColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL, NULL)
+		ON CONFLICT (uuid) DO UPDATE SET
+			title = excluded.title, author = excluded.author, authors = excluded.authors,
+			publish_date = excluded.publish_date, created_at = excluded.created_at,
+			last_updated = excluded.last_updated, file_type = excluded.file_type, doc_type = excluded.doc_type,
+			dewey_decimal = excluded.dewey_decimal, path = excluded.path, size = excluded.size,
+			hash = excluded.hash, isbn = excluded.isbn, doi = excluded.doi, publisher = excluded.publisher,
+			edition = excluded.edition, language = excluded.language, page_count = excluded.page_count,
+			tags = excluded.tags, description = excluded.description, custom = excluded.custom,
+			payload = excluded.payload, deleted_at = NULL, deleted_by = NULL`,
+		r.Uuid, r.Title, r.Author, r.Authors, r.PublishDate, r.CreatedAt, r.LastUpdated, r.FileType, r.DocType,
+		r.DeweyDecimal, r.Path, r.Size, r.Hash, r.ISBN, r.DOI, r.Publisher, r.Edition, r.Language, r.PageCount,
+		r.Tags, r.Description, r.Custom, r.Payload)
+	if err != nil {
+		return err
+	}
+	if err := unindexSQLiteRecord(tx, record.Uuid); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO documents_fts (rowid, text) SELECT id, ? FROM documents WHERE uuid = ?`,
+		strings.Join(fuzzyFields(record.MetaData), "\n"), record.Uuid)
+	return err
+}
+
+// unindexSQLiteRecord drops a document from the FTS index, as it leaves the current documents
+func unindexSQLiteRecord(tx *sql.Tx, id string) error {
+	_, err := tx.Exec(`DELETE FROM documents_fts WHERE rowid = (SELECT id FROM documents WHERE uuid = ?)`, id)
+	return err
+}
+
+func deleteSQLiteDocument(tx *sql.Tx, id string) error {
+	if err := unindexSQLiteRecord(tx, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM documents WHERE uuid = ?`, id)
+	return err
+}
+
+// scan decodes the rows of a record set matching condition, calling fn with each. Rows that
+// can't be decoded are skipped, and quarantined once the scan is over.
+func (s *SQLiteDao) scan(ctx context.Context, set RecordSet, condition string, args []any, fn func(TrashedRecord)) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteRecordColumns+` FROM documents WHERE `+setCondition(set)+` AND (`+condition+`) ORDER BY uuid`, args...)
+	if err != nil {
+		return err
+	}
+	corrupt := map[string]corruptValue{}
+	var records []TrashedRecord
+	for rows.Next() {
+		row, err := scanSQLiteRecord(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		record, err := row.trashed()
+		if err != nil {
+			corrupt[row.Uuid] = corruptValue{raw: row.raw(), reason: err.Error()}
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// called once the rows are closed, the one connection is free for fn to use
+	for _, record := range records {
+		fn(record)
+	}
+	if len(corrupt) > 0 {
+		s.skipped.Add(int64(len(corrupt)))
+		s.quarantineCorrupt(set, corrupt)
+	}
+	return nil
+}
+
+// update runs fn in a transaction, committed when it returns nil. the transaction is rolled
+// back if ctx is done before then.
+func (s *SQLiteDao) update(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this build's %d", version, len(sqliteMigrations))
+	}
+	for i, migration := range sqliteMigrations[version:] {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migration); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version+i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,663 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//------------------SQLITE-HISTORY-------------------
+//---------------------------------------------------
+
+// the SQLite counterparts of history.go, bulk.go, trash.go, journal.go, quarantine.go and fsck.go.
+// revisions are stored as the same JSON Bolt keeps, one row each.
+
+// Save writes the document and appends a revision for the change, see BoltDao.Save.
+func (s *SQLiteDao) Save(ctx context.Context, doc Document, change Change) error {
+	record, err := NewRecord(doc)
+	if err != nil {
+		return fmt.Errorf("could not save document: %v", err)
+	}
+
+	err = s.update(ctx, func(tx *sql.Tx) error {
+		if trashed, err := sqliteRow(ctx, tx, record.Uuid, true); err != nil || trashed != nil {
+			if err == nil {
+				err = ErrDocumentTrashed
+			}
+			return err
+		}
+		prev, err := storedSQLiteRecord(ctx, tx, record.Uuid, false)
+		if err != nil {
+			return err
+		}
+		record, err = commitSQLiteRecord(tx, record, currentRecord(prev), change, 0)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not save document: %w", err)
+	}
+	return doc.SetMetaData(record.MetaData)
+}
+
+// ReplaceFile points an existing document at a newly stored file.
+func (s *SQLiteDao) ReplaceFile(ctx context.Context, id uuid.UUID, file FileVersion, user string) (Record, error) {
+	var record Record
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		prev, err := storedSQLiteRecord(ctx, tx, id.String(), false)
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return ErrDocumentNotFound
+		}
+
+		record = prev.Record
+		record.Path = file.Path
+		record.FileType = file.FileType
+		record.Size = file.Size
+		record.Hash = file.Hash
+		record, err = commitSQLiteRecord(tx, record, &prev.Record, Change{User: user, Action: ActionFile}, 0)
+		return err
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("could not replace file: %w", err)
+	}
+	return record, nil
+}
+
+// BulkUpdate applies edit to each of ids in one transaction, see BoltDao.BulkUpdate. A dry
+// run writes nothing, so the transaction has nothing to commit.
+func (s *SQLiteDao) BulkUpdate(ctx context.Context, ids []uuid.UUID, edit BulkEdit, change Change, dryRun bool) ([]BulkResult, error) {
+	var results []BulkResult
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		results = make([]BulkResult, 0, len(ids))
+		for _, id := range ids {
+			result := BulkResult{Uuid: id.String()}
+			trashed, err := sqliteRow(ctx, tx, id.String(), true)
+			if err != nil {
+				return err
+			}
+			prev, err := storedSQLiteRecord(ctx, tx, id.String(), false)
+			switch {
+			case trashed != nil:
+				result.Err = ErrDocumentTrashed
+			case err != nil:
+				result.Err = err
+			case prev == nil:
+				result.Err = ErrDocumentNotFound
+			default:
+				result.Record, result.Changes, result.Err = bulkEdit(prev.Record, edit)
+			}
+
+			if result.Updated() {
+				if dryRun {
+					result.Record = stampRecord(result.Record, &prev.Record, Timestamp())
+				} else if result.Record, err = commitSQLiteRecord(tx, result.Record, &prev.Record, change, 0); err != nil {
+					return err
+				}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error in bulk update: %w", err)
+	}
+	return results, nil
+}
+
+// Restore makes a past revision's record current again, as a new revision.
+func (s *SQLiteDao) Restore(ctx context.Context, id uuid.UUID, number int, user string) (Record, error) {
+	var record Record
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		if trashed, err := sqliteRow(ctx, tx, id.String(), true); err != nil || trashed != nil {
+			if err == nil {
+				err = ErrDocumentTrashed
+			}
+			return err
+		}
+		history, err := sqliteHistory(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(history, func(rev Revision) bool { return rev.Number == number })
+		if i < 0 {
+			return ErrRevisionNotFound
+		}
+		target := history[i]
+		if target.Record.Path != "" && slices.ContainsFunc(history, func(rev Revision) bool {
+			return rev.File != nil && rev.File.Path == target.Record.Path && rev.File.Pruned
+		}) {
+			return ErrFilePruned
+		}
+
+		prev, err := storedSQLiteRecord(ctx, tx, id.String(), false)
+		if err != nil {
+			return err
+		}
+		record, err = commitSQLiteRecord(tx, target.Record, currentRecord(prev), Change{User: user, Action: ActionRestore}, number)
+		return err
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("could not restore revision %d: %w", number, err)
+	}
+	return record, nil
+}
+
+// History returns every revision of a document, oldest first.
+func (s *SQLiteDao) History(ctx context.Context, id uuid.UUID) ([]Revision, error) {
+	history, err := sqliteHistory(ctx, s.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return history, nil
+}
+
+// PruneFiles marks all but the keep most recent file versions as pruned, see BoltDao.PruneFiles.
+func (s *SQLiteDao) PruneFiles(ctx context.Context, id uuid.UUID, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	var pruned []string
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		history, err := sqliteHistory(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		current, err := storedSQLiteRecord(ctx, tx, id.String(), false)
+		if err != nil {
+			return err
+		}
+
+		kept := map[string]bool{}
+		if current != nil && current.Path != "" {
+			kept[current.Path] = true
+		}
+
+		// newest first, so the versions past keep are the oldest ones
+		for i := len(history) - 1; i >= 0; i-- {
+			rev := history[i]
+			if rev.File == nil || rev.File.Pruned {
+				continue
+			}
+			path := rev.File.Path
+			if kept[path] || len(kept) < keep {
+				kept[path] = true
+				continue
+			}
+
+			rev.File.Pruned = true
+			data, err := json.Marshal(rev)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE revisions SET revision = ? WHERE uuid = ? AND number = ?`, string(data), id.String(), rev.Number); err != nil {
+				return err
+			}
+			if !slices.Contains(pruned, path) {
+				pruned = append(pruned, path)
+			}
+		}
+		if pruned, err = unreferencedSQLiteFiles(ctx, tx, pruned); err != nil {
+			return err
+		}
+		return journalSQLiteDeletes(ctx, tx, id.String(), pruned)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pruning file versions: %w", err)
+	}
+	return pruned, nil
+}
+
+// commitSQLiteRecord stamps and stores record, then appends its revision, see commitRecord
+func commitSQLiteRecord(tx *sql.Tx, record Record, prev *Record, change Change, restoredFrom int) (Record, error) {
+	now := Timestamp()
+	record = stampRecord(record, prev, now)
+	if err := putSQLiteRecord(tx, record); err != nil {
+		return Record{}, err
+	}
+	return record, appendSQLiteRevision(tx, newRevision(record, prev, change, restoredFrom, now))
+}
+
+func appendSQLiteRevision(tx *sql.Tx, rev Revision) error {
+	id := rev.Record.Uuid
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(number), 0) + 1 FROM revisions WHERE uuid = ?`, id).Scan(&rev.Number); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO revisions (uuid, number, revision) VALUES (?, ?, ?)`, id, rev.Number, string(data))
+	return err
+}
+
+func sqliteHistory(ctx context.Context, q sqliteQuerier, id uuid.UUID) ([]Revision, error) {
+	rows, err := q.QueryContext(ctx, `SELECT revision FROM revisions WHERE uuid = ? ORDER BY number`, id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []Revision
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var rev Revision
+		if err := json.Unmarshal([]byte(data), &rev); err != nil {
+			return nil, fmt.Errorf("error unmarshaling revision: %v", err)
+		}
+		history = append(history, rev)
+	}
+	return history, rows.Err()
+}
+
+// currentRecord is the Record of a row read from the documents, nil when there was none
+func currentRecord(stored *TrashedRecord) *Record {
+	if stored == nil {
+		return nil
+	}
+	return &stored.Record
+}
+
+//---------------------------------------------------
+//-------------------SQLITE-TRASH--------------------
+//---------------------------------------------------
+
+// Trash moves a document to the trash, recording the deletion in its history.
+func (s *SQLiteDao) Trash(ctx context.Context, id uuid.UUID, user string) (TrashedRecord, error) {
+	var trashed TrashedRecord
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		prev, err := storedSQLiteRecord(ctx, tx, id.String(), false)
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return ErrDocumentNotFound
+		}
+
+		trashed = TrashedRecord{Record: prev.Record, DeletedAt: Timestamp(), DeletedBy: user}
+		if err := unindexSQLiteRecord(tx, id.String()); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE documents SET deleted_at = ?, deleted_by = ? WHERE uuid = ?`, trashed.DeletedAt, user, id.String()); err != nil {
+			return err
+		}
+		return appendSQLiteRevision(tx, Revision{Timestamp: trashed.DeletedAt, User: user, Action: ActionTrash, Record: prev.Record})
+	})
+	if err != nil {
+		return TrashedRecord{}, fmt.Errorf("could not trash document: %w", err)
+	}
+	return trashed, nil
+}
+
+// Untrash moves a document out of the trash as it was when deleted, recorded as a new revision.
+func (s *SQLiteDao) Untrash(ctx context.Context, id uuid.UUID, user string) (Record, error) {
+	var record Record
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		trashed, err := storedSQLiteRecord(ctx, tx, id.String(), true)
+		if err != nil {
+			return err
+		}
+		if trashed == nil {
+			return ErrDocumentNotFound
+		}
+		record, err = commitSQLiteRecord(tx, trashed.Record, &trashed.Record, Change{User: user, Action: ActionUntrash}, 0)
+		return err
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("could not restore document from trash: %w", err)
+	}
+	return record, nil
+}
+
+// ReadTrashed returns a document in the trash.
+func (s *SQLiteDao) ReadTrashed(ctx context.Context, id uuid.UUID) (TrashedRecord, error) {
+	trashed, err := storedSQLiteRecord(ctx, s.db, id.String(), true)
+	if err == nil && trashed == nil {
+		err = ErrDocumentNotFound
+	}
+	if err != nil {
+		return TrashedRecord{}, fmt.Errorf("error retrieving trashed document: %w", err)
+	}
+	return *trashed, nil
+}
+
+// Trashed returns every document in the trash, skipping and quarantining any that can't be decoded.
+func (s *SQLiteDao) Trashed(ctx context.Context) ([]TrashedRecord, error) {
+	var results []TrashedRecord
+	err := s.scan(ctx, RecordsTrashed, "TRUE", nil, func(trashed TrashedRecord) {
+		results = append(results, trashed)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing trash: %w", err)
+	}
+	return results, nil
+}
+
+// Purge permanently removes a document from the trash along with its history, see BoltDao.Purge.
+func (s *SQLiteDao) Purge(ctx context.Context, id uuid.UUID) ([]string, error) {
+	var paths []string
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		trashed, err := storedSQLiteRecord(ctx, tx, id.String(), true)
+		if err != nil {
+			return err
+		}
+		if trashed == nil {
+			return ErrDocumentNotFound
+		}
+
+		if trashed.Path != "" {
+			paths = append(paths, trashed.Path)
+		}
+		history, err := sqliteHistory(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		for _, rev := range history {
+			if rev.File != nil && !rev.File.Pruned && !slices.Contains(paths, rev.File.Path) {
+				paths = append(paths, rev.File.Path)
+			}
+		}
+
+		if err := deleteSQLiteDocument(tx, id.String()); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM revisions WHERE uuid = ?`, id.String()); err != nil {
+			return err
+		}
+		if paths, err = unreferencedSQLiteFiles(ctx, tx, paths); err != nil {
+			return err
+		}
+		return journalSQLiteDeletes(ctx, tx, id.String(), paths)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not purge document: %w", err)
+	}
+	return paths, nil
+}
+
+//---------------------------------------------------
+//------------------SQLITE-JOURNAL-------------------
+//---------------------------------------------------
+
+// JournalFileOp records a storage operation before it's carried out, replacing any
+// pending operation on the same path.
+func (s *SQLiteDao) JournalFileOp(ctx context.Context, op FileOp) error {
+	if err := putSQLiteFileOp(ctx, s.db, op); err != nil {
+		return fmt.Errorf("could not journal file operation: %w", err)
+	}
+	return nil
+}
+
+// CompleteFileOp clears the pending operation on a path, once storage reflects it.
+func (s *SQLiteDao) CompleteFileOp(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM journal WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("could not clear file operation: %w", err)
+	}
+	return nil
+}
+
+// PendingFileOps returns every storage operation that hasn't been completed, by path.
+func (s *SQLiteDao) PendingFileOps(ctx context.Context) ([]FileOp, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT path, action, uuid, created FROM journal ORDER BY path`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	defer rows.Close()
+
+	var ops []FileOp
+	for rows.Next() {
+		var op FileOp
+		if err := rows.Scan(&op.Path, &op.Action, &op.Uuid, &op.Created); err != nil {
+			return nil, fmt.Errorf("error reading journal: %w", err)
+		}
+		ops = append(ops, op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	return ops, nil
+}
+
+// ReferencedFiles returns every path a current or trashed record, or an unpruned file
+// version in a history, points at.
+func (s *SQLiteDao) ReferencedFiles(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT path FROM documents WHERE path != ''
+		UNION SELECT json_extract(revision, '$.File.Path') FROM revisions
+		WHERE json_extract(revision, '$.File.Path') IS NOT NULL AND NOT COALESCE(json_extract(revision, '$.File.Pruned'), 0)`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing referenced files: %w", err)
+	}
+	defer rows.Close()
+
+	paths := map[string]bool{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("error listing referenced files: %w", err)
+		}
+		paths[path] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing referenced files: %w", err)
+	}
+	return paths, nil
+}
+
+// unreferencedSQLiteFiles drops the paths another document still points at, see unreferencedFiles
+func unreferencedSQLiteFiles(ctx context.Context, q sqliteQuerier, paths []string) ([]string, error) {
+	var kept []string
+	for _, path := range paths {
+		var referenced bool
+		err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM documents WHERE path = ?)
+			OR EXISTS (SELECT 1 FROM revisions WHERE json_extract(revision, '$.File.Path') = ?
+			AND NOT COALESCE(json_extract(revision, '$.File.Pruned'), 0))`, path, path).Scan(&referenced)
+		if err != nil {
+			return nil, err
+		}
+		if !referenced {
+			kept = append(kept, path)
+		}
+	}
+	return kept, nil
+}
+
+// journalSQLiteDeletes queues the files for deletion as part of the transaction that drops them
+func journalSQLiteDeletes(ctx context.Context, tx *sql.Tx, id string, paths []string) error {
+	now := Timestamp()
+	for _, path := range paths {
+		if err := putSQLiteFileOp(ctx, tx, FileOp{Path: path, Action: FileOpDelete, Uuid: id, Created: now}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putSQLiteFileOp(ctx context.Context, q sqliteQuerier, op FileOp) error {
+	if op.Created == "" {
+		op.Created = Timestamp()
+	}
+	_, err := q.ExecContext(ctx, `INSERT OR REPLACE INTO journal (path, action, uuid, created) VALUES (?, ?, ?, ?)`,
+		op.Path, op.Action, op.Uuid, op.Created)
+	return err
+}
+
+//---------------------------------------------------
+//----------------SQLITE-QUARANTINE------------------
+//---------------------------------------------------
+
+// ScanRecords returns every current and trashed record, with Err set on those that can't
+// be decoded.
+func (s *SQLiteDao) ScanRecords(ctx context.Context) ([]RawRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteRecordColumns+` FROM documents ORDER BY deleted_at IS NOT NULL, uuid`)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RawRecord
+	for rows.Next() {
+		row, err := scanSQLiteRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning records: %w", err)
+		}
+		raw := RawRecord{Set: row.set(), Key: row.Uuid}
+		if raw.Record, raw.Err = row.record(); raw.Err != nil {
+			raw.Raw = row.raw()
+		}
+		records = append(records, raw)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning records: %w", err)
+	}
+	return records, nil
+}
+
+// RepairRecord overwrites a current or trashed record with a fixed up copy, recorded as a
+// "repair" revision by user.
+func (s *SQLiteDao) RepairRecord(ctx context.Context, set RecordSet, record Record, user string) error {
+	id, err := uuid.Parse(record.Uuid)
+	if err != nil {
+		return fmt.Errorf("could not repair document: %w", err)
+	}
+
+	err = s.update(ctx, func(tx *sql.Tx) error {
+		stored, err := storedSQLiteRecord(ctx, tx, id.String(), set == RecordsTrashed)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return ErrDocumentNotFound
+		}
+		if set == RecordsCurrent {
+			_, err = commitSQLiteRecord(tx, record, &stored.Record, Change{User: user, Action: ActionRepair}, 0)
+			return err
+		}
+
+		// a trashed record keeps its deletion details, only the record itself changes
+		if err := putSQLiteRecord(tx, record); err != nil {
+			return err
+		}
+		if err := unindexSQLiteRecord(tx, id.String()); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE documents SET deleted_at = ?, deleted_by = ? WHERE uuid = ?`, stored.DeletedAt, stored.DeletedBy, id.String()); err != nil {
+			return err
+		}
+		rev := Revision{Timestamp: Timestamp(), User: user, Action: ActionRepair, Changes: diffRecords(&stored.Record, record), Record: record}
+		return appendSQLiteRevision(tx, rev)
+	})
+	if err != nil {
+		return fmt.Errorf("could not repair document: %w", err)
+	}
+	return nil
+}
+
+// Quarantine moves a row out of the documents or the trash into quarantine.
+func (s *SQLiteDao) Quarantine(ctx context.Context, set RecordSet, key, reason string) error {
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		row, err := sqliteRow(ctx, tx, key, set == RecordsTrashed)
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			return ErrDocumentNotFound
+		}
+		return quarantineSQLiteRow(tx, *row, reason)
+	})
+	if err != nil {
+		return fmt.Errorf("could not quarantine %s/%s: %w", set, key, err)
+	}
+	return nil
+}
+
+// QuarantineCount is how many records taken out of set are quarantined.
+func (s *SQLiteDao) QuarantineCount(ctx context.Context, set RecordSet) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quarantine WHERE bucket = ?`, string(set)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting quarantine: %w", err)
+	}
+	return count, nil
+}
+
+// Quarantined returns every quarantined record.
+func (s *SQLiteDao) Quarantined(ctx context.Context) ([]QuarantinedRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT bucket, key, raw, reason, quarantined_at FROM quarantine ORDER BY bucket, key`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing quarantine: %w", err)
+	}
+	defer rows.Close()
+
+	var records []QuarantinedRecord
+	for rows.Next() {
+		var record QuarantinedRecord
+		if err := rows.Scan(&record.Set, &record.Key, &record.Raw, &record.Reason, &record.QuarantinedAt); err != nil {
+			return nil, fmt.Errorf("error listing quarantine: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing quarantine: %w", err)
+	}
+	return records, nil
+}
+
+// Stats counts the records in each table.
+func (s *SQLiteDao) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{SkippedRecords: s.skipped.Load()}
+	err := s.db.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM documents WHERE deleted_at IS NULL),
+		(SELECT COUNT(*) FROM documents WHERE deleted_at IS NOT NULL),
+		(SELECT COUNT(*) FROM quarantine),
+		(SELECT COUNT(*) FROM journal)`).Scan(&stats.Documents, &stats.Trashed, &stats.Quarantined, &stats.PendingFileOps)
+	if err != nil {
+		return Stats{}, fmt.Errorf("error reading database stats: %w", err)
+	}
+	return stats, nil
+}
+
+// quarantineCorrupt moves the rows a scan couldn't decode to quarantine, logging failures
+// as BoltDao does.
+func (s *SQLiteDao) quarantineCorrupt(set RecordSet, corrupt map[string]corruptValue) {
+	// not tied to the scan's context, the records are as corrupt if its caller has gone away
+	ctx := context.Background()
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		for key, value := range corrupt {
+			row, err := sqliteRow(ctx, tx, key, set == RecordsTrashed)
+			if err != nil {
+				return err
+			}
+			// it may have been fixed or removed since the scan
+			if row == nil || string(row.raw()) != string(value.raw) {
+				continue
+			}
+			if err := quarantineSQLiteRow(tx, *row, value.reason); err != nil {
+				return err
+			}
+			log.Printf("quarantined corrupt record %s/%s: %s", set, key, value.reason)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to quarantine corrupt records in %s: %v", set, err)
+	}
+}
+
+func quarantineSQLiteRow(tx *sql.Tx, row sqliteRecord, reason string) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO quarantine (bucket, key, raw, reason, quarantined_at) VALUES (?, ?, ?, ?, ?)`,
+		string(row.set()), row.Uuid, row.raw(), reason, Timestamp())
+	if err != nil {
+		return err
+	}
+	return deleteSQLiteDocument(tx, row.Uuid)
+}
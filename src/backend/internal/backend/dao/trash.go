@@ -0,0 +1,203 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/boltdb/bolt"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//----------------------TRASH------------------------
+//---------------------------------------------------
+
+// deleting a document moves its record from "documents" to "trash" in one transaction, so
+// scans and reads no longer see it. its history and files are left alone until it's purged.
+
+// ErrDocumentTrashed is returned (wrapped) when writing to a document that's in the trash.
+var ErrDocumentTrashed = errors.New("document is in the trash")
+
+// the Action of the revisions trashing and untrashing a document
+const (
+	ActionTrash   = "trash"
+	ActionUntrash = "untrash"
+)
+
+// TrashedRecord is a record in the trash, with when and by whom it was deleted.
+type TrashedRecord struct {
+	Record
+	DeletedAt string
+	DeletedBy string
+}
+
+// Trash moves a document to the trash, recording the deletion in its history.
+func (b *BoltDao) Trash(ctx context.Context, id uuid.UUID, user string) (TrashedRecord, error) {
+	var trashed TrashedRecord
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		documents := tx.Bucket([]byte("documents"))
+		prev, err := storedRecord(documents, id.String())
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return ErrDocumentNotFound
+		}
+
+		trash, err := tx.CreateBucketIfNotExists([]byte("trash"))
+		if err != nil {
+			return fmt.Errorf("could not create trash bucket: %v", err)
+		}
+		trashed = TrashedRecord{Record: *prev, DeletedAt: Timestamp(), DeletedBy: user}
+		data, err := json.Marshal(trashed)
+		if err != nil {
+			return err
+		}
+		if err := trash.Put([]byte(id.String()), data); err != nil {
+			return err
+		}
+		if err := documents.Delete([]byte(id.String())); err != nil {
+			return err
+		}
+
+		rev := Revision{Timestamp: trashed.DeletedAt, User: user, Action: ActionTrash, Record: *prev}
+		return appendRevision(tx, id, rev)
+	})
+	if err != nil {
+		return TrashedRecord{}, fmt.Errorf("could not trash document: %w", err)
+	}
+	return trashed, nil
+}
+
+// Untrash moves a document out of the trash as it was when deleted, recorded as a new revision.
+func (b *BoltDao) Untrash(ctx context.Context, id uuid.UUID, user string) (Record, error) {
+	var record Record
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		trash := tx.Bucket([]byte("trash"))
+		trashed, err := trashedRecord(trash, id.String())
+		if err != nil {
+			return err
+		}
+		if trashed == nil {
+			return ErrDocumentNotFound
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("documents")); err != nil {
+			return fmt.Errorf("could not create documents bucket: %v", err)
+		}
+		if err := trash.Delete([]byte(id.String())); err != nil {
+			return err
+		}
+		record, err = commitRecord(tx, trashed.Record, &trashed.Record, Change{User: user, Action: ActionUntrash}, 0)
+		return err
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("could not restore document from trash: %w", err)
+	}
+	return record, nil
+}
+
+// ReadTrashed returns a document in the trash.
+func (b *BoltDao) ReadTrashed(ctx context.Context, id uuid.UUID) (TrashedRecord, error) {
+	var trashed *TrashedRecord
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		var err error
+		trashed, err = trashedRecord(tx.Bucket([]byte("trash")), id.String())
+		if err == nil && trashed == nil {
+			err = ErrDocumentNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return TrashedRecord{}, fmt.Errorf("error retrieving trashed document: %w", err)
+	}
+	return *trashed, nil
+}
+
+// Trashed returns every document in the trash, skipping and quarantining any that can't be decoded.
+func (b *BoltDao) Trashed(ctx context.Context) ([]TrashedRecord, error) {
+	var results []TrashedRecord
+	err := scanBucket(ctx, b, RecordsTrashed, false, func(trashed TrashedRecord) {
+		results = append(results, trashed)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing trash: %w", err)
+	}
+	return results, nil
+}
+
+// Purge permanently removes a document from the trash, along with its history. It returns
+// the paths of every stored version of its file that no other document references,
+// journaled for the caller to remove from storage. Documents that aren't in the trash
+// can't be purged.
+func (b *BoltDao) Purge(ctx context.Context, id uuid.UUID) ([]string, error) {
+	var paths []string
+	err := b.update(ctx, func(tx *bolt.Tx) error {
+		trash := tx.Bucket([]byte("trash"))
+		trashed, err := trashedRecord(trash, id.String())
+		if err != nil {
+			return err
+		}
+		if trashed == nil {
+			return ErrDocumentNotFound
+		}
+
+		if trashed.Path != "" {
+			paths = append(paths, trashed.Path)
+		}
+		if revisions := revisionBucket(tx, id, false); revisions != nil {
+			err := revisions.ForEach(func(_, v []byte) error {
+				var rev Revision
+				if err := json.Unmarshal(v, &rev); err != nil {
+					return fmt.Errorf("error unmarshaling revision: %v", err)
+				}
+				if rev.File != nil && !rev.File.Pruned && !slices.Contains(paths, rev.File.Path) {
+					paths = append(paths, rev.File.Path)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := trash.Delete([]byte(id.String())); err != nil {
+			return err
+		}
+		if err := deleteHistory(tx, id); err != nil {
+			return err
+		}
+		if paths, err = unreferencedFiles(tx, paths); err != nil {
+			return err
+		}
+		return journalDeletes(tx, id.String(), paths)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not purge document: %w", err)
+	}
+	return paths, nil
+}
+
+// inTrash guards the write paths, a trashed document has to be untrashed before it changes
+func inTrash(tx *bolt.Tx, id string) bool {
+	trash := tx.Bucket([]byte("trash"))
+	return trash != nil && trash.Get([]byte(id)) != nil
+}
+
+func trashedRecord(bucket *bolt.Bucket, id string) (*TrashedRecord, error) {
+	if bucket == nil {
+		return nil, nil
+	}
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var trashed TrashedRecord
+	if err := json.Unmarshal(data, &trashed); err != nil {
+		return nil, fmt.Errorf("error reading trashed document: %v", err)
+	}
+	return &trashed, nil
+}
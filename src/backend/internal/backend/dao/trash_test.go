@@ -0,0 +1,152 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWhenTrashExpectExcludedFromScans(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{Content: "binned"}
+		doc.SetMetaData(MetaData{Title: "Binned", DocType: "Notes", Uuid: id.String()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+
+		trashed, err := db.Trash(context.Background(), id, "ada")
+		if err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+		if trashed.DeletedBy != "ada" || trashed.DeletedAt == "" || trashed.Title != "Binned" {
+			t.Errorf("unexpected trashed record: %+v", trashed)
+		}
+
+		if all, _ := db.GetAll(context.Background()); len(all) != 0 {
+			t.Errorf("wanted no documents outside the trash; have %d", len(all))
+		}
+		if found, _ := db.FuzzySearch(context.Background(), "binned"); len(found) != 0 {
+			t.Errorf("wanted trashed document left out of search; have %d results", len(found))
+		}
+		if _, err := db.ReadRaw(context.Background(), id); !errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("wanted ErrDocumentNotFound reading a trashed document; have %v", err)
+		}
+		if all, _ := db.Trashed(context.Background()); len(all) != 1 || all[0].Uuid != id.String() {
+			t.Errorf("wanted the document in the trash; have %+v", all)
+		}
+
+		if err := db.Save(context.Background(), doc, Change{Action: ActionUpdate}); !errors.Is(err, ErrDocumentTrashed) {
+			t.Errorf("wanted ErrDocumentTrashed saving a trashed document; have %v", err)
+		}
+	})
+}
+
+func TestWhenUntrashExpectRecordBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{Content: "kept"}
+		doc.SetMetaData(MetaData{Title: "Kept", DocType: "Notes", Uuid: id.String(), Path: "kept.txt"})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+		if _, err := db.Trash(context.Background(), id, "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+
+		record, err := db.Untrash(context.Background(), id, "grace")
+		if err != nil {
+			t.Fatalf("error untrashing document: %s", err)
+		}
+		if record.Title != "Kept" || record.Path != "kept.txt" {
+			t.Errorf("wanted the record as it was deleted; have %+v", record)
+		}
+
+		var read Document = &Notes{}
+		if read, err = db.Read(context.Background(), &read, id); err != nil || read.(*Notes).Content != "kept" {
+			t.Errorf("wanted the document readable again; have %v, %v", read, err)
+		}
+		if all, _ := db.Trashed(context.Background()); len(all) != 0 {
+			t.Errorf("wanted an empty trash; have %d", len(all))
+		}
+
+		history, _ := db.History(context.Background(), id)
+		if len(history) != 3 || history[1].Action != ActionTrash || history[2].Action != ActionUntrash || history[2].User != "grace" {
+			t.Errorf("wanted trash and untrash revisions; have %+v", history)
+		}
+
+		if _, err := db.Untrash(context.Background(), id, "grace"); !errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("wanted ErrDocumentNotFound untrashing a current document; have %v", err)
+		}
+	})
+}
+
+func TestWhenPurgeExpectRecordAndHistoryGone(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		id := uuid.New()
+		doc := &Notes{}
+		doc.SetMetaData(MetaData{Title: "Purged", DocType: "Notes", Uuid: id.String()})
+		if err := db.Create(context.Background(), doc); err != nil {
+			t.Fatalf("error creating document: %s", err)
+		}
+
+		// only trashed documents can be purged
+		if _, err := db.Purge(context.Background(), id); !errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("wanted ErrDocumentNotFound purging a current document; have %v", err)
+		}
+
+		if _, err := db.Trash(context.Background(), id, "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+		if _, err := db.Purge(context.Background(), id); err != nil {
+			t.Fatalf("error purging document: %s", err)
+		}
+
+		if _, err := db.ReadTrashed(context.Background(), id); !errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("wanted ErrDocumentNotFound after purge; have %v", err)
+		}
+		if history, _ := db.History(context.Background(), id); len(history) != 0 {
+			t.Errorf("wanted no history after purge; have %d revisions", len(history))
+		}
+	})
+}
+
+func TestWhenFileSharedExpectPurgeAndPruneKeepIt(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db fullDAO) {
+		ctx := context.Background()
+		first, second := uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{first, second} {
+			doc := &Notes{}
+			doc.SetMetaData(MetaData{Title: "Shared", DocType: "Notes", Uuid: id.String(), Path: "shared.pdf"})
+			if err := db.Create(ctx, doc); err != nil {
+				t.Fatalf("error creating document: %s", err)
+			}
+		}
+
+		if _, err := db.ReplaceFile(ctx, first, FileVersion{Path: "second.pdf", FileType: ".pdf"}, "ada"); err != nil {
+			t.Fatalf("error replacing file: %s", err)
+		}
+		if pruned, err := db.PruneFiles(ctx, first, 1); err != nil || len(pruned) != 0 {
+			t.Errorf("wanted the shared version kept by the prune; have %v, %v", pruned, err)
+		}
+
+		if _, err := db.Trash(ctx, first, "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+		if paths, err := db.Purge(ctx, first); err != nil || len(paths) != 1 || paths[0] != "second.pdf" {
+			t.Errorf("wanted only the unshared file purged; have %v, %v", paths, err)
+		}
+		if ops, _ := db.PendingFileOps(ctx); len(ops) != 1 || ops[0].Path != "second.pdf" {
+			t.Errorf("wanted only the unshared file journaled; have %+v", ops)
+		}
+
+		if _, err := db.Trash(ctx, second, "ada"); err != nil {
+			t.Fatalf("error trashing document: %s", err)
+		}
+		if paths, err := db.Purge(ctx, second); err != nil || len(paths) != 1 || paths[0] != "shared.pdf" {
+			t.Errorf("wanted the file purged with its last document; have %v, %v", paths, err)
+		}
+	})
+}
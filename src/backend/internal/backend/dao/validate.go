@@ -0,0 +1,116 @@
+package dao
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+//---------------------------------------------------
+//-----------------METADATA-CHECKS-------------------
+//---------------------------------------------------
+
+// requirableMetaData are the MetaData fields a type may list in RequiredMetaData, the
+// server owned ones (Uuid, Path, ...) are always set by the time a document is stored.
+var requirableMetaData = []string{
+	"Author", "PublishDate", "DeweyDecimal", "ISBN", "DOI", "Publisher",
+	"Edition", "Language", "PageCount", "Description", "Tags",
+}
+
+var deweyPattern = regexp.MustCompile(`^(\d{3})(\.\d+)?$`)
+
+// publish dates are ISO 8601, as precise as is known: a year, a month or a full date,
+// optionally with a time. the layouts are tried in order and the input is kept as given.
+var dateLayouts = []string{"2006", "2006-01", "2006-01-02", time.RFC3339, "2006-01-02T15:04:05"}
+
+// NormalizeMetaData trims and collapses whitespace in the client supplied fields, then
+// checks Title, the publish date, the Dewey code and the fields the schema requires.
+// every problem is returned in one ValidationError.
+func NormalizeMetaData(meta *MetaData, schema TypeSchema) error {
+	for _, field := range []*string{
+		&meta.Title, &meta.Author, &meta.PublishDate, &meta.DeweyDecimal, &meta.ISBN,
+		&meta.DOI, &meta.Publisher, &meta.Edition, &meta.Language,
+	} {
+		*field = collapseSpace(*field)
+	}
+	// descriptions keep their line breaks
+	meta.Description = strings.TrimSpace(meta.Description)
+	meta.Authors = normalizeList(meta.Authors)
+	meta.Tags = normalizeList(meta.Tags)
+	for key, value := range meta.Custom {
+		delete(meta.Custom, key)
+		if key = collapseSpace(key); key != "" {
+			meta.Custom[key] = strings.TrimSpace(value)
+		}
+	}
+
+	var problems ValidationError
+	if meta.Title == "" {
+		problems.add("Title", "required")
+	}
+	if meta.PublishDate != "" && !validDate(meta.PublishDate) {
+		problems.add("PublishDate", "must be an ISO 8601 date, e.g. 2009, 2009-07 or 2009-07-31")
+	}
+	if meta.DeweyDecimal != "" && !validDewey(meta.DeweyDecimal) {
+		problems.add("DeweyDecimal", "must be a code within a known category, e.g. 510 or 512.7")
+	}
+	for _, field := range schema.RequiredMetaData {
+		if metaDataFieldEmpty(*meta, field) {
+			problems.add(field, "required for %s", schema.Title)
+		}
+	}
+	return problems.err()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// normalizeList collapses whitespace in every entry and drops the empty ones
+func normalizeList(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(list))
+	for _, entry := range list {
+		if entry = collapseSpace(entry); entry != "" {
+			normalized = append(normalized, entry)
+		}
+	}
+	return normalized
+}
+
+func validDate(date string) bool {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, date); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validDewey accepts any code whose division (e.g. 510 for 512.7) is one of DeweyCategories
+func validDewey(code string) bool {
+	match := deweyPattern.FindStringSubmatch(code)
+	if match == nil {
+		return false
+	}
+	division := match[1][:2] + "0"
+	return slices.ContainsFunc(DeweyCategories, func(c DeweyCategory) bool { return c.Code == division })
+}
+
+func metaDataFieldEmpty(meta MetaData, field string) bool {
+	if field == "Author" {
+		return meta.Author == "" && len(meta.Authors) == 0
+	}
+	value := reflect.ValueOf(meta).FieldByName(field)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Invalid:
+		return true
+	}
+	return value.IsZero()
+}
@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWhenNormalizeMetaDataExpectWhitespaceCollapsed(t *testing.T) {
+	meta := MetaData{
+		Title:       "  The   C\tProgramming Language ",
+		Author:      " Kernighan  and Ritchie",
+		Description: "\n  First line.\nSecond line.  \n",
+		Tags:        []string{" c ", "", "  programming   languages"},
+		Custom:      map[string]string{" shelf ": " B2 "},
+	}
+	if err := NormalizeMetaData(&meta, TypeSchema{}); err != nil {
+		t.Fatalf("wanted no error; have %v", err)
+	}
+
+	want := MetaData{
+		Title:       "The C Programming Language",
+		Author:      "Kernighan and Ritchie",
+		Description: "First line.\nSecond line.",
+		Tags:        []string{"c", "programming languages"},
+		Custom:      map[string]string{"shelf": "B2"},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("wanted %+v; have %+v", want, meta)
+	}
+}
+
+func TestWhenValidDatesAndDeweyExpectAccepted(t *testing.T) {
+	for _, date := range []string{"1978", "1978-02", "1978-02-22", "1978-02-22T10:00:00Z", "1978-02-22T10:00:00"} {
+		if !validDate(date) {
+			t.Errorf("wanted %s to be a valid date", date)
+		}
+	}
+	for _, date := range []string{"22/02/1978", "1978-13", "78", "February 1978"} {
+		if validDate(date) {
+			t.Errorf("wanted %s to be an invalid date", date)
+		}
+	}
+
+	for _, code := range []string{"005.133", "510", "512.7", "999"} {
+		if !validDewey(code) {
+			t.Errorf("wanted %s to be a valid Dewey code", code)
+		}
+	}
+	// 041 sits in the unassigned 040 division
+	for _, code := range []string{"041", "51", "512.", "abc"} {
+		if validDewey(code) {
+			t.Errorf("wanted %s to be an invalid Dewey code", code)
+		}
+	}
+}
+
+func TestWhenMetaDataInvalidExpectEveryFieldReported(t *testing.T) {
+	meta := MetaData{Title: "   ", PublishDate: "last year", DeweyDecimal: "041"}
+	err := NormalizeMetaData(&meta, (&Book{}).Schema())
+
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("wanted a ValidationError; have %v", err)
+	}
+	want := []string{"Title", "PublishDate", "DeweyDecimal", "Author"}
+	if len(invalid.Fields) != len(want) {
+		t.Fatalf("wanted %d field errors; have %+v", len(want), invalid.Fields)
+	}
+	for i, field := range want {
+		if invalid.Fields[i].Field != field {
+			t.Errorf("wanted field %d to be %s; have %s", i, field, invalid.Fields[i].Field)
+		}
+	}
+
+	// Authors satisfies a required Author
+	meta = MetaData{Title: "Book", Authors: []string{"Kernighan"}}
+	if err := NormalizeMetaData(&meta, (&Book{}).Schema()); err != nil {
+		t.Errorf("wanted Authors to satisfy Author; have %v", err)
+	}
+}
+
+func TestWhenRequiredMetaDataUnknownExpectSchemaRejected(t *testing.T) {
+	schema := TypeSchema{Title: "Thesis", Type: "object", RequiredMetaData: []string{"Uuid"}}
+
+	var invalid *ValidationError
+	if err := schema.Check(); !errors.As(err, &invalid) || invalid.Fields[0].Field != "x-required-metadata" {
+		t.Fatalf("wanted x-required-metadata to be rejected; have %v", err)
+	}
+}
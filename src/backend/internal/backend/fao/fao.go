@@ -1,17 +1,39 @@
 package fao
 
 import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
     "fmt"
     "io"
+    "io/fs"
     "os"
     "path/filepath"
+    "strings"
+    "time"
 )
 
+// FAO is the storage behind the library. ctx bounds each call, and for GetFile the reads
+// from the returned stream as well.
 type FAO interface {
-    SaveFile(path string, data io.Reader) error
-    GetFile(path string) (io.ReadCloser, error)
-    DeleteFile(path string) error
-    FileExists(filename string) bool
+    SaveFile(ctx context.Context, path string, data io.Reader) error
+    GetFile(ctx context.Context, path string) (io.ReadCloser, error)
+    DeleteFile(ctx context.Context, path string) error
+    FileExists(ctx context.Context, filename string) bool
+    StatFile(ctx context.Context, path string) (FileInfo, error)
+    ListFiles(ctx context.Context, prefix string) ([]FileInfo, error)
+    ListFilesWithHash(ctx context.Context, prefix string) ([]FileInfo, error)
+}
+
+// FileInfo describes a stored file, Path being relative to the FAO's root.
+// Hash is the hex-encoded sha256 of the contents, and is only populated by StatFile
+// and ListFilesWithHash.
+type FileInfo struct {
+    Path    string
+    Size    int64
+    ModTime time.Time
+    Hash    string
 }
 
 type LocalFao struct {
@@ -23,7 +45,10 @@ func NewLocalFao(basePath string) *LocalFao {
 }
 
 // writes a file to disk, path being the destination, data being the stream.
-func (l *LocalFao) SaveFile(path string, data io.Reader) error {
+func (l *LocalFao) SaveFile(ctx context.Context, path string, data io.Reader) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
     filePath := filepath.Join(l.basePath, path)
 
     file, err := os.Create(filePath)
@@ -32,7 +57,7 @@ func (l *LocalFao) SaveFile(path string, data io.Reader) error {
     }
     defer file.Close()
 
-    _, err = io.Copy(file, data)
+    _, err = io.Copy(file, contextReader{ctx: ctx, r: data})
     if err != nil {
         return fmt.Errorf("failed to write file: %w", err)
     }
@@ -41,7 +66,10 @@ func (l *LocalFao) SaveFile(path string, data io.Reader) error {
 }
 
 // retrieves a file from disk, path being the source, returning a stream/error
-func (l LocalFao) GetFile(path string) (io.ReadCloser, error) {
+func (l LocalFao) GetFile(ctx context.Context, path string) (io.ReadCloser, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
     filePath := filepath.Join(l.basePath, path)
 
     file, err := os.Open(filePath)
@@ -49,11 +77,14 @@ func (l LocalFao) GetFile(path string) (io.ReadCloser, error) {
         return nil, fmt.Errorf("failed to open file: %w", err)
     }
 
-    return file, nil
+    return contextReadCloser{contextReader{ctx: ctx, r: file}, file}, nil
 }
 
 // deletes a file on disk
-func (l LocalFao) DeleteFile(path string) error {
+func (l LocalFao) DeleteFile(ctx context.Context, path string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
     filePath := filepath.Join(l.basePath, path)
 
     err := os.Remove(filePath)
@@ -64,8 +95,119 @@ func (l LocalFao) DeleteFile(path string) error {
     return nil
 }
 // returns true if file exists, otherwise false.
-func (l LocalFao) FileExists(path string) bool {
+func (l LocalFao) FileExists(ctx context.Context, path string) bool {
     filePath := filepath.Join(l.basePath, path)
     _, err := os.Stat(filePath)
     return !os.IsNotExist(err)
 }
+
+// returns size, modification time and content hash of a file on disk.
+func (l LocalFao) StatFile(ctx context.Context, path string) (FileInfo, error) {
+    if err := ctx.Err(); err != nil {
+        return FileInfo{}, err
+    }
+    filePath := filepath.Join(l.basePath, path)
+
+    file, err := os.Open(filePath)
+    if err != nil {
+        return FileInfo{}, fmt.Errorf("failed to open file: %w", err)
+    }
+    defer file.Close()
+
+    stat, err := file.Stat()
+    if err != nil {
+        return FileInfo{}, fmt.Errorf("failed to stat file: %w", err)
+    }
+
+    hash := sha256.New()
+    if _, err := io.Copy(hash, contextReader{ctx: ctx, r: file}); err != nil {
+        return FileInfo{}, fmt.Errorf("failed to hash file: %w", err)
+    }
+
+    return FileInfo{
+        Path:    path,
+        Size:    stat.Size(),
+        ModTime: stat.ModTime(),
+        Hash:    hex.EncodeToString(hash.Sum(nil)),
+    }, nil
+}
+
+// lists every file under the base path whose relative path starts with prefix.
+// hashes are not computed, use StatFile for that.
+func (l LocalFao) ListFiles(ctx context.Context, prefix string) ([]FileInfo, error) {
+    var files []FileInfo
+
+    err := filepath.WalkDir(l.basePath, func(filePath string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if d.IsDir() {
+            return nil
+        }
+
+        rel, err := filepath.Rel(l.basePath, filePath)
+        if err != nil {
+            return err
+        }
+        rel = filepath.ToSlash(rel)
+        if !strings.HasPrefix(rel, prefix) {
+            return nil
+        }
+
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        files = append(files, FileInfo{Path: rel, Size: info.Size(), ModTime: info.ModTime()})
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to list files: %w", err)
+    }
+
+    return files, nil
+}
+
+// lists files like ListFiles, hashing each one like StatFile. a file deleted while
+// the listing is hashed is left out.
+func (l LocalFao) ListFilesWithHash(ctx context.Context, prefix string) ([]FileInfo, error) {
+    listed, err := l.ListFiles(ctx, prefix)
+    if err != nil {
+        return nil, err
+    }
+
+    files := make([]FileInfo, 0, len(listed))
+    for _, info := range listed {
+        info, err := l.StatFile(ctx, info.Path)
+        if errors.Is(err, fs.ErrNotExist) {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        files = append(files, info)
+    }
+
+    return files, nil
+}
+
+// contextReader fails reads once ctx is done, so a copy stops along with the request it's for.
+type contextReader struct {
+    ctx context.Context
+    r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+    if err := c.ctx.Err(); err != nil {
+        return 0, err
+    }
+    return c.r.Read(p)
+}
+
+type contextReadCloser struct {
+    contextReader
+    io.Closer
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"scriptorium/internal/backend/backup"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//---------------------------------------------------
+//------------------ADMIN-HANDLER--------------------
+//---------------------------------------------------
+
+// AdminHandler serves maintenance operations on the database and storage. They're not
+// meant for library users, every route needs Token as a bearer token.
+type AdminHandler struct {
+	DaoService DaoService
+	// FaoService reaches storage through the file service, like every other handler
+	FaoService fao.FAO
+	// DocumentFactory builds the documents an import creates and holds the defined types
+	DocumentFactory *dao.DocumentFactory
+	// Token is the bearer token requests have to carry, nothing is served without one
+	Token string
+}
+
+func NewAdminHandler(daos DaoService, faoService fao.FAO, token string) *AdminHandler {
+	return &AdminHandler{DaoService: daos, FaoService: faoService, Token: token}
+}
+
+func (h *AdminHandler) GetService() any {
+	return h.DaoService
+}
+
+func (h *AdminHandler) Middleware() []gin.HandlerFunc {
+	return []gin.HandlerFunc{h.authorize}
+}
+
+// authorize lets a request through when it carries Token as "Authorization: Bearer <token>".
+// An empty Token lets nothing through, the routes stream backups and read server paths.
+func (h *AdminHandler) authorize(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || h.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "A valid admin token is required")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// Fsck reports inconsistencies between the records and storage without changing anything.
+func (h *AdminHandler) Fsck(c *gin.Context) {
+	h.fsck(c, nil)
+}
+
+// Repair runs the same check, fixing the requested classes of problem.
+func (h *AdminHandler) Repair(c *gin.Context) {
+	var req FsckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request body",
+			ErrorDetail{Message: err.Error()})
+		return
+	}
+	fix, err := ParseFsckClasses(req.Fix)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error(),
+			ErrorDetail{Field: "fix", Message: "unknown problem class"})
+		return
+	}
+	h.fsck(c, fix)
+}
+
+func (h *AdminHandler) fsck(c *gin.Context, fix []string) {
+	report, err := h.DaoService.Fsck(c.Request.Context(), h.FaoService, fix, requestUser(c))
+	if errors.Is(err, dao.ErrUnsupported) {
+		respondDaoError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("fsck failed: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to check the database against storage")
+		return
+	}
+	respond(c, http.StatusOK, report)
+}
+
+// Metrics counts the records in each part of the database, including those quarantined.
+func (h *AdminHandler) Metrics(c *gin.Context) {
+	stats, err := h.DaoService.Stats(c.Request.Context())
+	if err != nil {
+		log.Printf("failed to read database stats: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to read metrics")
+		return
+	}
+	respond(c, http.StatusOK, MetricsResponse{
+		Documents:      stats.Documents,
+		Trashed:        stats.Trashed,
+		Quarantined:    stats.Quarantined,
+		PendingFileOps: stats.PendingFileOps,
+		SkippedRecords: stats.SkippedRecords,
+	})
+}
+
+// Quarantine lists the records scans couldn't decode, as they were stored.
+func (h *AdminHandler) Quarantine(c *gin.Context) {
+	records, err := h.DaoService.Quarantined(c.Request.Context())
+	if errors.Is(err, dao.ErrUnsupported) {
+		respondDaoError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to list quarantine: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to list quarantined records")
+		return
+	}
+	respond(c, http.StatusOK, newQuarantineResponse(records))
+}
+
+// Backup streams a tar.zst of a database snapshot and every stored file, see backup.Write.
+func (h *AdminHandler) Backup(c *gin.Context) {
+	name := fmt.Sprintf("scriptorium-%s.tar.zst", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Type", "application/zstd")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", name))
+
+	// once the body has started errors can only be logged, the archive is left without its
+	// manifest so it won't restore
+	manifest, err := backup.Write(c.Request.Context(), c.Writer, &h.DaoService, h.FaoService, dao.SchemaVersion())
+	if err != nil {
+		log.Printf("backup failed: %v", err)
+		switch {
+		case c.Writer.Written():
+		case errors.Is(err, dao.ErrUnsupported):
+			c.Writer.Header().Del("Content-Disposition")
+			respondDaoError(c, err)
+		default:
+			c.Writer.Header().Del("Content-Disposition")
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to back up the library")
+		}
+		return
+	}
+	log.Printf("backup %s written with %d files", name, len(manifest.Files))
+}
+
+// Import stores the files of a directory or zip archive on the server, or the entries of a
+// bibliography, see Importer. It streams a line of JSON per file as it's handled, then the
+// report.
+func (h *AdminHandler) Import(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request body",
+			ErrorDetail{Message: err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(c.Writer)
+	send := func(event ImportEvent) {
+		encoder.Encode(event)
+		c.Writer.Flush()
+	}
+	importer := &Importer{
+		DaoService:      &h.DaoService,
+		Files:           h.FaoService,
+		DocumentFactory: h.DocumentFactory,
+		DocType:         req.DocType,
+		User:            requestUser(c),
+		Progress: func(done, total int, result ImportResult) {
+			send(ImportEvent{Progress: &ImportProgress{Done: done, Total: total, Result: result}})
+		},
+	}
+
+	report, err := importer.Import(c.Request.Context(), req.Source)
+	switch {
+	case err == nil:
+		send(ImportEvent{Report: &report})
+	case !c.Writer.Written():
+		c.Writer.Header().Del("Content-Type")
+		if errors.Is(err, ErrInvalidImport) {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		log.Printf("import of %s failed: %v", req.Source, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to import")
+	default:
+		// what was imported stays, running the import again picks up where it stopped
+		log.Printf("import of %s stopped after %d file(s): %v", req.Source, len(report.Results), err)
+		send(ImportEvent{Report: &report, Error: &ErrorBody{Code: ErrCodeInternal, Message: "The import stopped part way, run it again to resume"}})
+	}
+}
+
+// DefineDocumentType creates or replaces a schema-defined type, the body's title
+// defaults to the :name parameter and must match it when given. Types are saved to the
+// server's types file, so defining them is an admin's job.
+func (h *AdminHandler) DefineDocumentType(c *gin.Context) {
+	name := c.Param("name")
+
+	var schema dao.TypeSchema
+	if err := c.ShouldBindJSON(&schema); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidSchema, "Request body must be a JSON Schema object",
+			ErrorDetail{Message: err.Error()})
+		return
+	}
+	if schema.Title == "" {
+		schema.Title = name
+	}
+	if schema.Title != name {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidSchema, "Schema title must match the type name in the path",
+			ErrorDetail{Field: "title", Message: "must be " + name})
+		return
+	}
+
+	if err := h.DocumentFactory.DefineType(schema); err != nil {
+		respondTypeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, TypesResponse{
+		Types:   h.DocumentFactory.GetRegisteredTypes(),
+		Schemas: h.DocumentFactory.GetSchemas(),
+	})
+}
+
+// RemoveDocumentType removes a schema-defined type, keeping its documents.
+func (h *AdminHandler) RemoveDocumentType(c *gin.Context) {
+	if err := h.DocumentFactory.RemoveType(c.Param("name")); err != nil {
+		respondTypeError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, TypesResponse{
+		Types:   h.DocumentFactory.GetRegisteredTypes(),
+		Schemas: h.DocumentFactory.GetSchemas(),
+	})
+}
+
+func (h *AdminHandler) GetRouterGroups() (string, map[string]gin.HandlerFunc) {
+	groupName := "/admin"
+
+	routes := map[string]gin.HandlerFunc{
+		"GET /fsck":           h.Fsck,
+		"POST /fsck":          h.Repair,
+		"GET /metrics":        h.Metrics,
+		"GET /quarantine":     h.Quarantine,
+		"GET /backup":         h.Backup,
+		"POST /import":        h.Import,
+		"PUT /types/:name":    h.DefineDocumentType,
+		"DELETE /types/:name": h.RemoveDocumentType,
+	}
+
+	return groupName, routes
+}
+
+func (h *AdminHandler) GetRouteDocs() map[string]RouteDoc {
+	return map[string]RouteDoc{
+		"PUT /types/:name": {
+			Summary:     "Define or replace a document type from a JSON Schema",
+			Description: "Properties may be string, integer, number, boolean or array, with required, enum and pattern. Built-in types can't be replaced. The types are saved to TYPES_PATH, GET /data/types lists them.",
+			Request:     dao.TypeSchema{},
+			Response:    TypesResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusInternalServerError},
+		},
+		"DELETE /types/:name": {
+			Summary:     "Remove a schema-defined document type",
+			Description: "Existing documents of the type are kept.",
+			Response:    TypesResponse{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		"GET /fsck": {
+			Summary:     "Check records against storage",
+			Description: "Reports missing files, orphaned files, size or sha256 mismatches and records that can't be decoded. Nothing is changed.",
+			Response:    FsckReport{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		"POST /fsck": {
+			Summary:     "Check records against storage and fix problems",
+			Description: "fix lists the classes to fix: missing clears a record's file fields, orphan deletes the file, mismatch records the stored size and sha256, corrupt moves the record to quarantine. all fixes every class. Record repairs are added to the document's history.",
+			Request:     FsckRequest{},
+			Response:    FsckReport{},
+			Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		"GET /metrics": {
+			Summary:     "Count the records in the database",
+			Description: "skipped_records counts the corrupt records searches have skipped since the server started, each is quarantined the first time.",
+			Response:    MetricsResponse{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		"GET /quarantine": {
+			Summary:     "List quarantined records",
+			Description: "Records that couldn't be decoded are moved out of search by the first scan that finds them, and kept here as stored.",
+			Response:    QuarantineResponse{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		"GET /backup": {
+			Summary:     "Download a backup of the library",
+			Description: "A zstd compressed tar of a consistent database snapshot, every stored file and a manifest.json with their sizes and sha256 sums. Writes carry on while it's taken. Restore it with scriptorium restore.",
+			Binary:      "application/zstd",
+			Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		"POST /import": {
+			Summary:     "Import a directory, zip archive or bibliography on the server",
+			Description: "Stores every file of an allowed type with a record. Metadata comes from the file name, a manifest.csv at the root and a JSON or YAML sidecar next to the file, each overriding the last. Files already in the library are reported as duplicates, so an interrupted import can be run again to resume. A .bib or .ris source creates a document per entry, as POST /v1/data/import does. The body is newline delimited JSON: a progress line per file, then the report.",
+			Request:     ImportRequest{},
+			Binary:      "application/x-ndjson",
+			Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+	}
+}
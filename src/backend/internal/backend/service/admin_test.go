@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"scriptorium/internal/backend/backup"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+
+	"github.com/boltdb/bolt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testAdminToken = "admin-secret"
+
+// adminRequest builds a request carrying the admin token
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	return req
+}
+
+func TestV1AdminRequiresToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, token := range []string{testAdminToken, ""} {
+		r := gin.New()
+		if err := registerRoutes(r, true, NewAdminHandler(DaoService{}, nil, token)); err != nil {
+			t.Fatalf("failed to register routes: %v", err)
+		}
+		for _, path := range []string{"/v1/admin/backup", "/admin/backup"} {
+			for _, header := range []string{"", "Bearer wrong", testAdminToken, "Bearer "} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.Header.Set("Authorization", header)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusUnauthorized {
+					t.Errorf("expected 401 for %s with %q and token %q, got %d", path, header, token, w.Code)
+				}
+			}
+		}
+	}
+}
+
+// a record that can't be decoded is left out of search and shows up in the admin endpoints
+func TestV1SearchSkipsCorruptRecords(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to create test db: %v", err)
+	}
+	bad := uuid.NewString()
+	db.Update(func(tx *bolt.Tx) error {
+		documents, _ := tx.CreateBucketIfNotExists([]byte("documents"))
+		return documents.Put([]byte(bad), []byte(`{"Title": "Half written`))
+	})
+	db.Close()
+
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: dbPath, Mode: 0600})
+	if err != nil {
+		t.Fatalf("failed to connect to test db: %v", err)
+	}
+	defer d.Disconnect()
+	daos := DaoService{dao: d}
+	doc := &dao.Notes{}
+	doc.SetMetaData(dao.MetaData{Title: "Readable", DocType: "Notes", Uuid: uuid.NewString()})
+	if err := daos.Create(context.Background(), doc, "ada"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	factory := dao.NewDocumentFactory()
+	r := gin.New()
+	if err := registerRoutes(r, false, NewAPIHandler(daos, factory, nil), NewAdminHandler(daos, fao.NewLocalFao(t.TempDir()), testAdminToken)); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/search", nil))
+	var search SearchResponse
+	json.Unmarshal(w.Body.Bytes(), &search)
+	if w.Code != http.StatusOK || search.TotalCount != 1 || search.WarningCount != 1 {
+		t.Fatalf("expected the readable record and one warning, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, adminRequest(http.MethodGet, "/v1/admin/quarantine", nil))
+	var quarantine QuarantineResponse
+	json.Unmarshal(w.Body.Bytes(), &quarantine)
+	if quarantine.Count != 1 || quarantine.Records[0].Key != bad || quarantine.Records[0].Raw != `{"Title": "Half written` {
+		t.Fatalf("expected the corrupt record in quarantine, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, adminRequest(http.MethodGet, "/v1/admin/metrics", nil))
+	var metrics MetricsResponse
+	json.Unmarshal(w.Body.Bytes(), &metrics)
+	if metrics.Documents != 1 || metrics.Quarantined != 1 || metrics.SkippedRecords != 1 {
+		t.Fatalf("unexpected metrics: %s", w.Body.String())
+	}
+}
+
+// the backup endpoint streams an archive that restores to the same documents
+func TestV1AdminBackupRestores(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: filepath.Join(t.TempDir(), "test.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("failed to connect to test db: %v", err)
+	}
+	defer d.Disconnect()
+	daos := DaoService{dao: d}
+	doc := &dao.Notes{}
+	doc.SetMetaData(dao.MetaData{Title: "Kept", DocType: "Notes", Uuid: uuid.NewString()})
+	if err := daos.Create(context.Background(), doc, "ada"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	r := gin.New()
+	if err := registerRoutes(r, false, NewAdminHandler(daos, fao.NewLocalFao(t.TempDir()), testAdminToken)); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, adminRequest(http.MethodGet, "/v1/admin/backup", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zstd" {
+		t.Fatalf("expected an archive, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	dir := t.TempDir()
+	manifest, err := backup.Restore(bytes.NewReader(w.Body.Bytes()), backup.RestoreOptions{
+		DatabasePath:  filepath.Join(dir, "restored.db"),
+		DatabaseMode:  0600,
+		StoragePath:   filepath.Join(dir, "storage"),
+		SchemaVersion: dao.SchemaVersion(),
+	})
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if manifest.SchemaVersion != dao.SchemaVersion() {
+		t.Errorf("expected schema version %d, got %d", dao.SchemaVersion(), manifest.SchemaVersion)
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"scriptorium/internal/backend/citation"
+	"scriptorium/internal/backend/dao"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//-------------------BIBLIOGRAPHY--------------------
+//---------------------------------------------------
+
+// a bibliography is a BibTeX or RIS file from a reference manager. importing one creates a
+// document with only metadata for each entry, its DocType from the entry type. an entry that
+// matches a document already in the library, by UUID, DOI or title, doesn't create one: a
+// document with a file, as an upload leaves it, has its metadata filled in from the entry,
+// one without is a duplicate, so running an import again reports what it got through as
+// duplicates. fields of an entry the document has no place for are listed in its result.
+
+// the parser of each bibliography format, by extension
+var bibliographyFormats = map[string]func(io.Reader) ([]citation.Entry, error){
+	".bib": citation.ParseBibTeX,
+	".ris": citation.ParseRIS,
+}
+
+// ImportBibliography imports the entries of a BibTeX or RIS file, the format going by the
+// extension of name. When ctx is cancelled it stops after the entry in hand, returning the
+// report so far.
+func (im *Importer) ImportBibliography(ctx context.Context, r io.Reader, name string) (ImportReport, error) {
+	report := ImportReport{Source: name, Results: []ImportResult{}}
+	parse, ok := bibliographyFormats[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return report, fmt.Errorf("%w: %s is not a .bib or .ris file", ErrInvalidImport, name)
+	}
+	if _, err := im.DocumentFactory.NewDocument(im.docType()); err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	entries, err := parse(r)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	report.Files = len(entries)
+
+	docs, err := im.DaoService.searchLibrary(ctx, "", "", "")
+	if err != nil {
+		return report, fmt.Errorf("failed to list the library: %w", err)
+	}
+	library := newBibliographyLibrary(docs)
+
+	base := filepath.Base(name)
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		result := im.importEntry(ctx, entry, library)
+		result.Path = fmt.Sprintf("%s:%d", base, entry.Line)
+		report.add(result)
+		if im.Progress != nil {
+			im.Progress(i+1, report.Files, result)
+		}
+	}
+	return report, nil
+}
+
+// importEntry creates the document of an entry, or fills in the one it matches, adding it
+// to library.
+func (im *Importer) importEntry(ctx context.Context, entry citation.Entry, library *bibliographyLibrary) ImportResult {
+	result := ImportResult{Key: entry.Key, Status: ImportFailed}
+	if entry.Err != nil {
+		result.Code, result.Message = ErrCodeInvalidRequest, entry.Err.Error()
+		return result
+	}
+
+	// an entry exported from a library keeps the document's UUID as its RIS ID
+	id := ""
+	if parsed, err := uuid.Parse(entry.Key); err == nil {
+		id = parsed.String()
+	}
+	matches, by := library.match(id, entry.Item)
+	switch {
+	case len(matches) == 1:
+		return im.fillEntry(ctx, entry, matches[0], library, result)
+	case len(matches) > 1:
+		result.Status = ImportSkipped
+		result.Message = fmt.Sprintf("Matches %d documents by %s: %s", len(matches), by, strings.Join(matches, ", "))
+		return result
+	}
+
+	docType := citation.DocType(entry.Item.Kind)
+	if _, known := im.DocumentFactory.GetSchema(docType); docType == "" || !known {
+		docType = im.docType()
+	}
+	schema, _ := im.DocumentFactory.GetSchema(docType)
+	fields, unmapped := entryFields(entry, docType, schema)
+	result.Unmapped = unmapped
+	if id == "" {
+		id = uuid.New().String()
+	}
+	doc, reqErr := documentFromRequest(im.DocumentFactory, fields, dao.MetaData{Uuid: id})
+	if reqErr != nil {
+		result.Code, result.Message, result.Details = reqErr.code, reqErr.message, reqErr.details
+		return result
+	}
+	if err := im.DaoService.Create(ctx, doc, im.User); err != nil {
+		result.Code, result.Message = ErrCodeInternal, fmt.Sprintf("failed to create database record: %v", err)
+		return result
+	}
+	library.add(doc.GetMetaData())
+	result.Status, result.Uuid = ImportImported, id
+	return result
+}
+
+// fillEntry fills in the metadata of the document an entry matched, when it has a file. The
+// document keeps its DocType, so the entry is mapped to the fields of that type. Its tags are
+// kept alongside the entry's keywords.
+func (im *Importer) fillEntry(ctx context.Context, entry citation.Entry, id string, library *bibliographyLibrary, result ImportResult) ImportResult {
+	if !library.hasFile(id) {
+		result.Status, result.DuplicateOf, result.Message = ImportDuplicate, id, "The library already has this reference"
+		return result
+	}
+
+	var reqErr *requestError
+	edit := func(record *dao.Record) error {
+		schema, _ := im.DocumentFactory.GetSchema(record.DocType)
+		fields, unmapped := entryFields(entry, record.DocType, schema)
+		result.Unmapped = unmapped
+		if len(entry.Item.Keywords) > 0 {
+			tags := make([]any, 0, len(record.Tags)+len(entry.Item.Keywords))
+			for _, tag := range record.Tags {
+				tags = append(tags, tag)
+			}
+			for _, keyword := range entry.Item.Keywords {
+				if !slices.Contains(record.Tags, keyword) {
+					tags = append(tags, keyword)
+				}
+			}
+			fields["Tags"] = tags
+		}
+
+		merged := mergePatch(record.Fields(), fields)
+		if _, ok := fields["Authors"]; ok {
+			// rederived from the entry's authors
+			delete(merged, "Author")
+		}
+		owned := dao.MetaData{Uuid: record.Uuid, Path: record.Path, FileType: record.FileType, Size: record.Size, Hash: record.Hash}
+		doc, docErr := documentFromRequest(im.DocumentFactory, merged, owned)
+		if docErr != nil {
+			reqErr = docErr
+			return errPatchRejected
+		}
+		filled, err := dao.NewRecord(doc)
+		if err != nil {
+			return err
+		}
+		*record = filled
+		return nil
+	}
+
+	patched, err := im.DaoService.Patch(ctx, uuid.MustParse(id), edit, im.User)
+	if err == nil {
+		err = patched.Err
+	}
+	switch {
+	case errors.Is(err, errPatchRejected):
+		result.Code, result.Message, result.Details = reqErr.code, reqErr.message, reqErr.details
+		return result
+	case err != nil:
+		result.Code, result.Message = ErrCodeInternal, fmt.Sprintf("failed to update %s: %v", id, err)
+		return result
+	case len(patched.Changes) == 0:
+		result.Status, result.DuplicateOf, result.Message = ImportDuplicate, id, "The document already has this reference's metadata"
+		return result
+	}
+	library.add(patched.Record.MetaData)
+	result.Status, result.Uuid = ImportMatched, id
+	return result
+}
+
+// entryFields is the body an entry's document of docType is created or filled in from, with
+// the names of the entry's fields schema has no place for. A field of the file that isn't
+// part of an Item is taken when schema has a property of that name, so a "product" field
+// fills in a Manual's Product.
+func entryFields(entry citation.Entry, docType string, schema dao.TypeSchema) (map[string]any, []string) {
+	item := entry.Item
+	fields := map[string]any{"DocType": docType}
+	set := func(name, value string) {
+		if value != "" {
+			fields[name] = value
+		}
+	}
+	set("Title", item.Title)
+	set("PublishDate", item.Date.String())
+	set("Publisher", item.Publisher)
+	set("Edition", item.Edition)
+	set("ISBN", item.ISBN)
+	set("DOI", item.DOI)
+	set("Language", item.Language)
+	set("Description", item.Abstract)
+	if len(item.Authors) > 0 {
+		authors := make([]any, 0, len(item.Authors))
+		for _, author := range item.Authors {
+			authors = append(authors, author.Inverted())
+		}
+		fields["Authors"] = authors
+	}
+	if len(item.Keywords) > 0 {
+		tags := make([]any, 0, len(item.Keywords))
+		for _, keyword := range item.Keywords {
+			tags = append(tags, keyword)
+		}
+		fields["Tags"] = tags
+	}
+	if item.PageCount > 0 {
+		fields["PageCount"] = float64(item.PageCount)
+	}
+
+	// the type specific fields, by the names the built in types give them
+	var unmapped []string
+	for name, value := range map[string]string{
+		"Journal": item.Container, "Series": item.Series, "Volume": item.Volume, "Issue": item.Issue,
+		"Pages": item.Pages, "Institution": item.Institution, "ReportNumber": item.Number, "Version": item.Version,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, ok := schema.Properties[name]; ok {
+			fields[name] = value
+		} else {
+			unmapped = append(unmapped, name)
+		}
+	}
+
+	row := map[string]string{}
+	for name, value := range entry.Unmapped {
+		property := ""
+		for candidate := range schema.Properties {
+			if strings.EqualFold(candidate, name) {
+				property = candidate
+			}
+		}
+		if _, taken := fields[property]; property == "" || taken {
+			unmapped = append(unmapped, name)
+			continue
+		}
+		row[property] = value
+	}
+	for name, value := range manifestFields(row, schema) {
+		fields[name] = value
+	}
+	slices.Sort(unmapped)
+	return fields, unmapped
+}
+
+// bibliographyLibrary is what's in the library, indexed by what an entry is matched on.
+type bibliographyLibrary struct {
+	docs   map[string]dao.MetaData
+	dois   map[string][]string
+	titles map[string][]string
+}
+
+func newBibliographyLibrary(docs []dao.MetaData) *bibliographyLibrary {
+	library := &bibliographyLibrary{
+		docs:   make(map[string]dao.MetaData, len(docs)),
+		dois:   map[string][]string{},
+		titles: map[string][]string{},
+	}
+	for _, doc := range docs {
+		library.add(doc)
+	}
+	return library
+}
+
+// add indexes a document, replacing what was indexed of it before
+func (l *bibliographyLibrary) add(meta dao.MetaData) {
+	if prev, ok := l.docs[meta.Uuid]; ok {
+		drop := func(ids []string) []string {
+			return slices.DeleteFunc(ids, func(id string) bool { return id == meta.Uuid })
+		}
+		l.dois[matchDOI(prev.DOI)] = drop(l.dois[matchDOI(prev.DOI)])
+		l.titles[matchTitle(prev.Title)] = drop(l.titles[matchTitle(prev.Title)])
+	}
+	l.docs[meta.Uuid] = meta
+	if doi := matchDOI(meta.DOI); doi != "" {
+		l.dois[doi] = append(l.dois[doi], meta.Uuid)
+	}
+	if title := matchTitle(meta.Title); title != "" {
+		l.titles[title] = append(l.titles[title], meta.Uuid)
+	}
+}
+
+func (l *bibliographyLibrary) hasFile(id string) bool {
+	return l.docs[id].Path != ""
+}
+
+// match finds the documents an item is, by the UUID given for it, its DOI or else its
+// title, saying which. A title only matches documents without a DOI, two works with the
+// same title and different DOIs are different works. Of several matches, the one document
+// with a file is taken.
+func (l *bibliographyLibrary) match(id string, item citation.Item) (matches []string, by string) {
+	if _, ok := l.docs[id]; ok {
+		return []string{id}, "UUID"
+	}
+	if doi := matchDOI(item.DOI); doi != "" && len(l.dois[doi]) > 0 {
+		matches, by = l.dois[doi], "DOI"
+	} else if title := matchTitle(item.Title); title != "" {
+		for _, candidate := range l.titles[title] {
+			if l.docs[candidate].DOI == "" || item.DOI == "" {
+				matches = append(matches, candidate)
+			}
+		}
+		by = "title"
+	}
+	if len(matches) > 1 {
+		var withFile []string
+		for _, candidate := range matches {
+			if l.hasFile(candidate) {
+				withFile = append(withFile, candidate)
+			}
+		}
+		if len(withFile) == 1 {
+			return withFile, by
+		}
+	}
+	return slices.Clone(matches), by
+}
+
+// DOIs are case insensitive
+func matchDOI(doi string) string {
+	return strings.ToLower(strings.TrimSpace(doi))
+}
+
+// matchTitle is what titles are compared by: their letters and digits, lowercased, so
+// "Computing Machinery and Intelligence" matches the "computing_machinery-and intelligence"
+// an uploaded file's name gives
+func matchTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
+
+// ImportBibliography creates documents from the entries of an uploaded BibTeX or RIS file,
+// see Importer.ImportBibliography, responding with the report.
+func (h *APIHandler) ImportBibliography(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(c, http.StatusRequestEntityTooLarge, ErrCodeFileTooLarge, "File size exceeds maximum limit of 100MB")
+			return
+		}
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid file upload, expected a multipart 'file' field")
+		return
+	}
+	defer file.Close()
+	if _, ok := bibliographyFormats[strings.ToLower(path.Ext(header.Filename))]; !ok {
+		respondError(c, http.StatusBadRequest, ErrCodeUnsupportedFileType, "A bibliography must be a .bib or .ris file")
+		return
+	}
+
+	importer := &Importer{
+		DaoService:      &h.DaoService,
+		DocumentFactory: h.DocumentFactory,
+		DocType:         c.PostForm("doc_type"),
+		User:            requestUser(c),
+	}
+	report, err := importer.ImportBibliography(c.Request.Context(), file, header.Filename)
+	switch {
+	case errors.Is(err, ErrInvalidImport):
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+	case err != nil:
+		// what was imported stays, importing the file again picks up where it stopped
+		log.Printf("import of %s stopped after %d entries: %v", header.Filename, len(report.Results), err)
+		respondDaoError(c, err)
+	default:
+		respond(c, http.StatusOK, report)
+	}
+}
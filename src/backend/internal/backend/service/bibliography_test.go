@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testBibliography = `@article{turing1950computing,
+  author = {Turing, Alan Mathison},
+  title = {Computing Machinery and Intelligence},
+  journal = {Mind}, year = 1950, month = oct, volume = {59}, number = {236}, pages = {433--460},
+  doi = {10.1093/mind/LIX.236.433},
+  keywords = {ai},
+  url = {https://academic.oup.com/mind/article/LIX/236/433/986238}
+}
+
+@book{knuth1997art,
+  author = {Donald E. Knuth}, title = {The Art of Computer Programming},
+  publisher = {Addison-Wesley}, year = 1997, edition = {3}
+}
+
+@book{knuth1997artb,
+  author = {Knuth, Donald E.}, title = {The {A}rt of {C}omputer {P}rogramming}
+}
+
+@misc{lecture, title = {Lecture notes on {\"U}bersetzerbau}, year = 2021}
+
+@article{nojournal, author = {Nobody}, title = {Nowhere}}
+`
+
+// importBibliography posts a bibliography to /data/import as filename
+func importBibliography(t *testing.T, r http.Handler, filename, content string) ImportReport {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("import failed: %d: %s", w.Code, w.Body.String())
+	}
+	var report ImportReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	return report
+}
+
+func TestBibliographyImportMatchesUploadsAndReportsDuplicates(t *testing.T) {
+	r, handler, cleanup := setupFileRouter(t)
+	defer cleanup()
+
+	// an upload titled by its file name, with a tag of its own
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/v1/file/upload", "turing.txt", "can machines think?",
+		`{"DocType":"Notes","Title":"computing_machinery-and intelligence","Tags":["to read"]}`))
+	var upload UploadResponse
+	json.Unmarshal(w.Body.Bytes(), &upload)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	report := importBibliography(t, r, "library.bib", testBibliography)
+	if report.Files != 5 || report.Matched != 1 || report.Imported != 2 || report.Duplicates != 1 || report.Failed != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	turing, knuth, knuthAgain, lecture, nojournal := report.Results[0], report.Results[1], report.Results[2], report.Results[3], report.Results[4]
+
+	if turing.Status != ImportMatched || turing.Uuid != upload.Uuid || turing.Path != "library.bib:1" || turing.Key != "turing1950computing" {
+		t.Errorf("expected the upload matched by title, got %+v", turing)
+	}
+	if want := []string{"Issue", "Journal", "Pages", "Volume", "url"}; !slices.Equal(turing.Unmapped, want) {
+		t.Errorf("expected %v unmapped for Notes, got %v", want, turing.Unmapped)
+	}
+	doc := readImported(t, r, turing)
+	if doc.Title != "Computing Machinery and Intelligence" || doc.DOI != "10.1093/mind/LIX.236.433" || doc.PublishDate != "1950-10" ||
+		!slices.Equal(doc.Tags, []string{"to read", "ai"}) || doc.Sha256 == "" {
+		t.Errorf("expected the upload's metadata filled in, keeping its file and tags, got %+v", doc)
+	}
+
+	if book := readImported(t, r, knuth); book.DocType != "Book" || book.Publisher != "Addison-Wesley" || book.Edition != "3" {
+		t.Errorf("expected a Book, got %+v", book)
+	}
+	if knuthAgain.Status != ImportDuplicate || knuthAgain.DuplicateOf != knuth.Uuid {
+		t.Errorf("expected the second entry for the book a duplicate of the first, got %+v", knuthAgain)
+	}
+	if notes := readImported(t, r, lecture); notes.DocType != "Notes" || notes.Title != "Lecture notes on Übersetzerbau" {
+		t.Errorf("expected a misc entry imported as Notes, got %+v", notes)
+	}
+	if nojournal.Status != ImportFailed || nojournal.Code != ErrCodeValidationFailed {
+		t.Errorf("expected the article without a journal to fail validation, got %+v", nojournal)
+	}
+
+	// running it again, from a file on the server, only the invalid entry isn't a duplicate
+	source := filepath.Join(t.TempDir(), "library.bib")
+	os.WriteFile(source, []byte(testBibliography), 0644)
+	again, err := newTestImporter(handler).Import(context.Background(), source)
+	if err != nil {
+		t.Fatalf("error importing again: %s", err)
+	}
+	if again.Duplicates != 4 || again.Failed != 1 || again.Results[0].DuplicateOf != upload.Uuid {
+		t.Errorf("expected everything a duplicate, got %+v", again)
+	}
+}
+
+func TestBibliographyImportRejectsOtherFiles(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "library.csv")
+	part.Write([]byte("title\n"))
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a csv refused, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"scriptorium/internal/backend/dao"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//--------------------BULK-UPDATE--------------------
+//---------------------------------------------------
+
+// the MetaData fields a bulk update may set. the rest are owned by the server, and Custom
+// is set a key at a time as "Custom.<key>"
+var bulkFields = []string{
+	"Title", "Author", "Authors", "PublishDate", "DeweyDecimal", "ISBN", "DOI",
+	"Publisher", "Edition", "Language", "PageCount", "Description", "Tags",
+}
+
+// metaDataPatch is the set, add_tags and remove_tags of a bulk update, decoded and type
+// checked once before it's applied to any document.
+type metaDataPatch struct {
+	fields []string
+	values dao.MetaData
+	// custom maps a key to its new value, nil removing it
+	custom     map[string]*string
+	addTags    []string
+	removeTags []string
+}
+
+func newMetaDataPatch(req BulkUpdateRequest) (metaDataPatch, []ErrorDetail) {
+	patch := metaDataPatch{custom: map[string]*string{}}
+	var problems []ErrorDetail
+
+	plain := map[string]any{}
+	for _, field := range sortedFieldNames(req.Set) {
+		value := req.Set[field]
+		if key, ok := strings.CutPrefix(field, "Custom."); ok && key != "" {
+			str, isStr := value.(string)
+			switch {
+			case value == nil:
+				patch.custom[key] = nil
+			case isStr:
+				patch.custom[key] = &str
+			default:
+				problems = append(problems, ErrorDetail{Field: field, Message: "must be a string or null"})
+			}
+			continue
+		}
+		if !slices.Contains(bulkFields, field) {
+			problems = append(problems, ErrorDetail{Field: field, Message: "can't be set by a bulk update"})
+			continue
+		}
+		patch.fields = append(patch.fields, field)
+		plain[field] = value
+	}
+	values, typeProblems := metaDataFromRequest(plain)
+	patch.values = values
+	problems = append(problems, typeProblems...)
+
+	patch.addTags = dropEmpty(req.AddTags)
+	patch.removeTags = dropEmpty(req.RemoveTags)
+	if len(patch.fields) == 0 && len(patch.custom) == 0 && len(patch.addTags) == 0 && len(patch.removeTags) == 0 && len(problems) == 0 {
+		problems = append(problems, ErrorDetail{Field: "set", Message: "set, add_tags or remove_tags must change something"})
+	}
+	return patch, problems
+}
+
+func dropEmpty(list []string) []string {
+	return slices.DeleteFunc(slices.Clone(list), func(s string) bool { return strings.TrimSpace(s) == "" })
+}
+
+// apply sets the patched fields on meta. Setting only one of Author and Authors rederives
+// the other, as a create would.
+func (p metaDataPatch) apply(meta *dao.MetaData) {
+	target, source := reflect.ValueOf(meta).Elem(), reflect.ValueOf(p.values)
+	for _, field := range p.fields {
+		target.FieldByName(field).Set(source.FieldByName(field))
+	}
+	switch {
+	case slices.Contains(p.fields, "Author") && !slices.Contains(p.fields, "Authors"):
+		meta.Authors = nil
+	case slices.Contains(p.fields, "Authors") && !slices.Contains(p.fields, "Author"):
+		meta.Author = ""
+	}
+	meta.SyncAuthors()
+
+	for key, value := range p.custom {
+		if value == nil {
+			delete(meta.Custom, key)
+			continue
+		}
+		if meta.Custom == nil {
+			meta.Custom = map[string]string{}
+		}
+		meta.Custom[key] = *value
+	}
+
+	for _, tag := range p.addTags {
+		if !slices.Contains(meta.Tags, tag) {
+			meta.Tags = append(meta.Tags, tag)
+		}
+	}
+	meta.Tags = slices.DeleteFunc(meta.Tags, func(tag string) bool { return slices.Contains(p.removeTags, tag) })
+}
+
+// bulkEdit applies the patch to a stored record, then normalizes and validates it the way
+// documentFromRequest does a request body, failing with a *dao.ValidationError.
+func (h *APIHandler) bulkEdit(patch metaDataPatch) dao.BulkEdit {
+	return func(record *dao.Record) error {
+		patch.apply(&record.MetaData)
+
+		var problems dao.ValidationError
+		var invalid *dao.ValidationError
+		schema, _ := h.DocumentFactory.GetSchema(record.DocType)
+		if err := dao.NormalizeMetaData(&record.MetaData, schema); errors.As(err, &invalid) {
+			problems.Fields = append(problems.Fields, invalid.Fields...)
+		}
+
+		// records of a type that's since been removed only get the MetaData checks
+		if doc, err := h.DocumentFactory.NewDocument(record.DocType); err == nil {
+			if len(record.Payload) > 0 {
+				if err := json.Unmarshal(record.Payload, doc); err != nil {
+					return fmt.Errorf("error reading %s payload: %v", record.DocType, err)
+				}
+			}
+			doc.SetMetaData(record.MetaData)
+			if err := dao.ValidateDocument(doc); errors.As(err, &invalid) {
+				problems.Fields = append(problems.Fields, invalid.Fields...)
+			}
+		}
+
+		if len(problems.Fields) > 0 {
+			return &problems
+		}
+		return nil
+	}
+}
+
+// BulkUpdate applies one patch to many documents, in a single transaction. Per-document
+// failures are reported in the results, the request only fails when it's malformed.
+func (h *APIHandler) BulkUpdate(c *gin.Context) {
+	var req BulkUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Request body must be a bulk update object",
+			ErrorDetail{Message: err.Error()})
+		return
+	}
+
+	patch, problems := newMetaDataPatch(req)
+	if len(problems) > 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid patch", problems...)
+		return
+	}
+
+	ids, results, ok := h.bulkTargets(c, req)
+	if !ok {
+		return
+	}
+
+	updated, err := h.DaoService.BulkUpdate(c.Request.Context(), ids, h.bulkEdit(patch), requestUser(c), req.DryRun)
+	if err != nil {
+		respondDaoError(c, err)
+		return
+	}
+
+	response := BulkUpdateResponse{DryRun: req.DryRun, Results: results}
+	for _, result := range updated {
+		response.Results = append(response.Results, newBulkUpdateResultJSON(result))
+	}
+	for _, result := range response.Results {
+		switch result.Status {
+		case BulkUpdated:
+			response.UpdatedCount++
+		case BulkUnchanged:
+			response.UnchangedCount++
+		default:
+			response.FailedCount++
+		}
+	}
+	response.MatchedCount = len(response.Results)
+	respond(c, http.StatusOK, response)
+}
+
+// bulkTargets resolves the documents a bulk update names, by UUID or by search. UUIDs that
+// don't parse are returned as failed results. It responds itself when neither is given.
+func (h *APIHandler) bulkTargets(c *gin.Context, req BulkUpdateRequest) ([]uuid.UUID, []BulkUpdateResultJSON, bool) {
+	byQuery := req.Query != "" || req.Key != ""
+	if len(req.Uuids) == 0 && !byQuery {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Either uuids or a search (q, or key and value) is required",
+			ErrorDetail{Field: "uuids", Message: "required without q or key"})
+		return nil, nil, false
+	}
+	if len(req.Uuids) > 0 && byQuery {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Give either uuids or a search, not both",
+			ErrorDetail{Field: "uuids", Message: "not allowed with q or key"})
+		return nil, nil, false
+	}
+
+	ids := []uuid.UUID{}
+	results := []BulkUpdateResultJSON{}
+	if byQuery {
+		matches, err := h.DaoService.Search(c.Request.Context(), req.Query, req.Key, req.Value)
+		if err != nil {
+			respondDaoError(c, err)
+			return nil, nil, false
+		}
+		for _, meta := range matches {
+			if id, err := uuid.Parse(meta.Uuid); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return ids, results, true
+	}
+
+	for _, uuidStr := range req.Uuids {
+		id, err := uuid.Parse(uuidStr)
+		if err != nil {
+			results = append(results, BulkUpdateResultJSON{Uuid: uuidStr, Status: BulkFailed, Changes: []FieldChangeJSON{},
+				Error: &ErrorBody{Code: ErrCodeInvalidUUID, Message: fmt.Sprintf("Invalid UUID '%s'", uuidStr)}})
+			continue
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, results, true
+}
+
+func newBulkUpdateResultJSON(result dao.BulkResult) BulkUpdateResultJSON {
+	entry := BulkUpdateResultJSON{Uuid: result.Uuid, Status: BulkUnchanged, Changes: make([]FieldChangeJSON, 0, len(result.Changes))}
+	for _, change := range result.Changes {
+		entry.Changes = append(entry.Changes, FieldChangeJSON{Field: change.Field, Old: change.Old, New: change.New})
+	}
+
+	var invalid *dao.ValidationError
+	switch {
+	case result.Err == nil:
+		if result.Updated() {
+			entry.Status = BulkUpdated
+		}
+		doc := newRecordJSON(result.Record)
+		entry.Document = &doc
+		return entry
+	case errors.Is(result.Err, dao.ErrDocumentNotFound):
+		entry.Error = &ErrorBody{Code: ErrCodeNotFound, Message: fmt.Sprintf("Document '%s' not found", result.Uuid)}
+	case errors.Is(result.Err, dao.ErrDocumentTrashed):
+		entry.Error = &ErrorBody{Code: ErrCodeTrashed, Message: "The document is in the trash, restore it first"}
+	case errors.As(result.Err, &invalid):
+		entry.Error = &ErrorBody{Code: ErrCodeValidationFailed, Message: "Document failed validation", Details: dedupeDetails(fieldErrorDetails(invalid))}
+	default:
+		log.Printf("bulk update of %s failed: %v", result.Uuid, result.Err)
+		entry.Error = &ErrorBody{Code: ErrCodeInternal, Message: fmt.Sprintf("Failed to update UUID '%s'", result.Uuid)}
+	}
+	entry.Status = BulkFailed
+	return entry
+}
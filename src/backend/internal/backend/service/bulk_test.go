@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// patchBulk sends body to PATCH /v1/data/bulk
+func patchBulk(t *testing.T, r http.Handler, body map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	bodyBytes, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPatch, "/v1/data/bulk", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "ada")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestV1BulkUpdateByUuids(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	calculus := createNote(t, r, "Calculus")
+	topology := createNote(t, r, "Topology")
+	missing := uuid.New().String()
+
+	w := patchBulk(t, r, map[string]any{
+		"uuids":    []string{calculus.Uuid, topology.Uuid, missing, "not-a-uuid"},
+		"set":      map[string]any{"DeweyDecimal": "510", "Custom.shelf": "B2"},
+		"add_tags": []string{"maths"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp BulkUpdateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.DryRun || resp.MatchedCount != 4 || resp.UpdatedCount != 2 || resp.FailedCount != 2 {
+		t.Fatalf("unexpected counts: %s", w.Body.String())
+	}
+
+	codes := map[string]string{}
+	for _, result := range resp.Results {
+		if result.Error != nil {
+			codes[result.Uuid] = result.Error.Code
+			continue
+		}
+		doc := result.Document
+		if result.Status != BulkUpdated || doc.DeweyDecimal != "510" || doc.Custom["shelf"] != "B2" || len(doc.Tags) != 1 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	}
+	if codes[missing] != ErrCodeNotFound || codes["not-a-uuid"] != ErrCodeInvalidUUID {
+		t.Errorf("expected not_found and invalid_uuid failures, got %v", codes)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/data/history/"+calculus.Uuid, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var history HistoryResponse
+	json.Unmarshal(w.Body.Bytes(), &history)
+	if len(history.Revisions) != 2 || history.Revisions[1].User != "ada" {
+		t.Errorf("expected a revision by ada, got: %s", w.Body.String())
+	}
+}
+
+func TestV1BulkUpdateByQueryDryRun(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createNote(t, r, "Linear Algebra")
+	createNote(t, r, "Poetry")
+
+	w := patchBulk(t, r, map[string]any{
+		"q":       "algebra",
+		"set":     map[string]any{"DeweyDecimal": "512"},
+		"dry_run": true,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp BulkUpdateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.DryRun || resp.MatchedCount != 1 || resp.UpdatedCount != 1 || resp.Results[0].Uuid != created.Uuid {
+		t.Fatalf("expected the one match previewed, got: %s", w.Body.String())
+	}
+	if changes := resp.Results[0].Changes; len(changes) != 1 || changes[0].Field != "DeweyDecimal" || changes[0].New != "512" {
+		t.Errorf("expected the DeweyDecimal change previewed, got %+v", changes)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/data/read/"+created.Uuid, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var read DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &read)
+	if read.Document.DeweyDecimal != "" {
+		t.Errorf("expected the dry run to leave the document alone, got %+v", read.Document)
+	}
+}
+
+func TestV1BulkUpdateValidation(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createNote(t, r, "Calculus")
+
+	tests := []struct {
+		name string
+		body map[string]any
+		code string
+	}{
+		{"no documents", map[string]any{"set": map[string]any{"DeweyDecimal": "510"}}, ErrCodeInvalidRequest},
+		{"uuids and query", map[string]any{"uuids": []string{created.Uuid}, "q": "calc", "set": map[string]any{"DeweyDecimal": "510"}}, ErrCodeInvalidRequest},
+		{"server owned field", map[string]any{"uuids": []string{created.Uuid}, "set": map[string]any{"Path": "x.txt"}}, ErrCodeValidationFailed},
+		{"wrong type", map[string]any{"uuids": []string{created.Uuid}, "set": map[string]any{"PageCount": "many"}}, ErrCodeValidationFailed},
+		{"empty patch", map[string]any{"uuids": []string{created.Uuid}}, ErrCodeValidationFailed},
+	}
+	for _, tt := range tests {
+		w := patchBulk(t, r, tt.body)
+		var errResp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &errResp)
+		if w.Code != http.StatusBadRequest || errResp.Error.Code != tt.code {
+			t.Errorf("%s: expected 400 %s, got %d: %s", tt.name, tt.code, w.Code, w.Body.String())
+		}
+	}
+
+	// a patch that leaves a document invalid fails for that document only
+	w := patchBulk(t, r, map[string]any{"uuids": []string{created.Uuid}, "set": map[string]any{"Title": nil}})
+	var resp BulkUpdateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.FailedCount != 1 || resp.Results[0].Error.Code != ErrCodeValidationFailed {
+		t.Fatalf("expected a per-document validation failure, got %d: %s", w.Code, w.Body.String())
+	}
+	if details := resp.Results[0].Error.Details; len(details) != 1 || details[0].Field != "Title" {
+		t.Errorf("expected the Title to be reported, got %+v", details)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"scriptorium/internal/backend/citation"
+	"scriptorium/internal/backend/dao"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//---------------------------------------------------
+//----------------------CITE-------------------------
+//---------------------------------------------------
+
+// a document is cited either as a formatted reference, in one of citation.Styles, or as an
+// entry for a reference manager in one of the citation export formats.
+
+// the export formats that are citations, which /data/cite also takes as a style
+var citationFormats = []string{ExportBibTeX, ExportRIS, ExportCSLJSON}
+
+// writeCitations writes items in one of citationFormats
+func writeCitations(w io.Writer, format string, items []citation.Item) error {
+	switch format {
+	case ExportBibTeX:
+		return citation.WriteBibTeX(w, items)
+	case ExportRIS:
+		return citation.WriteRIS(w, items)
+	case ExportCSLJSON:
+		return citation.WriteCSLJSON(w, items)
+	}
+	return fmt.Errorf("%s is not a citation format", format)
+}
+
+// Cite formats a reference to a document, in APA style unless another is asked for. The
+// citation export formats are taken as styles too, giving the document's entry as text.
+func (h *APIHandler) Cite(c *gin.Context) {
+	id, ok := parseUUIDParam(c)
+	if !ok {
+		return
+	}
+	style := strings.ToLower(c.DefaultQuery("style", citation.StyleAPA))
+	styles := append(slices.Clone(citation.Styles), citationFormats...)
+	if !slices.Contains(styles, style) {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Unknown citation style '%s'", style),
+			ErrorDetail{Field: "style", Message: "must be one of " + strings.Join(styles, ", ")})
+		return
+	}
+
+	rawData, err := h.DaoService.ReadRaw(c.Request.Context(), id)
+	if err != nil {
+		respondDaoError(c, err)
+		return
+	}
+	var record dao.Record
+	if err := json.Unmarshal(rawData, &record); err != nil {
+		log.Printf("failed to parse document %s: %v", id, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse document metadata")
+		return
+	}
+
+	item := citation.NewItem(record)
+	response := CitationResponse{Uuid: record.Uuid, Style: style}
+	if slices.Contains(citationFormats, style) {
+		var entry bytes.Buffer
+		err = writeCitations(&entry, style, []citation.Item{item})
+		response.Text = entry.String()
+	} else {
+		var ref citation.Reference
+		ref, err = citation.Format(item, style)
+		response.Text, response.HTML = ref.Text, ref.HTML
+	}
+	if err != nil {
+		log.Printf("failed to cite %s as %s: %v", id, style, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to cite the document")
+		return
+	}
+	respond(c, http.StatusOK, response)
+}
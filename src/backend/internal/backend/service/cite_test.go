@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// createTuring stores an article with enough metadata to cite
+func createTuring(t *testing.T, r http.Handler) DocumentJSON {
+	t.Helper()
+	bodyBytes, _ := json.Marshal(map[string]any{
+		"DocType": "Article", "Title": "Computing Machinery and Intelligence", "Author": "Alan Turing",
+		"PublishDate": "1950-10", "Journal": "Mind", "Volume": "59", "Issue": "236", "Pages": "433-460",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d: %s", w.Code, w.Body.String())
+	}
+	var createResp CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &createResp)
+	return createResp.Document
+}
+
+func TestV1CiteFormatsReference(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createTuring(t, r)
+	cite := func(style string) (int, CitationResponse, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/cite/"+created.Uuid+"?style="+style, nil))
+		var resp CitationResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp, w.Body.String()
+	}
+
+	code, resp, body := cite("chicago")
+	if code != http.StatusOK || resp.Text != "Turing, Alan. “Computing Machinery and Intelligence.” Mind 59, no. 236 (1950): 433–460." {
+		t.Fatalf("expected a Chicago reference, got %d: %s", code, body)
+	}
+	if !strings.Contains(resp.HTML, "<i>Mind</i>") {
+		t.Errorf("expected the journal in italics, got %s", resp.HTML)
+	}
+
+	code, resp, body = cite("bibtex")
+	if code != http.StatusOK || !strings.HasPrefix(resp.Text, "@article{turing1950computing,") || resp.HTML != "" {
+		t.Errorf("expected a BibTeX entry, got %d: %s", code, body)
+	}
+
+	if code, _, body = cite("harvard"); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown style rejected, got %d: %s", code, body)
+	}
+}
+
+func TestV1ExportCitations(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createTuring(t, r)
+	createNote(t, r, "Shopping list")
+
+	w := getExport(t, r, "format=ris&q=machinery")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/x-research-info-systems") {
+		t.Fatalf("expected RIS, got %d: %s", w.Code, w.Body.String())
+	}
+	if ris := w.Body.String(); strings.Count(ris, "TY  - ") != 1 || !strings.Contains(ris, "ID  - "+created.Uuid) {
+		t.Errorf("expected the one match, got %s", ris)
+	}
+
+	w = getExport(t, r, "format=csl-json")
+	var items []map[string]any
+	json.Unmarshal(w.Body.Bytes(), &items)
+	if len(items) != 2 || !strings.Contains(w.Header().Get("Content-Disposition"), ".json") {
+		t.Errorf("expected every document as CSL-JSON, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"scriptorium/internal/backend/citation"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+//---------------------------------------------------
+//----------------------EXPORT-----------------------
+//---------------------------------------------------
+
+// an export writes the records of the library, or of a search, as CSV, JSON Lines or a zip
+// archive of the stored files with a manifest.csv. the archive is laid out the way an
+// Importer reads a source, so importing it into another library gives the same documents,
+// with the same UUIDs, back. the citation formats write them for reference managers.
+
+// the formats of GET /data/export
+const (
+	ExportCSV     = "csv"
+	ExportJSONL   = "jsonl"
+	ExportZip     = "zip"
+	ExportBibTeX  = "bibtex"
+	ExportRIS     = "ris"
+	ExportCSLJSON = "csl-json"
+)
+
+var ExportFormats = []string{ExportCSV, ExportJSONL, ExportZip, ExportBibTeX, ExportRIS, ExportCSLJSON}
+
+// the content type and file extension of each format
+var exportMedia = map[string][2]string{
+	ExportCSV:     {"text/csv; charset=utf-8", "csv"},
+	ExportJSONL:   {"application/x-ndjson", "jsonl"},
+	ExportZip:     {"application/zip", "zip"},
+	ExportBibTeX:  {"application/x-bibtex; charset=utf-8", "bib"},
+	ExportRIS:     {"application/x-research-info-systems; charset=utf-8", "ris"},
+	ExportCSLJSON: {"application/vnd.citationstyles.csl+json", "json"},
+}
+
+// the MetaData columns of a CSV export, named as in a create body. Author is derived from
+// Authors and Path is storage's own name for the file, so both are left out. Custom.<key>
+// columns and the type specific fields follow.
+var exportColumns = []string{
+	"Uuid", "DocType", "Title", "Authors", "PublishDate", "DeweyDecimal", "ISBN", "DOI",
+	"Publisher", "Edition", "Language", "PageCount", "Description", "Tags",
+	"FileType", "Size", "Hash", "CreatedAt", "LastUpdated",
+}
+
+// the directory of an export archive the files are written to
+const exportFilesDir = "files"
+
+// ExportSource calls fn with each record of an export in turn, stopping at fn's first
+// error. Writers that need more than one pass, like CSV for its header, call it again.
+type ExportSource func(fn func(dao.Record) error) error
+
+// exportRecords is the source of the documents matching the search, or of every document
+// when it's empty. Only the search's metadata is held, each pass reads the records anew, a
+// batch at a time, see dao.DAO.ReadRecords. Documents deleted since the search are left out.
+func (ds *DaoService) exportRecords(ctx context.Context, query, key, value string) (ExportSource, error) {
+	matches, err := ds.searchLibrary(ctx, query, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(matches))
+	for _, meta := range matches {
+		if id, err := uuid.Parse(meta.Uuid); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return func(fn func(dao.Record) error) error {
+		return ds.dao.ReadRecords(ctx, ids, fn)
+	}, nil
+}
+
+// WriteExportCSV writes records as CSV, a row each under a header of exportColumns, the
+// Custom keys and then the type specific fields any of them have, found by a first pass
+// over the records. Lists are joined with ";", the way a manifest is read. With files
+// given, a "file" column leads each row with the file's name in an export archive.
+func WriteExportCSV(w io.Writer, records ExportSource, files map[string]string) error {
+	custom := map[string]bool{}
+	payload := map[string]bool{}
+	err := records(func(record dao.Record) error {
+		for key := range record.Custom {
+			custom[key] = true
+		}
+		for name := range record.Fields() {
+			if !slices.Contains(exportColumns, name) && name != "Author" && name != "Path" && name != "Custom" {
+				payload[name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	header := slices.Clone(exportColumns)
+	for _, key := range sortedKeys(custom) {
+		header = append(header, "Custom."+key)
+	}
+	header = append(header, sortedKeys(payload)...)
+
+	out := csv.NewWriter(w)
+	if files != nil {
+		out.Write(append([]string{"file"}, header...))
+	} else {
+		out.Write(header)
+	}
+	err = records(func(record dao.Record) error {
+		var row []string
+		if files != nil {
+			row = append(row, files[record.Uuid])
+		}
+		fields := record.Fields()
+		for _, column := range header {
+			if key, ok := strings.CutPrefix(column, "Custom."); ok {
+				row = append(row, record.Custom[key])
+				continue
+			}
+			row = append(row, exportCell(fields[column]))
+		}
+		return out.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// exportCell formats a decoded JSON value for a CSV cell, a zero value as an empty cell
+func exportCell(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		if value == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, exportCell(item))
+		}
+		return strings.Join(items, ";")
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// WriteExportJSONL writes records as JSON Lines, each in the form the read endpoint
+// responds with.
+func WriteExportJSONL(w io.Writer, records ExportSource) error {
+	encoder := json.NewEncoder(w)
+	return records(func(record dao.Record) error {
+		return encoder.Encode(newRecordJSON(record))
+	})
+}
+
+// WriteExportArchive writes a zip of the records' files, named by title under files/, with
+// a manifest.csv of every record. A file that can't be read from storage is left out and
+// logged, its row is kept without one.
+func WriteExportArchive(ctx context.Context, w io.Writer, records ExportSource, files fao.FAO) error {
+	archive := zip.NewWriter(w)
+	names := map[string]string{}
+	taken := map[string]bool{}
+	err := records(func(record dao.Record) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if record.Path == "" {
+			return nil
+		}
+		name := exportFileName(record, taken)
+		if err := exportFile(ctx, archive, name, record.Path, files); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("export left out the file of %s: %v", record.Uuid, err)
+			return nil
+		}
+		names[record.Uuid] = name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// written last, so an archive that was cut short is missing it
+	manifest, err := archive.Create(importManifests[0])
+	if err != nil {
+		return err
+	}
+	if err := WriteExportCSV(manifest, records, names); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func exportFile(ctx context.Context, archive *zip.Writer, name, storagePath string, files fao.FAO) error {
+	src, err := files.GetFile(ctx, storagePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// exportFileName names a record's file in an export archive after its title, with its
+// extension. Characters that aren't safe in a file name are replaced, and a name already
+// taken gets a number: "Notes (2).pdf".
+func exportFileName(record dao.Record, taken map[string]bool) string {
+	stem := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(record.Title))
+	stem = strings.TrimLeft(stem, ". ")
+	if runes := []rune(stem); len(runes) > 100 {
+		stem = strings.TrimSpace(string(runes[:100]))
+	}
+	if stem == "" {
+		stem = record.Uuid
+	}
+
+	ext := record.FileType
+	if ext == "" {
+		ext = path.Ext(record.Path)
+	}
+	name := path.Join(exportFilesDir, stem+ext)
+	for n := 2; taken[strings.ToLower(name)]; n++ {
+		name = path.Join(exportFilesDir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// Export streams the documents matching a search, or every document, as CSV, JSON Lines,
+// a zip archive that can be imported again, or citations.
+func (h *APIHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", ExportCSV)
+	if !slices.Contains(ExportFormats, format) {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Unknown export format '%s'", format),
+			ErrorDetail{Field: "format", Message: "must be one of " + strings.Join(ExportFormats, ", ")})
+		return
+	}
+
+	records, err := h.DaoService.exportRecords(c.Request.Context(), c.Query("q"), c.Query("key"), c.Query("value"))
+	if err != nil {
+		respondDaoError(c, err)
+		return
+	}
+
+	media := exportMedia[format]
+	name := fmt.Sprintf("scriptorium-%s.%s", time.Now().UTC().Format("20060102T150405Z"), media[1])
+	c.Header("Content-Type", media[0])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", name))
+	c.Status(http.StatusOK)
+
+	// once the body has started errors can only be logged
+	switch format {
+	case ExportCSV:
+		err = WriteExportCSV(c.Writer, records, nil)
+	case ExportJSONL:
+		err = WriteExportJSONL(c.Writer, records)
+	case ExportZip:
+		err = WriteExportArchive(c.Request.Context(), c.Writer, records, h.FaoService)
+	default:
+		// a citation format's entries depend on one another, BibTeX keys are made unique
+		var items []citation.Item
+		err = records(func(record dao.Record) error {
+			items = append(items, citation.NewItem(record))
+			return nil
+		})
+		if err == nil {
+			err = writeCitations(c.Writer, format, items)
+		}
+	}
+	if err != nil {
+		log.Printf("export %s failed: %v", name, err)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"scriptorium/internal/backend/dao"
+
+	"github.com/google/uuid"
+)
+
+func getExport(t *testing.T, r http.Handler, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/export?"+query, nil))
+	return w
+}
+
+func TestV1ExportCSVAndJSONL(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	calculus := createNote(t, r, "Calculus")
+	createNote(t, r, "Poetry")
+
+	w := getExport(t, r, "format=csv")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("expected CSV, got %d: %s", w.Code, w.Body.String())
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %v: %v", rows, err)
+	}
+	header := rows[0]
+	if header[0] != "Uuid" || !slices.Contains(header, "Content") || slices.Contains(header, "Path") {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	w = getExport(t, r, "format=jsonl&q=calc")
+	var docs []DocumentJSON
+	lines := bufio.NewScanner(w.Body)
+	for lines.Scan() {
+		var doc DocumentJSON
+		json.Unmarshal(lines.Bytes(), &doc)
+		docs = append(docs, doc)
+	}
+	if len(docs) != 1 || docs[0].Uuid != calculus.Uuid || docs[0].Fields["Content"] != "Calculus" {
+		t.Fatalf("expected the one match with its fields, got %+v", docs)
+	}
+
+	w = getExport(t, r, "format=xml")
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Error.Details[0].Field != "format" {
+		t.Errorf("expected an unknown format rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// rawCountingDAO counts the documents read one at a time
+type rawCountingDAO struct {
+	dao.DAO
+	reads int
+}
+
+func (d *rawCountingDAO) ReadRaw(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	d.reads++
+	return d.DAO.ReadRaw(ctx, id)
+}
+
+// an export streams the records, read in batches rather than one by one, and a column only
+// the last of them has still makes the header
+func TestV1ExportReadsRecordsInBatches(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+	counting := &rawCountingDAO{DAO: handler.DaoService.dao}
+	handler.DaoService.dao = counting
+
+	const count = 250
+	for i := range count {
+		doc := &dao.Notes{}
+		meta := dao.MetaData{Uuid: uuid.New().String(), DocType: "Notes", Title: fmt.Sprintf("Note %03d", i)}
+		if i == count-1 {
+			meta.Custom = map[string]string{"shelf": "Z9"}
+		}
+		doc.SetMetaData(meta)
+		if err := handler.DaoService.Create(context.Background(), doc, "ada"); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	w := getExport(t, r, "format=csv")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != count+1 {
+		t.Fatalf("expected a header and %d rows, got %d: %v", count, len(rows), err)
+	}
+	shelf := slices.Index(rows[0], "Custom.shelf")
+	if shelf < 0 || !slices.ContainsFunc(rows[1:], func(row []string) bool { return row[shelf] == "Z9" }) {
+		t.Errorf("expected the last record's custom field exported, got header %v", rows[0])
+	}
+	if counting.reads != 0 {
+		t.Errorf("expected no documents read one at a time, got %d", counting.reads)
+	}
+}
+
+// importing an export archive into another library gives the same documents back
+func TestV1ExportArchiveRoundTripsThroughImport(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	source := fstest.MapFS{
+		"turing.pdf":      {Data: []byte("%PDF computing machinery")},
+		"turing.pdf.json": {Data: []byte(`{"DocType": "Article", "Title": "Computing Machinery", "Authors": ["Alan Turing"], "Journal": "Mind", "PageCount": 28, "Custom": {"shelf": "B2"}}`)},
+		"copy/notes.txt":  {Data: []byte("first")},
+		"notes.txt":       {Data: []byte("second")},
+	}
+	if report, err := newTestImporter(handler).ImportFS(context.Background(), source, "library"); err != nil || report.Imported != 3 {
+		t.Fatalf("import failed: %+v %v", report, err)
+	}
+	createNote(t, r, "No file")
+
+	w := getExport(t, r, "format=zip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("expected an archive, got %d", w.Code)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("failed to open the archive: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	// both notes are titled "notes"
+	want := []string{"files/Computing Machinery.pdf", "files/notes.txt", "files/notes (2).txt", "manifest.csv"}
+	if slices.Sort(names); !slices.Equal(names, slices.Sorted(slices.Values(want))) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+
+	r2, handler2, cleanup2 := setupTestRouter(t)
+	defer cleanup2()
+	report, err := newTestImporter(handler2).ImportFS(context.Background(), archive, "export.zip")
+	if err != nil || report.Imported != 4 || report.Failed != 0 {
+		t.Fatalf("expected every document imported, got %+v %v", report, err)
+	}
+
+	exported := getExport(t, r, "format=jsonl")
+	imported := getExport(t, r2, "format=jsonl")
+	documents := func(body *bytes.Buffer) map[string]DocumentJSON {
+		docs := map[string]DocumentJSON{}
+		lines := bufio.NewScanner(body)
+		for lines.Scan() {
+			var doc DocumentJSON
+			json.Unmarshal(lines.Bytes(), &doc)
+			// stamped and named anew by the import
+			doc.CreatedAt, doc.LastUpdated, doc.Path = "", "", ""
+			docs[doc.Uuid] = doc
+		}
+		return docs
+	}
+	before, after := documents(exported.Body), documents(imported.Body)
+	if len(before) != 4 || !reflect.DeepEqual(before, after) {
+		t.Errorf("expected the same documents back\nbefore: %+v\nafter:  %+v", before, after)
+	}
+
+	// and the same archive again is all duplicates
+	report, _ = newTestImporter(handler2).ImportFS(context.Background(), archive, "export.zip")
+	if report.Duplicates != 4 {
+		t.Errorf("expected every document a duplicate, got %+v", report)
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"slices"
+	"strings"
+)
+
+//---------------------------------------------------
+//-----------------------FSCK------------------------
+//---------------------------------------------------
+
+// the classes of problem Fsck reports, each can be fixed on its own
+const (
+	// FsckMissing is a record whose file isn't in storage, fixed by clearing the file fields
+	FsckMissing = "missing"
+	// FsckOrphan is a stored file nothing references, fixed by deleting it
+	FsckOrphan = "orphan"
+	// FsckMismatch is a file whose size or sha256 differs from its record, fixed by
+	// recording what's stored
+	FsckMismatch = "mismatch"
+	// FsckCorrupt is a record that can't be decoded, fixed by moving it to quarantine
+	FsckCorrupt = "corrupt"
+)
+
+// FsckClasses lists every class of problem, in the order they're checked.
+var FsckClasses = []string{FsckMissing, FsckOrphan, FsckMismatch, FsckCorrupt}
+
+// FsckProblem is a single inconsistency found between the database and storage.
+type FsckProblem struct {
+	Class string `json:"class"`
+	// Bucket is where the record lives, documents or trash, unset for orphans
+	Bucket string `json:"bucket,omitempty"`
+	Uuid   string `json:"uuid,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Detail string `json:"detail"`
+	Fixed  bool   `json:"fixed"`
+	// FixError is why a requested fix didn't go through
+	FixError string `json:"fix_error,omitempty"`
+}
+
+// FsckReport is the outcome of a check, Counts holding the number of problems per class.
+type FsckReport struct {
+	Records  int            `json:"records"`
+	Files    int            `json:"files"`
+	Problems []FsckProblem  `json:"problems"`
+	Counts   map[string]int `json:"counts"`
+	Fixed    int            `json:"fixed"`
+}
+
+// Unfixed is the number of problems still present after the check.
+func (r FsckReport) Unfixed() int {
+	return len(r.Problems) - r.Fixed
+}
+
+// ParseFsckClasses validates the classes to fix, "all" standing for every class.
+func ParseFsckClasses(classes []string) ([]string, error) {
+	var parsed []string
+	for _, class := range classes {
+		class = strings.ToLower(strings.TrimSpace(class))
+		switch {
+		case class == "":
+			continue
+		case class == "all":
+			return slices.Clone(FsckClasses), nil
+		case !slices.Contains(FsckClasses, class):
+			return nil, fmt.Errorf("unknown problem class %q, expected one of %s or all", class, strings.Join(FsckClasses, ", "))
+		case !slices.Contains(parsed, class):
+			parsed = append(parsed, class)
+		}
+	}
+	return parsed, nil
+}
+
+// Fsck checks every current and trashed record against storage, fixing the classes of
+// problem listed in fix. Repairs to records are recorded in their history under user.
+//
+// A stored file isn't an orphan when a record or an unpruned file version points at it,
+// when it's journaled (reconciling deals with those), when it's a cached conversion of a
+// referenced file, or when it's mentioned by a record that's corrupt or quarantined.
+func (ds *DaoService) Fsck(ctx context.Context, files fao.FAO, fix []string, user string) (FsckReport, error) {
+	report := FsckReport{Problems: []FsckProblem{}, Counts: map[string]int{}}
+
+	quarantine, err := capability[dao.QuarantineStore](ds, "fsck")
+	if err != nil {
+		return report, err
+	}
+	journal, err := capability[dao.FileJournal](ds, "fsck")
+	if err != nil {
+		return report, err
+	}
+	records, err := quarantine.ScanRecords(ctx)
+	if err != nil {
+		return report, err
+	}
+	referenced, err := journal.ReferencedFiles(ctx)
+	if err != nil {
+		return report, err
+	}
+	pending, err := journal.PendingFileOps(ctx)
+	if err != nil {
+		return report, err
+	}
+	// hashed as they're listed, in a single call to the file service
+	stored, err := files.ListFilesWithHash(ctx, "")
+	if err != nil {
+		return report, fmt.Errorf("failed to list storage: %w", err)
+	}
+	report.Records = len(records)
+	report.Files = len(stored)
+
+	inStorage := make(map[string]fao.FileInfo, len(stored))
+	for _, info := range stored {
+		inStorage[info.Path] = info
+	}
+
+	add := func(problem FsckProblem, repair func() error) {
+		if slices.Contains(fix, problem.Class) {
+			if err := repair(); err != nil {
+				problem.FixError = err.Error()
+			} else {
+				problem.Fixed = true
+				report.Fixed++
+			}
+		}
+		report.Counts[problem.Class]++
+		report.Problems = append(report.Problems, problem)
+	}
+
+	// files mentioned by quarantined records may still be wanted once they're fixed by hand
+	quarantined, err := quarantine.Quarantined(ctx)
+	if err != nil {
+		return report, err
+	}
+	var corrupt [][]byte
+	for _, record := range quarantined {
+		corrupt = append(corrupt, record.Raw)
+	}
+	for _, raw := range records {
+		if raw.Err != nil {
+			corrupt = append(corrupt, raw.Raw)
+			add(FsckProblem{Class: FsckCorrupt, Bucket: string(raw.Set), Uuid: raw.Key, Detail: raw.Err.Error()}, func() error {
+				return quarantine.Quarantine(ctx, raw.Set, raw.Key, raw.Err.Error())
+			})
+			continue
+		}
+
+		record := raw.Record
+		if record.Path == "" {
+			continue
+		}
+		problem := FsckProblem{Bucket: string(raw.Set), Uuid: record.Uuid, Path: record.Path}
+
+		info, ok := inStorage[record.Path]
+		if !ok {
+			problem.Class = FsckMissing
+			problem.Detail = "file is not in storage"
+			add(problem, func() error {
+				record.Path, record.FileType, record.Size, record.Hash = "", "", 0, ""
+				return quarantine.RepairRecord(ctx, raw.Set, record, user)
+			})
+			continue
+		}
+
+		switch {
+		case record.Hash != "" && record.Hash != info.Hash:
+			problem.Detail = fmt.Sprintf("sha256 is %s, recorded as %s", info.Hash, record.Hash)
+		case record.Size != info.Size:
+			problem.Detail = fmt.Sprintf("size is %d bytes, recorded as %d", info.Size, record.Size)
+		default:
+			continue
+		}
+		problem.Class = FsckMismatch
+		add(problem, func() error {
+			record.Size, record.Hash = info.Size, info.Hash
+			return quarantine.RepairRecord(ctx, raw.Set, record, user)
+		})
+	}
+
+	journaled := make(map[string]bool, len(pending))
+	for _, op := range pending {
+		journaled[op.Path] = true
+	}
+	// conversions are cached next to their source as <name>.<format>
+	sources := make(map[string]bool, len(referenced))
+	for path := range referenced {
+		sources[strings.TrimSuffix(path, filepath.Ext(path))] = true
+	}
+
+	for _, info := range stored {
+		path := info.Path
+		if referenced[path] || journaled[path] || sources[strings.TrimSuffix(path, filepath.Ext(path))] {
+			continue
+		}
+		if slices.ContainsFunc(corrupt, func(raw []byte) bool { return bytes.Contains(raw, []byte(path)) }) {
+			continue
+		}
+		add(FsckProblem{Class: FsckOrphan, Path: path, Detail: "no record references the file"}, func() error {
+			// journaled first, so a delete that fails part way is retried on start up
+			if err := journal.JournalFileOp(ctx, dao.FileOp{Path: path, Action: dao.FileOpDelete}); err != nil {
+				return err
+			}
+			return ds.deleteFiles(ctx, []string{path}, files)
+		})
+	}
+
+	return report, nil
+}
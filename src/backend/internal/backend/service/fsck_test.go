@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"scriptorium/internal/backend/service/pb"
+
+	"github.com/google/uuid"
+)
+
+func TestFsckFindsAndFixesEachClass(t *testing.T) {
+	tmpDir := t.TempDir()
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: filepath.Join(tmpDir, "test.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("failed to connect to test db: %v", err)
+	}
+	defer d.Disconnect()
+	storage := fao.NewLocalFao(t.TempDir())
+	daos := DaoService{dao: d}
+
+	create := func(path string, size int64, hash string) string {
+		doc := &dao.Notes{}
+		doc.SetMetaData(dao.MetaData{Title: path, DocType: "Notes", Uuid: uuid.NewString(), Path: path, Size: size, Hash: hash})
+		if err := daos.Create(context.Background(), doc, "ada"); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+		return doc.GetID()
+	}
+	sum := sha256.Sum256([]byte("intact"))
+	storage.SaveFile(context.Background(), "intact.txt", strings.NewReader("intact"))
+	create("intact.txt", 6, hex.EncodeToString(sum[:]))
+	// a conversion cached next to its source isn't an orphan
+	storage.SaveFile(context.Background(), "intact.html", strings.NewReader("<p>intact</p>"))
+	missing := create("missing.txt", 4, "")
+	storage.SaveFile(context.Background(), "changed.txt", strings.NewReader("changed later"))
+	changed := create("changed.txt", 7, hex.EncodeToString(sum[:]))
+	storage.SaveFile(context.Background(), "orphan.txt", strings.NewReader("orphan"))
+
+	report, err := daos.Fsck(context.Background(), storage, nil, "admin")
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+	if report.Counts[FsckMissing] != 1 || report.Counts[FsckMismatch] != 1 || report.Counts[FsckOrphan] != 1 || len(report.Problems) != 3 {
+		t.Fatalf("unexpected problems: %+v", report.Problems)
+	}
+	if report.Fixed != 0 || !storage.FileExists(context.Background(), "orphan.txt") {
+		t.Fatal("expected a check without fixes to change nothing")
+	}
+
+	report, err = daos.Fsck(context.Background(), storage, FsckClasses, "admin")
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+	if report.Fixed != 3 || report.Unfixed() != 0 {
+		t.Fatalf("expected every problem fixed: %+v", report.Problems)
+	}
+	if storage.FileExists(context.Background(), "orphan.txt") || !storage.FileExists(context.Background(), "intact.html") {
+		t.Error("expected only the orphan removed from storage")
+	}
+	read := func(id string) dao.MetaData {
+		var meta dao.MetaData
+		raw, _ := daos.ReadRaw(context.Background(), uuid.MustParse(id))
+		json.Unmarshal(raw, &meta)
+		return meta
+	}
+	if meta := read(missing); meta.Path != "" {
+		t.Errorf("expected the missing file cleared from its record, got %q", meta.Path)
+	}
+	if meta := read(changed); meta.Size != 13 || meta.Hash == hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the stored size and hash recorded, got %d %s", meta.Size, meta.Hash)
+	}
+
+	if report, _ := daos.Fsck(context.Background(), storage, nil, "admin"); len(report.Problems) != 0 {
+		t.Errorf("expected a clean check after fixing, got %+v", report.Problems)
+	}
+}
+
+func TestParseFsckClasses(t *testing.T) {
+	if classes, err := ParseFsckClasses([]string{"all"}); err != nil || len(classes) != len(FsckClasses) {
+		t.Errorf("expected all to select every class, got %v, %v", classes, err)
+	}
+	if classes, err := ParseFsckClasses([]string{" Orphan", "orphan", ""}); err != nil || len(classes) != 1 {
+		t.Errorf("expected one class, got %v, %v", classes, err)
+	}
+	if _, err := ParseFsckClasses([]string{"everything"}); err == nil {
+		t.Error("expected an unknown class to be rejected")
+	}
+}
+
+// the admin handler checks storage through the file service, not the disk
+func TestFsckOverFileService(t *testing.T) {
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: filepath.Join(t.TempDir(), "test.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("failed to connect to test db: %v", err)
+	}
+	defer d.Disconnect()
+	daos := DaoService{dao: d}
+
+	conn, cleanup := setupTestGrpc(t)
+	defer cleanup()
+	remote := NewFileServiceFao(pb.NewFileServiceClient(conn))
+	remote.SaveFile(context.Background(), "changed.txt", strings.NewReader("changed later"))
+	doc := &dao.Notes{}
+	doc.SetMetaData(dao.MetaData{Title: "Changed", DocType: "Notes", Uuid: uuid.NewString(), Path: "changed.txt", Size: 13, Hash: "stale"})
+	if err := daos.Create(context.Background(), doc, "ada"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	report, err := daos.Fsck(context.Background(), remote, nil, "admin")
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+	// the test server keeps its own database in the storage directory, an orphan here
+	if report.Counts[FsckMismatch] != 1 || report.Problems[0].Path != "changed.txt" {
+		t.Fatalf("expected the hash mismatch found over the file service: %+v", report)
+	}
+}
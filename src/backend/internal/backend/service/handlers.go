@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"path/filepath"
@@ -15,11 +19,14 @@ import (
 	"scriptorium/internal/backend/service/pb"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const maxFileSize = 100 * 1024 * 1024 // 100MB
@@ -45,6 +52,13 @@ type Handler interface {
 	GetService() any
 }
 
+// GuardedHandler is implemented by handlers whose routes all run behind middleware, e.g.
+// authentication. It's added to the group before any route is registered.
+type GuardedHandler interface {
+	Handler
+	Middleware() []gin.HandlerFunc
+}
+
 //---------------------------------------------------
 //-------------------FILE-HANDLER--------------------
 //---------------------------------------------------
@@ -54,6 +68,9 @@ type FileHandler struct {
 	FileServiceClient pb.FileServiceClient
 	APIHandler        *APIHandler
 	Converter         converter.Converter
+	// StorageTimeout is the deadline on each transfer to or from the storage node, 0 leaving
+	// it to the request. Either way the transfer stops when the client goes away.
+	StorageTimeout time.Duration
 }
 
 func (f *FileHandler) GetService() any {
@@ -84,51 +101,190 @@ func NewFileHandler(faos FileHandlerService, conn *grpc.ClientConn, apiHandler *
 //
 // The function will:
 // 1. Generate a unique filename for the uploaded file
-// 2. Save the file to the server's storage location
-// 3. If metadata is provided, create a database record with the file path
-// 4. Return the generated file path and document UUID
+// 2. If metadata is provided, journal the file so it can't outlive a failed record
+// 3. Save the file to the server's storage location
+// 4. If metadata is provided, create a database record with the file path
+// 5. Return the generated file path and document UUID
 func (f FileHandler) UploadFile(c *gin.Context) {
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
-
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		if err.Error() == "http: request body too large" {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File size exceeds maximum limit of 100MB"})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file Upload", "output": err.Error()})
+	file, header, fileExt, ok := receiveUpload(c)
+	if !ok {
 		return
 	}
-
 	defer file.Close()
 
-	fileExt := strings.ToLower(filepath.Ext(header.Filename))
-	if !isAllowedFileType(fileExt) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("File type '%s' is not supported. Supported types: PDF, DOCX, DOC, TXT, MD, RTF, ODT, EPUB, JPG, JPEG, PNG, GIF, SVG, MP3, WAV, FLAC, AAC, MP4, AVI, MOV, MKV", fileExt),
-		})
-		return
-	}
-
 	// Parse metadata from form data
 	var metadata map[string]any
 	if metadataStr := c.PostForm("metadata"); metadataStr != "" {
 		if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata format", "output": err.Error()})
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid metadata format",
+				ErrorDetail{Field: "metadata", Message: err.Error()})
 			return
 		}
 	}
 
 	// Generate unique filename to avoid conflicts
-	uniqueFilename := uuid.New().String() + fileExt
-	filePath := uniqueFilename
+	filePath := uuid.New().String() + fileExt
+
+	// build and validate the document before anything is stored, so bad metadata doesn't leave a file behind
+	var doc dao.Document
+	if len(metadata) > 0 {
+		owned := dao.MetaData{Uuid: uuid.New().String(), Path: filePath, FileType: fileExt, Size: header.Size}
+		var reqErr *requestError
+		if doc, reqErr = documentFromRequest(f.APIHandler.DocumentFactory, metadata, owned); reqErr != nil {
+			reqErr.respond(c)
+			return
+		}
+	}
+
+	// Just file upload without database record
+	if doc == nil {
+		message, _, ok := f.storeFile(c, file, filePath)
+		if !ok {
+			return
+		}
+		respond(c, http.StatusCreated, UploadResponse{Message: message, FilePath: filePath, OriginalFilename: header.Filename})
+		return
+	}
+
+	write, ok := f.beginFileWrite(c, uuid.MustParse(doc.GetID()), filePath)
+	if !ok {
+		return
+	}
+	message, hash, ok := f.storeFile(c, file, filePath)
+	if !ok {
+		write.Abort(c.Request.Context())
+		return
+	}
+	meta := doc.GetMetaData()
+	meta.Hash = hash
+	doc.SetMetaData(meta)
 
-	stream, err := f.FileServiceClient.UploadFile(context.Background())
+	// Save to database
+	err := f.APIHandler.DaoService.Create(c.Request.Context(), doc, requestUser(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload stream", "output": err.Error()})
+		write.Abort(c.Request.Context())
+		log.Printf("failed to create database record: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create database record")
+		return
+	}
+	write.Commit(c.Request.Context())
+
+	response := UploadResponse{
+		Message:          message,
+		FilePath:         filePath,
+		OriginalFilename: header.Filename,
+	}
+
+	document := newDocumentJSONFor(doc)
+	response.Uuid = doc.GetID()
+	response.Document = &document
+	respond(c, http.StatusCreated, response)
+}
+
+// UploadRevision replaces an existing document's file with a new version. The previous file
+// is kept in storage and listed in the document's history, until the retention policy prunes it.
+func (f FileHandler) UploadRevision(c *gin.Context) {
+	id, ok := parseUUIDParam(c)
+	if !ok {
 		return
 	}
+	if _, err := f.APIHandler.DaoService.ReadRaw(c.Request.Context(), id); err != nil {
+		respondDaoError(c, err)
+		return
+	}
+
+	file, header, fileExt, ok := receiveUpload(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
 
+	filePath := uuid.New().String() + fileExt
+	write, ok := f.beginFileWrite(c, id, filePath)
+	if !ok {
+		return
+	}
+	message, hash, ok := f.storeFile(c, file, filePath)
+	if !ok {
+		write.Abort(c.Request.Context())
+		return
+	}
+
+	version := dao.FileVersion{Path: filePath, FileType: fileExt, Size: header.Size, Hash: hash}
+	record, err := f.APIHandler.DaoService.ReplaceFile(c.Request.Context(), id, version, requestUser(c))
+	if err != nil {
+		// the new file isn't referenced by anything, so it's removed again
+		write.Abort(c.Request.Context())
+		respondDaoError(c, err)
+		return
+	}
+	write.Commit(c.Request.Context())
+
+	if err := f.APIHandler.DaoService.PruneFiles(c.Request.Context(), id, f.APIHandler.KeepFileVersions, f.APIHandler.FaoService); err != nil {
+		log.Printf("failed to prune file versions of %s: %v", id, err)
+	}
+
+	document := newRecordJSON(record)
+	respond(c, http.StatusCreated, UploadResponse{
+		Message:          message,
+		FilePath:         filePath,
+		OriginalFilename: header.Filename,
+		Uuid:             record.Uuid,
+		Document:         &document,
+	})
+}
+
+// receiveUpload reads the multipart "file" field, enforcing the size limit and allowed file types.
+// ext is the lowercased extension, the caller closes file.
+func receiveUpload(c *gin.Context) (file multipart.File, header *multipart.FileHeader, ext string, ok bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(c, http.StatusRequestEntityTooLarge, ErrCodeFileTooLarge, "File size exceeds maximum limit of 100MB")
+			return nil, nil, "", false
+		}
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid file upload, expected a multipart 'file' field")
+		return nil, nil, "", false
+	}
+
+	ext = strings.ToLower(filepath.Ext(header.Filename))
+	if !isAllowedFileType(ext) {
+		file.Close()
+		respondError(c, http.StatusBadRequest, ErrCodeUnsupportedFileType,
+			fmt.Sprintf("File type '%s' is not supported. Supported types: PDF, DOCX, DOC, TXT, MD, RTF, ODT, EPUB, JPG, JPEG, PNG, GIF, SVG, MP3, WAV, FLAC, AAC, MP4, AVI, MOV, MKV", ext))
+		return nil, nil, "", false
+	}
+	return file, header, ext, true
+}
+
+// beginFileWrite journals a file about to be stored for document id, see FileWrite.
+func (f FileHandler) beginFileWrite(c *gin.Context, id uuid.UUID, filePath string) (*FileWrite, bool) {
+	write, err := f.APIHandler.DaoService.BeginFileWrite(c.Request.Context(), id, filePath, f.APIHandler.FaoService)
+	if err != nil {
+		log.Printf("failed to journal upload %s: %v", filePath, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to store file")
+		return nil, false
+	}
+	return write, true
+}
+
+// storeFile streams the upload to the gRPC file service under filePath, responding with
+// an error when it fails. It returns the file service's message and the sha256 of what was sent.
+func (f FileHandler) storeFile(c *gin.Context, file io.Reader, filePath string) (message, hash string, ok bool) {
+	ctx, cancel := f.storageContext(c)
+	defer cancel()
+	stream, err := f.FileServiceClient.UploadFile(ctx)
+	if err != nil {
+		log.Printf("failed to create upload stream: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeStorage, "Failed to store file")
+		return "", "", false
+	}
+
+	sum := sha256.New()
+	file = io.TeeReader(file, sum)
 	buf := make([]byte, 4096)
 	firstChunk := true
 	for {
@@ -137,8 +293,8 @@ func (f FileHandler) UploadFile(c *gin.Context) {
 			break
 		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error reading file", "output": err.Error()})
-			return
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Error reading uploaded file")
+			return "", "", false
 		}
 
 		chunk := &pb.FileChunk{Data: buf[:n]}
@@ -148,130 +304,60 @@ func (f FileHandler) UploadFile(c *gin.Context) {
 		}
 
 		if err := stream.Send(chunk); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send file chunk", "output": err.Error()})
-			return
+			log.Printf("failed to send file chunk: %v", err)
+			respondError(c, http.StatusInternalServerError, ErrCodeStorage, "Failed to store file")
+			return "", "", false
 		}
 	}
 	// Close the stream and get the response
 	resp, err := stream.CloseAndRecv()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload failed"})
-		return
+		log.Printf("upload failed: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeStorage, "Failed to store file")
+		return "", "", false
 	}
+	return resp.Message, hex.EncodeToString(sum.Sum(nil)), true
+}
 
-	// Create database record with the file path
-	if len(metadata) > 0 {
-		// Add the file path to metadata
-		metadata["Path"] = filePath
-		metadata["FileType"] = fileExt
-
-		// Create document record
-		docType, ok := metadata["DocType"].(string)
-		if !ok || docType == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid document type in metadata"})
-			return
-		}
-
-		// Use the API handler's document factory to create the document
-		doc, err := f.APIHandler.DocumentFactory.NewDocument(docType)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown document type"})
-			return
-		}
-
-		// Set metadata
-		meta := doc.GetMetaData()
-		meta.Uuid = uuid.New().String()
-		meta.DocType = docType
-		meta.Path = filePath
-		meta.FileType = fileExt
-		if title, ok := metadata["Title"].(string); ok {
-			meta.Title = title
-		}
-		if author, ok := metadata["Author"].(string); ok {
-			meta.Author = author
-		}
-		if publishDate, ok := metadata["PublishDate"].(string); ok {
-			meta.PublishDate = publishDate
-		}
-		if dewey, ok := metadata["DeweyDecimal"].(string); ok {
-			meta.DeweyDecimal = dewey
-		}
-
-		err = doc.SetMetaData(meta)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set metadata"})
-			return
-		}
-
-		// Set other document fields
-		docJSON, _ := json.Marshal(metadata)
-		if err := json.Unmarshal(docJSON, &doc); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode document"})
-			return
-		}
-
-		// Save to database
-		err = f.APIHandler.DaoService.Create(doc)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create database record", "output": err.Error()})
-			return
-		}
-
-		// Respond with success message and document info
-		c.JSON(http.StatusOK, gin.H{
-			"message":           resp.Message,
-			"file_path":         filePath,
-			"document_uuid":     doc.GetID(),
-			"original_filename": header.Filename,
-		})
-	} else {
-		// Just file upload without database record
-		c.JSON(http.StatusOK, gin.H{
-			"message":           resp.Message,
-			"file_path":         filePath,
-			"original_filename": header.Filename,
-		})
+// storageContext is the request's context, bounded by StorageTimeout when one is set.
+func (f FileHandler) storageContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	if f.StorageTimeout > 0 {
+		return context.WithTimeout(c.Request.Context(), f.StorageTimeout)
 	}
+	return context.WithCancel(c.Request.Context())
 }
 
 func (f FileHandler) DownloadFile(c *gin.Context) {
-	uuidStr := c.Param("uuid")
-	if uuidStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing UUID parameter"})
+	metadata, ok := f.APIHandler.readMetaData(c)
+	if !ok {
 		return
 	}
 
-	// Parse UUID
-	uuid, err := uuid.Parse(uuidStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+	if metadata.Path == "" {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Document has no stored file")
 		return
 	}
 
-	// Get document metadata from database
-	rawData, err := f.APIHandler.DaoService.ReadRaw(uuid)
+	// Call gRPC DownloadFile method with the file path from database, the stream is cancelled
+	// along with the request
+	ctx, cancel := f.storageContext(c)
+	defer cancel()
+	stream, err := f.FileServiceClient.DownloadFile(ctx, &pb.FileRequest{Filename: metadata.Path})
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		log.Printf("failed to open download stream: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeStorage, "Failed to download file")
 		return
 	}
 
-	// Parse metadata to get file path
-	var metadata dao.MetaData
-	if err := json.Unmarshal(rawData, &metadata); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse document metadata"})
-		return
-	}
-
-	if metadata.Path == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File path not found in document metadata"})
-		return
-	}
-
-	// Call gRPC DownloadFile method with the file path from database
-	stream, err := f.FileServiceClient.DownloadFile(context.Background(), &pb.FileRequest{Filename: metadata.Path})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file"})
+	// receive the first chunk before writing headers, so a missing file can still be reported as such
+	chunk, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		if status.Code(err) == codes.NotFound {
+			respondError(c, http.StatusNotFound, ErrCodeNotFound, "File not found in storage")
+			return
+		}
+		log.Printf("failed to download file: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeStorage, "Failed to download file")
 		return
 	}
 
@@ -283,66 +369,48 @@ func (f FileHandler) DownloadFile(c *gin.Context) {
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", downloadFilename))
 	c.Header("Content-Type", "application/octet-stream")
 
-	// Stream file chunks
-	for {
-		chunk, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error receiving file chunk", "output": err.Error()})
+	// Stream file chunks, once the body has started errors can only be logged
+	for err != io.EOF {
+		if _, err := c.Writer.Write(chunk.Data); err != nil {
+			log.Printf("failed to write file chunk: %v", err)
 			return
 		}
 
-		// Write chunk to HTTP response
-		_, err = c.Writer.Write(chunk.Data)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write file chunk", "output": err.Error()})
+		chunk, err = stream.Recv()
+		if err != nil && err != io.EOF {
+			log.Printf("error receiving file chunk: %v", err)
 			return
 		}
 	}
 }
 func (f FileHandler) ConvertFile(c *gin.Context) {
-	uuidStr := c.Param("uuid")
-	if uuidStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing UUID parameter"})
-		return
-	}
-
 	format := c.DefaultQuery("format", "pdf")
 
-	parsedUUID, err := uuid.Parse(uuidStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
-		return
-	}
-
-	rawData, err := f.APIHandler.DaoService.ReadRaw(parsedUUID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
-		return
-	}
-
-	var metadata dao.MetaData
-	if err := json.Unmarshal(rawData, &metadata); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse document metadata"})
+	metadata, ok := f.APIHandler.readMetaData(c)
+	if !ok {
 		return
 	}
 
 	if metadata.Path == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File path not found in document metadata"})
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Document has no stored file")
 		return
 	}
 
-	outputPath, err := f.Converter.ConvertDocumentByUUID(uuidStr, strings.TrimPrefix(metadata.FileType, "."), format)
+	outputPath, err := f.Converter.ConvertDocumentByUUID(c.Request.Context(), metadata.Uuid, strings.TrimPrefix(metadata.FileType, "."), format)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Conversion failed", "output": err.Error()})
+		log.Printf("conversion of %s to %s failed: %v", metadata.Uuid, format, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			respondError(c, http.StatusGatewayTimeout, ErrCodeTimeout, fmt.Sprintf("Conversion to '%s' took too long", format))
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrCodeConversionFailed, fmt.Sprintf("Conversion to '%s' failed", format))
 		return
 	}
 
-	file, err := f.APIHandler.FaoService.GetFile(outputPath)
+	file, err := f.APIHandler.FaoService.GetFile(c.Request.Context(), outputPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve converted file"})
+		log.Printf("failed to retrieve converted file: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeStorage, "Failed to retrieve converted file")
 		return
 	}
 	defer file.Close()
@@ -373,6 +441,7 @@ func (f *FileHandler) GetRouterGroups() (string, map[string]gin.HandlerFunc) {
 
 	routes := map[string]gin.HandlerFunc{
 		"POST /upload":        f.UploadFile,
+		"POST /upload/:uuid":  f.UploadRevision,
 		"GET /download/:uuid": f.DownloadFile,
 		"GET /convert/:uuid":  f.ConvertFile,
 	}
@@ -380,6 +449,43 @@ func (f *FileHandler) GetRouterGroups() (string, map[string]gin.HandlerFunc) {
 	return groupName, routes
 }
 
+func (f *FileHandler) GetRouteDocs() map[string]RouteDoc {
+	return map[string]RouteDoc{
+		"POST /upload": {
+			Summary:     "Upload a file",
+			Description: "Stores the file through the gRPC file service. When metadata is given a document record is created for it.",
+			Form: map[string]any{
+				"file":     map[string]any{"type": "string", "format": "binary"},
+				"metadata": map[string]any{"type": "string", "description": "JSON encoded UploadMetadata"},
+			},
+			Status:   http.StatusCreated,
+			Response: UploadResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusInternalServerError},
+		},
+		"POST /upload/:uuid": {
+			Summary:     "Upload a new version of a document's file",
+			Description: "The previous file stays in storage and in the document's history until the retention policy (HISTORY_KEEP_FILES) prunes it.",
+			Form: map[string]any{
+				"file": map[string]any{"type": "string", "format": "binary"},
+			},
+			Status:   http.StatusCreated,
+			Response: UploadResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusInternalServerError},
+		},
+		"GET /download/:uuid": {
+			Summary: "Download a document's file",
+			Binary:  "application/octet-stream",
+			Errors:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		"GET /convert/:uuid": {
+			Summary: "Convert a document's file with pandoc and stream the result",
+			Query:   []ParamDoc{{Name: "format", Description: "Target format, defaults to pdf"}},
+			Binary:  "application/octet-stream",
+			Errors:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+		},
+	}
+}
+
 //---------------------------------------------------
 //-------------------API-HANDLER---------------------
 //---------------------------------------------------
@@ -388,6 +494,10 @@ type APIHandler struct {
 	DaoService      DaoService
 	DocumentFactory *dao.DocumentFactory
 	FaoService      fao.FAO
+	// KeepFileVersions is how many file versions of a document are kept in storage, 0 keeps all of them
+	KeepFileVersions int
+	// TrashRetention is how long deleted documents stay in the trash before they're purged, 0 keeps them
+	TrashRetention time.Duration
 }
 
 func (h *APIHandler) GetService() any {
@@ -402,68 +512,93 @@ func (h *APIHandler) SearchByKeyValue(c *gin.Context) {
 	query := c.Query("q")
 	key := c.Query("key")
 	value := c.Query("value")
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "10")
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page parameter. Must be a positive integer."})
+	page, limit, ok := parsePagination(c)
+	if !ok {
 		return
 	}
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 || limit > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter. Must be between 1 and 100."})
+	allResults, err := h.DaoService.Search(c.Request.Context(), query, key, value)
+	if err != nil {
+		log.Printf("search failed: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Search failed")
 		return
 	}
 
-	var results []dao.MetaData
-	var totalCount int
+	respond(c, http.StatusOK, h.newSearchResponse(c.Request.Context(), allResults, page, limit))
+}
+
+// recent lists documents newest first, by LastUpdated when modified is set, otherwise by CreatedAt.
+func (h *APIHandler) recent(modified bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit, ok := parsePagination(c)
+		if !ok {
+			return
+		}
+
+		allResults, err := h.DaoService.Recent(c.Request.Context(), modified)
+		if err != nil {
+			log.Printf("listing recent documents failed: %v", err)
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to list recent documents")
+			return
+		}
 
-	var allResults []dao.MetaData
-	if query != "" {
-		allResults, err = h.DaoService.FuzzySearch(query)
-	} else {
-		allResults, err = h.DaoService.SearchByKeyValue(key, value)
+		respond(c, http.StatusOK, h.newSearchResponse(c.Request.Context(), allResults, page, limit))
 	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+}
+
+// parsePagination reads the page and limit query parameters, responding with an error when they're invalid.
+func parsePagination(c *gin.Context) (page, limit int, ok bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidPagination, "Invalid page parameter. Must be a positive integer.",
+			ErrorDetail{Field: "page", Message: "must be a positive integer"})
+		return 0, 0, false
 	}
 
-	totalCount = len(allResults)
+	limit, err = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidPagination, "Invalid limit parameter. Must be between 1 and 100.",
+			ErrorDetail{Field: "limit", Message: "must be between 1 and 100"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+func (h *APIHandler) newSearchResponse(ctx context.Context, allResults []dao.MetaData, page, limit int) SearchResponse {
+	results, totalPages := paginate(allResults, page, limit)
+
+	return SearchResponse{
+		Results:      newDocumentJSONList(results),
+		Count:        len(results),
+		TotalCount:   len(allResults),
+		Page:         page,
+		Limit:        limit,
+		TotalPages:   totalPages,
+		HasNext:      page < totalPages,
+		HasPrev:      page > 1,
+		WarningCount: h.DaoService.HiddenRecords(ctx),
+		raw:          results,
+	}
+}
+
+// paginate returns the 1-indexed page of results along with the total number of pages.
+func paginate[T any](all []T, page, limit int) ([]T, int) {
+	totalCount := len(all)
+	totalPages := (totalCount + limit - 1) / limit // Ceiling division
 
-	// Apply pagination
 	start := (page - 1) * limit
 	end := start + limit
 
 	if start >= totalCount {
 		// Page is beyond available data
-		results = []dao.MetaData{}
-	} else if end > totalCount {
+		return []T{}, totalPages
+	}
+	if end > totalCount {
 		// Last page
-		results = allResults[start:totalCount]
-	} else {
-		// Regular page
-		results = allResults[start:end]
+		end = totalCount
 	}
-
-	// Calculate pagination info
-	totalPages := (totalCount + limit - 1) / limit // Ceiling division
-	hasNext := page < totalPages
-	hasPrev := page > 1
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "Search completed",
-		"count":       len(results),
-		"total_count": totalCount,
-		"page":        page,
-		"limit":       limit,
-		"total_pages": totalPages,
-		"has_next":    hasNext,
-		"has_prev":    hasPrev,
-		"results":     results,
-	})
+	return all[start:end], totalPages
 }
 
 func (h *APIHandler) Create(c *gin.Context) {
@@ -471,253 +606,724 @@ func (h *APIHandler) Create(c *gin.Context) {
 	var reqData map[string]any
 
 	if err := c.ShouldBindJSON(&reqData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	docType, ok := reqData["DocType"].(string)
-	if !ok || docType == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid document type"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Request body must be a JSON object")
 		return
 	}
 
-	doc, err := h.DocumentFactory.NewDocument(docType)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown document type"})
-		return
-	}
-
-	docJSON, _ := json.Marshal(reqData)
-	if err := json.Unmarshal(docJSON, &doc); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode document"})
-		return
-	}
-
-	meta := doc.GetMetaData()
-	meta.Uuid = uuid.New().String()
-	meta.DocType = docType
-	if title, ok := reqData["Title"].(string); ok {
-		meta.Title = title
-	}
-	if author, ok := reqData["Author"].(string); ok {
-		meta.Author = author
-	}
-	if publishDate, ok := reqData["PublishDate"].(string); ok {
-		meta.PublishDate = publishDate
-	}
-	if dewey, ok := reqData["DeweyDecimal"].(string); ok {
-		meta.DeweyDecimal = dewey
-	}
-	err = doc.SetMetaData(meta)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to set metadata"})
+	doc, reqErr := documentFromRequest(h.DocumentFactory, reqData, dao.MetaData{Uuid: uuid.New().String()})
+	if reqErr != nil {
+		reqErr.respond(c)
 		return
 	}
 
-	err = h.DaoService.Create(doc)
+	err := h.DaoService.Create(c.Request.Context(), doc, requestUser(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		log.Printf("failed to create document: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create document")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Document inserted into DB", "UUID": doc.GetID()})
+	respond(c, http.StatusCreated, CreateResponse{Uuid: doc.GetID(), Document: newDocumentJSONFor(doc)})
 }
 
 func (h *APIHandler) Read(c *gin.Context) {
-	// Get UUID from URL parameter
-	uuidStr := c.Param("uuid")
-	if uuidStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing UUID parameter"})
+	id, ok := parseUUIDParam(c)
+	if !ok {
 		return
 	}
 
-	uuid, err := uuid.Parse(uuidStr)
+	rawData, err := h.DaoService.ReadRaw(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondDaoError(c, err)
 		return
 	}
 
-	rawData, err := h.DaoService.ReadRaw(uuid)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var record dao.Record
+	if err := json.Unmarshal(rawData, &record); err != nil {
+		log.Printf("failed to parse document %s: %v", id, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse document metadata")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "document retrieved", "value": string(rawData)})
+	c.Header("ETag", recordETag(record))
+	respond(c, http.StatusOK, DocumentResponse{
+		Document:      newRecordJSON(record),
+		legacyMessage: "document retrieved",
+		legacyValue:   string(rawData),
+	})
 }
 
 func (h *APIHandler) Update(c *gin.Context) {
 	var reqData map[string]any
 
 	if err := c.ShouldBindJSON(&reqData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Request body must be a JSON object")
 		return
 	}
 
 	docType, ok := reqData["DocType"].(string)
 	if !ok || docType == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid document type"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Missing or invalid document type",
+			ErrorDetail{Field: "DocType", Message: "required"})
 		return
 	}
 
 	uuidStr, ok := reqData["Uuid"].(string)
 	if !ok || uuidStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid UUID"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidUUID, "Missing or invalid UUID",
+			ErrorDetail{Field: "Uuid", Message: "required"})
 		return
 	}
 
-	doc, err := h.DocumentFactory.NewDocument(docType)
+	id, err := uuid.Parse(uuidStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown document type"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidUUID, "Invalid UUID format",
+			ErrorDetail{Field: "Uuid", Message: err.Error()})
 		return
 	}
 
-	docJSON, _ := json.Marshal(reqData)
-	if err := json.Unmarshal(docJSON, &doc); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode document"})
+	// updates only apply to existing records, Put would otherwise silently create one
+	rawData, err := h.DaoService.ReadRaw(c.Request.Context(), id)
+	if err != nil {
+		respondDaoError(c, err)
+		return
+	}
+	var stored dao.MetaData
+	if err := json.Unmarshal(rawData, &stored); err != nil {
+		log.Printf("failed to parse document %s: %v", id, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse document metadata")
 		return
 	}
 
-	meta := doc.GetMetaData()
-	meta.Uuid = uuidStr
-	meta.DocType = docType
-	if title, ok := reqData["Title"].(string); ok {
-		meta.Title = title
+	// the file fields describe what's in storage, whatever the client sends back is ignored
+	owned := dao.MetaData{Uuid: id.String(), Path: stored.Path, FileType: stored.FileType, Size: stored.Size, Hash: stored.Hash}
+	doc, reqErr := documentFromRequest(h.DocumentFactory, reqData, owned)
+	if reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
+
+	err = h.DaoService.Update(c.Request.Context(), doc, requestUser(c))
+	if err != nil {
+		log.Printf("failed to update document %s: %v", uuidStr, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update document")
+		return
 	}
-	if author, ok := reqData["Author"].(string); ok {
-		meta.Author = author
+
+	respond(c, http.StatusOK, DocumentResponse{
+		Document:      newDocumentJSONFor(doc),
+		legacyMessage: "update successful",
+		legacyValue:   doc.GetID(),
+	})
+}
+
+var (
+	// errPreconditionFailed is returned by a patch's edit when If-Match names another version
+	errPreconditionFailed = errors.New("document has changed")
+	// errPatchRejected is returned by a patch's edit when the patched document is invalid,
+	// the problems are kept in a requestError alongside
+	errPatchRejected = errors.New("patch rejected")
+)
+
+// recordETag is the entity tag of a document, the quoted fingerprint of its stored record
+func recordETag(record dao.Record) string {
+	return `"` + record.Fingerprint() + `"`
+}
+
+// etagMatches reports whether an If-Match header names etag. Weak tags never match, If-Match
+// uses the strong comparison.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
 	}
-	if publishDate, ok := reqData["PublishDate"].(string); ok {
-		meta.PublishDate = publishDate
+	return false
+}
+
+// Patch applies a JSON merge patch (RFC 7396) to the stored document, in the shape of an
+// update body. With If-Match it's only applied while the document is still at a version the
+// header names, checked in the transaction that writes it.
+func (h *APIHandler) Patch(c *gin.Context) {
+	id, ok := parseUUIDParam(c)
+	if !ok {
+		return
 	}
-	if dewey, ok := reqData["DeweyDecimal"].(string); ok {
-		meta.DeweyDecimal = dewey
+
+	var patch map[string]any
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Request body must be a JSON object")
+		return
 	}
-	if path, ok := reqData["Path"].(string); ok {
-		meta.Path = path
+	ifMatch := c.GetHeader("If-Match")
+
+	var reqErr *requestError
+	edit := func(record *dao.Record) error {
+		if ifMatch != "" && !etagMatches(ifMatch, recordETag(*record)) {
+			return errPreconditionFailed
+		}
+		if docType, ok := patch["DocType"]; ok && docType != record.DocType {
+			reqErr = &requestError{http.StatusBadRequest, ErrCodeInvalidRequest, "A document's type can't be patched, replace it with /data/update",
+				[]ErrorDetail{{Field: "DocType", Message: "must be the document's current type"}}}
+			return errPatchRejected
+		}
+
+		fields := mergePatch(record.Fields(), patch)
+		// patching one of Author and Authors rederives the other, as a create would
+		_, author := patch["Author"]
+		_, authors := patch["Authors"]
+		switch {
+		case author && !authors:
+			delete(fields, "Authors")
+		case authors && !author:
+			delete(fields, "Author")
+		}
+
+		owned := dao.MetaData{Uuid: record.Uuid, Path: record.Path, FileType: record.FileType, Size: record.Size, Hash: record.Hash}
+		doc, docErr := documentFromRequest(h.DocumentFactory, fields, owned)
+		if docErr != nil {
+			reqErr = docErr
+			return errPatchRejected
+		}
+		patched, err := dao.NewRecord(doc)
+		if err != nil {
+			return err
+		}
+		*record = patched
+		return nil
 	}
-	if fileType, ok := reqData["FileType"].(string); ok {
-		meta.FileType = fileType
+
+	result, err := h.DaoService.Patch(c.Request.Context(), id, edit, requestUser(c))
+	if err == nil {
+		err = result.Err
 	}
-	if err := doc.SetMetaData(meta); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set metadata"})
+	switch {
+	case errors.Is(err, errPatchRejected):
+		reqErr.respond(c)
+		return
+	case errors.Is(err, errPreconditionFailed):
+		respondError(c, http.StatusPreconditionFailed, ErrCodePreconditionFailed,
+			"The document has changed since it was read, read it again and reapply the changes")
+		return
+	case err != nil:
+		respondDaoError(c, err)
 		return
 	}
 
-	err = h.DaoService.Update(doc)
+	c.Header("ETag", recordETag(result.Record))
+	respond(c, http.StatusOK, DocumentResponse{
+		Document:      newRecordJSON(result.Record),
+		legacyMessage: "update successful",
+		legacyValue:   result.Uuid,
+	})
+}
+
+// Delete moves documents to the trash. Only the records move, their files stay in storage
+// until the documents are purged.
+func (h *APIHandler) Delete(c *gin.Context) {
+	result, ok := forEachUuid(c, "delete", func(id uuid.UUID, _ func(ErrorDetail)) error {
+		_, err := h.DaoService.Trash(c.Request.Context(), id, requestUser(c))
+		return err
+	})
+	if !ok {
+		return
+	}
+	response := DeleteResponse{DeletedCount: len(result.done), DeletedUuids: result.done, Failures: result.failures}
+	respondBulk(c, result, response, ErrCodeDeleteFailed, "No documents were deleted")
+}
+
+// Trash lists the documents in the trash, most recently deleted first.
+func (h *APIHandler) Trash(c *gin.Context) {
+	page, limit, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	trashed, err := h.DaoService.Trashed(c.Request.Context())
+	if errors.Is(err, dao.ErrUnsupported) {
+		respondDaoError(c, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		log.Printf("listing trash failed: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to list the trash")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "update successful", "value": doc.GetID()})
+	results, totalPages := paginate(trashed, page, limit)
+	response := TrashResponse{
+		Results:    make([]TrashedDocumentJSON, 0, len(results)),
+		Count:      len(results),
+		TotalCount: len(trashed),
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+		HasPrev:    page > 1,
+	}
+	for _, record := range results {
+		response.Results = append(response.Results, newTrashedDocumentJSON(record, h.TrashRetention))
+	}
+	respond(c, http.StatusOK, response)
 }
 
-func (h *APIHandler) Delete(c *gin.Context) {
+// RestoreTrash moves documents out of the trash, as they were when deleted.
+func (h *APIHandler) RestoreTrash(c *gin.Context) {
+	result, ok := forEachUuid(c, "restore", func(id uuid.UUID, _ func(ErrorDetail)) error {
+		_, err := h.DaoService.Untrash(c.Request.Context(), id, requestUser(c))
+		return err
+	})
+	if !ok {
+		return
+	}
+	response := RestoreTrashResponse{RestoredCount: len(result.done), RestoredUuids: result.done, Failures: result.failures}
+	respondBulk(c, result, response, ErrCodeRestoreFailed, "No documents were restored")
+}
 
-	// Parse JSON body to get UUIDs
-	var req struct {
-		Uuids []string `json:"uuids"`
+// PurgeTrash permanently removes documents from the trash, with their history and files.
+func (h *APIHandler) PurgeTrash(c *gin.Context) {
+	result, ok := forEachUuid(c, "purge", func(id uuid.UUID, warn func(ErrorDetail)) error {
+		purged, err := h.DaoService.Purge(c.Request.Context(), id, h.FaoService)
+		if purged.FileErr != nil {
+			log.Printf("failed to delete files of purged document %s: %v", id, purged.FileErr)
+			warn(ErrorDetail{Code: ErrCodeStorage, Message: fmt.Sprintf("Failed to delete file for UUID '%s'", id)})
+		}
+		return err
+	})
+	if !ok {
+		return
 	}
+	response := DeleteResponse{DeletedCount: len(result.done), DeletedUuids: result.done, Failures: result.failures, Warnings: result.warnings}
+	respondBulk(c, result, response, ErrCodeDeleteFailed, "No documents were purged")
+}
 
+// bulkResult collects the per-document outcomes of a request naming documents by UUID.
+type bulkResult struct {
+	done     []string
+	failures []ErrorDetail
+	warnings []ErrorDetail
+	// allNotFound is set when every failure was a missing document
+	allNotFound bool
+}
+
+// forEachUuid binds a {"uuids": [...]} body and runs action on each UUID in it. action
+// returns an error for a document that wasn't handled, and reports a document that was
+// handled with a problem (e.g. a stale file) through warn. It responds itself when the
+// body is invalid.
+func forEachUuid(c *gin.Context, verb string, action func(id uuid.UUID, warn func(ErrorDetail)) error) (bulkResult, bool) {
+	var req DeleteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid 'uuids' parameter."})
-		return
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Missing or invalid 'uuids' parameter.",
+			ErrorDetail{Field: "uuids", Message: "must be a list of UUIDs"})
+		return bulkResult{}, false
 	}
 
 	if len(req.Uuids) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one UUID is required"})
-		return
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "At least one UUID is required",
+			ErrorDetail{Field: "uuids", Message: "must not be empty"})
+		return bulkResult{}, false
 	}
 
-	var deletedUUIDs []string
-	var errors []string
-
-	// Delete each UUID
+	result := bulkResult{done: []string{}, allNotFound: true}
 	for _, uuidStr := range req.Uuids {
-		uuid, err := uuid.Parse(uuidStr)
+		id, err := uuid.Parse(uuidStr)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("Invalid UUID '%s': %s", uuidStr, err.Error()))
+			result.allNotFound = false
+			result.failures = append(result.failures, ErrorDetail{Uuid: uuidStr, Code: ErrCodeInvalidUUID,
+				Message: fmt.Sprintf("Invalid UUID '%s'", uuidStr)})
 			continue
 		}
 
-		// Get the document metadata to find the file path before deleting the record
-		rawData, err := h.DaoService.ReadRaw(uuid)
+		err = action(id, func(warning ErrorDetail) {
+			warning.Uuid = uuidStr
+			result.warnings = append(result.warnings, warning)
+		})
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("Failed to read document metadata for UUID '%s': %s", uuidStr, err.Error()))
+			if errors.Is(err, dao.ErrDocumentNotFound) {
+				result.failures = append(result.failures, ErrorDetail{Uuid: uuidStr, Code: ErrCodeNotFound,
+					Message: fmt.Sprintf("Document '%s' not found", uuidStr)})
+				continue
+			}
+			result.allNotFound = false
+			log.Printf("failed to %s %s: %v", verb, uuidStr, err)
+			result.failures = append(result.failures, ErrorDetail{Uuid: uuidStr, Code: ErrCodeInternal,
+				Message: fmt.Sprintf("Failed to %s UUID '%s'", verb, uuidStr)})
 			continue
 		}
 
-		// Parse metadata to get file path
-		var metadata dao.MetaData
-		if err := json.Unmarshal(rawData, &metadata); err != nil {
-			errors = append(errors, fmt.Sprintf("Failed to parse document metadata for UUID '%s': %s", uuidStr, err.Error()))
-			continue
-		}
+		result.done = append(result.done, uuidStr)
+	}
+	return result, true
+}
 
-		// Delete the physical file if path exists
-		if metadata.Path != "" {
-			if err := h.FaoService.DeleteFile(metadata.Path); err != nil {
-				// Log the file deletion error but continue with database deletion
-				errors = append(errors, fmt.Sprintf("Failed to delete file '%s' for UUID '%s': %s", metadata.Path, uuidStr, err.Error()))
-				// Don't continue here - we still want to delete the database record
-			}
-		}
+// respondBulk answers 200 when any document was handled, otherwise a 404 when none of them
+// existed, or a 400.
+func respondBulk(c *gin.Context, result bulkResult, response any, code, message string) {
+	if len(result.done) > 0 {
+		respond(c, http.StatusOK, response)
+		return
+	}
 
-		// delete the record via the DaoService
-		err = h.DaoService.Delete(uuid)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Failed to delete UUID '%s': %s", uuidStr, err.Error()))
-			continue
+	if isLegacy(c) {
+		respond(c, http.StatusBadRequest, response)
+		return
+	}
+	httpStatus := http.StatusBadRequest
+	if result.allNotFound {
+		httpStatus = http.StatusNotFound
+	}
+	respondError(c, httpStatus, code, message, result.failures...)
+}
+
+// History lists every revision of a document, oldest first.
+func (h *APIHandler) History(c *gin.Context) {
+	id, ok := parseUUIDParam(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.DaoService.History(c.Request.Context(), id)
+	if err != nil {
+		respondDaoError(c, err)
+		return
+	}
+	// documents stored before history was kept have none, but still exist
+	if len(revisions) == 0 {
+		if _, err := h.DaoService.ReadRaw(c.Request.Context(), id); err != nil {
+			respondDaoError(c, err)
+			return
 		}
+	}
+
+	respond(c, http.StatusOK, newHistoryResponse(id.String(), revisions))
+}
 
-		deletedUUIDs = append(deletedUUIDs, uuidStr)
+// Restore makes a past revision current again, recorded as a new revision. Restoring a
+// revision with an older file points the document back at that file.
+func (h *APIHandler) Restore(c *gin.Context) {
+	id, ok := parseUUIDParam(c)
+	if !ok {
+		return
 	}
 
-	// Prepare response
-	response := gin.H{
-		"deleted_count": len(deletedUUIDs),
-		"deleted_uuids": deletedUUIDs,
+	var req RestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Revision < 1 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "A revision number is required",
+			ErrorDetail{Field: "revision", Message: "must be a positive integer"})
+		return
 	}
 
-	if len(errors) > 0 {
-		response["errors"] = errors
-		response["error_count"] = len(errors)
+	record, err := h.DaoService.Restore(c.Request.Context(), id, req.Revision, requestUser(c))
+	if err != nil {
+		respondDaoError(c, err)
+		return
 	}
 
-	if len(deletedUUIDs) > 0 {
-		c.JSON(http.StatusOK, response)
-	} else {
-		c.JSON(http.StatusBadRequest, response)
+	respond(c, http.StatusOK, DocumentResponse{
+		Document:      newRecordJSON(record),
+		legacyMessage: "restore successful",
+		legacyValue:   record.Uuid,
+	})
+}
+
+// userHeader names whoever made a change, for the revision history. There's no
+// authentication, so it's taken on trust.
+const userHeader = "X-User"
+
+func requestUser(c *gin.Context) string {
+	return changeUser(c.GetHeader(userHeader))
+}
+
+func changeUser(name string) string {
+	if name = strings.TrimSpace(name); name == "" {
+		return "anonymous"
 	}
+	return name
 }
 
 func (h *APIHandler) GetDocumentTypes(c *gin.Context) {
-	types := h.DocumentFactory.GetRegisteredTypes()
-	c.JSON(http.StatusOK, gin.H{"types": types})
+	respond(c, http.StatusOK, TypesResponse{
+		Types:   h.DocumentFactory.GetRegisteredTypes(),
+		Schemas: h.DocumentFactory.GetSchemas(),
+	})
+}
+
+// respondTypeError maps DocumentFactory errors, an invalid schema is reported field by field.
+func respondTypeError(c *gin.Context, err error) {
+	var invalid *dao.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidSchema, "Invalid document type schema", fieldErrorDetails(invalid)...)
+	case errors.Is(err, dao.ErrBuiltInType):
+		respondError(c, http.StatusConflict, ErrCodeInvalidSchema, "Built-in document types can't be changed")
+	case errors.Is(err, dao.ErrUnknownType):
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Document type not found")
+	default:
+		log.Printf("failed to save document types: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to save document types")
+	}
 }
 
 func (h *APIHandler) GetDeweyCategories(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"categories": dao.DeweyCategories})
+	respond(c, http.StatusOK, DeweyResponse{Categories: dao.DeweyCategories})
+}
+
+// parseUUIDParam reads the :uuid route parameter, responding with a 400 when it's malformed.
+func parseUUIDParam(c *gin.Context) (uuid.UUID, bool) {
+	uuidStr := c.Param("uuid")
+	if uuidStr == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidUUID, "Missing UUID parameter")
+		return uuid.UUID{}, false
+	}
+
+	id, err := uuid.Parse(uuidStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidUUID, "Invalid UUID format",
+			ErrorDetail{Field: "uuid", Message: err.Error()})
+		return uuid.UUID{}, false
+	}
+	return id, true
+}
+
+// readMetaData loads the metadata for the :uuid route parameter, responding with the
+// appropriate error when it can't.
+func (h *APIHandler) readMetaData(c *gin.Context) (dao.MetaData, bool) {
+	id, ok := parseUUIDParam(c)
+	if !ok {
+		return dao.MetaData{}, false
+	}
+
+	rawData, err := h.DaoService.ReadRaw(c.Request.Context(), id)
+	if err != nil {
+		respondDaoError(c, err)
+		return dao.MetaData{}, false
+	}
+
+	var metadata dao.MetaData
+	if err := json.Unmarshal(rawData, &metadata); err != nil {
+		log.Printf("failed to parse document %s: %v", id, err)
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to parse document metadata")
+		return dao.MetaData{}, false
+	}
+	return metadata, true
+}
+
+func fieldErrorDetails(invalid *dao.ValidationError) []ErrorDetail {
+	details := make([]ErrorDetail, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		details = append(details, ErrorDetail{Field: field.Field, Message: field.Message})
+	}
+	return details
+}
+
+// respondDaoError maps a DAO error to a 404 when the document doesn't exist, a 501 when the
+// database backend can't do what was asked, or a 500 otherwise.
+func respondDaoError(c *gin.Context, err error) {
+	if errors.Is(err, dao.ErrDocumentNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Document not found")
+		return
+	}
+	if errors.Is(err, dao.ErrRevisionNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Revision not found")
+		return
+	}
+	if errors.Is(err, dao.ErrDocumentTrashed) {
+		respondError(c, http.StatusConflict, ErrCodeTrashed, "The document is in the trash, restore it first")
+		return
+	}
+	if errors.Is(err, dao.ErrFilePruned) {
+		respondError(c, http.StatusConflict, ErrCodeFilePruned, "The revision's file has been pruned by the retention policy")
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		respondError(c, http.StatusGatewayTimeout, ErrCodeTimeout, "The database took too long to respond")
+		return
+	}
+	if errors.Is(err, dao.ErrUnsupported) {
+		respondError(c, http.StatusNotImplemented, ErrCodeUnsupported, "The database backend doesn't support this")
+		return
+	}
+	log.Printf("database error: %v", err)
+	respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to read document")
 }
 
 func (h *APIHandler) GetRouterGroups() (string, map[string]gin.HandlerFunc) {
 	groupName := "/data"
 
 	routes := map[string]gin.HandlerFunc{
-		"POST /create":    h.Create,
-		"GET /read/:uuid": h.Read,
-		"PUT /update":     h.Update,
-		"GET /search":     h.SearchByKeyValue,
-		"DELETE /delete":  h.Delete,
-		"GET /types":      h.GetDocumentTypes,
-		"GET /dewey":      h.GetDeweyCategories,
+		"POST /create":                h.Create,
+		"GET /read/:uuid":             h.Read,
+		"PUT /update":                 h.Update,
+		"PATCH /bulk":                 h.BulkUpdate,
+		"PATCH /:uuid":                h.Patch,
+		"GET /search":                 h.SearchByKeyValue,
+		"GET /export":                 h.Export,
+		"GET /cite/:uuid":             h.Cite,
+		"POST /import":                h.ImportBibliography,
+		"GET /recent/added":           h.recent(false),
+		"GET /recent/modified":        h.recent(true),
+		"DELETE /delete":              h.Delete,
+		"GET /types":                  h.GetDocumentTypes,
+		"GET /dewey":                  h.GetDeweyCategories,
+		"GET /history/:uuid":          h.History,
+		"POST /history/:uuid/restore": h.Restore,
+		"GET /trash":                  h.Trash,
+		"POST /trash/restore":         h.RestoreTrash,
+		"DELETE /trash/purge":         h.PurgeTrash,
 	}
 
 	return groupName, routes
 }
 
-func StartGrcpService(grpcServer *grpc.Server, fileHandlerService FileHandlerService, port int) <-chan error {
+var paginationDocs = []ParamDoc{
+	{Name: "page", Description: "Page number, default 1", Type: "integer"},
+	{Name: "limit", Description: "Results per page, 1-100, default 10", Type: "integer"},
+}
+
+func (h *APIHandler) GetRouteDocs() map[string]RouteDoc {
+	return map[string]RouteDoc{
+		"POST /create": {
+			Summary:  "Create a document record",
+			Request:  CreateRequest{},
+			Status:   http.StatusCreated,
+			Response: CreateResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		"GET /read/:uuid": {
+			Summary:  "Read a document by UUID",
+			Response: DocumentResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		"PUT /update": {
+			Summary:  "Replace a document's metadata",
+			Request:  UpdateRequest{},
+			Response: DocumentResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		"PATCH /:uuid": {
+			Summary:     "Apply a JSON merge patch to a document",
+			Description: "The body is an RFC 7396 merge patch against the stored document, in the shape of an update body: members that are left out are kept, null removes one, and objects such as Custom are merged. The patched document is validated like an update. If-Match takes the ETag returned by reads and patches, a patch of a document that has changed since fails with precondition_failed. The response carries the new ETag.",
+			Request:     map[string]any{},
+			Response:    DocumentResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusGatewayTimeout, http.StatusInternalServerError},
+		},
+		"PATCH /bulk": {
+			Summary:     "Apply one metadata patch to many documents",
+			Description: "Documents are named by uuids, or matched by q or key/value as in /data/search. set takes MetaData field names (null clears one, Custom.<key> sets a custom field), add_tags and remove_tags edit the tags. Every document is written in one transaction, with a revision each. Failures are reported per document in results. With dry_run nothing is written, the results show what would change.",
+			Request:     BulkUpdateRequest{},
+			Response:    BulkUpdateResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusGatewayTimeout, http.StatusInternalServerError},
+		},
+		"GET /search": {
+			Summary:     "Search documents with pagination",
+			Description: "q takes priority over key/value. With neither, every document is returned.",
+			Query: append([]ParamDoc{
+				{Name: "q", Description: "Fuzzy search across the text fields"},
+				{Name: "key", Description: "Field name to match exactly, e.g. Author. List fields (Authors, Tags) match any element, map entries use a dotted path, e.g. Custom.series"},
+				{Name: "value", Description: "Value to match against key"},
+			}, paginationDocs...),
+			Response: SearchResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		"GET /export": {
+			Summary:     "Export documents as CSV, JSON Lines, a zip archive or citations",
+			Description: "Every document, or those matching q or key/value as in search. csv has a row per document with lists joined by ';' and Custom.<key> and type specific columns; jsonl a document per line as read returns it; zip the stored files under files/, named by title, with a manifest.csv that scriptorium import reads back. bibtex, ris and csl-json are citations for reference managers.",
+			Query: []ParamDoc{
+				{Name: "format", Description: "csv (default), jsonl, zip, bibtex, ris or csl-json"},
+				{Name: "q", Description: "Fuzzy search across the text fields"},
+				{Name: "key", Description: "Field name to match exactly"},
+				{Name: "value", Description: "Value to match against key"},
+			},
+			Binary: "application/octet-stream",
+			Errors: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusGatewayTimeout},
+		},
+		"GET /cite/:uuid": {
+			Summary:     "Cite a document",
+			Description: "A formatted reference in APA (7th edition) or Chicago (17th edition, bibliography) style, as text and as HTML with titles in italics. The bibtex, ris and csl-json styles give the document's entry in that format as text. Book, Article, Report and Manual map to the matching entry types, other types are cited as generic works.",
+			Query: []ParamDoc{
+				{Name: "style", Description: "apa (default), chicago, bibtex, ris or csl-json"},
+			},
+			Response: CitationResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+		},
+		"POST /import": {
+			Summary:     "Import a BibTeX or RIS bibliography",
+			Description: "Creates a document with only metadata for each entry, the entry type giving the DocType: article and inproceedings an Article, book and incollection a Book, techreport a Report, manual a Manual, anything else doc_type. An entry matching a document by UUID, DOI or title fills in that document's metadata when it has a file, and is a duplicate when it hasn't. Each result lists the entry's fields the document has no place for.",
+			Form: map[string]any{
+				"file":     map[string]any{"type": "string", "format": "binary", "description": "a .bib or .ris file"},
+				"doc_type": map[string]any{"type": "string", "description": "DocType of entries of no other type, Notes when empty"},
+			},
+			Response: ImportReport{},
+			Errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusInternalServerError, http.StatusGatewayTimeout},
+		},
+		"GET /recent/added": {
+			Summary:     "List documents, most recently added first",
+			Description: "Ordered by created_at. Records stored before timestamps were kept come last.",
+			Query:       paginationDocs,
+			Response:    SearchResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		"GET /recent/modified": {
+			Summary:     "List documents, most recently modified first",
+			Description: "Ordered by last_updated, which is set on create and every update.",
+			Query:       paginationDocs,
+			Response:    SearchResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		"DELETE /delete": {
+			Summary:     "Move documents to the trash by UUID",
+			Description: "Deleted documents leave search and reads, and can be restored from the trash until they're purged.",
+			Request:     DeleteRequest{},
+			Response:    DeleteResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		"GET /trash": {
+			Summary:     "List the documents in the trash, most recently deleted first",
+			Description: "purge_at is when the background purge will remove a document, absent when the trash is kept indefinitely.",
+			Query:       paginationDocs,
+			Response:    TrashResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		"POST /trash/restore": {
+			Summary:  "Move documents out of the trash",
+			Request:  DeleteRequest{},
+			Response: RestoreTrashResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		"DELETE /trash/purge": {
+			Summary:     "Permanently remove documents from the trash",
+			Description: "The record and history are removed first, then every stored version of the file. A file that can't be removed is reported under warnings.",
+			Request:     DeleteRequest{},
+			Response:    DeleteResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		"GET /types": {
+			Summary:     "List registered document types",
+			Description: "schemas describes the type specific fields of each type, for rendering forms.",
+			Response:    TypesResponse{},
+		},
+		"GET /dewey": {
+			Summary:  "List Dewey Decimal categories",
+			Response: DeweyResponse{},
+		},
+		"GET /history/:uuid": {
+			Summary:     "List a document's revisions, oldest first",
+			Description: "Each revision records who made it (the X-User header), when, the fields it changed and, when it stored or restored a file, that file version.",
+			Response:    HistoryResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		"POST /history/:uuid/restore": {
+			Summary:     "Restore a past revision of a document",
+			Description: "The restored state is recorded as a new revision. Fails with file_pruned when the revision's file has been removed by retention.",
+			Request:     RestoreRequest{},
+			Response:    DocumentResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+	}
+}
+
+func StartGrcpService(grpcServer *grpc.Server, fileHandlerService FileHandlerService, libraryServer *LibraryServer, port int) <-chan error {
 	errCh := make(chan error, 1)
 	go func() {
 		addr := fmt.Sprintf(":%d", port)
@@ -728,6 +1334,7 @@ func StartGrcpService(grpcServer *grpc.Server, fileHandlerService FileHandlerSer
 		}
 
 		pb.RegisterFileServiceServer(grpcServer, fileHandlerService)
+		pb.RegisterLibraryServiceServer(grpcServer, libraryServer)
 
 		log.Printf("gRPC server listening on %s", addr)
 		if err := grpcServer.Serve(lis); err != nil {
@@ -737,26 +1344,84 @@ func StartGrcpService(grpcServer *grpc.Server, fileHandlerService FileHandlerSer
 	return errCh
 }
 
-func StartRestAPI(port int, handlers ...Handler) <-chan error {
+// StartTrashPurge purges documents that have been in the trash for longer than maxAge every
+// interval, until the returned stop function is called. the first run waits an interval too,
+// so the file service is up by the time files are deleted.
+func StartTrashPurge(daos *DaoService, files fao.FAO, maxAge, interval time.Duration) (stop func()) {
+	// stopping cancels a purge that's under way as well
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			purged, err := daos.PurgeExpired(ctx, maxAge, files)
+			if err != nil {
+				log.Printf("trash purge failed: %v", err)
+			} else if purged > 0 {
+				log.Printf("purged %d document(s) from the trash", purged)
+			}
+		}
+	}()
+	return cancel
+}
+
+func StartRestAPI(port int, legacyAPI bool, handlers ...Handler) <-chan error {
 	errCh := make(chan error, 1) // Buffered channel to capture errors
 
 	go func() {
 		r := gin.Default()
-		r.Use(cors.Default())
-		for _, handler := range handlers {
-			path, routes := handler.GetRouterGroups()
-			group := r.Group(path) // Create a RouterGroup dynamically
-
-			for route, fn := range routes {
-				parts := strings.Split(route, " ") // Extract method and route path
-				if len(parts) != 2 {
-					log.Printf("invalid route format: %s", route)
-					errCh <- fmt.Errorf("invalid route format: %s", route)
-					return
-				}
-				method, endpoint := parts[0], parts[1]
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowAllOrigins = true
+		// patches send back the ETag of the read they're based on
+		corsConfig.AddAllowHeaders("If-Match")
+		corsConfig.AddExposeHeaders("ETag")
+		r.Use(cors.New(corsConfig))
+		if err := registerRoutes(r, legacyAPI, handlers...); err != nil {
+			log.Print(err)
+			errCh <- err
+			return
+		}
+		registerDocs(r, handlers...)
 
-				// Register route dynamically based on method
+		addr := fmt.Sprintf(":%d", port)
+		// Start the server and capture any errors
+		if err := r.Run(addr); err != nil {
+			errCh <- err
+		}
+	}()
+
+	return errCh // Return the error channel to listen for errors
+}
+
+// registerRoutes mounts every handler's routes under /v1. When legacyAPI is set they are
+// also mounted at their old unversioned paths, answering in the pre-/v1 response shapes.
+func registerRoutes(r *gin.Engine, legacyAPI bool, handlers ...Handler) error {
+	for _, handler := range handlers {
+		path, routes := handler.GetRouterGroups()
+		var middleware []gin.HandlerFunc
+		if guarded, ok := handler.(GuardedHandler); ok {
+			middleware = guarded.Middleware()
+		}
+		groups := []*gin.RouterGroup{r.Group("/v1"+path, middleware...)} // Create a RouterGroup dynamically
+		if legacyAPI {
+			groups = append(groups, r.Group(path, append([]gin.HandlerFunc{legacyMiddleware}, middleware...)...))
+		}
+
+		for route, fn := range routes {
+			parts := strings.Split(route, " ") // Extract method and route path
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid route format: %s", route)
+			}
+			method, endpoint := parts[0], parts[1]
+
+			// Register route dynamically based on method
+			for _, group := range groups {
 				switch method {
 				case "GET":
 					group.GET(endpoint, fn)
@@ -764,22 +1429,15 @@ func StartRestAPI(port int, handlers ...Handler) <-chan error {
 					group.POST(endpoint, fn)
 				case "PUT":
 					group.PUT(endpoint, fn)
+				case "PATCH":
+					group.PATCH(endpoint, fn)
 				case "DELETE":
 					group.DELETE(endpoint, fn)
 				default:
-					log.Printf("unsupported method: %s", method)
-					errCh <- fmt.Errorf("unsupported method: %s", method)
-					return
+					return fmt.Errorf("unsupported method: %s", method)
 				}
 			}
 		}
-
-		addr := fmt.Sprintf(":%d", port)
-		// Start the server and capture any errors
-		if err := r.Run(addr); err != nil {
-			errCh <- err
-		}
-	}()
-
-	return errCh // Return the error channel to listen for errors
+	}
+	return nil
 }
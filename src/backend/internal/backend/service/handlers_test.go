@@ -2,18 +2,29 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
-	"strings"
 	"testing"
+	"time"
 
 	"scriptorium/internal/backend/dao"
 	"scriptorium/internal/backend/fao"
+	"scriptorium/internal/backend/service/pb"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func setupTestRouter(t *testing.T) (*gin.Engine, *APIHandler, func()) {
@@ -25,9 +36,8 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *APIHandler, func()) {
 	storagePath := filepath.Join(tmpDir, "storage")
 	os.MkdirAll(storagePath, 0755)
 
-	conparams := &dao.BoltConnectionParams{Path: dbPath, Mode: 0600, Opts: nil}
-	d := &dao.BoltDao{}
-	if err := d.Connect(conparams); err != nil {
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: dbPath, Mode: 0600, Opts: nil})
+	if err != nil {
 		t.Fatalf("failed to connect to test db: %v", err)
 	}
 
@@ -35,30 +45,16 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *APIHandler, func()) {
 
 	docFactory := dao.NewDocumentFactory()
 	docFactory.RegisterDocumentType("Notes", func() dao.Document { return &dao.Notes{} })
-	docFactory.RegisterDocumentType("Book", func() dao.Document { return &dao.Notes{} })
+	docFactory.RegisterDocumentType("Book", func() dao.Document { return &dao.Book{} })
+	docFactory.RegisterDocumentType("Article", func() dao.Document { return &dao.Article{} })
 
 	daos := DaoService{dao: d}
 	handler := NewAPIHandler(daos, docFactory, f)
 
+	// legacy routes are mounted alongside /v1, the unversioned tests below cover the compatibility shapes
 	r := gin.New()
-	_, routes := handler.GetRouterGroups()
-	group := r.Group("/data")
-	for route, fn := range routes {
-		parts := strings.Split(route, " ")
-		if len(parts) != 2 {
-			continue
-		}
-		method, endpoint := parts[0], parts[1]
-		switch method {
-		case "GET":
-			group.GET(endpoint, fn)
-		case "POST":
-			group.POST(endpoint, fn)
-		case "PUT":
-			group.PUT(endpoint, fn)
-		case "DELETE":
-			group.DELETE(endpoint, fn)
-		}
+	if err := registerRoutes(r, true, handler); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
 	}
 
 	cleanup := func() {
@@ -314,3 +310,936 @@ func TestCreateWithInvalidDocType(t *testing.T) {
 		t.Fatalf("expected 400 for invalid doc type, got %d", w.Code)
 	}
 }
+
+func TestV1CreateAndReadDocument(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	body := map[string]any{
+		"DocType": "Book",
+		"Title":   "Typed Doc",
+		"Author":  "Cormen",
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var createResp CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &createResp)
+	if createResp.Uuid == "" || createResp.Document.Title != "Typed Doc" {
+		t.Fatalf("unexpected create response: %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/data/read/"+createResp.Uuid, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("read failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var readResp map[string]map[string]any
+	json.Unmarshal(w.Body.Bytes(), &readResp)
+	if readResp["document"]["author"] != "Cormen" || readResp["document"]["doc_type"] != "Book" {
+		t.Fatalf("expected snake_case document fields, got: %s", w.Body.String())
+	}
+}
+
+func TestV1CreateWithExtendedMetadata(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	body := map[string]any{
+		"DocType":   "Book",
+		"Title":     "The C Programming Language",
+		"Author":    "Kernighan and Ritchie",
+		"Tags":      []string{"c", "classic"},
+		"ISBN":      "0131103628",
+		"PageCount": 272,
+		"Custom":    map[string]string{"shelf": "B2"},
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/data/search?key=Custom.shelf&value=B2", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var searchResp SearchResponse
+	json.Unmarshal(w.Body.Bytes(), &searchResp)
+	if len(searchResp.Results) != 1 {
+		t.Fatalf("expected 1 result for a custom key, got: %s", w.Body.String())
+	}
+	doc := searchResp.Results[0]
+	if len(doc.Authors) != 2 || doc.Authors[1] != "Ritchie" || doc.PageCount != 272 || doc.ISBN != "0131103628" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
+
+func TestV1CreateRejectsMistypedMetadata(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	bodyBytes := []byte(`{"DocType": "Book", "Title": "Bad", "Author": "Someone", "Tags": "not-a-list", "PageCount": -1}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if len(errResp.Error.Details) != 2 || errResp.Error.Details[0].Field != "Tags" || errResp.Error.Details[1].Field != "PageCount" {
+		t.Fatalf("expected field errors for Tags and PageCount, got: %s", w.Body.String())
+	}
+}
+
+func TestV1TypedDocumentFieldsRoundTrip(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	body := map[string]any{
+		"DocType": "Article",
+		"Title":   "Deep learning",
+		"Author":  "LeCun, Bengio and Hinton",
+		"DOI":     "10.1038/nature14539",
+		"Journal": "Nature",
+		"Volume":  "521",
+		"Pages":   "436-444",
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var createResp CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &createResp)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/data/read/"+createResp.Uuid, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var readResp DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &readResp)
+	if readResp.Document.Fields["Journal"] != "Nature" || readResp.Document.Fields["Pages"] != "436-444" {
+		t.Fatalf("expected the article fields back, got: %s", w.Body.String())
+	}
+}
+
+func TestV1TypedDocumentValidation(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	bodyBytes := []byte(`{"DocType": "Article", "Title": "No journal", "Author": "Someone", "DOI": "not-a-doi"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if errResp.Error.Code != ErrCodeValidationFailed || len(errResp.Error.Details) != 2 {
+		t.Fatalf("expected validation errors for Journal and DOI, got: %s", w.Body.String())
+	}
+}
+
+func TestV1CreateReportsMetadataFieldErrors(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	bodyBytes := []byte(`{"DocType": "Notes", "Title": "  ", "PublishDate": "31/07/2009", "DeweyDecimal": "041", "Content": "x"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	want := []string{"Title", "PublishDate", "DeweyDecimal"}
+	if errResp.Error.Code != ErrCodeValidationFailed || len(errResp.Error.Details) != len(want) {
+		t.Fatalf("expected errors for %v, got: %s", want, w.Body.String())
+	}
+	for i, field := range want {
+		if errResp.Error.Details[i].Field != field {
+			t.Errorf("expected detail %d to be %s, got %s", i, field, errResp.Error.Details[i].Field)
+		}
+	}
+}
+
+func TestV1CreateNormalizesMetadata(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	bodyBytes := []byte(`{"DocType": "Notes", "Title": "  Field   notes ", "Author": "Darwin ", "PublishDate": "1859", "DeweyDecimal": "576.8", "Tags": [" evolution ", ""]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var createResp CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &createResp)
+	doc := createResp.Document
+	if doc.Title != "Field notes" || doc.Author != "Darwin" || len(doc.Tags) != 1 || doc.Tags[0] != "evolution" {
+		t.Fatalf("expected normalized metadata, got: %+v", doc)
+	}
+}
+
+// createNote posts a Notes document to /v1 and returns the created document
+func createNote(t *testing.T, r http.Handler, title string) DocumentJSON {
+	t.Helper()
+	bodyBytes, _ := json.Marshal(map[string]any{"DocType": "Notes", "Title": title, "Content": title})
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var createResp CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &createResp)
+	return createResp.Document
+}
+
+func TestV1UpdateIgnoresServerOwnedFields(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createNote(t, r, "Owned")
+	if created.CreatedAt == "" || created.LastUpdated != created.CreatedAt {
+		t.Fatalf("expected created_at and last_updated to be stamped, got: %+v", created)
+	}
+
+	updateBytes, _ := json.Marshal(map[string]any{
+		"DocType":     "Notes",
+		"Uuid":        created.Uuid,
+		"Title":       "Owned",
+		"Path":        "../../etc/passwd",
+		"FileType":    ".exe",
+		"Size":        12345,
+		"CreatedAt":   "1970-01-01T00:00:00Z",
+		"LastUpdated": "1970-01-01T00:00:00Z",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/v1/data/update", bytes.NewReader(updateBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var updateResp DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &updateResp)
+	doc := updateResp.Document
+	if doc.Path != "" || doc.FileType != "" || doc.Size != 0 || doc.CreatedAt != created.CreatedAt {
+		t.Fatalf("expected server owned fields to be kept, got: %+v", doc)
+	}
+	createdAt, _ := time.Parse(time.RFC3339Nano, created.CreatedAt)
+	if lastUpdated, err := time.Parse(time.RFC3339Nano, doc.LastUpdated); err != nil || lastUpdated.Before(createdAt) {
+		t.Fatalf("expected last_updated to be restamped, got %s", doc.LastUpdated)
+	}
+}
+
+// patchDocument sends a merge patch for id, with If-Match when etag is set
+func patchDocument(t *testing.T, r http.Handler, id, etag string, patch map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	bodyBytes, _ := json.Marshal(patch)
+	req := httptest.NewRequest(http.MethodPatch, "/v1/data/"+id, bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestV1PatchMergesIntoStoredDocument(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	bodyBytes, _ := json.Marshal(map[string]any{
+		"DocType": "Book", "Title": "Calculus", "Author": "Spivak", "ISBN": "978-0-914098-91-1",
+		"Tags": []string{"maths"}, "Custom": map[string]string{"shelf": "B2", "loaned": "yes"}, "Series": "Analysis",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var created CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = patchDocument(t, r, created.Uuid, "", map[string]any{
+		"DeweyDecimal": "515",
+		"Tags":         nil,
+		"Custom":       map[string]any{"loaned": nil, "edition": "4th"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	doc := resp.Document
+	if doc.Title != "Calculus" || doc.Author != "Spivak" || doc.ISBN != "978-0-914098-91-1" || doc.DeweyDecimal != "515" {
+		t.Errorf("expected omitted fields kept and DeweyDecimal set, got %+v", doc)
+	}
+	if len(doc.Tags) != 0 || len(doc.Custom) != 2 || doc.Custom["shelf"] != "B2" || doc.Custom["edition"] != "4th" {
+		t.Errorf("expected Tags removed and Custom merged, got %+v", doc)
+	}
+	if doc.Fields["Series"] != "Analysis" {
+		t.Errorf("expected type specific fields kept, got %+v", doc.Fields)
+	}
+
+	w = patchDocument(t, r, created.Uuid, "", map[string]any{"DocType": "Notes"})
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Error.Code != ErrCodeInvalidRequest {
+		t.Errorf("expected a DocType change rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = patchDocument(t, r, created.Uuid, "", map[string]any{"Title": nil})
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Error.Code != ErrCodeValidationFailed {
+		t.Errorf("expected removing the Title to fail validation, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = patchDocument(t, r, uuid.New().String(), "", map[string]any{"Title": "Missing"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 patching a missing document, got %d", w.Code)
+	}
+}
+
+func TestV1PatchIfMatch(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createNote(t, r, "Draft")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/read/"+created.Uuid, nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected reads to return an ETag")
+	}
+
+	// the first of two saves based on the same read wins
+	w = patchDocument(t, r, created.Uuid, etag, map[string]any{"Title": "First save"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	newETag := w.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Fatalf("expected a new ETag after the patch, got %q", newETag)
+	}
+
+	w = patchDocument(t, r, created.Uuid, etag, map[string]any{"Title": "Second save"})
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusPreconditionFailed || errResp.Error.Code != ErrCodePreconditionFailed {
+		t.Fatalf("expected 412 for a stale ETag, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// the ETag returned by the patch is the one a read now returns
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/read/"+created.Uuid, nil))
+	var read DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &read)
+	if w.Header().Get("ETag") != newETag || read.Document.Title != "First save" {
+		t.Errorf("expected the first save kept with ETag %s, got %s: %+v", newETag, w.Header().Get("ETag"), read.Document)
+	}
+
+	w = patchDocument(t, r, created.Uuid, `W/`+newETag+`, "other"`, map[string]any{"Title": "Weak"})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected a weak ETag not to match, got %d", w.Code)
+	}
+	w = patchDocument(t, r, created.Uuid, `"other", `+newETag, map[string]any{"Title": "Listed"})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected any listed ETag to match, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestV1RecentListings(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	first := createNote(t, r, "First")
+	createNote(t, r, "Second")
+
+	updateBytes, _ := json.Marshal(map[string]any{"DocType": "Notes", "Uuid": first.Uuid, "Title": "First, revised"})
+	req := httptest.NewRequest(http.MethodPut, "/v1/data/update", bytes.NewReader(updateBytes))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	for path, want := range map[string][]string{
+		"/v1/data/recent/added":    {"Second", "First, revised"},
+		"/v1/data/recent/modified": {"First, revised", "Second"},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s failed: %d: %s", path, w.Code, w.Body.String())
+		}
+
+		var resp SearchResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if len(resp.Results) != len(want) {
+			t.Fatalf("%s: expected %d results, got: %s", path, len(want), w.Body.String())
+		}
+		for i, title := range want {
+			if resp.Results[i].Title != title {
+				t.Errorf("%s: expected result %d to be %q, got %q", path, i, title, resp.Results[i].Title)
+			}
+		}
+	}
+}
+
+func TestV1ReadMissingDocumentIsNotFound(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/data/read/"+uuid.New().String(), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if errResp.Error.Code != ErrCodeNotFound {
+		t.Fatalf("expected error code %q, got: %s", ErrCodeNotFound, w.Body.String())
+	}
+}
+
+func TestV1ReadPastDatabaseDeadlineIsGatewayTimeout(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+	handler.DaoService.Timeout = time.Nanosecond
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/data/read/"+uuid.New().String(), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if errResp.Error.Code != ErrCodeTimeout {
+		t.Fatalf("expected error code %q, got: %s", ErrCodeTimeout, w.Body.String())
+	}
+}
+
+func TestV1ErrorEnvelope(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	bodyBytes, _ := json.Marshal(map[string]any{"DocType": "InvalidType"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if errResp.Error.Code != ErrCodeUnknownDocType || len(errResp.Error.Details) == 0 || errResp.Error.Details[0].Field != "DocType" {
+		t.Fatalf("unexpected error envelope: %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/data/search?limit=1000", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Error.Code != ErrCodeInvalidPagination {
+		t.Fatalf("expected invalid_pagination, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLegacyRoutesRequireFlag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewAPIHandler(DaoService{}, dao.NewDocumentFactory(), nil)
+
+	r := gin.New()
+	if err := registerRoutes(r, false, handler); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/data/types", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected unversioned route to be absent, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/data/types", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected /v1 route to be served, got %d", w.Code)
+	}
+}
+
+func TestV1SchemaDefinedDocumentType(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	admin := NewAdminHandler(handler.DaoService, handler.FaoService, testAdminToken)
+	admin.DocumentFactory = handler.DocumentFactory
+	if err := registerRoutes(r, false, admin); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+
+	// types are defined by admins, the data routes only list them
+	schema := `{"type": "object", "properties": {"University": {"type": "string"}, "Degree": {"type": "string", "enum": ["MSc", "PhD"]}}, "required": ["University"]}`
+	req := httptest.NewRequest(http.MethodPut, "/v1/data/types/Thesis", bytes.NewReader([]byte(schema)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected no type definition under /data, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/admin/types/Thesis", bytes.NewReader([]byte(schema)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = adminRequest(http.MethodPut, "/v1/admin/types/Thesis", bytes.NewReader([]byte(schema)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("define type failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/data/types", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var typesResp TypesResponse
+	json.Unmarshal(w.Body.Bytes(), &typesResp)
+	var found bool
+	for _, s := range typesResp.Schemas {
+		if s.Title == "Thesis" && len(s.Properties) == 2 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the Thesis schema in /types, got: %s", w.Body.String())
+	}
+
+	bodyBytes := []byte(`{"DocType": "Thesis", "Title": "On Things", "Degree": "BA"}`)
+	req = httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || len(errResp.Error.Details) != 2 {
+		t.Fatalf("expected University and Degree errors, got %d: %s", w.Code, w.Body.String())
+	}
+
+	bodyBytes = []byte(`{"DocType": "Thesis", "Title": "On Things", "University": "Leiden", "Degree": "PhD"}`)
+	req = httptest.NewRequest(http.MethodPost, "/v1/data/create", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var createResp CreateResponse
+	json.Unmarshal(w.Body.Bytes(), &createResp)
+	if w.Code != http.StatusCreated || createResp.Document.Fields["University"] != "Leiden" {
+		t.Fatalf("expected the thesis to be created, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = adminRequest(http.MethodPut, "/v1/admin/types/Book", bytes.NewReader([]byte(`{"type": "object"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 redefining a built-in type, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// setupFileRouter extends setupTestRouter with the /file routes, backed by an in-process
+// gRPC file service over the same storage.
+func setupFileRouter(t *testing.T) (*gin.Engine, *APIHandler, func()) {
+	t.Helper()
+	r, handler, cleanup := setupTestRouter(t)
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pb.RegisterFileServiceServer(server, FileHandlerService{fao: handler.FaoService})
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial test gRPC server: %v", err)
+	}
+
+	fileHandler := NewFileHandler(FileHandlerService{fao: handler.FaoService}, conn, handler, nil)
+	if err := registerRoutes(r, false, fileHandler); err != nil {
+		t.Fatalf("failed to register file routes: %v", err)
+	}
+
+	return r, handler, func() {
+		conn.Close()
+		server.Stop()
+		cleanup()
+	}
+}
+
+// uploadRequest builds a multipart upload of content as filename, with optional metadata
+func uploadRequest(t *testing.T, path, filename, content, metadata string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	if metadata != "" {
+		form.WriteField("metadata", metadata)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+// a document whose stored file has gone is a 404, as reported by the file service
+func TestV1DownloadMissingFile(t *testing.T) {
+	r, handler, cleanup := setupFileRouter(t)
+	defer cleanup()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/v1/file/upload", "gone.txt", "soon gone", `{"DocType": "Notes", "Title": "Gone"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload failed: %d: %s", w.Code, w.Body.String())
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(w.Body.Bytes(), &uploaded)
+	if err := handler.FaoService.DeleteFile(context.Background(), uploaded.FilePath); err != nil {
+		t.Fatalf("failed to delete the stored file: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/file/download/"+uploaded.Uuid, nil))
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusNotFound || errResp.Error.Code != ErrCodeNotFound {
+		t.Fatalf("expected 404 not_found, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestV1HistoryAndRestore(t *testing.T) {
+	r, _, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	created := createNote(t, r, "Draft")
+
+	updateBytes, _ := json.Marshal(map[string]any{"DocType": "Notes", "Uuid": created.Uuid, "Title": "Final", "Content": "Draft"})
+	req := httptest.NewRequest(http.MethodPut, "/v1/data/update", bytes.NewReader(updateBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "grace")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/history/"+created.Uuid, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("history failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var history HistoryResponse
+	json.Unmarshal(w.Body.Bytes(), &history)
+	if len(history.Revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got: %s", w.Body.String())
+	}
+	first, second := history.Revisions[0], history.Revisions[1]
+	if first.User != "anonymous" || first.Action != "create" {
+		t.Errorf("unexpected first revision: %+v", first)
+	}
+	if second.User != "grace" || len(second.Changes) != 1 || second.Changes[0].Field != "Title" || second.Changes[0].New != "Final" {
+		t.Errorf("expected grace's title change, got: %+v", second)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/data/history/"+created.Uuid+"/restore", bytes.NewReader([]byte(`{"revision": 1}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var restored DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &restored)
+	if restored.Document.Title != "Draft" || restored.Document.CreatedAt != created.CreatedAt {
+		t.Fatalf("expected the first revision back, got: %+v", restored.Document)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/data/history/"+created.Uuid+"/restore", bytes.NewReader([]byte(`{"revision": 42}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing revision, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestV1UploadRevisionPrunesOldFiles(t *testing.T) {
+	r, handler, cleanup := setupFileRouter(t)
+	defer cleanup()
+	handler.KeepFileVersions = 1
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/v1/file/upload", "scan.txt", "first scan", `{"DocType": "Notes", "Title": "Scan"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload failed: %d: %s", w.Code, w.Body.String())
+	}
+	var first UploadResponse
+	json.Unmarshal(w.Body.Bytes(), &first)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/v1/file/upload/"+first.Uuid, "scan.md", "second scan", ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("revision upload failed: %d: %s", w.Code, w.Body.String())
+	}
+	var second UploadResponse
+	json.Unmarshal(w.Body.Bytes(), &second)
+	if second.Document == nil || second.Document.Path != second.FilePath || second.Document.FileType != ".md" || second.Document.Title != "Scan" {
+		t.Fatalf("expected the document to point at the new file, got: %s", w.Body.String())
+	}
+	if sum := sha256.Sum256([]byte("second scan")); second.Document.Sha256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected the sha256 of the new file recorded, got %q", second.Document.Sha256)
+	}
+
+	// one version is kept, so the first file is gone and can't be restored
+	if handler.FaoService.FileExists(context.Background(), first.FilePath) || !handler.FaoService.FileExists(context.Background(), second.FilePath) {
+		t.Fatalf("expected only the new file to be kept in storage")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/data/history/"+first.Uuid+"/restore", bytes.NewReader([]byte(`{"revision": 1}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var errResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusConflict || errResp.Error.Code != ErrCodeFilePruned {
+		t.Fatalf("expected file_pruned, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/v1/file/upload/"+uuid.New().String(), "scan.txt", "orphan", ""))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 uploading to a missing document, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestV1TrashRestoreAndPurge(t *testing.T) {
+	r, handler, cleanup := setupFileRouter(t)
+	defer cleanup()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/v1/file/upload", "binned.txt", "binned", `{"DocType": "Notes", "Title": "Binned"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload failed: %d: %s", w.Code, w.Body.String())
+	}
+	var upload UploadResponse
+	json.Unmarshal(w.Body.Bytes(), &upload)
+
+	bulk := func(method, path string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(DeleteRequest{Uuids: []string{upload.Uuid}})
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", "ada")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := bulk(http.MethodDelete, "/v1/data/delete"); w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %d: %s", w.Code, w.Body.String())
+	}
+	if !handler.FaoService.FileExists(context.Background(), upload.FilePath) {
+		t.Fatal("expected the file to stay in storage while the document is in the trash")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/search?q=binned", nil))
+	var search SearchResponse
+	json.Unmarshal(w.Body.Bytes(), &search)
+	if search.TotalCount != 0 {
+		t.Errorf("expected trashed document left out of search, got: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/trash", nil))
+	var trash TrashResponse
+	json.Unmarshal(w.Body.Bytes(), &trash)
+	if w.Code != http.StatusOK || trash.TotalCount != 1 || trash.Results[0].Uuid != upload.Uuid || trash.Results[0].DeletedBy != "ada" {
+		t.Fatalf("expected the document in the trash, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := bulk(http.MethodPost, "/v1/data/trash/restore"); w.Code != http.StatusOK {
+		t.Fatalf("restore failed: %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/read/"+upload.Uuid, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the restored document to be readable, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// purging only applies to the trash
+	if w := bulk(http.MethodDelete, "/v1/data/trash/purge"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 purging a current document, got %d: %s", w.Code, w.Body.String())
+	}
+
+	bulk(http.MethodDelete, "/v1/data/delete")
+	if w := bulk(http.MethodDelete, "/v1/data/trash/purge"); w.Code != http.StatusOK {
+		t.Fatalf("purge failed: %d: %s", w.Code, w.Body.String())
+	}
+	if handler.FaoService.FileExists(context.Background(), upload.FilePath) {
+		t.Error("expected the file to be removed by the purge")
+	}
+	if w := bulk(http.MethodPost, "/v1/data/trash/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 restoring a purged document, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// undeletableFao fails every delete, as storage that's gone read only would
+type undeletableFao struct {
+	fao.FAO
+}
+
+func (undeletableFao) DeleteFile(context.Context, string) error {
+	return errors.New("read-only file system")
+}
+
+func TestV1PurgeWarnsWhenFileDeleteFails(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	id := uuid.New()
+	doc := &dao.Notes{}
+	doc.SetMetaData(dao.MetaData{Uuid: id.String(), DocType: "Notes", Title: "Stuck", Path: "stuck.txt"})
+	if err := handler.DaoService.Create(context.Background(), doc, "ada"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := handler.DaoService.Trash(context.Background(), id, "ada"); err != nil {
+		t.Fatalf("trash failed: %v", err)
+	}
+	handler.FaoService = undeletableFao{handler.FaoService}
+
+	body, _ := json.Marshal(DeleteRequest{Uuids: []string{id.String()}})
+	req := httptest.NewRequest(http.MethodDelete, "/v1/data/trash/purge", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// the document is purged all the same, the file stays journaled for the next reconcile
+	var response DeleteResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.DeletedCount != 1 {
+		t.Fatalf("expected the purge to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(response.Warnings) != 1 || response.Warnings[0].Uuid != id.String() || response.Warnings[0].Code != ErrCodeStorage {
+		t.Errorf("expected a storage warning for the document, got %+v", response.Warnings)
+	}
+}
+
+// coreDAO hides every optional capability of the DAO it wraps
+type coreDAO struct {
+	dao.DAO
+}
+
+func TestV1WhenBackendLacksCapabilityExpectNotSupported(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+	handler.DaoService.dao = coreDAO{handler.DaoService.dao}
+
+	// documents are stored and read without the capabilities
+	created := createNote(t, r, "Plain")
+
+	for _, path := range []string{"/v1/data/trash", "/v1/data/history/" + created.Uuid} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotImplemented || !bytes.Contains(w.Body.Bytes(), []byte(ErrCodeUnsupported)) {
+			t.Errorf("expected 501 from %s, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	for _, title := range []string{"Old", "Older"} {
+		created := createNote(t, r, title)
+		if _, err := handler.DaoService.Trash(context.Background(), uuid.MustParse(created.Uuid), "ada"); err != nil {
+			t.Fatalf("trash failed: %v", err)
+		}
+	}
+
+	if purged, err := handler.DaoService.PurgeExpired(context.Background(), time.Hour, handler.FaoService); err != nil || purged != 0 {
+		t.Fatalf("expected nothing purged before the retention period, got %d, %v", purged, err)
+	}
+	if purged, err := handler.DaoService.PurgeExpired(context.Background(), 0, handler.FaoService); err != nil || purged != 2 {
+		t.Fatalf("expected both documents purged, got %d, %v", purged, err)
+	}
+	if trashed, _ := handler.DaoService.Trashed(context.Background()); len(trashed) != 0 {
+		t.Errorf("expected an empty trash, got %d", len(trashed))
+	}
+}
@@ -0,0 +1,672 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+//---------------------------------------------------
+//----------------------IMPORT-----------------------
+//---------------------------------------------------
+
+// an import walks a directory or zip archive and stores every file of an allowed type with a
+// record, as an upload would. a file's metadata comes from, each overriding the last: defaults
+// from its name, its row of a CSV manifest at the root of the source, and a sidecar next to it.
+// manifest rows without a file create documents with only metadata. files whose sha256 is
+// already in the library, documents whose given Uuid is, and rows without a file naming a work
+// the library has, by DOI, ISBN or title and authors, are skipped as duplicates, which also
+// makes an interrupted import resumable: running it again skips whatever it got through.
+
+// the Status of an ImportResult
+const (
+	ImportImported  = "imported"
+	ImportDuplicate = "duplicate"
+	ImportSkipped   = "skipped"
+	ImportFailed    = "failed"
+	// ImportMatched is a bibliography entry that filled in a document already in the library
+	ImportMatched = "matched"
+)
+
+// ErrInvalidImport is returned (wrapped) when the source or its manifest can't be read, or
+// the default DocType isn't registered, before anything is imported.
+var ErrInvalidImport = errors.New("invalid import")
+
+// the names a manifest is looked for under, at the root of the source
+var importManifests = []string{"manifest.csv", "metadata.csv"}
+
+// a sidecar is the file's name with one of these added ("paper.pdf.json"), or in place of its
+// extension ("paper.json"), tried in that order
+var sidecarExts = []string{".json", ".yaml", ".yml"}
+
+// ImportResult is what happened to one file of the source, Path being relative to its root,
+// or to one entry of a bibliography.
+type ImportResult struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	// Key is a bibliography entry's citation key
+	Key  string `json:"key,omitempty"`
+	Uuid string `json:"uuid,omitempty"`
+	// DuplicateOf is the document that already holds the same file, or reference
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Unmapped lists the fields of a bibliography entry the document has no place for
+	Unmapped []string      `json:"unmapped,omitempty"`
+	Code     string        `json:"code,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Details  []ErrorDetail `json:"details,omitempty"`
+}
+
+// ImportReport is the outcome of an import, with a result for every file.
+type ImportReport struct {
+	Source string `json:"source"`
+	// Files counts the files to import and the manifest rows without one, or the entries of
+	// a bibliography
+	Files      int            `json:"files"`
+	Imported   int            `json:"imported"`
+	Matched    int            `json:"matched"`
+	Duplicates int            `json:"duplicates"`
+	Skipped    int            `json:"skipped"`
+	Failed     int            `json:"failed"`
+	Results    []ImportResult `json:"results"`
+}
+
+func (r *ImportReport) add(result ImportResult) {
+	r.Results = append(r.Results, result)
+	switch result.Status {
+	case ImportImported:
+		r.Imported++
+	case ImportMatched:
+		r.Matched++
+	case ImportDuplicate:
+		r.Duplicates++
+	case ImportSkipped:
+		r.Skipped++
+	default:
+		r.Failed++
+	}
+}
+
+// Importer stores the files of a directory or zip archive, each with a document record, or
+// creates the documents of a bibliography.
+type Importer struct {
+	DaoService      *DaoService
+	Files           fao.FAO
+	DocumentFactory *dao.DocumentFactory
+	// DocType is given to documents whose metadata has none, Notes when empty
+	DocType string
+	// User is who the documents' first revisions are recorded as made by
+	User string
+	// Progress, when set, is called with each file's result as it's handled
+	Progress func(done, total int, result ImportResult)
+}
+
+func (im *Importer) docType() string {
+	if im.DocType == "" {
+		return "Notes"
+	}
+	return im.DocType
+}
+
+// Import imports a directory, a zip archive or a bibliography on the local filesystem.
+func (im *Importer) Import(ctx context.Context, source string) (ImportReport, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return ImportReport{Source: source}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if info.IsDir() {
+		return im.ImportFS(ctx, os.DirFS(source), source)
+	}
+	if _, ok := bibliographyFormats[strings.ToLower(path.Ext(source))]; ok {
+		f, err := os.Open(source)
+		if err != nil {
+			return ImportReport{Source: source}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		defer f.Close()
+		return im.ImportBibliography(ctx, f, source)
+	}
+	if !strings.EqualFold(path.Ext(source), ".zip") {
+		return ImportReport{Source: source}, fmt.Errorf("%w: %s is not a directory, zip archive or bibliography", ErrInvalidImport, source)
+	}
+	archive, err := zip.OpenReader(source)
+	if err != nil {
+		return ImportReport{Source: source}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	defer archive.Close()
+	return im.ImportFS(ctx, archive, source)
+}
+
+// ImportFS imports every file of fsys, name being what the report calls it. When ctx is
+// cancelled it stops after the file in hand, returning the report so far.
+func (im *Importer) ImportFS(ctx context.Context, fsys fs.FS, name string) (ImportReport, error) {
+	report := ImportReport{Source: name, Results: []ImportResult{}}
+	if _, err := im.DocumentFactory.NewDocument(im.docType()); err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	files, err := listImportFiles(fsys)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	manifest, err := readManifest(fsys)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	// sidecars and the manifest are metadata, not files to import
+	sidecars := map[string]string{}
+	consumed := map[string]bool{manifest.path: true}
+	for _, file := range files {
+		if sidecar := findSidecar(files, file); sidecar != "" && isAllowedFileType(strings.ToLower(path.Ext(file))) {
+			sidecars[file] = sidecar
+			consumed[sidecar] = true
+		}
+	}
+	files = slices.DeleteFunc(files, func(file string) bool { return consumed[file] })
+	// manifest rows naming a file that isn't there are reported alongside
+	var missing []string
+	for file := range manifest.files {
+		if !slices.Contains(files, file) {
+			missing = append(missing, file)
+		}
+	}
+	slices.Sort(missing)
+	report.Files = len(files) + len(missing) + len(manifest.records)
+
+	library, err := im.readLibrary(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	done := 0
+	handled := func(result ImportResult) {
+		report.add(result)
+		done++
+		if im.Progress != nil {
+			im.Progress(done, report.Files, result)
+		}
+	}
+	for _, file := range missing {
+		handled(ImportResult{Path: file, Status: ImportFailed, Code: ErrCodeNotFound, Message: "Listed in the manifest but not in the source"})
+	}
+	for _, record := range manifest.records {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		handled(im.importRecord(ctx, manifest.path, record, library))
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		handled(im.importFile(ctx, fsys, file, manifest.files[file], sidecars[file], library))
+	}
+	return report, nil
+}
+
+// importFile stores one file with its record, adding it to library.
+func (im *Importer) importFile(ctx context.Context, fsys fs.FS, file string, row map[string]string, sidecar string, library importLibrary) ImportResult {
+	result := ImportResult{Path: file, Status: ImportFailed}
+	ext := strings.ToLower(path.Ext(file))
+	if !isAllowedFileType(ext) {
+		result.Status, result.Code, result.Message = ImportSkipped, ErrCodeUnsupportedFileType, fmt.Sprintf("File type '%s' is not supported", ext)
+		return result
+	}
+
+	info, err := fs.Stat(fsys, file)
+	if err != nil {
+		result.Code, result.Message = ErrCodeInvalidRequest, err.Error()
+		return result
+	}
+	if info.Size() > maxFileSize {
+		result.Code, result.Message = ErrCodeFileTooLarge, "File size exceeds maximum limit of 100MB"
+		return result
+	}
+
+	hash, err := hashImportFile(fsys, file)
+	if err != nil {
+		result.Code, result.Message = ErrCodeInvalidRequest, err.Error()
+		return result
+	}
+	if existing, ok := library.hashes[hash]; ok {
+		result.Status, result.DuplicateOf, result.Message = ImportDuplicate, existing, "The library already has this file"
+		return result
+	}
+
+	var fromSidecar map[string]any
+	if sidecar != "" {
+		if fromSidecar, err = readSidecar(fsys, sidecar); err != nil {
+			result.Code, result.Message = ErrCodeInvalidRequest, fmt.Sprintf("invalid sidecar %s: %v", sidecar, err)
+			return result
+		}
+	}
+	metadata := im.importMetaData(titleFromFileName(file), row, fromSidecar)
+	id, exists := library.documentUuid(metadata)
+	if exists {
+		result.Status, result.DuplicateOf, result.Message = ImportDuplicate, id, "The library already has this document"
+		return result
+	}
+
+	filePath := uuid.New().String() + ext
+	owned := dao.MetaData{Uuid: id, Path: filePath, FileType: ext, Size: info.Size()}
+	doc, reqErr := documentFromRequest(im.DocumentFactory, metadata, owned)
+	if reqErr != nil {
+		result.Code, result.Message, result.Details = reqErr.code, reqErr.message, reqErr.details
+		return result
+	}
+
+	stored, err := im.storeFile(ctx, fsys, file, doc)
+	if err != nil {
+		result.Code, result.Message = ErrCodeStorage, err.Error()
+		return result
+	}
+	library.add(doc.GetMetaData(), stored)
+	result.Status, result.Uuid = ImportImported, id
+	return result
+}
+
+// importRecord creates a document with no file from a manifest row, adding it to library.
+// The result's Path is the manifest and the row's line number.
+func (im *Importer) importRecord(ctx context.Context, manifestPath string, record manifestRecord, library importLibrary) ImportResult {
+	result := ImportResult{Path: fmt.Sprintf("%s:%d", manifestPath, record.line), Status: ImportFailed}
+	metadata := im.importMetaData("", record.fields, nil)
+	id, exists := library.documentUuid(metadata)
+	if exists {
+		result.Status, result.DuplicateOf, result.Message = ImportDuplicate, id, "The library already has this document"
+		return result
+	}
+
+	doc, reqErr := documentFromRequest(im.DocumentFactory, metadata, dao.MetaData{Uuid: id})
+	if reqErr != nil {
+		result.Code, result.Message, result.Details = reqErr.code, reqErr.message, reqErr.details
+		return result
+	}
+	// without a file or a Uuid there's only the metadata to tell it's been imported before
+	if existing, ok := library.works[workKey(doc.GetMetaData())]; ok {
+		result.Status, result.DuplicateOf, result.Message = ImportDuplicate, existing, "The library already has this work"
+		return result
+	}
+	if err := im.DaoService.Create(ctx, doc, im.User); err != nil {
+		result.Code, result.Message = ErrCodeInternal, fmt.Sprintf("failed to create database record: %v", err)
+		return result
+	}
+	library.add(doc.GetMetaData(), "")
+	result.Status, result.Uuid = ImportImported, id
+	return result
+}
+
+// storeFile stores file under the document's path, journaled like an upload, then creates
+// the record. It returns the sha256 of what was stored.
+func (im *Importer) storeFile(ctx context.Context, fsys fs.FS, file string, doc dao.Document) (string, error) {
+	meta := doc.GetMetaData()
+	write, err := im.DaoService.BeginFileWrite(ctx, uuid.MustParse(meta.Uuid), meta.Path, im.Files)
+	if err != nil {
+		return "", fmt.Errorf("failed to journal %s: %w", meta.Path, err)
+	}
+
+	src, err := fsys.Open(file)
+	if err != nil {
+		write.Abort(ctx)
+		return "", err
+	}
+	defer src.Close()
+	sum := sha256.New()
+	if err := im.Files.SaveFile(ctx, meta.Path, io.TeeReader(src, sum)); err != nil {
+		write.Abort(ctx)
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	meta.Hash = hex.EncodeToString(sum.Sum(nil))
+	doc.SetMetaData(meta)
+	if err := im.DaoService.Create(ctx, doc, im.User); err != nil {
+		write.Abort(ctx)
+		return "", fmt.Errorf("failed to create database record: %w", err)
+	}
+	write.Commit(ctx)
+	return meta.Hash, nil
+}
+
+// importLibrary is what's in the library already, to spot duplicates by: the document
+// holding each file, by sha256, the UUID of every document, and the document of each work,
+// by workKey.
+type importLibrary struct {
+	hashes map[string]string
+	uuids  map[string]bool
+	works  map[string]string
+}
+
+func (im *Importer) readLibrary(ctx context.Context) (importLibrary, error) {
+	docs, err := im.DaoService.searchLibrary(ctx, "", "", "")
+	if err != nil {
+		return importLibrary{}, fmt.Errorf("failed to list the library: %w", err)
+	}
+	library := importLibrary{
+		hashes: make(map[string]string, len(docs)),
+		uuids:  make(map[string]bool, len(docs)),
+		works:  make(map[string]string, len(docs)),
+	}
+	for _, doc := range docs {
+		library.add(doc, doc.Hash)
+	}
+	return library, nil
+}
+
+// add indexes a document, hash being the sha256 of its file, empty when it has none
+func (l importLibrary) add(meta dao.MetaData, hash string) {
+	l.uuids[meta.Uuid] = true
+	if hash != "" {
+		l.hashes[hash] = meta.Uuid
+	}
+	if key := workKey(meta); key != "" {
+		if _, taken := l.works[key]; !taken {
+			l.works[key] = meta.Uuid
+		}
+	}
+}
+
+// workKey is what tells a work apart when there's no file to go by: its DOI, else its ISBN,
+// else its title with its authors, compared the way bibliography entries are matched. It's
+// empty when there's none of them.
+func workKey(meta dao.MetaData) string {
+	if doi := matchDOI(meta.DOI); doi != "" {
+		return "doi:" + doi
+	}
+	if isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(meta.ISBN)); isbn != "" {
+		return "isbn:" + isbn
+	}
+	title := matchTitle(meta.Title)
+	if title == "" {
+		return ""
+	}
+	authors := meta.Authors
+	if len(authors) == 0 && meta.Author != "" {
+		authors = []string{meta.Author}
+	}
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		names = append(names, matchTitle(author))
+	}
+	slices.Sort(names)
+	return "title:" + title + "/" + strings.Join(names, ",")
+}
+
+// documentUuid is the Uuid the metadata gives, so an exported library keeps its UUIDs when
+// it's imported again, or a new one. exists is set when the library already has it.
+func (l importLibrary) documentUuid(metadata map[string]any) (id string, exists bool) {
+	if given, ok := metadata["Uuid"].(string); ok {
+		if parsed, err := uuid.Parse(strings.TrimSpace(given)); err == nil {
+			return parsed.String(), l.uuids[parsed.String()]
+		}
+	}
+	return uuid.New().String(), false
+}
+
+// importMetaData is the body an upload would have carried: the title given and the default
+// DocType, overridden by a manifest row and then a sidecar.
+func (im *Importer) importMetaData(title string, row map[string]string, fromSidecar map[string]any) map[string]any {
+	metadata := map[string]any{"DocType": im.docType()}
+	if title != "" {
+		metadata["Title"] = title
+	}
+
+	// the manifest's text values are converted for the type the document ends up with
+	if value, ok := row["DocType"]; ok && value != "" {
+		metadata["DocType"] = value
+	}
+	if value, ok := fromSidecar["DocType"].(string); ok && value != "" {
+		metadata["DocType"] = value
+	}
+	schema, _ := im.DocumentFactory.GetSchema(fmt.Sprint(metadata["DocType"]))
+	for field, value := range manifestFields(row, schema) {
+		metadata[field] = value
+	}
+	return mergePatch(metadata, fromSidecar)
+}
+
+// titleFromFileName turns "lecture_notes-week1.pdf" into "lecture notes-week1"
+func titleFromFileName(file string) string {
+	name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+	return strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " ")
+}
+
+// listImportFiles lists the regular files of fsys in lexical order, leaving out hidden ones
+// and the resource forks macOS adds to archives.
+func listImportFiles(fsys fs.FS) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		hidden := p != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "__MACOSX")
+		switch {
+		case d.IsDir() && hidden:
+			return fs.SkipDir
+		case d.IsDir() || hidden || !d.Type().IsRegular():
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+// findSidecar returns the sidecar in files for file, empty when there isn't one
+func findSidecar(files []string, file string) string {
+	stem := strings.TrimSuffix(file, path.Ext(file))
+	for _, base := range []string{file, stem} {
+		for _, ext := range sidecarExts {
+			if candidate := base + ext; candidate != file && slices.Contains(files, candidate) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// readSidecar decodes a JSON or YAML sidecar into the shape a decoded JSON body has
+func readSidecar(fsys fs.FS, sidecar string) (map[string]any, error) {
+	data, err := fs.ReadFile(fsys, sidecar)
+	if err != nil {
+		return nil, err
+	}
+	var metadata map[string]any
+	if path.Ext(sidecar) == ".json" {
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}
+
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	// YAML decodes numbers as ints and unquoted dates as times, a JSON round trip gives
+	// float64s, and dates are put back the way they were most likely written
+	data, err = json.Marshal(yamlDates(metadata))
+	if err != nil {
+		return nil, err
+	}
+	// decoded into a fresh map, Unmarshal would merge into the YAML one
+	metadata = nil
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func yamlDates(value any) any {
+	switch value := value.(type) {
+	case time.Time:
+		if value.Equal(value.Truncate(24 * time.Hour)) {
+			return value.Format("2006-01-02")
+		}
+		return value.Format(time.RFC3339)
+	case map[string]any:
+		for k, v := range value {
+			value[k] = yamlDates(v)
+		}
+	case []any:
+		for i, v := range value {
+			value[i] = yamlDates(v)
+		}
+	}
+	return value
+}
+
+// importManifest is the CSV manifest at the root of a source, if it has one.
+type importManifest struct {
+	path string
+	// files maps a file, relative to the root, to its row
+	files map[string]map[string]string
+	// records are the rows without a file, each a document with only metadata
+	records []manifestRecord
+}
+
+type manifestRecord struct {
+	line   int
+	fields map[string]string
+}
+
+func readManifest(fsys fs.FS) (importManifest, error) {
+	for _, name := range importManifests {
+		f, err := fsys.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return importManifest{}, err
+		}
+		defer f.Close()
+
+		rows, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			return importManifest{}, fmt.Errorf("%s: %v", name, err)
+		}
+		manifest, err := parseManifest(rows)
+		if err != nil {
+			return importManifest{}, fmt.Errorf("%s: %v", name, err)
+		}
+		manifest.path = name
+		return manifest, nil
+	}
+	return importManifest{}, nil
+}
+
+// parseManifest keys the rows after the header by their file (or path) column, relative to
+// the source root. Rows with the column empty are kept as records.
+func parseManifest(rows [][]string) (importManifest, error) {
+	if len(rows) == 0 {
+		return importManifest{}, fmt.Errorf("empty manifest")
+	}
+	header := rows[0]
+	fileColumn := slices.IndexFunc(header, func(name string) bool {
+		name = strings.ToLower(strings.TrimSpace(name))
+		return name == "file" || name == "path"
+	})
+	if fileColumn < 0 {
+		return importManifest{}, fmt.Errorf("no file column")
+	}
+
+	manifest := importManifest{files: make(map[string]map[string]string, len(rows)-1)}
+	for i, row := range rows[1:] {
+		fields := map[string]string{}
+		for column, value := range row {
+			if column != fileColumn && strings.TrimSpace(value) != "" {
+				fields[strings.TrimSpace(header[column])] = value
+			}
+		}
+
+		file := strings.TrimSpace(row[fileColumn])
+		if file == "" {
+			manifest.records = append(manifest.records, manifestRecord{line: i + 2, fields: fields})
+			continue
+		}
+		file = path.Clean(strings.TrimPrefix(file, "./"))
+		if !fs.ValidPath(file) || file == "." {
+			return importManifest{}, fmt.Errorf("row %d: %q is not a path within the source", i+2, row[fileColumn])
+		}
+		manifest.files[file] = fields
+	}
+	return manifest, nil
+}
+
+// manifestFields converts a manifest row's text to the JSON types a body would carry: list
+// fields are split on ";", PageCount and the numeric and boolean fields of schema are parsed,
+// and "Custom.<key>" columns become entries of Custom. A value that doesn't parse is passed
+// on as text, for validation to report.
+func manifestFields(row map[string]string, schema dao.TypeSchema) map[string]any {
+	fields := map[string]any{}
+	custom := map[string]any{}
+	for name, value := range row {
+		if key, ok := strings.CutPrefix(name, "Custom."); ok {
+			custom[key] = value
+			continue
+		}
+
+		kind := schema.Properties[name].Type
+		switch name {
+		case "Authors", "Tags":
+			kind = "array"
+		case "PageCount":
+			kind = "integer"
+		}
+		switch kind {
+		case "array":
+			var list []any
+			for _, item := range strings.Split(value, ";") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			fields[name] = list
+		case "integer", "number":
+			if n, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				fields[name] = n
+			} else {
+				fields[name] = value
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+				fields[name] = b
+			} else {
+				fields[name] = value
+			}
+		default:
+			fields[name] = value
+		}
+	}
+	if len(custom) > 0 {
+		fields["Custom"] = custom
+	}
+	return fields
+}
+
+func hashImportFile(fsys fs.FS, file string) (string, error) {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestImporter(handler *APIHandler) *Importer {
+	return &Importer{DaoService: &handler.DaoService, Files: handler.FaoService, DocumentFactory: handler.DocumentFactory, User: "ada"}
+}
+
+// readImported reads a document an import created back through the API
+func readImported(t *testing.T, r http.Handler, result ImportResult) DocumentJSON {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/data/read/"+result.Uuid, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %s imported, got %d: %s", result.Path, w.Code, w.Body.String())
+	}
+	var resp DocumentResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Document
+}
+
+func TestImportReadsSidecarsAndManifest(t *testing.T) {
+	r, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	source := fstest.MapFS{
+		"manifest.csv": {Data: []byte("file,Title,Authors,PageCount,Custom.shelf\n" +
+			"papers/alpha.txt,Alpha,Ada Lovelace; Grace Hopper,12,B2\n" +
+			"papers/beta.md,From the manifest,,,\n" +
+			"gone.txt,Gone,,,\n")},
+		"papers/alpha.txt":       {Data: []byte("alpha")},
+		"papers/beta.md":         {Data: []byte("beta")},
+		"papers/beta.yaml":       {Data: []byte("Title: Beta\nPublishDate: 2020-01-02\nTags: [maths, logic]\n")},
+		"lecture_notes.txt":      {Data: []byte("gamma")},
+		"lecture_notes.txt.json": {Data: []byte(`{"Description": "Week one"}`)},
+		"setup.exe":              {Data: []byte("binary")},
+		".DS_Store":              {Data: []byte("hidden")},
+	}
+
+	var progress []int
+	importer := newTestImporter(handler)
+	importer.Progress = func(done, total int, result ImportResult) { progress = append(progress, done) }
+	report, err := importer.ImportFS(context.Background(), source, "library")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if report.Files != 5 || report.Imported != 3 || report.Skipped != 1 || report.Failed != 1 || len(progress) != 5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	results := map[string]ImportResult{}
+	for _, result := range report.Results {
+		results[result.Path] = result
+	}
+	if results["gone.txt"].Code != ErrCodeNotFound || results["setup.exe"].Code != ErrCodeUnsupportedFileType {
+		t.Errorf("expected the missing and unsupported files reported, got %+v", report.Results)
+	}
+
+	alpha := readImported(t, r, results["papers/alpha.txt"])
+	if alpha.Title != "Alpha" || len(alpha.Authors) != 2 || alpha.Authors[1] != "Grace Hopper" || alpha.PageCount != 12 || alpha.Custom["shelf"] != "B2" || alpha.Sha256 == "" {
+		t.Errorf("expected the manifest row applied, got %+v", alpha)
+	}
+	// the sidecar overrides the manifest
+	beta := readImported(t, r, results["papers/beta.md"])
+	if beta.Title != "Beta" || beta.PublishDate != "2020-01-02" || len(beta.Tags) != 2 {
+		t.Errorf("expected the YAML sidecar applied, got %+v", beta)
+	}
+	notes := readImported(t, r, results["lecture_notes.txt"])
+	if notes.Title != "lecture notes" || notes.Description != "Week one" || notes.DocType != "Notes" {
+		t.Errorf("expected a title from the file name and the JSON sidecar applied, got %+v", notes)
+	}
+}
+
+// files already in the library are duplicates, so running an import again resumes it
+func TestImportAgainReportsDuplicates(t *testing.T) {
+	_, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	source := fstest.MapFS{
+		"one.txt":      {Data: []byte("same")},
+		"copy/one.txt": {Data: []byte("same")},
+		"two.txt":      {Data: []byte("other")},
+	}
+	first, err := newTestImporter(handler).ImportFS(context.Background(), source, "library")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if first.Imported != 2 || first.Duplicates != 1 || first.Results[1].DuplicateOf != first.Results[0].Uuid {
+		t.Fatalf("expected the copy reported as a duplicate, got %+v", first)
+	}
+
+	second, err := newTestImporter(handler).ImportFS(context.Background(), source, "library")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if second.Imported != 0 || second.Duplicates != 3 {
+		t.Fatalf("expected nothing imported twice, got %+v", second)
+	}
+	if docs, _ := handler.DaoService.SearchByKeyValue(context.Background(), "", ""); len(docs) != 2 {
+		t.Errorf("expected 2 documents, got %d", len(docs))
+	}
+}
+
+// manifest rows without a file have no hash to go by, they're recognised by DOI, ISBN or
+// title and authors instead
+func TestImportMetadataOnlyManifestAgainReportsDuplicates(t *testing.T) {
+	_, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	source := fstest.MapFS{
+		"manifest.csv": {Data: []byte("file,Title,Authors,DOI,ISBN\n" +
+			",Computing Machinery and Intelligence,Alan Turing,10.1093/mind/LIX.236.433,\n" +
+			",The Art of Computer Programming,Donald Knuth,,978-0-201-89683-1\n" +
+			",On Computable Numbers,Alan Turing,,\n" +
+			",On computable numbers!,Alan Turing,,\n")},
+	}
+	first, err := newTestImporter(handler).ImportFS(context.Background(), source, "library")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if first.Imported != 3 || first.Duplicates != 1 || first.Results[3].DuplicateOf != first.Results[2].Uuid {
+		t.Fatalf("expected the repeated title reported as a duplicate, got %+v", first)
+	}
+
+	second, err := newTestImporter(handler).ImportFS(context.Background(), source, "library")
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if second.Imported != 0 || second.Duplicates != 4 {
+		t.Fatalf("expected nothing imported twice, got %+v", second)
+	}
+	if docs, _ := handler.DaoService.SearchByKeyValue(context.Background(), "", ""); len(docs) != 3 {
+		t.Errorf("expected 3 documents, got %d", len(docs))
+	}
+}
+
+func TestV1AdminImportStreamsProgress(t *testing.T) {
+	_, handler, cleanup := setupTestRouter(t)
+	defer cleanup()
+
+	r := gin.New()
+	admin := NewAdminHandler(handler.DaoService, handler.FaoService, testAdminToken)
+	admin.DocumentFactory = handler.DocumentFactory
+	if err := registerRoutes(r, false, admin); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+	post := func(body ImportRequest) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, adminRequest(http.MethodPost, "/v1/admin/import", bytes.NewReader(bodyBytes)))
+		return w
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"Author": "Ada Lovelace", "Journal": "Notes"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+
+	w := post(ImportRequest{Source: dir, DocType: "Article"})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected a stream, got %d: %s", w.Code, w.Body.String())
+	}
+	var events []ImportEvent
+	lines := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+	for lines.Scan() {
+		var event ImportEvent
+		json.Unmarshal(lines.Bytes(), &event)
+		events = append(events, event)
+	}
+	if len(events) != 3 || events[0].Progress == nil || events[0].Progress.Total != 2 || events[2].Report == nil || events[2].Report.Imported != 1 {
+		t.Fatalf("expected a progress line per file then the report, got %s", w.Body.String())
+	}
+	// an Article needs an Author and Journal, only the sidecar gave them
+	if failed := events[1].Progress.Result; failed.Path != "b.txt" || failed.Code != ErrCodeValidationFailed || len(failed.Details) != 2 {
+		t.Errorf("expected b.txt to fail validation, got %+v", failed)
+	}
+
+	for _, body := range []ImportRequest{{Source: filepath.Join(dir, "missing")}, {Source: dir, DocType: "Pamphlet"}} {
+		w = post(body)
+		var errResp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &errResp)
+		if w.Code != http.StatusBadRequest || errResp.Error.Code != ErrCodeInvalidRequest {
+			t.Errorf("%+v: expected 400 invalid_request, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -1,18 +1,29 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"path/filepath"
 	"scriptorium/internal/backend/converter"
 	"scriptorium/internal/backend/dao"
 	"scriptorium/internal/backend/fao"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	pb "scriptorium/internal/backend/service/pb"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type Service interface {
@@ -41,14 +52,14 @@ func (fcs FileConverterService) New(f any) (Service, error) {
 	return fc, nil
 }
 
-func (fcs FileConverterService) Convert(file dao.MetaData, toFormat string) (string, error) {
-	exists := fcs.fao.FileExists(file.Path)
+func (fcs FileConverterService) Convert(ctx context.Context, file dao.MetaData, toFormat string) (string, error) {
+	exists := fcs.fao.FileExists(ctx, file.Path)
 	if !exists {
 		return "", fmt.Errorf("%s does not exist", file.Path)
 	}
 
 	fromFormat := strings.TrimPrefix(file.FileType, ".")
-	outputPath, err := fcs.converter.ConvertFileByPath(file.Path, fromFormat, toFormat)
+	outputPath, err := fcs.converter.ConvertFileByPath(ctx, file.Path, fromFormat, toFormat)
 	if err != nil {
 		return "", fmt.Errorf("conversion failed: %w", err)
 	}
@@ -81,17 +92,28 @@ func (fhs FileHandlerService) UploadFile(stream grpc.ClientStreamingServer[pb.Fi
 	var fileReader io.Reader
 	var firstChunk = true
 	var filename string
+	// the save runs alongside the receive loop, the result is waited on before
+	// responding so that callers never observe a partially written file.
+	saveErr := make(chan error, 1)
 
 	for {
 		chunk, err := stream.Recv()
 		if err == io.EOF {
 			if fileData != nil {
 				_ = fileData.Close() // close writer after all chunks
+				if err := <-saveErr; err != nil {
+					log.Println("failed to save file:", err)
+					return status.Errorf(codes.Internal, "failed to save file: %v", err)
+				}
 			}
-			return stream.SendAndClose(&pb.FileUploadResponse{Message: "Upload complete"})
+			return stream.SendAndClose(&pb.FileUploadResponse{Message: "Upload complete", FileId: filename})
 		}
 		if err != nil {
 			log.Println("failed to receive chunk:", err)
+			if fileData != nil {
+				_ = fileData.CloseWithError(err)
+				<-saveErr
+			}
 			return fmt.Errorf("failed to receive chunk: %w", err)
 		}
 
@@ -100,9 +122,7 @@ func (fhs FileHandlerService) UploadFile(stream grpc.ClientStreamingServer[pb.Fi
 			filename = chunk.Filename
 			fileReader, fileData = io.Pipe()
 			go func() {
-				if err := fhs.fao.SaveFile(filename, fileReader); err != nil {
-					log.Println("failed to save file:", err)
-				}
+				saveErr <- fhs.fao.SaveFile(stream.Context(), filename, fileReader)
 			}()
 			firstChunk = false
 		}
@@ -117,12 +137,11 @@ func (fhs FileHandlerService) UploadFile(stream grpc.ClientStreamingServer[pb.Fi
 
 // DownloadFile streams a file in chunks
 func (s FileHandlerService) DownloadFile(req *pb.FileRequest, stream grpc.ServerStreamingServer[pb.FileChunk]) error {
-	fmt.Printf("Streaming file: %s\n", req.Filename)
-
 	// Get file reader
-	file, err := s.fao.GetFile(req.Filename)
+	// the stream's context is cancelled when the client goes away, which stops the reads
+	file, err := s.fao.GetFile(stream.Context(), req.Filename)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return faoStatus(err)
 	}
 	defer file.Close()
 
@@ -145,6 +164,613 @@ func (s FileHandlerService) DownloadFile(req *pb.FileRequest, stream grpc.Server
 	return nil
 }
 
+// DeleteFile removes a file from storage
+func (s FileHandlerService) DeleteFile(ctx context.Context, req *pb.FileRequest) (*pb.DeleteFileResponse, error) {
+	if err := s.fao.DeleteFile(ctx, req.Filename); err != nil {
+		return nil, faoStatus(err)
+	}
+	return &pb.DeleteFileResponse{Message: "Delete complete"}, nil
+}
+
+// StatFile returns the size, modification time and sha256 of a stored file
+func (s FileHandlerService) StatFile(ctx context.Context, req *pb.FileRequest) (*pb.FileInfo, error) {
+	info, err := s.fao.StatFile(ctx, req.Filename)
+	if err != nil {
+		return nil, faoStatus(err)
+	}
+	return fileInfoToPb(info), nil
+}
+
+// ListFiles streams every stored file whose path starts with the requested prefix
+func (s FileHandlerService) ListFiles(req *pb.ListFilesRequest, stream grpc.ServerStreamingServer[pb.FileInfo]) error {
+	ctx := stream.Context()
+	list := s.fao.ListFiles
+	if req.IncludeHash {
+		list = s.fao.ListFilesWithHash
+	}
+	files, err := list(ctx, req.Prefix)
+	if err != nil {
+		return faoStatus(err)
+	}
+
+	for _, info := range files {
+		if err := stream.Send(fileInfoToPb(info)); err != nil {
+			return fmt.Errorf("failed to send file info: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Exists reports whether a file is present in storage
+func (s FileHandlerService) Exists(ctx context.Context, req *pb.FileRequest) (*pb.ExistsResponse, error) {
+	return &pb.ExistsResponse{Exists: s.fao.FileExists(ctx, req.Filename)}, nil
+}
+
+// maps FAO errors onto gRPC status codes, so clients can tell a missing file apart from a failure.
+func faoStatus(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func fileInfoToPb(info fao.FileInfo) *pb.FileInfo {
+	return &pb.FileInfo{
+		Filename: info.Path,
+		Size:     info.Size,
+		ModTime:  info.ModTime.Unix(),
+		Sha256:   info.Hash,
+	}
+}
+
+func fileInfoFromPb(info *pb.FileInfo) fao.FileInfo {
+	return fao.FileInfo{
+		Path:    info.Filename,
+		Size:    info.Size,
+		ModTime: time.Unix(info.ModTime, 0),
+		Hash:    info.Sha256,
+	}
+}
+
+//---------------------------------------------------
+//--------------FILE-SERVICE-CLIENT-FAO--------------
+//---------------------------------------------------
+
+// FileServiceFao satisfies fao.FAO by calling the gRPC FileService, so that anything
+// holding it (handlers, the converter) goes through the storage node instead of the disk.
+type FileServiceFao struct {
+	client pb.FileServiceClient
+	// Timeout is the deadline on each call to the storage node, a transfer included. 0 leaves
+	// it to the caller's context.
+	Timeout time.Duration
+}
+
+func NewFileServiceFao(client pb.FileServiceClient) *FileServiceFao {
+	return &FileServiceFao{client: client}
+}
+
+// withTimeout bounds ctx by Timeout, when one is set
+func (r *FileServiceFao) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout > 0 {
+		return context.WithTimeout(ctx, r.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// streams data to the storage node under the given path. cancelling ctx aborts the upload.
+func (r *FileServiceFao) SaveFile(ctx context.Context, path string, data io.Reader) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	stream, err := r.client.UploadFile(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create upload stream: %w", err)
+	}
+
+	buf := make([]byte, 4096)
+	firstChunk := true
+	for {
+		n, err := data.Read(buf)
+		if n > 0 || firstChunk {
+			// the first chunk is always sent, as it carries the filename, even for empty files
+			chunk := &pb.FileChunk{Data: buf[:n]}
+			if firstChunk {
+				chunk.Filename = path
+				firstChunk = false
+			}
+			if err := stream.Send(chunk); err != nil {
+				return fmt.Errorf("failed to send file chunk: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading file: %w", err)
+		}
+	}
+
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	return nil
+}
+
+// opens a download stream, the first chunk is received up front so a missing file errors here
+// rather than on the first Read. the stream lasts until it's closed or ctx is done.
+func (r *FileServiceFao) GetFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	stream, err := r.client.DownloadFile(ctx, &pb.FileRequest{Filename: path})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	reader := &downloadReader{stream: stream, cancel: cancel}
+	chunk, err := stream.Recv()
+	switch {
+	case err == io.EOF:
+		reader.eof = true
+	case err != nil:
+		cancel()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	default:
+		reader.buf = chunk.Data
+	}
+
+	return reader, nil
+}
+
+func (r *FileServiceFao) DeleteFile(ctx context.Context, path string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if _, err := r.client.DeleteFile(ctx, &pb.FileRequest{Filename: path}); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// returns false both when the file is absent and when the storage node can't be reached.
+func (r *FileServiceFao) FileExists(ctx context.Context, path string) bool {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	resp, err := r.client.Exists(ctx, &pb.FileRequest{Filename: path})
+	if err != nil {
+		log.Printf("failed to check existence of %s: %v", path, err)
+		return false
+	}
+	return resp.Exists
+}
+
+func (r *FileServiceFao) StatFile(ctx context.Context, path string) (fao.FileInfo, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	info, err := r.client.StatFile(ctx, &pb.FileRequest{Filename: path})
+	if err != nil {
+		return fao.FileInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return fileInfoFromPb(info), nil
+}
+
+func (r *FileServiceFao) ListFiles(ctx context.Context, prefix string) ([]fao.FileInfo, error) {
+	return r.listFiles(ctx, &pb.ListFilesRequest{Prefix: prefix})
+}
+
+// ListFilesWithHash has the file service hash every file as it lists them, in one call
+func (r *FileServiceFao) ListFilesWithHash(ctx context.Context, prefix string) ([]fao.FileInfo, error) {
+	return r.listFiles(ctx, &pb.ListFilesRequest{Prefix: prefix, IncludeHash: true})
+}
+
+func (r *FileServiceFao) listFiles(ctx context.Context, req *pb.ListFilesRequest) ([]fao.FileInfo, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	stream, err := r.client.ListFiles(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var files []fao.FileInfo
+	for {
+		info, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		files = append(files, fileInfoFromPb(info))
+	}
+	return files, nil
+}
+
+// downloadReader adapts a DownloadFile stream to an io.ReadCloser.
+type downloadReader struct {
+	stream grpc.ServerStreamingClient[pb.FileChunk]
+	cancel context.CancelFunc
+	buf    []byte
+	eof    bool
+}
+
+func (d *downloadReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.eof {
+			return 0, io.EOF
+		}
+		chunk, err := d.stream.Recv()
+		if err == io.EOF {
+			d.eof = true
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error receiving file chunk: %w", err)
+		}
+		d.buf = chunk.Data
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *downloadReader) Close() error {
+	d.cancel()
+	return nil
+}
+
+//---------------------------------------------------
+//-----------------LIBRARY-SERVICE-------------------
+//---------------------------------------------------
+
+// LibraryServer implements the gRPC LibraryService, a typed mirror of the REST /data API.
+type LibraryServer struct {
+	pb.UnimplementedLibraryServiceServer
+	DaoService      DaoService
+	DocumentFactory *dao.DocumentFactory
+	FaoService      fao.FAO
+}
+
+func NewLibraryServer(daos DaoService, documentFactory *dao.DocumentFactory, faoService fao.FAO) *LibraryServer {
+	return &LibraryServer{DaoService: daos, DocumentFactory: documentFactory, FaoService: faoService}
+}
+
+func (l *LibraryServer) Create(ctx context.Context, req *pb.CreateDocumentRequest) (*pb.DocumentResponse, error) {
+	meta := metaDataFromPb(req.Metadata)
+	if meta.DocType == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing document type")
+	}
+
+	doc, err := l.DocumentFactory.NewDocument(meta.DocType)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	meta.Uuid = uuid.New().String()
+	// the file fields are the server's, like on Update. Path only names the file_id
+	// FileService.UploadFile returned, which is copied to a path of the document's own
+	upload := meta.Path
+	meta.Path, meta.FileType, meta.Size, meta.Hash = "", "", 0, ""
+	schema, _ := l.DocumentFactory.GetSchema(meta.DocType)
+	if err := buildDocument(doc, meta, schema, req.Fields); err != nil {
+		return nil, err
+	}
+
+	if upload == "" {
+		if err := l.DaoService.Create(ctx, doc, grpcUser(ctx)); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return documentToPb(doc)
+	}
+
+	id := uuid.MustParse(meta.Uuid)
+	write, version, err := l.claimUpload(ctx, id, upload)
+	if err != nil {
+		return nil, err
+	}
+	meta = doc.GetMetaData()
+	meta.Path, meta.FileType, meta.Size, meta.Hash = version.Path, version.FileType, version.Size, version.Hash
+	if err := doc.SetMetaData(meta); err != nil {
+		write.Abort(ctx)
+		return nil, status.Errorf(codes.InvalidArgument, "failed to set metadata: %v", err)
+	}
+	if err := l.DaoService.Create(ctx, doc, grpcUser(ctx)); err != nil {
+		write.Abort(ctx)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	write.Commit(ctx)
+
+	// the upload has served its purpose, a second Create naming it finds nothing
+	if err := l.FaoService.DeleteFile(context.WithoutCancel(ctx), upload); err != nil && !fileMissing(err) {
+		log.Printf("failed to remove claimed upload %s: %v", upload, err)
+	}
+	return documentToPb(doc)
+}
+
+// claimUpload copies an uploaded file to a path of document id's own, so no two records
+// share a file. A path that a record, a file version or a pending file operation already
+// refers to isn't an upload and is refused. The copy is journaled, the caller commits or
+// aborts the write once the record is, or isn't, created.
+func (l *LibraryServer) claimUpload(ctx context.Context, id uuid.UUID, upload string) (*FileWrite, dao.FileVersion, error) {
+	inUse, err := l.DaoService.FileInUse(ctx, upload)
+	if err != nil {
+		return nil, dao.FileVersion{}, status.Error(codes.Internal, err.Error())
+	}
+	if inUse {
+		return nil, dao.FileVersion{}, status.Errorf(codes.InvalidArgument, "%s is not an upload, another document refers to it", upload)
+	}
+	ext := strings.ToLower(filepath.Ext(upload))
+	if !isAllowedFileType(ext) {
+		return nil, dao.FileVersion{}, status.Errorf(codes.InvalidArgument, "file type '%s' is not supported", ext)
+	}
+	info, err := l.FaoService.StatFile(ctx, upload)
+	if err != nil {
+		return nil, dao.FileVersion{}, status.Errorf(codes.InvalidArgument, "no uploaded file %s", upload)
+	}
+	src, err := l.FaoService.GetFile(ctx, upload)
+	if err != nil {
+		return nil, dao.FileVersion{}, faoStatus(err)
+	}
+	defer src.Close()
+
+	version := dao.FileVersion{Path: uuid.New().String() + ext, FileType: ext, Size: info.Size, Hash: info.Hash}
+	write, err := l.DaoService.BeginFileWrite(ctx, id, version.Path, l.FaoService)
+	if err != nil {
+		return nil, dao.FileVersion{}, status.Error(codes.Internal, err.Error())
+	}
+	if err := l.FaoService.SaveFile(ctx, version.Path, src); err != nil {
+		write.Abort(ctx)
+		return nil, dao.FileVersion{}, status.Errorf(codes.Internal, "failed to store file: %v", err)
+	}
+	return write, version, nil
+}
+
+func (l *LibraryServer) Get(ctx context.Context, req *pb.GetDocumentRequest) (*pb.DocumentResponse, error) {
+	record, err := l.readRecord(ctx, req.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DocumentResponse{Metadata: metaDataToPb(record.MetaData), Fields: record.Payload}, nil
+}
+
+// Update replaces the stored metadata. Path, FileType, Size and Hash always come from the
+// stored record, they're owned by the file upload.
+func (l *LibraryServer) Update(ctx context.Context, req *pb.UpdateDocumentRequest) (*pb.DocumentResponse, error) {
+	meta := metaDataFromPb(req.Metadata)
+	if meta.DocType == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing document type")
+	}
+
+	stored, err := l.readRecord(ctx, meta.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	meta.Path = stored.Path
+	meta.FileType = stored.FileType
+	meta.Size = stored.Size
+	meta.Hash = stored.Hash
+
+	doc, err := l.DocumentFactory.NewDocument(meta.DocType)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	schema, _ := l.DocumentFactory.GetSchema(meta.DocType)
+	if err := buildDocument(doc, meta, schema, req.Fields); err != nil {
+		return nil, err
+	}
+
+	if err := l.DaoService.Update(ctx, doc, grpcUser(ctx)); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return documentToPb(doc)
+}
+
+// Delete moves the document to the trash, matching /data/delete. Its file is kept
+// until the document is purged.
+func (l *LibraryServer) Delete(ctx context.Context, req *pb.DeleteDocumentRequest) (*pb.DeleteDocumentResponse, error) {
+	id, err := uuid.Parse(req.Uuid)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid UUID: %v", err)
+	}
+
+	if _, err := l.DaoService.Trash(ctx, id, grpcUser(ctx)); err != nil {
+		return nil, daoStatus(err)
+	}
+
+	return &pb.DeleteDocumentResponse{Uuid: req.Uuid}, nil
+}
+
+func (l *LibraryServer) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	page, limit := int(req.Page), int(req.Limit)
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	if page < 1 {
+		return nil, status.Error(codes.InvalidArgument, "page must be a positive integer")
+	}
+	if limit < 1 || limit > 100 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be between 1 and 100")
+	}
+
+	allResults, err := l.DaoService.Search(ctx, req.Query, req.Key, req.Value)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	results, totalPages := paginate(allResults, page, limit)
+	resp := &pb.SearchResponse{
+		TotalCount:   int32(len(allResults)),
+		Page:         int32(page),
+		Limit:        int32(limit),
+		TotalPages:   int32(totalPages),
+		HasNext:      page < totalPages,
+		HasPrev:      page > 1,
+		WarningCount: int32(l.DaoService.HiddenRecords(ctx)),
+	}
+	for _, meta := range results {
+		resp.Results = append(resp.Results, metaDataToPb(meta))
+	}
+
+	return resp, nil
+}
+
+// StreamSearch sends every match as its own message, for result sets too large to page through.
+func (l *LibraryServer) StreamSearch(req *pb.SearchRequest, stream grpc.ServerStreamingServer[pb.MetaData]) error {
+	results, err := l.DaoService.Search(stream.Context(), req.Query, req.Key, req.Value)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for _, meta := range results {
+		if err := stream.Send(metaDataToPb(meta)); err != nil {
+			return fmt.Errorf("failed to send result: %w", err)
+		}
+	}
+	return nil
+}
+
+func (l *LibraryServer) ListTypes(ctx context.Context, req *pb.ListTypesRequest) (*pb.ListTypesResponse, error) {
+	return &pb.ListTypesResponse{Types: l.DocumentFactory.GetRegisteredTypes()}, nil
+}
+
+func (l *LibraryServer) ListDewey(ctx context.Context, req *pb.ListDeweyRequest) (*pb.ListDeweyResponse, error) {
+	resp := &pb.ListDeweyResponse{}
+	for _, category := range dao.DeweyCategories {
+		resp.Categories = append(resp.Categories, &pb.DeweyCategory{Code: category.Code, Name: category.Name})
+	}
+	return resp, nil
+}
+
+func (l *LibraryServer) readRecord(ctx context.Context, uuidStr string) (dao.Record, error) {
+	id, err := uuid.Parse(uuidStr)
+	if err != nil {
+		return dao.Record{}, status.Errorf(codes.InvalidArgument, "invalid UUID: %v", err)
+	}
+
+	rawData, err := l.DaoService.ReadRaw(ctx, id)
+	if err != nil {
+		return dao.Record{}, daoStatus(err)
+	}
+
+	var record dao.Record
+	if err := json.Unmarshal(rawData, &record); err != nil {
+		return dao.Record{}, status.Errorf(codes.Internal, "failed to parse document metadata: %v", err)
+	}
+	return record, nil
+}
+
+// grpcUser is the author of a change made over gRPC, sent as "x-user" metadata like the
+// REST X-User header.
+func grpcUser(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(userHeader); len(values) > 0 {
+			return changeUser(values[0])
+		}
+	}
+	return changeUser("")
+}
+
+// buildDocument decodes the type specific fields onto doc, normalizes and sets its metadata
+// and validates it, following the same rules as the REST handlers.
+func buildDocument(doc dao.Document, meta dao.MetaData, schema dao.TypeSchema, fields []byte) error {
+	if err := dao.NormalizeMetaData(&meta, schema); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(fields) > 0 {
+		if err := json.Unmarshal(fields, doc); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid fields: %v", err)
+		}
+	}
+	if err := doc.SetMetaData(meta); err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to set metadata: %v", err)
+	}
+	if err := dao.ValidateDocument(doc); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+func documentToPb(doc dao.Document) (*pb.DocumentResponse, error) {
+	record, err := dao.NewRecord(doc)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.DocumentResponse{Metadata: metaDataToPb(record.MetaData), Fields: record.Payload}, nil
+}
+
+// maps DAO errors onto gRPC status codes.
+func daoStatus(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	if errors.Is(err, dao.ErrDocumentNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, dao.ErrDocumentTrashed) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, dao.ErrUnsupported) {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func metaDataToPb(meta dao.MetaData) *pb.MetaData {
+	return &pb.MetaData{
+		Title:        meta.Title,
+		Author:       meta.Author,
+		PublishDate:  meta.PublishDate,
+		CreatedAt:    meta.CreatedAt,
+		LastUpdated:  meta.LastUpdated,
+		FileType:     meta.FileType,
+		DocType:      meta.DocType,
+		DeweyDecimal: meta.DeweyDecimal,
+		Path:         meta.Path,
+		Uuid:         meta.Uuid,
+		Size:         meta.Size,
+		Sha256:       meta.Hash,
+		Authors:      meta.Authors,
+		Tags:         meta.Tags,
+		Isbn:         meta.ISBN,
+		Doi:          meta.DOI,
+		Publisher:    meta.Publisher,
+		Edition:      meta.Edition,
+		Language:     meta.Language,
+		PageCount:    int32(meta.PageCount),
+		Description:  meta.Description,
+		Custom:       meta.Custom,
+	}
+}
+
+// metaDataFromPb leaves out the timestamps, Size and Hash, which are only ever set by the server
+func metaDataFromPb(meta *pb.MetaData) dao.MetaData {
+	if meta == nil {
+		return dao.MetaData{}
+	}
+	return dao.MetaData{
+		Title:        meta.Title,
+		Author:       meta.Author,
+		PublishDate:  meta.PublishDate,
+		FileType:     meta.FileType,
+		DocType:      meta.DocType,
+		DeweyDecimal: meta.DeweyDecimal,
+		Path:         meta.Path,
+		Uuid:         meta.Uuid,
+		Authors:      meta.Authors,
+		Tags:         meta.Tags,
+		ISBN:         meta.Isbn,
+		DOI:          meta.Doi,
+		Publisher:    meta.Publisher,
+		Edition:      meta.Edition,
+		Language:     meta.Language,
+		PageCount:    int(meta.PageCount),
+		Description:  meta.Description,
+		Custom:       meta.Custom,
+	}
+}
+
 //---------------------------------------------------
 //-------------------DAO-SERVICE---------------------
 //---------------------------------------------------
@@ -154,6 +780,9 @@ func (s FileHandlerService) DownloadFile(req *pb.FileRequest, stream grpc.Server
 // underlying DAO type.
 type DaoService struct {
 	dao dao.DAO
+	// Timeout is the deadline on each database operation, 0 leaving it to the caller's
+	// context. Checking, reconciling and purging run through many records and go without.
+	Timeout time.Duration
 }
 
 func (ds DaoService) New(d any) (Service, error) {
@@ -166,49 +795,481 @@ func (ds DaoService) New(d any) (Service, error) {
 	return daos, nil
 }
 
-func (ds *DaoService) SearchByKeyValue(key, value string) ([]dao.MetaData, error) {
+// withTimeout bounds ctx by Timeout, when one is set
+func (ds *DaoService) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ds.Timeout > 0 {
+		return context.WithTimeout(ctx, ds.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (ds *DaoService) SearchByKeyValue(ctx context.Context, key, value string) ([]dao.MetaData, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
 	if key == "" && value == "" {
-		docs, err := ds.dao.GetAll()
+		docs, err := ds.dao.GetAll(ctx)
 		if err != nil {
 			return nil, err
 		}
 		return docs, nil
 	}
-	docs, err := ds.dao.SearchByKeyValue(key, value)
+	docs, err := ds.dao.SearchByKeyValue(ctx, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Search runs a fuzzy search when query is set, otherwise an exact key/value search
+// (or everything, when both are empty).
+func (ds *DaoService) Search(ctx context.Context, query, key, value string) ([]dao.MetaData, error) {
+	if query != "" {
+		return ds.FuzzySearch(ctx, query)
+	}
+	return ds.SearchByKeyValue(ctx, key, value)
+}
+
+// searchLibrary is Search for imports and exports, which work on the whole library. Bolt
+// has no documents bucket until the first create, so an empty library is no matches rather
+// than an error.
+func (ds *DaoService) searchLibrary(ctx context.Context, query, key, value string) ([]dao.MetaData, error) {
+	stats, err := ds.Stats(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if stats.Documents == 0 {
+		return nil, nil
+	}
+	return ds.Search(ctx, query, key, value)
+}
+
+// Recent returns every document newest first, by LastUpdated when modified is set and
+// CreatedAt otherwise. Records without the timestamp sort last.
+func (ds *DaoService) Recent(ctx context.Context, modified bool) ([]dao.MetaData, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	docs, err := ds.dao.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stamp := func(meta dao.MetaData) time.Time {
+		value := meta.CreatedAt
+		if modified {
+			value = meta.LastUpdated
+		}
+		t, _ := time.Parse(time.RFC3339Nano, value)
+		return t
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return stamp(docs[i]).After(stamp(docs[j]))
+	})
 	return docs, nil
 }
 
-func (ds *DaoService) FuzzySearch(query string) ([]dao.MetaData, error) {
-	return ds.dao.FuzzySearch(query)
+func (ds *DaoService) FuzzySearch(ctx context.Context, query string) ([]dao.MetaData, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.FuzzySearch(ctx, query)
+}
+
+// capability is the DAO as one of its optional capabilities, or dao.ErrUnsupported naming
+// what needed it when the backend doesn't have it.
+func capability[T any](ds *DaoService, what string) (T, error) {
+	c, ok := ds.dao.(T)
+	if !ok {
+		return c, fmt.Errorf("%s: %w", what, dao.ErrUnsupported)
+	}
+	return c, nil
+}
+
+// HiddenRecords is how many corrupt documents are quarantined, and so left out of every
+// search, those a search has just skipped included. Trashed records aren't searched, so
+// they don't count. It's only a warning, so a failure to count is logged and reported as
+// none, as it is for a backend without a quarantine.
+func (ds *DaoService) HiddenRecords(ctx context.Context) int {
+	quarantine, ok := ds.dao.(dao.QuarantineStore)
+	if !ok {
+		return 0
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	count, err := quarantine.QuarantineCount(ctx, dao.RecordsCurrent)
+	if err != nil {
+		log.Printf("failed to count quarantined records: %v", err)
+		return 0
+	}
+	return count
+}
+
+func (ds *DaoService) Quarantined(ctx context.Context) ([]dao.QuarantinedRecord, error) {
+	quarantine, err := capability[dao.QuarantineStore](ds, "quarantine")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return quarantine.Quarantined(ctx)
 }
 
-func (ds *DaoService) Connect(params dao.ConnectParams) error {
-	return ds.dao.Connect(params)
+func (ds *DaoService) Stats(ctx context.Context) (dao.Stats, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.Stats(ctx)
+}
+
+func (ds *DaoService) Snapshot(ctx context.Context, w io.Writer) (int64, error) {
+	snapshotter, err := capability[dao.Snapshotter](ds, "snapshot")
+	if err != nil {
+		return 0, err
+	}
+	return snapshotter.Snapshot(ctx, w)
 }
 
 func (ds *DaoService) Disconnect() error {
 	return ds.dao.Disconnect()
 }
 
-func (ds *DaoService) Create(doc dao.Document) error {
-	return ds.dao.Create(doc)
+// Create stores a new document, recording user as the author of its first revision.
+func (ds *DaoService) Create(ctx context.Context, doc dao.Document, user string) error {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.Save(ctx, doc, dao.Change{User: user, Action: dao.ActionCreate})
 }
 
 // basically defunct until I can somehow wrangle this to work
-func (ds *DaoService) Read(doc *dao.Document, uuid uuid.UUID) (dao.Document, error) {
-	return ds.dao.Read(doc, uuid)
+func (ds *DaoService) Read(ctx context.Context, doc *dao.Document, uuid uuid.UUID) (dao.Document, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.Read(ctx, doc, uuid)
+}
+
+func (ds *DaoService) ReadRaw(ctx context.Context, uuid uuid.UUID) ([]byte, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.ReadRaw(ctx, uuid)
+}
+func (ds *DaoService) Update(ctx context.Context, doc dao.Document, user string) error {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.Save(ctx, doc, dao.Change{User: user, Action: dao.ActionUpdate})
+}
+
+func (ds *DaoService) History(ctx context.Context, id uuid.UUID) ([]dao.Revision, error) {
+	history, err := capability[dao.HistoryStore](ds, "history")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return history.History(ctx, id)
+}
+
+func (ds *DaoService) Restore(ctx context.Context, id uuid.UUID, revision int, user string) (dao.Record, error) {
+	history, err := capability[dao.HistoryStore](ds, "history")
+	if err != nil {
+		return dao.Record{}, err
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return history.Restore(ctx, id, revision, user)
+}
+
+// BulkUpdate applies edit to every document in ids in one transaction, see dao.BulkUpdate.
+func (ds *DaoService) BulkUpdate(ctx context.Context, ids []uuid.UUID, edit dao.BulkEdit, user string, dryRun bool) ([]dao.BulkResult, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.BulkUpdate(ctx, ids, edit, dao.Change{User: user, Action: dao.ActionUpdate}, dryRun)
+}
+
+// Patch applies edit to one document, see dao.BulkUpdate. Its result carries the
+// document's own failure, if any.
+func (ds *DaoService) Patch(ctx context.Context, id uuid.UUID, edit dao.BulkEdit, user string) (dao.BulkResult, error) {
+	results, err := ds.BulkUpdate(ctx, []uuid.UUID{id}, edit, user, false)
+	if err != nil {
+		return dao.BulkResult{}, err
+	}
+	return results[0], nil
+}
+
+func (ds *DaoService) ReplaceFile(ctx context.Context, id uuid.UUID, file dao.FileVersion, user string) (dao.Record, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.ReplaceFile(ctx, id, file, user)
+}
+
+// PruneFiles applies the file retention policy to a document, removing the pruned
+// versions from storage. Failed deletes are logged and stay journaled, the versions are
+// pruned either way. A backend without history keeps no old versions, so there's nothing to do.
+func (ds *DaoService) PruneFiles(ctx context.Context, id uuid.UUID, keep int, files fao.FAO) error {
+	history, ok := ds.dao.(dao.HistoryStore)
+	if !ok {
+		return nil
+	}
+	dctx, cancel := ds.withTimeout(ctx)
+	paths, err := history.PruneFiles(dctx, id, keep)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if err := ds.deleteFiles(ctx, paths, files); err != nil {
+		log.Printf("failed to delete pruned files of %s: %v", id, err)
+	}
+	return nil
+}
+
+func (ds *DaoService) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return ds.dao.Delete(ctx, id)
+}
+
+// FileInUse reports whether a record, an unpruned file version or a pending file operation
+// refers to path. Without a file journal only the current records are looked at.
+func (ds *DaoService) FileInUse(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	journal, ok := ds.dao.(dao.FileJournal)
+	if !ok {
+		docs, err := ds.dao.GetAll(ctx)
+		if err != nil {
+			return false, err
+		}
+		return slices.ContainsFunc(docs, func(doc dao.MetaData) bool { return doc.Path == path }), nil
+	}
+	referenced, err := journal.ReferencedFiles(ctx)
+	if err != nil {
+		return false, err
+	}
+	if referenced[path] {
+		return true, nil
+	}
+	ops, err := journal.PendingFileOps(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(ops, func(op dao.FileOp) bool { return op.Path == path }), nil
+}
+
+// FileWrite is the unit of work for storing a file that a record written afterwards will
+// point at. The file is journaled before it's stored, so when the record never lands the
+// file is removed, by Abort or, after a crash, by ReconcileFiles. A backend without a file
+// journal leaves only Abort to clean up.
+type FileWrite struct {
+	ds    *DaoService
+	files fao.FAO
+	path  string
+}
+
+// BeginFileWrite journals a file about to be stored at path for document id.
+func (ds *DaoService) BeginFileWrite(ctx context.Context, id uuid.UUID, path string, files fao.FAO) (*FileWrite, error) {
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	if journal, ok := ds.dao.(dao.FileJournal); ok {
+		op := dao.FileOp{Path: path, Action: dao.FileOpStore, Uuid: id.String()}
+		if err := journal.JournalFileOp(ctx, op); err != nil {
+			return nil, err
+		}
+	}
+	return &FileWrite{ds: ds, files: files, path: path}, nil
+}
+
+// Commit clears the journal entry once the record pointing at the file has been written.
+// If that fails the entry is left, and reconciling finds the file referenced and keeps it.
+func (w *FileWrite) Commit(ctx context.Context) {
+	journal, ok := w.ds.dao.(dao.FileJournal)
+	if !ok {
+		return
+	}
+	ctx, cancel := w.ds.withTimeout(ctx)
+	defer cancel()
+	if err := journal.CompleteFileOp(ctx, w.path); err != nil {
+		log.Printf("failed to clear journaled write of %s: %v", w.path, err)
+	}
+}
+
+// Abort removes the stored file, or whatever part of it was written, and clears the entry.
+// It goes ahead when ctx is cancelled, as that's often why the write is being aborted.
+func (w *FileWrite) Abort(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	if err := w.ds.deleteFiles(ctx, []string{w.path}, w.files); err != nil {
+		log.Printf("failed to remove unused upload %s: %v", w.path, err)
+	}
+}
+
+// deleteFiles removes journaled files from storage, clearing each entry once its file is
+// gone. A file that's already missing counts as deleted.
+func (ds *DaoService) deleteFiles(ctx context.Context, paths []string, files fao.FAO) error {
+	journal, journaled := ds.dao.(dao.FileJournal)
+	var errs error
+	for _, path := range paths {
+		if err := files.DeleteFile(ctx, path); err != nil && !fileMissing(err) {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if !journaled {
+			continue
+		}
+		if err := journal.CompleteFileOp(ctx, path); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// fileMissing reports whether a delete failed because the file isn't there, from the local
+// FAO or through the file service.
+func fileMissing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || status.Code(err) == codes.NotFound
+}
+
+// ReconcileReport is what ReconcileFiles did with each journaled operation.
+type ReconcileReport struct {
+	// Kept are stored files a record turned out to point at
+	Kept []string
+	// Removed are files deleted from storage, or found already gone
+	Removed []string
+	// Failed are files that couldn't be deleted, they stay journaled
+	Failed []string
 }
 
-func (ds *DaoService) ReadRaw(uuid uuid.UUID) ([]byte, error) {
-	return ds.dao.ReadRaw(uuid)
+// ReconcileFiles brings storage in line with the database after a crash, by finishing every
+// journaled operation: a stored file is kept when a record points at it and removed when
+// none does, a file queued for deletion is deleted. Without a file journal there's nothing
+// to finish.
+func (ds *DaoService) ReconcileFiles(ctx context.Context, files fao.FAO) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	journal, ok := ds.dao.(dao.FileJournal)
+	if !ok {
+		return report, nil
+	}
+	ops, err := journal.PendingFileOps(ctx)
+	if err != nil {
+		return report, err
+	}
+	if len(ops) == 0 {
+		return report, nil
+	}
+	referenced, err := journal.ReferencedFiles(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, op := range ops {
+		// whatever the operation, a file a record points at is the database's to keep
+		if referenced[op.Path] {
+			if err := journal.CompleteFileOp(ctx, op.Path); err != nil {
+				return report, err
+			}
+			report.Kept = append(report.Kept, op.Path)
+			continue
+		}
+
+		if err := ds.deleteFiles(ctx, []string{op.Path}, files); err != nil {
+			log.Printf("failed to remove %s while reconciling storage: %v", op.Path, err)
+			report.Failed = append(report.Failed, op.Path)
+			continue
+		}
+		report.Removed = append(report.Removed, op.Path)
+	}
+	return report, nil
+}
+
+// Trash moves a document to the trash, recording user as the one who deleted it.
+func (ds *DaoService) Trash(ctx context.Context, id uuid.UUID, user string) (dao.TrashedRecord, error) {
+	trash, err := capability[dao.TrashStore](ds, "trash")
+	if err != nil {
+		return dao.TrashedRecord{}, err
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return trash.Trash(ctx, id, user)
+}
+
+func (ds *DaoService) Untrash(ctx context.Context, id uuid.UUID, user string) (dao.Record, error) {
+	trash, err := capability[dao.TrashStore](ds, "trash")
+	if err != nil {
+		return dao.Record{}, err
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	return trash.Untrash(ctx, id, user)
+}
+
+// Trashed returns the documents in the trash, most recently deleted first.
+func (ds *DaoService) Trashed(ctx context.Context) ([]dao.TrashedRecord, error) {
+	trash, err := capability[dao.TrashStore](ds, "trash")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := ds.withTimeout(ctx)
+	defer cancel()
+	trashed, err := trash.Trashed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt > trashed[j].DeletedAt
+	})
+	return trashed, nil
 }
-func (ds *DaoService) Update(doc dao.Document) error {
-	return ds.dao.Update(doc)
+
+// PurgeResult is what became of a purged document's files. FileErr is a failed delete, the
+// file stays journaled and is retried when the journal is next reconciled.
+type PurgeResult struct {
+	FileErr error
 }
 
-func (ds *DaoService) Delete(id uuid.UUID) error {
-	return ds.dao.Delete(id)
+// Purge permanently removes a trashed document. The record and its history go first, in the
+// transaction that journals every stored version of its file for deletion, then the files are
+// removed. err is only set when the document wasn't purged.
+func (ds *DaoService) Purge(ctx context.Context, id uuid.UUID, files fao.FAO) (PurgeResult, error) {
+	trash, err := capability[dao.TrashStore](ds, "trash")
+	if err != nil {
+		return PurgeResult{}, err
+	}
+	dctx, cancel := ds.withTimeout(ctx)
+	paths, err := trash.Purge(dctx, id)
+	cancel()
+	if err != nil {
+		return PurgeResult{}, err
+	}
+	return PurgeResult{FileErr: ds.deleteFiles(ctx, paths, files)}, nil
+}
+
+// PurgeExpired purges every document that has been in the trash for longer than maxAge,
+// returning how many were purged. Failed file deletes are logged.
+func (ds *DaoService) PurgeExpired(ctx context.Context, maxAge time.Duration, files fao.FAO) (int, error) {
+	trash, err := capability[dao.TrashStore](ds, "trash")
+	if err != nil {
+		return 0, err
+	}
+	trashed, err := trash.Trashed(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	purged := 0
+	for _, record := range trashed {
+		deletedAt, err := time.Parse(time.RFC3339Nano, record.DeletedAt)
+		if err != nil || deletedAt.After(cutoff) {
+			continue
+		}
+		id, err := uuid.Parse(record.Uuid)
+		if err != nil {
+			continue
+		}
+
+		result, err := ds.Purge(ctx, id, files)
+		if result.FileErr != nil {
+			log.Printf("failed to delete files of purged document %s: %v", id, result.FileErr)
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", id, err)
+		}
+		purged++
+	}
+	return purged, nil
 }
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"scriptorium/internal/backend/dao"
+	"scriptorium/internal/backend/fao"
+	"scriptorium/internal/backend/service/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func setupTestGrpc(t *testing.T) (*grpc.ClientConn, func()) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: filepath.Join(tmpDir, "test.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("failed to connect to test db: %v", err)
+	}
+	storage := fao.NewLocalFao(tmpDir)
+
+	docFactory := dao.NewDocumentFactory()
+	docFactory.RegisterDocumentType("Notes", func() dao.Document { return &dao.Notes{} })
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pb.RegisterFileServiceServer(server, FileHandlerService{fao: storage})
+	pb.RegisterLibraryServiceServer(server, NewLibraryServer(DaoService{dao: d}, docFactory, storage))
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial test gRPC server: %v", err)
+	}
+
+	cleanup := func() {
+		conn.Close()
+		server.Stop()
+		d.Disconnect()
+	}
+
+	return conn, cleanup
+}
+
+func setupTestFileService(t *testing.T) (pb.FileServiceClient, func()) {
+	t.Helper()
+	conn, cleanup := setupTestGrpc(t)
+	return pb.NewFileServiceClient(conn), cleanup
+}
+
+func TestFileServiceFaoRoundTrip(t *testing.T) {
+	client, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	remote := NewFileServiceFao(client)
+	content := bytes.Repeat([]byte("scriptorium "), 1000)
+
+	if err := remote.SaveFile(context.Background(), "book.txt", bytes.NewReader(content)); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	if !remote.FileExists(context.Background(), "book.txt") {
+		t.Fatal("expected file to exist after save")
+	}
+
+	file, err := remote.GetFile(context.Background(), "book.txt")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	got, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded content differs: got %d bytes, want %d", len(got), len(content))
+	}
+
+	info, err := remote.StatFile(context.Background(), "book.txt")
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if info.Size != int64(len(content)) || info.Hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected stat result: %+v", info)
+	}
+
+	files, err := remote.ListFiles(context.Background(), "book")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "book.txt" || files[0].Hash != "" {
+		t.Fatalf("expected [book.txt] without a hash, got %+v", files)
+	}
+	if files, err := remote.ListFilesWithHash(context.Background(), "book"); err != nil || len(files) != 1 || files[0].Hash != info.Hash {
+		t.Fatalf("expected [book.txt] with its hash, got %+v, %v", files, err)
+	}
+
+	if err := remote.DeleteFile(context.Background(), "book.txt"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if remote.FileExists(context.Background(), "book.txt") {
+		t.Fatal("expected file to be gone after delete")
+	}
+}
+
+func TestFileServiceStatMissingFileIsNotFound(t *testing.T) {
+	client, cleanup := setupTestFileService(t)
+	defer cleanup()
+
+	_, err := client.StatFile(context.Background(), &pb.FileRequest{Filename: "missing.pdf"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	if _, err := NewFileServiceFao(client).GetFile(context.Background(), "missing.pdf"); err == nil {
+		t.Fatal("expected error opening a missing file")
+	}
+}
+
+func TestLibraryServiceCrudAndSearch(t *testing.T) {
+	conn, cleanup := setupTestGrpc(t)
+	defer cleanup()
+
+	client := pb.NewLibraryServiceClient(conn)
+	ctx := context.Background()
+
+	created, err := client.Create(ctx, &pb.CreateDocumentRequest{Metadata: &pb.MetaData{DocType: "Notes", Title: "Grpc Doc", Author: "Knuth"}})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	id := created.Metadata.Uuid
+	if id == "" {
+		t.Fatal("expected a UUID to be assigned")
+	}
+
+	got, err := client.Get(ctx, &pb.GetDocumentRequest{Uuid: id})
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Metadata.Title != "Grpc Doc" || got.Metadata.Author != "Knuth" {
+		t.Fatalf("unexpected metadata: %+v", got.Metadata)
+	}
+
+	if _, err := client.Update(ctx, &pb.UpdateDocumentRequest{Metadata: &pb.MetaData{Uuid: id, DocType: "Notes", Title: "Renamed"}}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	search, err := client.Search(ctx, &pb.SearchRequest{Query: "renamed"})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if search.TotalCount != 1 || search.Results[0].Uuid != id {
+		t.Fatalf("expected the renamed document, got %+v", search)
+	}
+
+	stream, err := client.StreamSearch(ctx, &pb.SearchRequest{Key: "DocType", Value: "Notes"})
+	if err != nil {
+		t.Fatalf("stream search failed: %v", err)
+	}
+	var streamed int
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream recv failed: %v", err)
+		}
+		streamed++
+	}
+	if streamed != 1 {
+		t.Fatalf("expected 1 streamed result, got %d", streamed)
+	}
+
+	if _, err := client.Delete(ctx, &pb.DeleteDocumentRequest{Uuid: id}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := client.Get(ctx, &pb.GetDocumentRequest{Uuid: id}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound after delete, got %v", err)
+	}
+}
+
+func TestLibraryServiceRejectsUnknownDocType(t *testing.T) {
+	conn, cleanup := setupTestGrpc(t)
+	defer cleanup()
+
+	_, err := pb.NewLibraryServiceClient(conn).Create(context.Background(), &pb.CreateDocumentRequest{Metadata: &pb.MetaData{DocType: "Scroll"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestLibraryServiceCreateClaimsUpload(t *testing.T) {
+	conn, cleanup := setupTestGrpc(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	files := NewFileServiceFao(pb.NewFileServiceClient(conn))
+	client := pb.NewLibraryServiceClient(conn)
+	content := []byte("an uploaded paper")
+	if err := files.SaveFile(ctx, "upload.pdf", bytes.NewReader(content)); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// the file fields sent are ignored, they're worked out from the upload
+	created, err := client.Create(ctx, &pb.CreateDocumentRequest{Metadata: &pb.MetaData{
+		DocType: "Notes", Title: "Claimed", Path: "upload.pdf", FileType: ".txt", Size: 1, Sha256: "forged"}})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	meta := created.Metadata
+	sum := sha256.Sum256(content)
+	if meta.Path == "upload.pdf" || meta.FileType != ".pdf" || meta.Size != int64(len(content)) || meta.Sha256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected the upload copied to a path of the document's own, got %+v", meta)
+	}
+	if !files.FileExists(ctx, meta.Path) || files.FileExists(ctx, "upload.pdf") {
+		t.Fatal("expected the upload moved to the document's path")
+	}
+
+	// an upload is claimed once, and another document's file is never an upload
+	for _, path := range []string{"upload.pdf", meta.Path} {
+		_, err := client.Create(ctx, &pb.CreateDocumentRequest{Metadata: &pb.MetaData{DocType: "Notes", Title: "Thief", Path: path}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument creating a document with %s, got %v", path, err)
+		}
+	}
+}
+
+func TestReconcileFilesAfterCrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	d, err := dao.NewBoltDao(dao.BoltOptions{Path: filepath.Join(tmpDir, "test.db"), Mode: 0600})
+	if err != nil {
+		t.Fatalf("failed to connect to test db: %v", err)
+	}
+	defer d.Disconnect()
+	storage := fao.NewLocalFao(tmpDir)
+	daos := DaoService{dao: d}
+
+	// an upload whose record was written before the crash, and one whose record never was
+	for _, path := range []string{"landed.txt", "orphan.txt"} {
+		if _, err := daos.BeginFileWrite(context.Background(), uuid.New(), path, storage); err != nil {
+			t.Fatalf("failed to journal write: %v", err)
+		}
+		storage.SaveFile(context.Background(), path, strings.NewReader(path))
+	}
+	doc := &dao.Notes{}
+	doc.SetMetaData(dao.MetaData{Title: "Landed", DocType: "Notes", Uuid: uuid.NewString(), Path: "landed.txt"})
+	if err := daos.Create(context.Background(), doc, "ada"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	// a delete journaled with a purge, whose file was never removed
+	d.JournalFileOp(context.Background(), dao.FileOp{Path: "purged.txt", Action: dao.FileOpDelete})
+	storage.SaveFile(context.Background(), "purged.txt", strings.NewReader("purged"))
+	// and one whose file had already gone
+	d.JournalFileOp(context.Background(), dao.FileOp{Path: "gone.txt", Action: dao.FileOpDelete})
+
+	report, err := daos.ReconcileFiles(context.Background(), storage)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	sort.Strings(report.Removed)
+	if !reflect.DeepEqual(report.Kept, []string{"landed.txt"}) || !reflect.DeepEqual(report.Removed, []string{"gone.txt", "orphan.txt", "purged.txt"}) || len(report.Failed) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	if !storage.FileExists(context.Background(), "landed.txt") || storage.FileExists(context.Background(), "orphan.txt") || storage.FileExists(context.Background(), "purged.txt") {
+		t.Error("expected only the referenced file left in storage")
+	}
+	if ops, _ := d.PendingFileOps(context.Background()); len(ops) != 0 {
+		t.Errorf("expected an empty journal, got %+v", ops)
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//---------------------------------------------------
+//---------------------ROUTE-DOCS--------------------
+//---------------------------------------------------
+
+// RouteDoc describes a single route for the OpenAPI document. Request and Response are
+// zero values of the JSON body types, their schemas are derived by reflection.
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Query       []ParamDoc
+	// Request is the JSON body, nil when the route takes none
+	Request any
+	// Form describes a multipart/form-data body, field name to schema
+	Form map[string]any
+	// Status is the success status, defaulting to 200
+	Status   int
+	Response any
+	// Binary is the content type of a raw (non JSON) success body
+	Binary string
+	Errors []int
+}
+
+type ParamDoc struct {
+	Name        string
+	Description string
+	Type        string
+}
+
+// DocumentedHandler is implemented by handlers that describe their routes, keyed the same
+// way as GetRouterGroups ("METHOD /path").
+type DocumentedHandler interface {
+	Handler
+	GetRouteDocs() map[string]RouteDoc
+}
+
+//---------------------------------------------------
+//--------------------GENERATOR----------------------
+//---------------------------------------------------
+
+var ginParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// BuildOpenAPI generates an OpenAPI 3 document for the /v1 routes of the given handlers.
+// Routes without a RouteDoc are left out, the spec test relies on that to catch them.
+func BuildOpenAPI(handlers ...Handler) map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+
+	for _, handler := range handlers {
+		group, routes := handler.GetRouterGroups()
+		var docs map[string]RouteDoc
+		if documented, ok := handler.(DocumentedHandler); ok {
+			docs = documented.GetRouteDocs()
+		}
+
+		for route := range routes {
+			doc, ok := docs[route]
+			if !ok {
+				log.Printf("openapi: route %s%s has no documentation", group, route)
+				continue
+			}
+			method, endpoint, found := strings.Cut(route, " ")
+			if !found {
+				continue
+			}
+
+			path := "/v1" + group + ginParam.ReplaceAllString(endpoint, "{$1}")
+			if paths[path] == nil {
+				paths[path] = map[string]any{}
+			}
+			paths[path][strings.ToLower(method)] = buildOperation(doc, endpoint, schemas)
+		}
+	}
+
+	schemas["ErrorResponse"] = schemaFor(reflect.TypeOf(ErrorResponse{}), schemas)
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Scriptorium API",
+			"version":     "1",
+			"description": "Document library REST API. Generated from the registered routes.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func buildOperation(doc RouteDoc, endpoint string, schemas map[string]any) map[string]any {
+	op := map[string]any{"summary": doc.Summary}
+	if doc.Description != "" {
+		op["description"] = doc.Description
+	}
+
+	var params []any
+	for _, match := range ginParam.FindAllStringSubmatch(endpoint, -1) {
+		params = append(params, map[string]any{
+			"name": match[1], "in": "path", "required": true,
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, q := range doc.Query {
+		paramType := q.Type
+		if paramType == "" {
+			paramType = "string"
+		}
+		params = append(params, map[string]any{
+			"name": q.Name, "in": "query", "description": q.Description,
+			"schema": map[string]any{"type": paramType},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case doc.Request != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(doc.Request), schemas)},
+			},
+		}
+	case doc.Form != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"multipart/form-data": map[string]any{"schema": map[string]any{"type": "object", "properties": doc.Form}},
+			},
+		}
+	}
+
+	successStatus := doc.Status
+	if successStatus == 0 {
+		successStatus = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(successStatus)}
+	switch {
+	case doc.Binary != "":
+		success["content"] = map[string]any{
+			doc.Binary: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+		}
+	case doc.Response != nil:
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(doc.Response), schemas)},
+		}
+	}
+
+	responses := map[string]any{strconv.Itoa(successStatus): success}
+	for _, code := range doc.Errors {
+		responses[strconv.Itoa(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"}},
+			},
+		}
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+// schemaFor derives a JSON schema from a Go type, following json tags. Named structs are
+// registered under components/schemas and referenced.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, seen := schemas[name]; seen {
+			return ref
+		}
+		schemas[name] = map[string]any{} // placeholder, guards against recursive types
+
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			// encoding/json flattens embedded structs into the outer object, so the schema does too
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				schemaFor(field.Type, schemas)
+				embedded, _ := schemas[field.Type.Name()].(map[string]any)
+				if props, ok := embedded["properties"].(map[string]any); ok {
+					for name, prop := range props {
+						properties[name] = prop
+					}
+				}
+				if req, ok := embedded["required"].([]string); ok {
+					required = append(required, req...)
+				}
+				continue
+			}
+			jsonName, omitempty := jsonFieldName(field)
+			if jsonName == "-" {
+				continue
+			}
+			properties[jsonName] = schemaFor(field.Type, schemas)
+			if !omitempty {
+				required = append(required, jsonName)
+			}
+		}
+		sort.Strings(required)
+
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[name] = schema
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(opts, "omitempty")
+}
+
+//---------------------------------------------------
+//---------------------SERVING-----------------------
+//---------------------------------------------------
+
+//go:embed docs.html
+var docsPage []byte
+
+// registerDocs serves the generated spec at /openapi.json and a browsable page at /docs.
+// the spec is built once, the routes can't change after start up.
+func registerDocs(r *gin.Engine, handlers ...Handler) {
+	spec := BuildOpenAPI(handlers...)
+
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+	})
+}
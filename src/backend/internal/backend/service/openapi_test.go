@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"scriptorium/internal/backend/dao"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testHandlers() []Handler {
+	api := NewAPIHandler(DaoService{}, dao.NewDocumentFactory(), nil)
+	return []Handler{api, &FileHandler{APIHandler: api}, NewAdminHandler(DaoService{}, nil, "")}
+}
+
+// every route gin ends up serving under /v1 must be described by the generated spec
+func TestOpenAPICoversEveryRegisteredRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := testHandlers()
+
+	r := gin.New()
+	if err := registerRoutes(r, false, handlers...); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+
+	spec := BuildOpenAPI(handlers...)
+	paths := spec["paths"].(map[string]map[string]any)
+
+	for _, route := range r.Routes() {
+		if !strings.HasPrefix(route.Path, "/v1/") {
+			continue
+		}
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		op, ok := paths[path][strings.ToLower(route.Method)].(map[string]any)
+		if !ok {
+			t.Errorf("%s %s is registered but missing from the OpenAPI spec", route.Method, route.Path)
+			continue
+		}
+		if op["summary"] == "" {
+			t.Errorf("%s %s has no summary", route.Method, route.Path)
+		}
+	}
+}
+
+func TestOpenAPISchemasFollowJSONTags(t *testing.T) {
+	spec := BuildOpenAPI(testHandlers()...)
+
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	document, ok := schemas["DocumentJSON"].(map[string]any)
+	if !ok {
+		t.Fatal("expected DocumentJSON to be registered as a component schema")
+	}
+	if _, ok := document["properties"].(map[string]any)["dewey_decimal"]; !ok {
+		t.Fatalf("expected json tag names in DocumentJSON schema, got %v", document["properties"])
+	}
+
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatalf("spec does not marshal: %v", err)
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerDocs(r, testHandlers()...)
+
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 from %s, got %d", path, w.Code)
+		}
+	}
+}
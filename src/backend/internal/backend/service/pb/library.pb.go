@@ -0,0 +1,1091 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v4.25.2
+// source: internal/backend/service/pb/library.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MetaData struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Title       string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Author      string                 `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`
+	PublishDate string                 `protobuf:"bytes,3,opt,name=publish_date,json=publishDate,proto3" json:"publish_date,omitempty"`
+	LastUpdated string                 `protobuf:"bytes,4,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	// set by the server like size and sha256, ignored in requests
+	FileType     string `protobuf:"bytes,5,opt,name=file_type,json=fileType,proto3" json:"file_type,omitempty"`
+	DocType      string `protobuf:"bytes,6,opt,name=doc_type,json=docType,proto3" json:"doc_type,omitempty"`
+	DeweyDecimal string `protobuf:"bytes,7,opt,name=dewey_decimal,json=deweyDecimal,proto3" json:"dewey_decimal,omitempty"`
+	// in a CreateDocumentRequest, the file_id FileService.UploadFile returned. The upload is
+	// moved to a path of the document's own, other requests ignore it
+	Path        string            `protobuf:"bytes,8,opt,name=path,proto3" json:"path,omitempty"`
+	Uuid        string            `protobuf:"bytes,9,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Authors     []string          `protobuf:"bytes,10,rep,name=authors,proto3" json:"authors,omitempty"`
+	Tags        []string          `protobuf:"bytes,11,rep,name=tags,proto3" json:"tags,omitempty"`
+	Isbn        string            `protobuf:"bytes,12,opt,name=isbn,proto3" json:"isbn,omitempty"`
+	Doi         string            `protobuf:"bytes,13,opt,name=doi,proto3" json:"doi,omitempty"`
+	Publisher   string            `protobuf:"bytes,14,opt,name=publisher,proto3" json:"publisher,omitempty"`
+	Edition     string            `protobuf:"bytes,15,opt,name=edition,proto3" json:"edition,omitempty"`
+	Language    string            `protobuf:"bytes,16,opt,name=language,proto3" json:"language,omitempty"`
+	PageCount   int32             `protobuf:"varint,17,opt,name=page_count,json=pageCount,proto3" json:"page_count,omitempty"`
+	Description string            `protobuf:"bytes,18,opt,name=description,proto3" json:"description,omitempty"`
+	Custom      map[string]string `protobuf:"bytes,19,rep,name=custom,proto3" json:"custom,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// set by the server, ignored in requests
+	CreatedAt     string `protobuf:"bytes,20,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Size          int64  `protobuf:"varint,21,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        string `protobuf:"bytes,22,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetaData) Reset() {
+	*x = MetaData{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetaData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetaData) ProtoMessage() {}
+
+func (x *MetaData) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetaData.ProtoReflect.Descriptor instead.
+func (*MetaData) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MetaData) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *MetaData) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *MetaData) GetPublishDate() string {
+	if x != nil {
+		return x.PublishDate
+	}
+	return ""
+}
+
+func (x *MetaData) GetLastUpdated() string {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return ""
+}
+
+func (x *MetaData) GetFileType() string {
+	if x != nil {
+		return x.FileType
+	}
+	return ""
+}
+
+func (x *MetaData) GetDocType() string {
+	if x != nil {
+		return x.DocType
+	}
+	return ""
+}
+
+func (x *MetaData) GetDeweyDecimal() string {
+	if x != nil {
+		return x.DeweyDecimal
+	}
+	return ""
+}
+
+func (x *MetaData) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *MetaData) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *MetaData) GetAuthors() []string {
+	if x != nil {
+		return x.Authors
+	}
+	return nil
+}
+
+func (x *MetaData) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *MetaData) GetIsbn() string {
+	if x != nil {
+		return x.Isbn
+	}
+	return ""
+}
+
+func (x *MetaData) GetDoi() string {
+	if x != nil {
+		return x.Doi
+	}
+	return ""
+}
+
+func (x *MetaData) GetPublisher() string {
+	if x != nil {
+		return x.Publisher
+	}
+	return ""
+}
+
+func (x *MetaData) GetEdition() string {
+	if x != nil {
+		return x.Edition
+	}
+	return ""
+}
+
+func (x *MetaData) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *MetaData) GetPageCount() int32 {
+	if x != nil {
+		return x.PageCount
+	}
+	return 0
+}
+
+func (x *MetaData) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *MetaData) GetCustom() map[string]string {
+	if x != nil {
+		return x.Custom
+	}
+	return nil
+}
+
+func (x *MetaData) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *MetaData) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *MetaData) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type CreateDocumentRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Metadata *MetaData              `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// type specific fields as a JSON object, e.g. {"Journal": "Nature"} for an Article
+	Fields        []byte `protobuf:"bytes,2,opt,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDocumentRequest) Reset() {
+	*x = CreateDocumentRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDocumentRequest) ProtoMessage() {}
+
+func (x *CreateDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDocumentRequest.ProtoReflect.Descriptor instead.
+func (*CreateDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateDocumentRequest) GetMetadata() *MetaData {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CreateDocumentRequest) GetFields() []byte {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type GetDocumentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uuid          string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDocumentRequest) Reset() {
+	*x = GetDocumentRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDocumentRequest) ProtoMessage() {}
+
+func (x *GetDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDocumentRequest.ProtoReflect.Descriptor instead.
+func (*GetDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetDocumentRequest) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+type UpdateDocumentRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Metadata *MetaData              `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// type specific fields as a JSON object, e.g. {"Journal": "Nature"} for an Article
+	Fields        []byte `protobuf:"bytes,2,opt,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDocumentRequest) Reset() {
+	*x = UpdateDocumentRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDocumentRequest) ProtoMessage() {}
+
+func (x *UpdateDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDocumentRequest.ProtoReflect.Descriptor instead.
+func (*UpdateDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateDocumentRequest) GetMetadata() *MetaData {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *UpdateDocumentRequest) GetFields() []byte {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type DocumentResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Metadata *MetaData              `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// type specific fields as a JSON object, e.g. {"Journal": "Nature"} for an Article
+	Fields        []byte `protobuf:"bytes,2,opt,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DocumentResponse) Reset() {
+	*x = DocumentResponse{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DocumentResponse) ProtoMessage() {}
+
+func (x *DocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DocumentResponse.ProtoReflect.Descriptor instead.
+func (*DocumentResponse) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DocumentResponse) GetMetadata() *MetaData {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *DocumentResponse) GetFields() []byte {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type DeleteDocumentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uuid          string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDocumentRequest) Reset() {
+	*x = DeleteDocumentRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDocumentRequest) ProtoMessage() {}
+
+func (x *DeleteDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDocumentRequest.ProtoReflect.Descriptor instead.
+func (*DeleteDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteDocumentRequest) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+type DeleteDocumentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uuid          string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDocumentResponse) Reset() {
+	*x = DeleteDocumentResponse{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDocumentResponse) ProtoMessage() {}
+
+func (x *DeleteDocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDocumentResponse.ProtoReflect.Descriptor instead.
+func (*DeleteDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeleteDocumentResponse) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+// query takes priority over key/value, as with /data/search. page and limit
+// are ignored by StreamSearch.
+type SearchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Key           string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value         string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Page          int32                  `protobuf:"varint,4,opt,name=page,proto3" json:"page,omitempty"`
+	Limit         int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type SearchResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Results    []*MetaData            `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	TotalCount int32                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	Page       int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	Limit      int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	TotalPages int32                  `protobuf:"varint,5,opt,name=total_pages,json=totalPages,proto3" json:"total_pages,omitempty"`
+	HasNext    bool                   `protobuf:"varint,6,opt,name=has_next,json=hasNext,proto3" json:"has_next,omitempty"`
+	HasPrev    bool                   `protobuf:"varint,7,opt,name=has_prev,json=hasPrev,proto3" json:"has_prev,omitempty"`
+	// corrupt records left out of every search until they're fixed, see /v1/admin/quarantine
+	WarningCount  int32 `protobuf:"varint,8,opt,name=warning_count,json=warningCount,proto3" json:"warning_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SearchResponse) GetResults() []*MetaData {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetTotalCount() int32 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetTotalPages() int32 {
+	if x != nil {
+		return x.TotalPages
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetHasNext() bool {
+	if x != nil {
+		return x.HasNext
+	}
+	return false
+}
+
+func (x *SearchResponse) GetHasPrev() bool {
+	if x != nil {
+		return x.HasPrev
+	}
+	return false
+}
+
+func (x *SearchResponse) GetWarningCount() int32 {
+	if x != nil {
+		return x.WarningCount
+	}
+	return 0
+}
+
+type ListTypesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTypesRequest) Reset() {
+	*x = ListTypesRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTypesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTypesRequest) ProtoMessage() {}
+
+func (x *ListTypesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTypesRequest.ProtoReflect.Descriptor instead.
+func (*ListTypesRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{9}
+}
+
+type ListTypesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Types         []string               `protobuf:"bytes,1,rep,name=types,proto3" json:"types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTypesResponse) Reset() {
+	*x = ListTypesResponse{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTypesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTypesResponse) ProtoMessage() {}
+
+func (x *ListTypesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTypesResponse.ProtoReflect.Descriptor instead.
+func (*ListTypesResponse) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListTypesResponse) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+type ListDeweyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDeweyRequest) Reset() {
+	*x = ListDeweyRequest{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDeweyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDeweyRequest) ProtoMessage() {}
+
+func (x *ListDeweyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDeweyRequest.ProtoReflect.Descriptor instead.
+func (*ListDeweyRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{11}
+}
+
+type DeweyCategory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeweyCategory) Reset() {
+	*x = DeweyCategory{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeweyCategory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeweyCategory) ProtoMessage() {}
+
+func (x *DeweyCategory) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeweyCategory.ProtoReflect.Descriptor instead.
+func (*DeweyCategory) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeweyCategory) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *DeweyCategory) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListDeweyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Categories    []*DeweyCategory       `protobuf:"bytes,1,rep,name=categories,proto3" json:"categories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDeweyResponse) Reset() {
+	*x = ListDeweyResponse{}
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDeweyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDeweyResponse) ProtoMessage() {}
+
+func (x *ListDeweyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_service_pb_library_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDeweyResponse.ProtoReflect.Descriptor instead.
+func (*ListDeweyResponse) Descriptor() ([]byte, []int) {
+	return file_internal_backend_service_pb_library_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListDeweyResponse) GetCategories() []*DeweyCategory {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+var File_internal_backend_service_pb_library_proto protoreflect.FileDescriptor
+
+const file_internal_backend_service_pb_library_proto_rawDesc = "" +
+	"\n" +
+	")internal/backend/service/pb/library.proto\x12\alibrary\"\xa9\x05\n" +
+	"\bMetaData\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x16\n" +
+	"\x06author\x18\x02 \x01(\tR\x06author\x12!\n" +
+	"\fpublish_date\x18\x03 \x01(\tR\vpublishDate\x12!\n" +
+	"\flast_updated\x18\x04 \x01(\tR\vlastUpdated\x12\x1b\n" +
+	"\tfile_type\x18\x05 \x01(\tR\bfileType\x12\x19\n" +
+	"\bdoc_type\x18\x06 \x01(\tR\adocType\x12#\n" +
+	"\rdewey_decimal\x18\a \x01(\tR\fdeweyDecimal\x12\x12\n" +
+	"\x04path\x18\b \x01(\tR\x04path\x12\x12\n" +
+	"\x04uuid\x18\t \x01(\tR\x04uuid\x12\x18\n" +
+	"\aauthors\x18\n" +
+	" \x03(\tR\aauthors\x12\x12\n" +
+	"\x04tags\x18\v \x03(\tR\x04tags\x12\x12\n" +
+	"\x04isbn\x18\f \x01(\tR\x04isbn\x12\x10\n" +
+	"\x03doi\x18\r \x01(\tR\x03doi\x12\x1c\n" +
+	"\tpublisher\x18\x0e \x01(\tR\tpublisher\x12\x18\n" +
+	"\aedition\x18\x0f \x01(\tR\aedition\x12\x1a\n" +
+	"\blanguage\x18\x10 \x01(\tR\blanguage\x12\x1d\n" +
+	"\n" +
+	"page_count\x18\x11 \x01(\x05R\tpageCount\x12 \n" +
+	"\vdescription\x18\x12 \x01(\tR\vdescription\x125\n" +
+	"\x06custom\x18\x13 \x03(\v2\x1d.library.MetaData.CustomEntryR\x06custom\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x14 \x01(\tR\tcreatedAt\x12\x12\n" +
+	"\x04size\x18\x15 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x16 \x01(\tR\x06sha256\x1a9\n" +
+	"\vCustomEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"^\n" +
+	"\x15CreateDocumentRequest\x12-\n" +
+	"\bmetadata\x18\x01 \x01(\v2\x11.library.MetaDataR\bmetadata\x12\x16\n" +
+	"\x06fields\x18\x02 \x01(\fR\x06fields\"(\n" +
+	"\x12GetDocumentRequest\x12\x12\n" +
+	"\x04uuid\x18\x01 \x01(\tR\x04uuid\"^\n" +
+	"\x15UpdateDocumentRequest\x12-\n" +
+	"\bmetadata\x18\x01 \x01(\v2\x11.library.MetaDataR\bmetadata\x12\x16\n" +
+	"\x06fields\x18\x02 \x01(\fR\x06fields\"Y\n" +
+	"\x10DocumentResponse\x12-\n" +
+	"\bmetadata\x18\x01 \x01(\v2\x11.library.MetaDataR\bmetadata\x12\x16\n" +
+	"\x06fields\x18\x02 \x01(\fR\x06fields\"+\n" +
+	"\x15DeleteDocumentRequest\x12\x12\n" +
+	"\x04uuid\x18\x01 \x01(\tR\x04uuid\",\n" +
+	"\x16DeleteDocumentResponse\x12\x12\n" +
+	"\x04uuid\x18\x01 \x01(\tR\x04uuid\"w\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12\x12\n" +
+	"\x04page\x18\x04 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"\x84\x02\n" +
+	"\x0eSearchResponse\x12+\n" +
+	"\aresults\x18\x01 \x03(\v2\x11.library.MetaDataR\aresults\x12\x1f\n" +
+	"\vtotal_count\x18\x02 \x01(\x05R\n" +
+	"totalCount\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x1f\n" +
+	"\vtotal_pages\x18\x05 \x01(\x05R\n" +
+	"totalPages\x12\x19\n" +
+	"\bhas_next\x18\x06 \x01(\bR\ahasNext\x12\x19\n" +
+	"\bhas_prev\x18\a \x01(\bR\ahasPrev\x12#\n" +
+	"\rwarning_count\x18\b \x01(\x05R\fwarningCount\"\x12\n" +
+	"\x10ListTypesRequest\")\n" +
+	"\x11ListTypesResponse\x12\x14\n" +
+	"\x05types\x18\x01 \x03(\tR\x05types\"\x12\n" +
+	"\x10ListDeweyRequest\"7\n" +
+	"\rDeweyCategory\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"K\n" +
+	"\x11ListDeweyResponse\x126\n" +
+	"\n" +
+	"categories\x18\x01 \x03(\v2\x16.library.DeweyCategoryR\n" +
+	"categories2\xa4\x04\n" +
+	"\x0eLibraryService\x12C\n" +
+	"\x06Create\x12\x1e.library.CreateDocumentRequest\x1a\x19.library.DocumentResponse\x12=\n" +
+	"\x03Get\x12\x1b.library.GetDocumentRequest\x1a\x19.library.DocumentResponse\x12C\n" +
+	"\x06Update\x12\x1e.library.UpdateDocumentRequest\x1a\x19.library.DocumentResponse\x12I\n" +
+	"\x06Delete\x12\x1e.library.DeleteDocumentRequest\x1a\x1f.library.DeleteDocumentResponse\x129\n" +
+	"\x06Search\x12\x16.library.SearchRequest\x1a\x17.library.SearchResponse\x12;\n" +
+	"\fStreamSearch\x12\x16.library.SearchRequest\x1a\x11.library.MetaData0\x01\x12B\n" +
+	"\tListTypes\x12\x19.library.ListTypesRequest\x1a\x1a.library.ListTypesResponse\x12B\n" +
+	"\tListDewey\x12\x19.library.ListDeweyRequest\x1a\x1a.library.ListDeweyResponseB Z\x1einternal/backend/service/pb;pbb\x06proto3"
+
+var (
+	file_internal_backend_service_pb_library_proto_rawDescOnce sync.Once
+	file_internal_backend_service_pb_library_proto_rawDescData []byte
+)
+
+func file_internal_backend_service_pb_library_proto_rawDescGZIP() []byte {
+	file_internal_backend_service_pb_library_proto_rawDescOnce.Do(func() {
+		file_internal_backend_service_pb_library_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_backend_service_pb_library_proto_rawDesc), len(file_internal_backend_service_pb_library_proto_rawDesc)))
+	})
+	return file_internal_backend_service_pb_library_proto_rawDescData
+}
+
+var file_internal_backend_service_pb_library_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_internal_backend_service_pb_library_proto_goTypes = []any{
+	(*MetaData)(nil),               // 0: library.MetaData
+	(*CreateDocumentRequest)(nil),  // 1: library.CreateDocumentRequest
+	(*GetDocumentRequest)(nil),     // 2: library.GetDocumentRequest
+	(*UpdateDocumentRequest)(nil),  // 3: library.UpdateDocumentRequest
+	(*DocumentResponse)(nil),       // 4: library.DocumentResponse
+	(*DeleteDocumentRequest)(nil),  // 5: library.DeleteDocumentRequest
+	(*DeleteDocumentResponse)(nil), // 6: library.DeleteDocumentResponse
+	(*SearchRequest)(nil),          // 7: library.SearchRequest
+	(*SearchResponse)(nil),         // 8: library.SearchResponse
+	(*ListTypesRequest)(nil),       // 9: library.ListTypesRequest
+	(*ListTypesResponse)(nil),      // 10: library.ListTypesResponse
+	(*ListDeweyRequest)(nil),       // 11: library.ListDeweyRequest
+	(*DeweyCategory)(nil),          // 12: library.DeweyCategory
+	(*ListDeweyResponse)(nil),      // 13: library.ListDeweyResponse
+	nil,                            // 14: library.MetaData.CustomEntry
+}
+var file_internal_backend_service_pb_library_proto_depIdxs = []int32{
+	14, // 0: library.MetaData.custom:type_name -> library.MetaData.CustomEntry
+	0,  // 1: library.CreateDocumentRequest.metadata:type_name -> library.MetaData
+	0,  // 2: library.UpdateDocumentRequest.metadata:type_name -> library.MetaData
+	0,  // 3: library.DocumentResponse.metadata:type_name -> library.MetaData
+	0,  // 4: library.SearchResponse.results:type_name -> library.MetaData
+	12, // 5: library.ListDeweyResponse.categories:type_name -> library.DeweyCategory
+	1,  // 6: library.LibraryService.Create:input_type -> library.CreateDocumentRequest
+	2,  // 7: library.LibraryService.Get:input_type -> library.GetDocumentRequest
+	3,  // 8: library.LibraryService.Update:input_type -> library.UpdateDocumentRequest
+	5,  // 9: library.LibraryService.Delete:input_type -> library.DeleteDocumentRequest
+	7,  // 10: library.LibraryService.Search:input_type -> library.SearchRequest
+	7,  // 11: library.LibraryService.StreamSearch:input_type -> library.SearchRequest
+	9,  // 12: library.LibraryService.ListTypes:input_type -> library.ListTypesRequest
+	11, // 13: library.LibraryService.ListDewey:input_type -> library.ListDeweyRequest
+	4,  // 14: library.LibraryService.Create:output_type -> library.DocumentResponse
+	4,  // 15: library.LibraryService.Get:output_type -> library.DocumentResponse
+	4,  // 16: library.LibraryService.Update:output_type -> library.DocumentResponse
+	6,  // 17: library.LibraryService.Delete:output_type -> library.DeleteDocumentResponse
+	8,  // 18: library.LibraryService.Search:output_type -> library.SearchResponse
+	0,  // 19: library.LibraryService.StreamSearch:output_type -> library.MetaData
+	10, // 20: library.LibraryService.ListTypes:output_type -> library.ListTypesResponse
+	13, // 21: library.LibraryService.ListDewey:output_type -> library.ListDeweyResponse
+	14, // [14:22] is the sub-list for method output_type
+	6,  // [6:14] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_internal_backend_service_pb_library_proto_init() }
+func file_internal_backend_service_pb_library_proto_init() {
+	if File_internal_backend_service_pb_library_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_backend_service_pb_library_proto_rawDesc), len(file_internal_backend_service_pb_library_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_internal_backend_service_pb_library_proto_goTypes,
+		DependencyIndexes: file_internal_backend_service_pb_library_proto_depIdxs,
+		MessageInfos:      file_internal_backend_service_pb_library_proto_msgTypes,
+	}.Build()
+	File_internal_backend_service_pb_library_proto = out.File
+	file_internal_backend_service_pb_library_proto_goTypes = nil
+	file_internal_backend_service_pb_library_proto_depIdxs = nil
+}
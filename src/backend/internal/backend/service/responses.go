@@ -0,0 +1,601 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"scriptorium/internal/backend/dao"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//---------------------------------------------------
+//--------------------ERROR-CODES--------------------
+//---------------------------------------------------
+
+// stable, machine readable error codes returned in the error envelope.
+// messages may change, these must not.
+const (
+	ErrCodeInvalidRequest      = "invalid_request"
+	ErrCodeInvalidUUID         = "invalid_uuid"
+	ErrCodeInvalidPagination   = "invalid_pagination"
+	ErrCodeUnknownDocType      = "unknown_doc_type"
+	ErrCodeValidationFailed    = "validation_failed"
+	ErrCodeInvalidSchema       = "invalid_schema"
+	ErrCodeNotFound            = "not_found"
+	ErrCodeFileTooLarge        = "file_too_large"
+	ErrCodeUnsupportedFileType = "unsupported_file_type"
+	ErrCodeConversionFailed    = "conversion_failed"
+	ErrCodeStorage             = "storage_error"
+	ErrCodeDeleteFailed        = "delete_failed"
+	ErrCodeRestoreFailed       = "restore_failed"
+	ErrCodeTrashed             = "document_trashed"
+	ErrCodeFilePruned          = "file_pruned"
+	ErrCodeTimeout             = "timeout"
+	ErrCodePreconditionFailed  = "precondition_failed"
+	ErrCodeUnauthorized        = "unauthorized"
+	ErrCodeUnsupported         = "not_supported"
+	ErrCodeInternal            = "internal_error"
+)
+
+//---------------------------------------------------
+//-------------------ERROR-ENVELOPE------------------
+//---------------------------------------------------
+
+// ErrorDetail carries a single problem, optionally tied to a request field or document.
+type ErrorDetail struct {
+	Field   string `json:"field,omitempty"`
+	Uuid    string `json:"uuid,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+type ErrorBody struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ErrorResponse is the envelope every /v1 error is returned in: {"error": {code, message, details}}
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+//---------------------------------------------------
+//-------------------REQUEST-TYPES-------------------
+//---------------------------------------------------
+
+// CreateRequest is the body of /data/create. Fields beyond these are decoded onto the
+// concrete document type, e.g. Content for Notes.
+type CreateRequest struct {
+	DocType      string            `json:"DocType"`
+	Title        string            `json:"Title,omitempty"`
+	Author       string            `json:"Author,omitempty"`
+	PublishDate  string            `json:"PublishDate,omitempty"`
+	DeweyDecimal string            `json:"DeweyDecimal,omitempty"`
+	ISBN         string            `json:"ISBN,omitempty"`
+	DOI          string            `json:"DOI,omitempty"`
+	Publisher    string            `json:"Publisher,omitempty"`
+	Edition      string            `json:"Edition,omitempty"`
+	Language     string            `json:"Language,omitempty"`
+	PageCount    int               `json:"PageCount,omitempty"`
+	Description  string            `json:"Description,omitempty"`
+	Authors      []string          `json:"Authors,omitempty"`
+	Tags         []string          `json:"Tags,omitempty"`
+	Custom       map[string]string `json:"Custom,omitempty"`
+	Content      string            `json:"Content,omitempty"`
+}
+
+// UpdateRequest is the body of /data/update. Uuid, Path, FileType, Size and the timestamps
+// are owned by the server, any values sent for them are ignored.
+type UpdateRequest struct {
+	Uuid         string            `json:"Uuid"`
+	DocType      string            `json:"DocType"`
+	Title        string            `json:"Title,omitempty"`
+	Author       string            `json:"Author,omitempty"`
+	PublishDate  string            `json:"PublishDate,omitempty"`
+	DeweyDecimal string            `json:"DeweyDecimal,omitempty"`
+	ISBN         string            `json:"ISBN,omitempty"`
+	DOI          string            `json:"DOI,omitempty"`
+	Publisher    string            `json:"Publisher,omitempty"`
+	Edition      string            `json:"Edition,omitempty"`
+	Language     string            `json:"Language,omitempty"`
+	PageCount    int               `json:"PageCount,omitempty"`
+	Description  string            `json:"Description,omitempty"`
+	Authors      []string          `json:"Authors,omitempty"`
+	Tags         []string          `json:"Tags,omitempty"`
+	Custom       map[string]string `json:"Custom,omitempty"`
+	Content      string            `json:"Content,omitempty"`
+}
+
+// RestoreRequest is the body of /data/history/:uuid/restore.
+type RestoreRequest struct {
+	Revision int `json:"revision"`
+}
+
+type DeleteRequest struct {
+	Uuids []string `json:"uuids"`
+}
+
+// BulkUpdateRequest is the body of PATCH /data/bulk. Documents are named by uuids, or
+// matched by q or key/value as in /data/search. Set takes MetaData field names, with null
+// clearing a field and "Custom.<key>" setting a single custom field.
+type BulkUpdateRequest struct {
+	Uuids      []string       `json:"uuids,omitempty"`
+	Query      string         `json:"q,omitempty"`
+	Key        string         `json:"key,omitempty"`
+	Value      string         `json:"value,omitempty"`
+	Set        map[string]any `json:"set,omitempty"`
+	AddTags    []string       `json:"add_tags,omitempty"`
+	RemoveTags []string       `json:"remove_tags,omitempty"`
+	DryRun     bool           `json:"dry_run,omitempty"`
+}
+
+// FsckRequest is the body of POST /admin/fsck, Fix naming the classes of problem to fix.
+type FsckRequest struct {
+	Fix []string `json:"fix"`
+}
+
+// ImportRequest is the body of POST /admin/import. Source is a directory, zip archive or
+// bibliography on the server, DocType the type of documents whose metadata doesn't give one.
+type ImportRequest struct {
+	Source  string `json:"source" binding:"required"`
+	DocType string `json:"doc_type"`
+}
+
+// UploadMetadata is the JSON carried in the "metadata" form field of /file/upload.
+// When it's omitted the file is stored without a database record.
+type UploadMetadata struct {
+	DocType      string            `json:"DocType"`
+	Title        string            `json:"Title,omitempty"`
+	Author       string            `json:"Author,omitempty"`
+	PublishDate  string            `json:"PublishDate,omitempty"`
+	DeweyDecimal string            `json:"DeweyDecimal,omitempty"`
+	ISBN         string            `json:"ISBN,omitempty"`
+	DOI          string            `json:"DOI,omitempty"`
+	Publisher    string            `json:"Publisher,omitempty"`
+	Edition      string            `json:"Edition,omitempty"`
+	Language     string            `json:"Language,omitempty"`
+	PageCount    int               `json:"PageCount,omitempty"`
+	Description  string            `json:"Description,omitempty"`
+	Authors      []string          `json:"Authors,omitempty"`
+	Tags         []string          `json:"Tags,omitempty"`
+	Custom       map[string]string `json:"Custom,omitempty"`
+	Content      string            `json:"Content,omitempty"`
+}
+
+//---------------------------------------------------
+//-------------------RESPONSE-TYPES------------------
+//---------------------------------------------------
+
+// DocumentJSON is the /v1 representation of dao.MetaData.
+type DocumentJSON struct {
+	Uuid         string            `json:"uuid"`
+	Title        string            `json:"title"`
+	Author       string            `json:"author"`
+	PublishDate  string            `json:"publish_date"`
+	CreatedAt    string            `json:"created_at"`
+	LastUpdated  string            `json:"last_updated"`
+	FileType     string            `json:"file_type"`
+	DocType      string            `json:"doc_type"`
+	DeweyDecimal string            `json:"dewey_decimal"`
+	Path         string            `json:"path"`
+	Size         int64             `json:"size"`
+	Sha256       string            `json:"sha256"`
+	Authors      []string          `json:"authors"`
+	Tags         []string          `json:"tags"`
+	ISBN         string            `json:"isbn"`
+	DOI          string            `json:"doi"`
+	Publisher    string            `json:"publisher"`
+	Edition      string            `json:"edition"`
+	Language     string            `json:"language"`
+	PageCount    int               `json:"page_count"`
+	Description  string            `json:"description"`
+	Custom       map[string]string `json:"custom"`
+	// Fields holds the type specific fields, e.g. Journal for an Article. Only set when
+	// a single document is returned, search results leave it out.
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+func newDocumentJSON(meta dao.MetaData) DocumentJSON {
+	custom := meta.Custom
+	if custom == nil {
+		custom = map[string]string{}
+	}
+	return DocumentJSON{
+		Uuid:         meta.Uuid,
+		Title:        meta.Title,
+		Author:       meta.Author,
+		PublishDate:  meta.PublishDate,
+		CreatedAt:    meta.CreatedAt,
+		LastUpdated:  meta.LastUpdated,
+		FileType:     meta.FileType,
+		DocType:      meta.DocType,
+		DeweyDecimal: meta.DeweyDecimal,
+		Path:         meta.Path,
+		Size:         meta.Size,
+		Sha256:       meta.Hash,
+		Authors:      nonNil(meta.Authors),
+		Tags:         nonNil(meta.Tags),
+		ISBN:         meta.ISBN,
+		DOI:          meta.DOI,
+		Publisher:    meta.Publisher,
+		Edition:      meta.Edition,
+		Language:     meta.Language,
+		PageCount:    meta.PageCount,
+		Description:  meta.Description,
+		Custom:       custom,
+	}
+}
+
+// nonNil keeps empty lists as [] rather than null in responses
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// newRecordJSON includes the stored type specific payload.
+func newRecordJSON(record dao.Record) DocumentJSON {
+	doc := newDocumentJSON(record.MetaData)
+	if len(record.Payload) > 0 {
+		if err := json.Unmarshal(record.Payload, &doc.Fields); err != nil {
+			log.Printf("failed to decode payload of %s: %v", record.Uuid, err)
+		}
+	}
+	return doc
+}
+
+// newDocumentJSONFor includes the type specific fields of a concrete document.
+func newDocumentJSONFor(doc dao.Document) DocumentJSON {
+	record, err := dao.NewRecord(doc)
+	if err != nil {
+		log.Printf("failed to encode %s: %v", doc.GetID(), err)
+		return newDocumentJSON(doc.GetMetaData())
+	}
+	return newRecordJSON(record)
+}
+
+func newDocumentJSONList(metas []dao.MetaData) []DocumentJSON {
+	docs := make([]DocumentJSON, 0, len(metas))
+	for _, meta := range metas {
+		docs = append(docs, newDocumentJSON(meta))
+	}
+	return docs
+}
+
+type DocumentResponse struct {
+	Document DocumentJSON `json:"document"`
+
+	legacyMessage string
+	legacyValue   string
+}
+
+func (r DocumentResponse) legacyShape() gin.H {
+	return gin.H{"message": r.legacyMessage, "value": r.legacyValue}
+}
+
+type CreateResponse struct {
+	Uuid     string       `json:"uuid"`
+	Document DocumentJSON `json:"document"`
+}
+
+func (r CreateResponse) legacyShape() gin.H {
+	return gin.H{"message": "Document inserted into DB", "UUID": r.Uuid}
+}
+
+type SearchResponse struct {
+	Results    []DocumentJSON `json:"results"`
+	Count      int            `json:"count"`
+	TotalCount int            `json:"total_count"`
+	Page       int            `json:"page"`
+	Limit      int            `json:"limit"`
+	TotalPages int            `json:"total_pages"`
+	HasNext    bool           `json:"has_next"`
+	HasPrev    bool           `json:"has_prev"`
+	// WarningCount is the number of corrupt records left out of the results, see /admin/quarantine
+	WarningCount int `json:"warning_count"`
+
+	raw []dao.MetaData
+}
+
+func (r SearchResponse) legacyShape() gin.H {
+	return gin.H{
+		"message":     "Search completed",
+		"count":       r.Count,
+		"total_count": r.TotalCount,
+		"page":        r.Page,
+		"limit":       r.Limit,
+		"total_pages": r.TotalPages,
+		"has_next":    r.HasNext,
+		"has_prev":    r.HasPrev,
+		"results":     r.raw,
+	}
+}
+
+// DeleteResponse reports per-document outcomes of a delete or purge. Failures are documents
+// that were not deleted, warnings are documents whose record was purged with a problem (e.g. a
+// file that couldn't be removed).
+type DeleteResponse struct {
+	DeletedCount int           `json:"deleted_count"`
+	DeletedUuids []string      `json:"deleted_uuids"`
+	Failures     []ErrorDetail `json:"failures,omitempty"`
+	Warnings     []ErrorDetail `json:"warnings,omitempty"`
+}
+
+func (r DeleteResponse) legacyShape() gin.H {
+	response := gin.H{
+		"deleted_count": r.DeletedCount,
+		"deleted_uuids": r.DeletedUuids,
+	}
+
+	var errors []string
+	for _, problem := range append(r.Warnings, r.Failures...) {
+		errors = append(errors, problem.Message)
+	}
+	if len(errors) > 0 {
+		response["errors"] = errors
+		response["error_count"] = len(errors)
+	}
+	return response
+}
+
+// RestoreTrashResponse reports which documents were moved out of the trash.
+type RestoreTrashResponse struct {
+	RestoredCount int           `json:"restored_count"`
+	RestoredUuids []string      `json:"restored_uuids"`
+	Failures      []ErrorDetail `json:"failures,omitempty"`
+}
+
+// the Status of a BulkUpdateResultJSON
+const (
+	BulkUpdated   = "updated"
+	BulkUnchanged = "unchanged"
+	BulkFailed    = "failed"
+)
+
+// BulkUpdateResultJSON is what a bulk update did to one document, or would do in a dry run.
+// Document is the record after the update, Error is set when it failed.
+type BulkUpdateResultJSON struct {
+	Uuid     string            `json:"uuid"`
+	Status   string            `json:"status"`
+	Changes  []FieldChangeJSON `json:"changes"`
+	Document *DocumentJSON     `json:"document,omitempty"`
+	Error    *ErrorBody        `json:"error,omitempty"`
+}
+
+type BulkUpdateResponse struct {
+	DryRun         bool                   `json:"dry_run"`
+	MatchedCount   int                    `json:"matched_count"`
+	UpdatedCount   int                    `json:"updated_count"`
+	UnchangedCount int                    `json:"unchanged_count"`
+	FailedCount    int                    `json:"failed_count"`
+	Results        []BulkUpdateResultJSON `json:"results"`
+}
+
+// TrashedDocumentJSON is a document in the trash. PurgeAt is when the background purge will
+// remove it, empty when the trash is kept indefinitely.
+type TrashedDocumentJSON struct {
+	DocumentJSON
+	DeletedAt string `json:"deleted_at"`
+	DeletedBy string `json:"deleted_by"`
+	PurgeAt   string `json:"purge_at,omitempty"`
+}
+
+func newTrashedDocumentJSON(record dao.TrashedRecord, retention time.Duration) TrashedDocumentJSON {
+	doc := TrashedDocumentJSON{
+		DocumentJSON: newDocumentJSON(record.MetaData),
+		DeletedAt:    record.DeletedAt,
+		DeletedBy:    record.DeletedBy,
+	}
+	if deletedAt, err := time.Parse(time.RFC3339Nano, record.DeletedAt); err == nil && retention > 0 {
+		doc.PurgeAt = deletedAt.Add(retention).Format(time.RFC3339Nano)
+	}
+	return doc
+}
+
+type TrashResponse struct {
+	Results    []TrashedDocumentJSON `json:"results"`
+	Count      int                   `json:"count"`
+	TotalCount int                   `json:"total_count"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+	HasNext    bool                  `json:"has_next"`
+	HasPrev    bool                  `json:"has_prev"`
+}
+
+type UploadResponse struct {
+	Message          string        `json:"message"`
+	FilePath         string        `json:"file_path"`
+	OriginalFilename string        `json:"original_filename"`
+	Uuid             string        `json:"uuid,omitempty"`
+	Document         *DocumentJSON `json:"document,omitempty"`
+}
+
+func (r UploadResponse) legacyShape() gin.H {
+	response := gin.H{
+		"message":           r.Message,
+		"file_path":         r.FilePath,
+		"original_filename": r.OriginalFilename,
+	}
+	if r.Uuid != "" {
+		response["document_uuid"] = r.Uuid
+	}
+	return response
+}
+
+type TypesResponse struct {
+	Types   []string         `json:"types"`
+	Schemas []dao.TypeSchema `json:"schemas"`
+}
+
+type DeweyResponse struct {
+	Categories []dao.DeweyCategory `json:"categories"`
+}
+
+type FieldChangeJSON struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+type FileVersionJSON struct {
+	Path     string `json:"path"`
+	FileType string `json:"file_type"`
+	Size     int64  `json:"size"`
+	Sha256   string `json:"sha256"`
+	// Pruned is set once the retention policy has removed the file from storage
+	Pruned bool `json:"pruned"`
+}
+
+// RevisionJSON is one entry of a document's history. The full record of each revision is
+// kept for restores, but not returned.
+type RevisionJSON struct {
+	Revision     int               `json:"revision"`
+	Timestamp    string            `json:"timestamp"`
+	User         string            `json:"user"`
+	Action       string            `json:"action"`
+	RestoredFrom int               `json:"restored_from,omitempty"`
+	Changes      []FieldChangeJSON `json:"changes"`
+	File         *FileVersionJSON  `json:"file,omitempty"`
+}
+
+type HistoryResponse struct {
+	Uuid      string         `json:"uuid"`
+	Revisions []RevisionJSON `json:"revisions"`
+}
+
+func newHistoryResponse(id string, revisions []dao.Revision) HistoryResponse {
+	resp := HistoryResponse{Uuid: id, Revisions: make([]RevisionJSON, 0, len(revisions))}
+	for _, rev := range revisions {
+		entry := RevisionJSON{
+			Revision:     rev.Number,
+			Timestamp:    rev.Timestamp,
+			User:         rev.User,
+			Action:       rev.Action,
+			RestoredFrom: rev.RestoredFrom,
+			Changes:      make([]FieldChangeJSON, 0, len(rev.Changes)),
+		}
+		for _, change := range rev.Changes {
+			entry.Changes = append(entry.Changes, FieldChangeJSON{Field: change.Field, Old: change.Old, New: change.New})
+		}
+		if rev.File != nil {
+			entry.File = &FileVersionJSON{Path: rev.File.Path, FileType: rev.File.FileType, Size: rev.File.Size, Sha256: rev.File.Hash, Pruned: rev.File.Pruned}
+		}
+		resp.Revisions = append(resp.Revisions, entry)
+	}
+	return resp
+}
+
+// CitationResponse is a document's reference in Style. HTML, with titles in italics, is
+// only set for the formatted styles.
+type CitationResponse struct {
+	Uuid  string `json:"uuid"`
+	Style string `json:"style"`
+	Text  string `json:"text"`
+	HTML  string `json:"html,omitempty"`
+}
+
+// MetricsResponse counts the records in each part of the database.
+type MetricsResponse struct {
+	Documents      int `json:"documents"`
+	Trashed        int `json:"trashed"`
+	Quarantined    int `json:"quarantined"`
+	PendingFileOps int `json:"pending_file_ops"`
+	// SkippedRecords is how many corrupt records scans have skipped since the server started
+	SkippedRecords int64 `json:"skipped_records"`
+}
+
+type QuarantinedRecordJSON struct {
+	Bucket        string `json:"bucket"`
+	Key           string `json:"key"`
+	Reason        string `json:"reason"`
+	QuarantinedAt string `json:"quarantined_at"`
+	// Raw is the value as it was stored
+	Raw string `json:"raw"`
+}
+
+type QuarantineResponse struct {
+	Count   int                     `json:"count"`
+	Records []QuarantinedRecordJSON `json:"records"`
+}
+
+func newQuarantineResponse(records []dao.QuarantinedRecord) QuarantineResponse {
+	resp := QuarantineResponse{Count: len(records), Records: make([]QuarantinedRecordJSON, 0, len(records))}
+	for _, record := range records {
+		resp.Records = append(resp.Records, QuarantinedRecordJSON{
+			Bucket:        string(record.Set),
+			Key:           record.Key,
+			Reason:        record.Reason,
+			QuarantinedAt: record.QuarantinedAt,
+			Raw:           string(record.Raw),
+		})
+	}
+	return resp
+}
+
+// ImportEvent is a line of the POST /admin/import stream: a Progress per file, then the
+// Report, or an Error if the import stopped part way.
+type ImportEvent struct {
+	Progress *ImportProgress `json:"progress,omitempty"`
+	Report   *ImportReport   `json:"report,omitempty"`
+	Error    *ErrorBody      `json:"error,omitempty"`
+}
+
+type ImportProgress struct {
+	Done   int          `json:"done"`
+	Total  int          `json:"total"`
+	Result ImportResult `json:"result"`
+}
+
+//---------------------------------------------------
+//---------------------WRITERS-----------------------
+//---------------------------------------------------
+
+// legacyKey is set on the gin.Context by the unversioned route group, which only
+// exists when the compatibility flag is on.
+const legacyKey = "scriptorium.legacy"
+
+// legacyResponse is implemented by responses whose pre-/v1 shape differs from the typed one.
+type legacyResponse interface {
+	legacyShape() gin.H
+}
+
+func legacyMiddleware(c *gin.Context) {
+	c.Set(legacyKey, true)
+	c.Next()
+}
+
+func isLegacy(c *gin.Context) bool {
+	return c.GetBool(legacyKey)
+}
+
+// respond writes a successful response, in the legacy shape on the unversioned routes.
+// the old API only ever answered 200 on success, so 201 is downgraded there too.
+func respond(c *gin.Context, status int, resp any) {
+	if !isLegacy(c) {
+		c.JSON(status, resp)
+		return
+	}
+
+	if status == http.StatusCreated {
+		status = http.StatusOK
+	}
+	if legacy, ok := resp.(legacyResponse); ok {
+		c.JSON(status, legacy.legacyShape())
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// respondError writes the error envelope, or {"error": message} on the legacy routes.
+func respondError(c *gin.Context, status int, code, message string, details ...ErrorDetail) {
+	if isLegacy(c) {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.JSON(status, ErrorResponse{Error: ErrorBody{Code: code, Message: message, Details: details}})
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"scriptorium/internal/backend/dao"
+
+	"github.com/gin-gonic/gin"
+)
+
+//---------------------------------------------------
+//------------------REQUEST-DECODING-----------------
+//---------------------------------------------------
+
+// requestError is a failed request, written by respond so the three handlers report
+// problems the same way.
+type requestError struct {
+	status  int
+	code    string
+	message string
+	details []ErrorDetail
+}
+
+func (e *requestError) respond(c *gin.Context) {
+	respondError(c, e.status, e.code, e.message, e.details...)
+}
+
+// documentFromRequest is the single path Create, Update and Upload turn a decoded JSON body
+// into a document. owned carries the fields the server decides (Uuid, Path, FileType, Size, Hash),
+// the rest is copied from data, normalized and validated against the type. every field problem
+// is collected into one validation_failed error.
+func documentFromRequest(factory *dao.DocumentFactory, data map[string]any, owned dao.MetaData) (dao.Document, *requestError) {
+	docType, ok := data["DocType"].(string)
+	if !ok || strings.TrimSpace(docType) == "" {
+		return nil, &requestError{http.StatusBadRequest, ErrCodeInvalidRequest, "Missing or invalid document type",
+			[]ErrorDetail{{Field: "DocType", Message: "required"}}}
+	}
+
+	doc, err := factory.NewDocument(docType)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, ErrCodeUnknownDocType, fmt.Sprintf("Unknown document type '%s'", docType),
+			[]ErrorDetail{{Field: "DocType", Message: "not a registered document type"}}}
+	}
+	schema, _ := factory.GetSchema(docType)
+
+	// type specific fields, only surrounding whitespace is trimmed as they may be multi-line
+	payload := make(map[string]any, len(data))
+	for key, value := range data {
+		if str, isStr := value.(string); isStr {
+			value = strings.TrimSpace(str)
+		}
+		payload[key] = value
+	}
+	docJSON, _ := json.Marshal(payload)
+	if err := json.Unmarshal(docJSON, doc); err != nil {
+		return nil, &requestError{http.StatusBadRequest, ErrCodeValidationFailed, "Document fields have the wrong type",
+			[]ErrorDetail{{Message: err.Error()}}}
+	}
+
+	meta, problems := metaDataFromRequest(data)
+	meta.DocType = docType
+	meta.Uuid = owned.Uuid
+	meta.Path = owned.Path
+	meta.FileType = owned.FileType
+	meta.Size = owned.Size
+	meta.Hash = owned.Hash
+
+	var invalid *dao.ValidationError
+	if err := dao.NormalizeMetaData(&meta, schema); errors.As(err, &invalid) {
+		problems = append(problems, fieldErrorDetails(invalid)...)
+	}
+
+	if err := doc.SetMetaData(meta); err != nil {
+		return nil, &requestError{http.StatusBadRequest, ErrCodeInvalidRequest, "Failed to set metadata", nil}
+	}
+
+	if err := dao.ValidateDocument(doc); errors.As(err, &invalid) {
+		problems = append(problems, fieldErrorDetails(invalid)...)
+	}
+
+	if len(problems) > 0 {
+		return nil, &requestError{http.StatusBadRequest, ErrCodeValidationFailed, "Document failed validation", dedupeDetails(problems)}
+	}
+	return doc, nil
+}
+
+// metaDataFromRequest copies the client owned MetaData fields out of a decoded body.
+// fields that are present with the wrong JSON type are reported, not skipped.
+func metaDataFromRequest(data map[string]any) (dao.MetaData, []ErrorDetail) {
+	var meta dao.MetaData
+	var problems []ErrorDetail
+
+	strFields := []struct {
+		key  string
+		dest *string
+	}{
+		{"Title", &meta.Title},
+		{"Author", &meta.Author},
+		{"PublishDate", &meta.PublishDate},
+		{"DeweyDecimal", &meta.DeweyDecimal},
+		{"ISBN", &meta.ISBN},
+		{"DOI", &meta.DOI},
+		{"Publisher", &meta.Publisher},
+		{"Edition", &meta.Edition},
+		{"Language", &meta.Language},
+		{"Description", &meta.Description},
+	}
+	for _, field := range strFields {
+		value, ok := data[field.key]
+		if !ok || value == nil {
+			continue
+		}
+		str, isStr := value.(string)
+		if !isStr {
+			problems = append(problems, ErrorDetail{Field: field.key, Message: "must be a string"})
+			continue
+		}
+		*field.dest = str
+	}
+
+	listFields := []struct {
+		key  string
+		dest *[]string
+	}{
+		{"Authors", &meta.Authors},
+		{"Tags", &meta.Tags},
+	}
+	for _, field := range listFields {
+		value, ok := data[field.key]
+		if !ok || value == nil {
+			continue
+		}
+		list, isList := value.([]any)
+		if !isList {
+			problems = append(problems, ErrorDetail{Field: field.key, Message: "must be a list of strings"})
+			continue
+		}
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			str, isStr := item.(string)
+			if !isStr {
+				problems = append(problems, ErrorDetail{Field: field.key, Message: "must be a list of strings"})
+				break
+			}
+			strs = append(strs, str)
+		}
+		*field.dest = strs
+	}
+
+	if value, ok := data["PageCount"]; ok && value != nil {
+		// encoding/json decodes every number into a float64
+		count, isNum := value.(float64)
+		if !isNum || count < 0 || count != float64(int(count)) {
+			problems = append(problems, ErrorDetail{Field: "PageCount", Message: "must be a non-negative integer"})
+		} else {
+			meta.PageCount = int(count)
+		}
+	}
+
+	if value, ok := data["Custom"]; ok && value != nil {
+		fields, isMap := value.(map[string]any)
+		if !isMap {
+			problems = append(problems, ErrorDetail{Field: "Custom", Message: "must be an object of strings"})
+		} else {
+			meta.Custom = make(map[string]string, len(fields))
+			for _, k := range sortedFieldNames(fields) {
+				str, isStr := fields[k].(string)
+				if !isStr {
+					problems = append(problems, ErrorDetail{Field: "Custom." + k, Message: "must be a string"})
+					continue
+				}
+				meta.Custom[k] = str
+			}
+		}
+	}
+
+	return meta, problems
+}
+
+// mergePatch applies an RFC 7396 merge patch to target: null removes a member, an object
+// is merged into the member it names and anything else replaces it.
+func mergePatch(target map[string]any, patch map[string]any) map[string]any {
+	if target == nil {
+		target = map[string]any{}
+	}
+	for name, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(target, name)
+		case map[string]any:
+			existing, _ := target[name].(map[string]any)
+			target[name] = mergePatch(existing, value)
+		default:
+			target[name] = value
+		}
+	}
+	return target
+}
+
+// dedupeDetails drops repeats, a type's own Validate may recheck a field the schema already did
+func dedupeDetails(details []ErrorDetail) []ErrorDetail {
+	seen := make(map[ErrorDetail]bool, len(details))
+	unique := details[:0]
+	for _, detail := range details {
+		if !seen[detail] {
+			seen[detail] = true
+			unique = append(unique, detail)
+		}
+	}
+	return unique
+}
+
+func sortedFieldNames(m map[string]any) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
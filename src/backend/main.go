@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -10,13 +11,31 @@ import (
 	"scriptorium/internal/backend/dao"
 	"scriptorium/internal/backend/fao"
 	"scriptorium/internal/backend/service"
+	"scriptorium/internal/backend/service/pb"
 	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
+	// subcommands run against the database and storage instead of serving them
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fsck":
+			os.Exit(runFsck(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "backup":
+			os.Exit(runBackup(os.Args[2:]))
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		}
+	}
+
 	// Load configuration from environment variables
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -27,20 +46,20 @@ func main() {
 	//----------------API-HANDLER-SET-UP-----------------
 	//---------------------------------------------------
 
-	var conparams dao.BoltConnectionParams = dao.BoltConnectionParams{Path: cfg.Database.Path, Mode: os.FileMode(cfg.Database.Mode), Opts: nil}
-	var d dao.DAO = &dao.BoltDao{}
-	err = d.Connect(&conparams)
+	d, err := openDAO(cfg.Database, 0)
 	if err != nil {
 		log.Fatalf("error instantiating DB: %s", err.Error())
 	}
+	if boltDao, ok := d.(*dao.BoltDao); ok {
+		if migration := boltDao.MigrationReport(); len(migration.Applied) > 0 {
+			log.Printf("migrated database from schema version %d to %d, backup at %s", migration.From, migration.To, migration.Backup)
+		}
+	}
 
-	docFactory := dao.NewDocumentFactory()
-	docFactory.RegisterDocumentType("Notes", func() dao.Document { return &dao.Notes{} })
-	docFactory.RegisterDocumentType("Book", func() dao.Document { return &dao.Notes{} })
-	docFactory.RegisterDocumentType("Article", func() dao.Document { return &dao.Notes{} })
-	docFactory.RegisterDocumentType("Report", func() dao.Document { return &dao.Notes{} })
-	docFactory.RegisterDocumentType("Manual", func() dao.Document { return &dao.Notes{} })
-	docFactory.RegisterDocumentType("Reference", func() dao.Document { return &dao.Notes{} })
+	docFactory, err := newDocumentFactory(cfg.Types.Path)
+	if err != nil {
+		log.Fatalf("error loading document types: %s", err.Error())
+	}
 
 	daoService := service.DaoService{}
 	daoServ, err := daoService.New(d)
@@ -51,6 +70,7 @@ func main() {
 	if !ok {
 		log.Fatalf("error type checking DaoService")
 	}
+	daos.Timeout = cfg.Database.Timeout
 
 	//---------------------------------------------------
 	//----------------FILE-HANDLER-SET-UP----------------
@@ -61,12 +81,18 @@ func main() {
 		log.Fatalf("failed to create storage directory: %v", err)
 	}
 
+	// the local FAO is only handed to the gRPC file service, everything else
+	// reaches storage through the gRPC client.
 	f := fao.NewLocalFao(cfg.Storage.Path)
 
-	pandocConverter := converter.NewPandocConverterWithInterfaces("pandoc", d, f)
-	_ = service.NewFileConverterService(pandocConverter, f) // registered for potential direct use
-
-	apiHandler := service.NewAPIHandler(daos, docFactory, f)
+	// finish whatever storage operations a crash left journaled, before anything is served
+	report, err := daos.ReconcileFiles(context.Background(), f)
+	if err != nil {
+		log.Fatalf("error reconciling storage with the database: %s", err.Error())
+	}
+	if len(report.Kept)+len(report.Removed)+len(report.Failed) > 0 {
+		log.Printf("reconciled storage: %d file(s) kept, %d removed, %d failed", len(report.Kept), len(report.Removed), len(report.Failed))
+	}
 
 	fileHandlerService := service.FileHandlerService{}
 	fhServ, err := fileHandlerService.New(f)
@@ -79,9 +105,7 @@ func main() {
 		log.Fatalf("error type checking FileHandlerService")
 	}
 
-	grpcServer := grpc.NewServer()
-	grpcErrCh := service.StartGrcpService(grpcServer, faos, cfg.Server.GrpcPort)
-
+	// the client is lazy, so it can be built before the server it talks to is listening
 	grpcAddr := fmt.Sprintf("localhost:%d", cfg.Server.GrpcPort)
 	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
@@ -89,14 +113,47 @@ func main() {
 	}
 	defer conn.Close()
 
+	remoteFao := service.NewFileServiceFao(pb.NewFileServiceClient(conn))
+	remoteFao.Timeout = cfg.Storage.Timeout
+
+	libraryServer := service.NewLibraryServer(daos, docFactory, remoteFao)
+
+	grpcServer := grpc.NewServer()
+	grpcErrCh := service.StartGrcpService(grpcServer, faos, libraryServer, cfg.Server.GrpcPort)
+
+	pandocConverter := converter.NewPandocConverterWithInterfaces("pandoc", d, remoteFao)
+	pandocConverter.Timeout = cfg.Convert.Timeout
+	_ = service.NewFileConverterService(pandocConverter, remoteFao) // registered for potential direct use
+
+	apiHandler := service.NewAPIHandler(daos, docFactory, remoteFao)
+	apiHandler.KeepFileVersions = cfg.History.KeepFileVersions
+	apiHandler.TrashRetention = time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour
+
 	fileHandler := service.NewFileHandler(faos, conn, apiHandler, pandocConverter)
+	fileHandler.StorageTimeout = cfg.Storage.Timeout
+
+	handlers := []service.Handler{apiHandler, fileHandler}
+	// /admin streams backups and imports server paths, it isn't served without a token
+	if cfg.Server.AdminToken != "" {
+		adminHandler := service.NewAdminHandler(daos, remoteFao, cfg.Server.AdminToken)
+		adminHandler.DocumentFactory = docFactory
+		handlers = append(handlers, adminHandler)
+	} else {
+		log.Println("ADMIN_TOKEN is not set, /v1/admin is disabled")
+	}
 
 	//---------------------------------------------------
 	//-------------------SERVICE-START-------------------
 	//---------------------------------------------------
 
 	// Call StartRestAPI with handlers
-	errCh := service.StartRestAPI(cfg.Server.RestPort, apiHandler, fileHandler)
+	errCh := service.StartRestAPI(cfg.Server.RestPort, cfg.Server.LegacyAPI, handlers...)
+
+	// empty the trash of anything older than the retention period, checked hourly
+	if apiHandler.TrashRetention > 0 {
+		stopPurge := service.StartTrashPurge(&daos, remoteFao, apiHandler.TrashRetention, time.Hour)
+		defer stopPurge()
+	}
 
 	// Set up graceful shutdown
 	signalCh := make(chan os.Signal, 1)
@@ -120,3 +177,31 @@ func main() {
 		log.Println("Shutdown complete")
 	}
 }
+
+// openDAO opens the backend DB_BACKEND names. timeout is how long to wait on a lock held by
+// another process, 0 leaving it to the backend; Bolt then waits indefinitely.
+func openDAO(cfg config.DatabaseConfig, timeout time.Duration) (dao.DAO, error) {
+	return dao.Open(cfg.Backend, dao.Config{
+		Path:      cfg.Path,
+		Mode:      os.FileMode(cfg.Mode),
+		Timeout:   timeout,
+		BackupDir: cfg.BackupDir,
+		Options:   cfg.Options,
+	})
+}
+
+// newDocumentFactory registers the built in document types, then the user defined ones
+// saved at typesPath.
+func newDocumentFactory(typesPath string) (*dao.DocumentFactory, error) {
+	docFactory := dao.NewDocumentFactory()
+	docFactory.RegisterDocumentType("Notes", func() dao.Document { return &dao.Notes{} })
+	docFactory.RegisterDocumentType("Book", func() dao.Document { return &dao.Book{} })
+	docFactory.RegisterDocumentType("Article", func() dao.Document { return &dao.Article{} })
+	docFactory.RegisterDocumentType("Report", func() dao.Document { return &dao.Report{} })
+	docFactory.RegisterDocumentType("Manual", func() dao.Document { return &dao.Manual{} })
+	docFactory.RegisterDocumentType("Reference", func() dao.Document { return &dao.Reference{} })
+	if err := docFactory.LoadSchemaFile(typesPath); err != nil {
+		return nil, err
+	}
+	return docFactory, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"scriptorium/internal/backend/config"
+	"scriptorium/internal/backend/dao"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// runMigrate brings the database up to the current schema version, as `scriptorium migrate
+// [-dry-run]`. The server migrates on start up anyway, this is for checking what an upgrade
+// will do, or running it ahead of time.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "run the pending migrations and roll them back, leaving the database as it was")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	// SQLite's schema is brought up to date on connecting, there's nothing to preview
+	if cfg.Database.Backend != "bolt" {
+		fmt.Fprintf(os.Stderr, "migrate: only the bolt backend has migrations to run, DB_BACKEND is %s\n", cfg.Database.Backend)
+		return 1
+	}
+
+	d, err := dao.NewBoltDao(dao.BoltOptions{
+		Path:      cfg.Database.Path,
+		Mode:      os.FileMode(cfg.Database.Mode),
+		Opts:      &bolt.Options{Timeout: time.Second},
+		BackupDir: cfg.Database.BackupDir,
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v (is the server still running?)\n", err)
+		return 1
+	}
+	defer d.Disconnect()
+
+	report := d.MigrationReport()
+	if len(report.Applied) == 0 {
+		fmt.Printf("database is at schema version %d, nothing to migrate\n", report.From)
+		return 0
+	}
+	verb := "applied"
+	if report.DryRun {
+		verb = "would apply"
+	}
+	for i, name := range report.Applied {
+		fmt.Printf("%s migration %d: %s\n", verb, report.From+i+1, name)
+	}
+	if report.DryRun {
+		fmt.Printf("dry run, the database is still at schema version %d\n", report.From)
+		return 0
+	}
+	fmt.Printf("database migrated from schema version %d to %d\n", report.From, report.To)
+	if report.Backup != "" {
+		fmt.Printf("backup of version %d at %s\n", report.From, report.Backup)
+	}
+	return 0
+}
@@ -1 +1,133 @@
 export const API_BASE_URL = (import.meta as any).env?.VITE_API_BASE_URL || 'http://localhost:8080';
+
+// versioned API root, the unversioned paths are only served by backends running with LEGACY_API=true
+export const API_URL = `${API_BASE_URL}/v1`;
+
+export interface ApiDocument {
+  uuid: string;
+  title: string;
+  author: string;
+  publish_date: string;
+  created_at: string;
+  last_updated: string;
+  file_type: string;
+  doc_type: string;
+  dewey_decimal: string;
+  path: string;
+  size: number;
+  sha256: string;
+  authors: string[];
+  tags: string[];
+  isbn: string;
+  doi: string;
+  publisher: string;
+  edition: string;
+  language: string;
+  page_count: number;
+  description: string;
+  custom: Record<string, string>;
+  // type specific fields, only returned when reading a single document
+  fields?: Record<string, any>;
+}
+
+export interface PropertySchema {
+  type: 'string' | 'integer' | 'number' | 'boolean' | 'array';
+  title?: string;
+  description?: string;
+  enum?: any[];
+  pattern?: string;
+  items?: PropertySchema;
+}
+
+// a document type as returned by /data/types, a JSON Schema for the type specific fields
+export interface TypeSchema {
+  title: string;
+  description?: string;
+  type: 'object';
+  properties: Record<string, PropertySchema>;
+  required?: string[];
+  'x-builtin'?: boolean;
+}
+
+export interface TypeField {
+  name: string;
+  label: string;
+  type: PropertySchema['type'];
+  required: boolean;
+  options?: any[];
+}
+
+// flattens a schema into the form fields to render, in property name order
+export function schemaFields(schema: TypeSchema | undefined): TypeField[] {
+  if (!schema) return [];
+  return Object.keys(schema.properties || {}).sort().map(name => {
+    const prop = schema.properties[name];
+    return {
+      name,
+      label: prop.title || name,
+      type: prop.type,
+      required: (schema.required || []).includes(name),
+      options: prop.enum,
+    };
+  });
+}
+
+// converts a form input back to the JSON type the schema expects, undefined drops the field
+export function fieldValue(field: TypeField, value: any): any {
+  if (value === undefined || value === null || value === '') return undefined;
+  switch (field.type) {
+    case 'integer':
+    case 'number':
+      return Number(value);
+    case 'boolean':
+      return Boolean(value);
+    case 'array':
+      return Array.isArray(value) ? value : splitList(String(value));
+    default:
+      return value;
+  }
+}
+
+// maps a /v1 document onto the field names the components were written against
+export function toLibraryItem(doc: ApiDocument) {
+  return {
+    Uuid: doc.uuid,
+    Title: doc.title,
+    Author: doc.author,
+    PublishDate: doc.publish_date,
+    CreatedAt: doc.created_at,
+    LastUpdated: doc.last_updated,
+    FileType: doc.file_type,
+    DocType: doc.doc_type,
+    DeweyDecimal: doc.dewey_decimal,
+    Path: doc.path,
+    Size: doc.size,
+    Authors: doc.authors || [],
+    Tags: doc.tags || [],
+    ISBN: doc.isbn,
+    DOI: doc.doi,
+    Publisher: doc.publisher,
+    Edition: doc.edition,
+    Language: doc.language,
+    PageCount: doc.page_count,
+    Description: doc.description,
+    Custom: doc.custom || {},
+  };
+}
+
+// pulls the message out of the /v1 error envelope: {error: {code, message, details}},
+// listing the invalid fields so a failed validation says what to fix
+export function apiErrorMessage(body: any, fallback: string): string {
+  const message = body?.error?.message || fallback;
+  const details: { field?: string; message: string }[] = body?.error?.details || [];
+  if (details.length === 0) {
+    return message;
+  }
+  const fields = details.map(d => (d.field ? `${d.field}: ${d.message}` : d.message));
+  return `${message} (${fields.join('; ')})`;
+}
+
+// splits a comma separated input into a trimmed list, dropping empty entries
+export function splitList(value: string): string[] {
+  return value.split(',').map(v => v.trim()).filter(v => v !== '');
+}